@@ -40,7 +40,8 @@ type BootstrapEntry struct {
 // This ensures bootstrap logic is only used for bootstrapping and not borrowed for other purposes.
 type Bootstrapper struct {
 	logger   zerolog.Logger
-	numNodes int // number of nodes to bootstrap
+	numNodes int         // number of nodes to bootstrap
+	keys     []model.Key // optional keys of the nodes to bootstrap; if nil, nodes are positioned by their identifiers
 }
 
 // NewBootstrapper creates a new Bootstrapper instance.
@@ -51,6 +52,15 @@ func NewBootstrapper(logger zerolog.Logger, numNodes int) *Bootstrapper {
 	}
 }
 
+// NewKeyedBootstrapper creates a new Bootstrapper instance that bootstraps one node per supplied key.
+// Nodes are positioned in the skip graph by their keys (e.g., variable-length model.BytesKey) rather than their
+// identifiers; each node still gets a unique random identifier to be addressed by.
+func NewKeyedBootstrapper(logger zerolog.Logger, keys []model.Key) *Bootstrapper {
+	b := NewBootstrapper(logger, len(keys))
+	b.keys = keys
+	return b
+}
+
 // Bootstrap creates a skip graph with the specified number of nodes using centralized insert (Algorithm 2).
 // Returns an array of pointers to BootstrapEntry where each entry's lookup table contains references to other entries.
 // Users can create SkipGraphNode instances from these entries with their own network configuration.
@@ -59,6 +69,10 @@ func (b *Bootstrapper) Bootstrap() ([]*BootstrapEntry, error) {
 		return nil, fmt.Errorf("number of nodes must be positive, got %d", b.numNodes)
 	}
 
+	if err := b.validateKeys(); err != nil {
+		return nil, fmt.Errorf("invalid keys: %w", err)
+	}
+
 	lg := b.logger.With().Int("numNodes", b.numNodes).Logger()
 	lg.Info().Msg("bootstrapping skip graph started")
 
@@ -98,6 +112,25 @@ func (b *Bootstrapper) Bootstrap() ([]*BootstrapEntry, error) {
 	return result, nil
 }
 
+// validateKeys checks that the keys to bootstrap with, if any, are valid according to model.ValidateKey and unique.
+func (b *Bootstrapper) validateKeys() error {
+	if b.keys == nil {
+		return nil
+	}
+	keySet := make(map[string]bool, len(b.keys))
+	for i, key := range b.keys {
+		if err := model.ValidateKey(key); err != nil {
+			return fmt.Errorf("key at index %d: %w", i, err)
+		}
+		k := string(key.KeyBytes())
+		if keySet[k] {
+			return fmt.Errorf("duplicate key at index %d", i)
+		}
+		keySet[k] = true
+	}
+	return nil
+}
+
 // createBootstrapEntries creates numNodes bootstrap entries with unique identifiers and random membership vectors
 func (b *Bootstrapper) createBootstrapEntries() (*internal.SortedEntryList, error) {
 	entries := internal.NewSortedEntryList()
@@ -157,6 +190,9 @@ func (b *Bootstrapper) createBootstrapEntries() (*internal.SortedEntryList, erro
 		// Using the default port since actual network communication doesn't occur during bootstrap
 		addr := model.NewAddress("localhost", DefaultSkipGraphPort)
 		identity := model.NewIdentity(id, mv, addr)
+		if b.keys != nil {
+			identity.SetKey(b.keys[i])
+		}
 
 		// Create lookup table
 		lt := &lookup.Table{}
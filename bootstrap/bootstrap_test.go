@@ -1,6 +1,7 @@
 package bootstrap
 
 import (
+	"errors"
 	"fmt"
 	"github.com/thep2p/skipgraph-go/bootstrap/internal"
 	"testing"
@@ -132,6 +133,78 @@ func TestBootstrapInvalidInput(t *testing.T) {
 	}
 }
 
+// TestBootstrapWithKeys tests bootstrap of nodes positioned by variable-length keys rather than their identifiers.
+func TestBootstrapWithKeys(t *testing.T) {
+	nodeCount := 50
+	keys := make([]model.Key, 0, nodeCount)
+	keySet := make(map[model.BytesKey]bool)
+	for len(keys) < nodeCount {
+		key := unittest.BytesKeyFixture(t, 8)
+		if keySet[key] {
+			continue
+		}
+		keySet[key] = true
+		keys = append(keys, key)
+	}
+
+	bootstrapper := NewKeyedBootstrapper(unittest.Logger(zerolog.InfoLevel), keys)
+	entries, err := bootstrapper.Bootstrap()
+	require.NoError(t, err)
+	require.Len(t, entries, nodeCount)
+
+	// Verify entries are sorted by key and every key is assigned to exactly one entry
+	for i, entry := range entries {
+		key, ok := entry.Identity.GetKey().(model.BytesKey)
+		require.True(t, ok, "entry at index %d should be positioned by its bytes key", i)
+		require.True(t, keySet[key], "entry at index %d has an unknown key", i)
+		delete(keySet, key)
+		if i > 0 {
+			require.Equal(t, -1, model.CompareKeys(entries[i-1].Identity.GetKey(), key), "entries should be sorted by key at index %d", i)
+		}
+	}
+	require.Empty(t, keySet, "all keys should be assigned to entries")
+
+	// Verify level 0 links entries in key order
+	for i := 0; i < len(entries)-1; i++ {
+		right, err := entries[i].LookupTable.GetEntry(types.DirectionRight, 0)
+		require.NoError(t, err)
+		require.NotNil(t, right)
+		require.Equal(t, entries[i+1].Identity, *right)
+	}
+
+	t.Run(
+		"NeighborConsistency", func(t *testing.T) {
+			verifyNeighborConsistency(t, entries)
+		},
+	)
+	t.Run(
+		"ConnectedComponents", func(t *testing.T) {
+			verifyConnectedComponents(t, entries)
+		},
+	)
+}
+
+// TestBootstrapWithInvalidKeys tests that bootstrap rejects nil, empty and duplicate keys.
+func TestBootstrapWithInvalidKeys(t *testing.T) {
+	logger := unittest.Logger(zerolog.ErrorLevel)
+
+	result, err := NewKeyedBootstrapper(logger, []model.Key{model.BytesKey("a"), nil}).Bootstrap()
+	assert.Error(t, err)
+	assert.Nil(t, result)
+
+	result, err = NewKeyedBootstrapper(logger, []model.Key{model.BytesKey("a"), model.BytesKey("")}).Bootstrap()
+	assert.True(t, errors.Is(err, model.ErrEmptyKey))
+	assert.Nil(t, result)
+
+	result, err = NewKeyedBootstrapper(logger, []model.Key{model.BytesKey("a"), model.BytesKey("b"), model.BytesKey("a")}).Bootstrap()
+	assert.Error(t, err)
+	assert.Nil(t, result)
+
+	result, err = NewKeyedBootstrapper(logger, nil).Bootstrap()
+	assert.Error(t, err)
+	assert.Nil(t, result)
+}
+
 // verifyLevel0Ordering verifies that level 0 forms a sorted doubly-linked list
 func verifyLevel0Ordering(t *testing.T, entries []*BootstrapEntry) {
 	t.Helper()
@@ -5,7 +5,7 @@ import (
 	"github.com/thep2p/skipgraph-go/core"
 	"github.com/thep2p/skipgraph-go/core/model"
 	"github.com/thep2p/skipgraph-go/core/types"
-	"sort"
+	"slices"
 )
 
 // Entry is an internal structure used during bootstrap process
@@ -15,7 +15,7 @@ type Entry struct {
 	LookupTable core.MutableLookupTable
 }
 
-// SortedEntryList is a list of entries sorted by identifier in ascending order
+// SortedEntryList is a list of entries sorted by key in ascending order
 // It provides methods to add entries, get entries by index, and insert entries into the skip graph
 type SortedEntryList struct {
 	list []*Entry
@@ -43,14 +43,12 @@ func (e *SortedEntryList) Len() int {
 	return len(e.list)
 }
 
-// sort sorts the entries by identifier in ascending order.
+// sort sorts the entries by key in ascending order.
+// Unless entries have explicit keys, the key of an entry is its identifier; sorting does not allocate then.
 func (e *SortedEntryList) sort() {
-	sort.Slice(
-		e.list, func(i, j int) bool {
-			idI := e.list[i].Identity.GetIdentifier()
-			idJ := e.list[j].Identity.GetIdentifier()
-			comparison := idI.Compare(&idJ)
-			return comparison.GetComparisonResult() == model.CompareLess
+	slices.SortFunc(
+		e.list, func(a, b *Entry) int {
+			return model.CompareIdentities(&a.Identity, &b.Identity)
 		},
 	)
 }
@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/unittest"
+)
+
+// TestSortedEntryList_SortNoAllocation tests that sorting entries without explicit keys performs no allocation.
+func TestSortedEntryList_SortNoAllocation(t *testing.T) {
+	e := NewSortedEntryList()
+	for i := 0; i < 100; i++ {
+		e.Add(&Entry{Identity: unittest.IdentityFixture(t)})
+	}
+
+	require.Zero(
+		t, testing.AllocsPerRun(
+			10, func() {
+				// reversing the list makes the sort compare and move every entry
+				for i, j := 0, e.Len()-1; i < j; i, j = i+1, j-1 {
+					e.list[i], e.list[j] = e.list[j], e.list[i]
+				}
+				e.sort()
+			},
+		),
+	)
+	for i := 1; i < e.Len(); i++ {
+		id1, id2 := e.Get(i-1).Identity.GetIdentifier(), e.Get(i).Identity.GetIdentifier()
+		require.Negative(t, id1.Cmp(&id2))
+	}
+}
@@ -0,0 +1,9 @@
+package crypto
+
+import "errors"
+
+// ErrInvalidKey is returned when a key is not a valid ed25519 key.
+var ErrInvalidKey = errors.New("invalid ed25519 key")
+
+// ErrInvalidCertificate is returned when a peer presents a certificate that does not bind an ed25519 key.
+var ErrInvalidCertificate = errors.New("invalid identity certificate")
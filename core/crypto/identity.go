@@ -0,0 +1,96 @@
+// Package crypto binds the identifiers of Skip Graph nodes to cryptographic keys.
+//
+// A node owns an ed25519 key pair, and its identifier is the SHA-256 digest of its public key, so that a node proves
+// its identifier by proving the possession of the private key behind it. Nodes present their public key to each other
+// in a self-signed X.509 certificate, e.g., during a TLS handshake.
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/thep2p/skipgraph-go/core/model"
+)
+
+// certificateValidity is the validity period of self-signed certificates; the identity of a certificate is checked
+// against its key rather than its validity, so it only needs to be long.
+const certificateValidity = 100 * 365 * 24 * time.Hour
+
+// GenerateKey generates a new ed25519 private key.
+// Returns the key, or an error if the source of randomness fails.
+func GenerateKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate ed25519 key: %w", err)
+	}
+	return key, nil
+}
+
+// IdentifierOf returns the identifier bound to the public key, i.e., its SHA-256 digest.
+func IdentifierOf(pub ed25519.PublicKey) model.Identifier {
+	return sha256.Sum256(pub)
+}
+
+// IdentifierOfKey returns the identifier bound to the public key of the private key.
+// Returns an error wrapping ErrInvalidKey if the key is malformed.
+func IdentifierOfKey(key ed25519.PrivateKey) (model.Identifier, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return model.Identifier{}, fmt.Errorf("%w: private key of %d bytes", ErrInvalidKey, len(key))
+	}
+	return IdentifierOf(key.Public().(ed25519.PublicKey)), nil
+}
+
+// SelfSignedCertificate creates a self-signed certificate of the public key of the private key, for use in TLS.
+// The subject of the certificate is the identifier bound to the key.
+// Returns the certificate along with its private key, or an error wrapping ErrInvalidKey if the key is malformed.
+func SelfSignedCertificate(key ed25519.PrivateKey) (tls.Certificate, error) {
+	id, err := IdentifierOfKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("could not generate serial number: %w", err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: id.String()},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(certificateValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("could not create certificate: %w", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// IdentifierOfCertificate returns the identifier bound to the ed25519 key of a self-signed certificate.
+// The certificate is only checked to be self-signed by its key; it is the TLS handshake that proves the possession of
+// the private key by the peer presenting it.
+// Returns an error wrapping ErrInvalidCertificate if the certificate is malformed, is not self-signed, or does not
+// hold an ed25519 key.
+func IdentifierOfCertificate(der []byte) (model.Identifier, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return model.Identifier{}, fmt.Errorf("%w: %w", ErrInvalidCertificate, err)
+	}
+	pub, ok := cert.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return model.Identifier{}, fmt.Errorf("%w: %s key", ErrInvalidCertificate, cert.PublicKeyAlgorithm)
+	}
+	if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		return model.Identifier{}, fmt.Errorf("%w: not self-signed: %w", ErrInvalidCertificate, err)
+	}
+	return IdentifierOf(pub), nil
+}
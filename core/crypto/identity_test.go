@@ -0,0 +1,83 @@
+package crypto_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/crypto"
+	"github.com/thep2p/skipgraph-go/core/model"
+)
+
+// TestIdentifierOfKey tests that the identifier of a key is the SHA-256 digest of its public key, and that distinct
+// keys are bound to distinct identifiers.
+func TestIdentifierOfKey(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	id, err := crypto.IdentifierOfKey(key)
+	require.NoError(t, err)
+	pub := key.Public().(ed25519.PublicKey)
+	require.Equal(t, model.Identifier(sha256.Sum256(pub)), id)
+	require.Equal(t, id, crypto.IdentifierOf(pub))
+
+	other, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	otherID, err := crypto.IdentifierOfKey(other)
+	require.NoError(t, err)
+	require.NotEqual(t, id, otherID)
+
+	_, err = crypto.IdentifierOfKey(key[:10])
+	require.True(t, errors.Is(err, crypto.ErrInvalidKey))
+}
+
+// TestSelfSignedCertificate tests that the self-signed certificate of a key is bound to the identifier of the key.
+func TestSelfSignedCertificate(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	id, err := crypto.IdentifierOfKey(key)
+	require.NoError(t, err)
+
+	cert, err := crypto.SelfSignedCertificate(key)
+	require.NoError(t, err)
+	require.Len(t, cert.Certificate, 1)
+	certID, err := crypto.IdentifierOfCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	require.Equal(t, id, certID)
+}
+
+// TestIdentifierOfCertificate_Invalid tests that malformed certificates, and certificates of keys other than ed25519,
+// are rejected.
+func TestIdentifierOfCertificate_Invalid(t *testing.T) {
+	_, err := crypto.IdentifierOfCertificate([]byte("not a certificate"))
+	require.True(t, errors.Is(err, crypto.ErrInvalidCertificate))
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, ecKey.Public(), ecKey)
+	require.NoError(t, err)
+	_, err = crypto.IdentifierOfCertificate(der)
+	require.True(t, errors.Is(err, crypto.ErrInvalidCertificate))
+
+	// an ed25519 certificate signed by another key
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	der, err = x509.CreateCertificate(rand.Reader, template, template, key.Public(), signer)
+	require.NoError(t, err)
+	_, err = crypto.IdentifierOfCertificate(der)
+	require.True(t, errors.Is(err, crypto.ErrInvalidCertificate))
+}
@@ -65,8 +65,7 @@ func (l *Table) GetEntry(dir types.Direction, lev types.Level) (*model.Identity,
 	}
 
 	// Check if the identity is empty (all zeros)
-	empty := model.Identity{}
-	if res == empty {
+	if res.IsZero() {
 		return nil, nil
 	}
 
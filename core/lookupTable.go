@@ -6,7 +6,9 @@ import (
 )
 
 // MaxLookupTableLevel indicates the upper bound for the number of levels in a SkipGraph LookupTable.
-const MaxLookupTableLevel types.Level = model.IdentifierSizeBytes * 8
+// Nodes are linked at level l when their membership vectors share an l-bit prefix, hence the number of levels is bounded
+// by the size of the membership vector, independent of the size of the keys.
+const MaxLookupTableLevel types.Level = model.MembershipVectorSize * 8
 
 // ImmutableLookupTable represents a read-only view of a LookupTable.
 // It is meant to apply the principle of least privilege by exposing only the methods needed for read-only access.
@@ -0,0 +1,213 @@
+package model
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Transport is an enum type for the transport over which an Address is reachable.
+// Valid values are TransportTCP, TransportUnix and TransportInMemory.
+type Transport string
+
+const (
+	// TransportTCP indicates a host:port endpoint reachable over TCP.
+	TransportTCP = Transport("tcp")
+	// TransportUnix indicates a Unix domain socket endpoint identified by its path.
+	TransportUnix = Transport("unix")
+	// TransportInMemory indicates an endpoint inside the same process identified by its name.
+	TransportInMemory = Transport("inmem")
+)
+
+// transportSchemeSeparator separates the transport tag from the endpoint in the string form of an Address,
+// e.g., unix:///tmp/node.sock.
+const transportSchemeSeparator = "://"
+
+// Address contains network address information of a single endpoint of a node.
+// A tcp address consists of a host name (or IP) and a port, while unix and in-memory addresses consist of a path
+// (socket path or in-memory endpoint name).
+// Address is comparable and its zero value denotes an empty address.
+type Address struct {
+	transport Transport
+	hostName  string
+	port      string
+	path      string
+}
+
+// NewAddress initializes and returns an instance of a tcp Address with the supplied inputs.
+// The host name may be a DNS name, an IPv4 or an IPv6 address (without brackets).
+// An empty host name and port yield the zero Address. The port is not checked, see Validate.
+func NewAddress(hostname string, port string) Address {
+	if hostname == "" && port == "" {
+		return Address{}
+	}
+	return Address{
+		transport: TransportTCP,
+		hostName:  hostname,
+		port:      port,
+	}
+}
+
+// NewUnixAddress initializes and returns an Address of a Unix domain socket located at the supplied path.
+func NewUnixAddress(path string) Address {
+	return Address{
+		transport: TransportUnix,
+		path:      path,
+	}
+}
+
+// NewInMemoryAddress initializes and returns an Address of an in-memory endpoint with the supplied name.
+func NewInMemoryAddress(name string) Address {
+	return Address{
+		transport: TransportInMemory,
+		path:      name,
+	}
+}
+
+// ParseAddress parses the string representation of an Address, as produced by Address.String.
+// Accepted forms are:
+//   - the empty string, for the zero Address.
+//   - host:port and tcp://host:port, where IPv6 hosts must be bracketed, e.g., [::1]:8000.
+//   - unix://<socket path>, e.g., unix:///var/run/node.sock.
+//   - inmem://<name>.
+//
+// Returns an error wrapping ErrInvalidAddress if the string is malformed, or ErrUnknownTransport if the transport tag
+// is not recognized.
+func ParseAddress(s string) (Address, error) {
+	if s == "" {
+		return Address{}, nil
+	}
+	transport := TransportTCP
+	endpoint := s
+	if idx := strings.Index(s, transportSchemeSeparator); idx >= 0 {
+		transport = Transport(s[:idx])
+		endpoint = s[idx+len(transportSchemeSeparator):]
+	}
+
+	switch transport {
+	case TransportTCP:
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			return Address{}, fmt.Errorf("%w: %s", ErrInvalidAddress, err)
+		}
+		if err := validatePort(port); err != nil {
+			return Address{}, err
+		}
+		return NewAddress(host, port), nil
+	case TransportUnix:
+		if endpoint == "" {
+			return Address{}, fmt.Errorf("%w: empty unix socket path in %q", ErrInvalidAddress, s)
+		}
+		return NewUnixAddress(endpoint), nil
+	case TransportInMemory:
+		if endpoint == "" {
+			return Address{}, fmt.Errorf("%w: empty in-memory endpoint name in %q", ErrInvalidAddress, s)
+		}
+		return NewInMemoryAddress(endpoint), nil
+	default:
+		return Address{}, fmt.Errorf("%w: %q", ErrUnknownTransport, transport)
+	}
+}
+
+// validatePort returns an error wrapping ErrInvalidAddress if port is not a decimal number in [0, 65535].
+func validatePort(port string) error {
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("%w: invalid port %q", ErrInvalidAddress, port)
+	}
+	return nil
+}
+
+// Transport returns the transport of the address.
+// The zero Address is considered a tcp address.
+func (a Address) Transport() Transport {
+	if a.transport == "" {
+		return TransportTCP
+	}
+	return a.transport
+}
+
+// HostName returns the hostName
+func (a Address) HostName() string {
+	return a.hostName
+}
+
+// Port returns the port
+func (a Address) Port() string {
+	return a.port
+}
+
+// Path returns the socket path of a unix address or the endpoint name of an in-memory address.
+// It returns an empty string for tcp addresses.
+func (a Address) Path() string {
+	return a.path
+}
+
+// IsZero returns true if the address is the zero Address, false otherwise.
+func (a Address) IsZero() bool {
+	return a == Address{}
+}
+
+// Validate returns an error wrapping ErrInvalidAddress if the address does not parse back from its string
+// representation, e.g., a tcp address with a non-numeric port, or a unix address with an empty path.
+// The zero Address is valid.
+func (a Address) Validate() error {
+	parsed, err := ParseAddress(a.String())
+	if err != nil {
+		return err
+	}
+	if parsed != a {
+		return fmt.Errorf("%w: %s parses back as %s", ErrInvalidAddress, a, parsed)
+	}
+	return nil
+}
+
+// String stringifies an Address in a form that can be parsed back by ParseAddress.
+// tcp addresses are formatted as host:port (with IPv6 hosts bracketed), other transports are prefixed with their
+// transport tag, e.g., unix:///tmp/node.sock. The zero Address is formatted as the empty string.
+func (a Address) String() string {
+	if a.IsZero() {
+		return ""
+	}
+	switch a.Transport() {
+	case TransportUnix, TransportInMemory:
+		return string(a.transport) + transportSchemeSeparator + a.path
+	default:
+		return net.JoinHostPort(a.hostName, a.port)
+	}
+}
+
+// NetAddr resolves the address to a net.Addr.
+// tcp addresses are resolved to *net.TCPAddr (which may involve a DNS lookup), unix addresses to *net.UnixAddr,
+// and in-memory addresses to InMemoryAddr.
+func (a Address) NetAddr() (net.Addr, error) {
+	switch a.Transport() {
+	case TransportTCP:
+		addr, err := net.ResolveTCPAddr(string(TransportTCP), net.JoinHostPort(a.hostName, a.port))
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve tcp address %s: %w", a, err)
+		}
+		return addr, nil
+	case TransportUnix:
+		return &net.UnixAddr{Name: a.path, Net: string(TransportUnix)}, nil
+	case TransportInMemory:
+		return InMemoryAddr(a.path), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTransport, a.transport)
+	}
+}
+
+// InMemoryAddr is the net.Addr of an in-memory endpoint.
+type InMemoryAddr string
+
+var _ net.Addr = InMemoryAddr("")
+
+// Network returns the name of the in-memory transport.
+func (a InMemoryAddr) Network() string {
+	return string(TransportInMemory)
+}
+
+// String returns the endpoint name.
+func (a InMemoryAddr) String() string {
+	return string(a)
+}
@@ -0,0 +1,185 @@
+package model_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/unittest"
+)
+
+// TestParseAddress_RoundTrip tests that the string representation of an address parses back to the same address.
+func TestParseAddress_RoundTrip(t *testing.T) {
+	addrs := []model.Address{
+		model.NewAddress("localhost", "8000"),
+		model.NewAddress("127.0.0.1", "0"),
+		model.NewAddress("::1", "65535"),
+		model.NewAddress("fe80::1%eth0", "9000"),
+		model.NewAddress("", "8080"),
+		model.NewAddress("", ""),
+		model.NewUnixAddress("/tmp/skipgraph/node.sock"),
+		model.NewInMemoryAddress("node-1"),
+	}
+
+	for _, addr := range addrs {
+		parsed, err := model.ParseAddress(addr.String())
+		require.NoError(t, err, "failed to parse %s", addr)
+		require.Equal(t, addr, parsed)
+		require.Equal(t, addr.String(), parsed.String())
+	}
+}
+
+// TestParseAddress_Formats tests parsing of the accepted address formats.
+func TestParseAddress_Formats(t *testing.T) {
+	addr, err := model.ParseAddress("[2001:db8::1]:4000")
+	require.NoError(t, err)
+	require.Equal(t, model.TransportTCP, addr.Transport())
+	require.Equal(t, "2001:db8::1", addr.HostName())
+	require.Equal(t, "4000", addr.Port())
+	require.Equal(t, "[2001:db8::1]:4000", addr.String())
+
+	addr, err = model.ParseAddress("tcp://example.com:5555")
+	require.NoError(t, err)
+	require.Equal(t, model.NewAddress("example.com", "5555"), addr)
+	// the default transport is not prefixed in the string representation
+	require.Equal(t, "example.com:5555", addr.String())
+
+	addr, err = model.ParseAddress("unix:///var/run/node.sock")
+	require.NoError(t, err)
+	require.Equal(t, model.TransportUnix, addr.Transport())
+	require.Equal(t, "/var/run/node.sock", addr.Path())
+
+	addr, err = model.ParseAddress("inmem://node-7")
+	require.NoError(t, err)
+	require.Equal(t, model.TransportInMemory, addr.Transport())
+	require.Equal(t, "node-7", addr.Path())
+}
+
+// TestParseAddress_Invalid tests that malformed addresses are rejected with the proper sentinel errors.
+func TestParseAddress_Invalid(t *testing.T) {
+	invalid := []string{
+		"localhost",           // missing port
+		"::1:8000",            // unbracketed IPv6
+		"localhost:port",      // non-numeric port
+		"localhost:65536",     // port out of range
+		"localhost:-1",        // negative port
+		"unix://",             // empty socket path
+		"inmem://",            // empty endpoint name
+		"tcp://localhost:80:", // too many colons
+	}
+	for _, s := range invalid {
+		_, err := model.ParseAddress(s)
+		require.Error(t, err, "expected error parsing %q", s)
+		require.True(t, errors.Is(err, model.ErrInvalidAddress), "unexpected error for %q: %v", s, err)
+	}
+
+	_, err := model.ParseAddress("quic://localhost:80")
+	require.Error(t, err)
+	require.True(t, errors.Is(err, model.ErrUnknownTransport))
+}
+
+// TestAddress_Zero tests that an empty tcp address is the zero Address, and that it round trips through the empty
+// string.
+func TestAddress_Zero(t *testing.T) {
+	addr := model.NewAddress("", "")
+	require.True(t, addr.IsZero())
+	require.Equal(t, model.Address{}, addr)
+	require.Equal(t, "", addr.String())
+	require.NoError(t, addr.Validate())
+
+	parsed, err := model.ParseAddress("")
+	require.NoError(t, err)
+	require.True(t, parsed.IsZero())
+}
+
+// TestAddress_Validate tests that addresses that do not parse back from their string representation are invalid.
+func TestAddress_Validate(t *testing.T) {
+	valid := []model.Address{
+		model.NewAddress("localhost", "8000"),
+		model.NewAddress("", "8080"),
+		model.NewUnixAddress("/tmp/node.sock"),
+		model.NewInMemoryAddress("node-1"),
+	}
+	for _, addr := range valid {
+		require.NoError(t, addr.Validate(), "unexpected error for %s", addr)
+	}
+
+	invalid := []model.Address{
+		model.NewAddress("localhost", "abc"),
+		model.NewAddress("localhost", ""),
+		model.NewAddress("localhost", "65536"),
+		model.NewAddress("", "abc"),
+		model.NewUnixAddress(""),
+		model.NewInMemoryAddress(""),
+	}
+	for _, addr := range invalid {
+		err := addr.Validate()
+		require.True(t, errors.Is(err, model.ErrInvalidAddress), "unexpected error for %s: %v", addr, err)
+	}
+}
+
+// TestAddress_NetAddr tests resolution of addresses to net.Addr.
+func TestAddress_NetAddr(t *testing.T) {
+	netAddr, err := model.NewAddress("127.0.0.1", "8000").NetAddr()
+	require.NoError(t, err)
+	tcpAddr, ok := netAddr.(*net.TCPAddr)
+	require.True(t, ok)
+	require.Equal(t, 8000, tcpAddr.Port)
+	require.True(t, tcpAddr.IP.Equal(net.ParseIP("127.0.0.1")))
+
+	netAddr, err = model.NewAddress("::1", "9000").NetAddr()
+	require.NoError(t, err)
+	require.Equal(t, "[::1]:9000", netAddr.String())
+
+	netAddr, err = model.NewUnixAddress("/tmp/node.sock").NetAddr()
+	require.NoError(t, err)
+	require.Equal(t, "unix", netAddr.Network())
+	require.Equal(t, "/tmp/node.sock", netAddr.String())
+
+	netAddr, err = model.NewInMemoryAddress("node-1").NetAddr()
+	require.NoError(t, err)
+	require.Equal(t, "inmem", netAddr.Network())
+	require.Equal(t, "node-1", netAddr.String())
+}
+
+// TestIdentity_Addresses tests that an identity advertises all of its endpoints with the first one as primary.
+func TestIdentity_Addresses(t *testing.T) {
+	primary := model.NewAddress("10.0.0.1", "5555")
+	secondary := model.NewAddress("192.168.1.1", "5555")
+	unix := model.NewUnixAddress("/tmp/node.sock")
+
+	identity := model.NewIdentity(
+		unittest.IdentifierFixture(t),
+		unittest.MembershipVectorFixture(t),
+		primary,
+		secondary,
+		unix,
+	)
+	require.Equal(t, primary, identity.GetAddress())
+	require.Equal(t, []model.Address{primary, secondary, unix}, identity.GetAddresses())
+	require.Equal(t, []model.Address{primary, secondary}, identity.GetAddressesFor(model.TransportTCP))
+	require.Equal(t, []model.Address{unix}, identity.GetAddressesFor(model.TransportUnix))
+	require.Empty(t, identity.GetAddressesFor(model.TransportInMemory))
+
+	// modifying the returned addresses must not affect the identity
+	addrs := identity.GetAddresses()
+	addrs[0] = model.Address{}
+	require.Equal(t, primary, identity.GetAddress())
+
+	// replacing the primary address keeps the other endpoints, and does not affect copies of the identity
+	copied := identity
+	replacement := model.NewAddress("10.0.0.2", "6666")
+	identity.SetAddr(replacement)
+	require.Equal(t, []model.Address{replacement, secondary, unix}, identity.GetAddresses())
+	require.Equal(t, primary, copied.GetAddress())
+}
+
+// TestIdentity_IsZero tests the zero check of identities.
+func TestIdentity_IsZero(t *testing.T) {
+	require.True(t, model.Identity{}.IsZero())
+	require.True(t, model.NewIdentity(model.Identifier{}, model.MembershipVector{}, model.Address{}).IsZero())
+	require.False(t, unittest.IdentityFixture(t).IsZero())
+	require.False(t, model.NewIdentity(model.Identifier{}, model.MembershipVector{}, unittest.AddressFixture(t)).IsZero())
+}
@@ -31,3 +31,31 @@ var ErrNumBitsExceedsMax = errors.New("numBits exceeds membership vector size")
 
 // ErrMembershipVectorTooLarge is returned when attempting to convert a byte slice larger than MembershipVectorSize to a MembershipVector.
 var ErrMembershipVectorTooLarge = errors.New("input length exceeds membership vector size")
+
+// Validation errors for Address
+
+// ErrInvalidAddress is returned when attempting to parse a malformed address string.
+var ErrInvalidAddress = errors.New("invalid address")
+
+// ErrUnknownTransport is returned when an address refers to a transport that is not supported.
+var ErrUnknownTransport = errors.New("unknown address transport")
+
+// Validation errors for Key
+
+// ErrEmptyKey is returned when attempting to create a variable-length key from an empty byte slice.
+var ErrEmptyKey = errors.New("key must not be empty")
+
+// ErrKeyTooLarge is returned when attempting to create a variable-length key larger than MaxKeySizeBytes.
+var ErrKeyTooLarge = errors.New("key length exceeds maximum key size")
+
+// Validation errors for Interval
+
+// ErrInvalidInterval is returned when attempting to create an empty interval, i.e., whose lower bound is not strictly
+// less than its upper bound.
+var ErrInvalidInterval = errors.New("interval lower bound must be less than its upper bound")
+
+// ErrSplitOutOfRange is returned when attempting to split an interval at an identifier that is not strictly inside it.
+var ErrSplitOutOfRange = errors.New("split point must be strictly inside the interval")
+
+// ErrDisjointIntervals is returned when attempting to merge intervals that neither overlap nor are adjacent.
+var ErrDisjointIntervals = errors.New("intervals are disjoint")
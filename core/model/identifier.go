@@ -1,7 +1,7 @@
 package model
 
 import (
-	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"github.com/go-playground/validator/v10"
@@ -124,31 +124,42 @@ func (c *Comparison) DebugInfo() string {
 }
 
 // Compare compares two Identifiers and returns a Comparison result, including the debugging info and the first mismatching byte index, if applicable.
+// Compare is meant for debugging; hot paths (e.g., sorting and candidate selection) should use Cmp or Less instead,
+// which perform no allocation.
 func (i *Identifier) Compare(other *Identifier) Comparison {
 	for index := range i {
-		cmp := bytes.Compare(i[index:index+1], other[index:index+1])
-		switch cmp {
-		case 1:
-			cr, err := NewComparisonResult(CompareGreater)
-			if err != nil {
-				panic(err)
-			}
-			return Comparison{*cr, i, other, uint32(index)}
-		case -1:
-			cr, err := NewComparisonResult(CompareLess)
-			if err != nil {
-				panic(err)
-			}
-			return Comparison{*cr, i, other, uint32(index)}
-		default:
-			continue
+		switch {
+		case i[index] > other[index]:
+			// the comparison results are constructed directly, as they are known to be valid
+			return Comparison{ComparisonResult{CompareGreater}, i, other, uint32(index)}
+		case i[index] < other[index]:
+			return Comparison{ComparisonResult{CompareLess}, i, other, uint32(index)}
 		}
 	}
-	cr, err := NewComparisonResult(CompareEqual)
-	if err != nil {
-		panic(err)
+	return Comparison{ComparisonResult{CompareEqual}, i, other, uint32(len(i) - 1)}
+}
+
+// Cmp compares two Identifiers by their big-endian numerical value.
+// Returns -1 if i < other, 0 if i == other, and +1 if i > other.
+// Cmp performs no allocation and compares the identifiers eight bytes at a time.
+func (i *Identifier) Cmp(other *Identifier) int {
+	for offset := 0; offset < IdentifierSizeBytes; offset += 8 {
+		a := binary.BigEndian.Uint64(i[offset : offset+8])
+		b := binary.BigEndian.Uint64(other[offset : offset+8])
+		if a < b {
+			return -1
+		}
+		if a > b {
+			return 1
+		}
 	}
-	return Comparison{*cr, i, other, uint32(len(i) - 1)}
+	return 0
+}
+
+// Less returns true if i is strictly less than other, false otherwise.
+// Less performs no allocation.
+func (i *Identifier) Less(other *Identifier) bool {
+	return i.Cmp(other) < 0
 }
 
 // ByteToId converts a byte slice b to an Identifier.
@@ -0,0 +1,132 @@
+package model
+
+import "sort"
+
+// IdentifierSet is an ordered set of identifiers, sorted in ascending order.
+// It supports ordered queries (floor, ceiling, rank) and range iteration over Intervals, e.g., for range queries,
+// replica sets and partition maps.
+// The zero value is an empty set ready to use.
+// IdentifierSet is not safe for concurrent use; callers must synchronize access.
+type IdentifierSet struct {
+	ids IdentifierList // sorted in ascending order, without duplicates
+}
+
+// NewIdentifierSet creates a new IdentifierSet containing the supplied identifiers; duplicates are ignored.
+func NewIdentifierSet(ids ...Identifier) *IdentifierSet {
+	s := &IdentifierSet{ids: make(IdentifierList, 0, len(ids))}
+	for _, id := range ids {
+		s.Insert(id)
+	}
+	return s
+}
+
+// search returns the index of the smallest identifier >= id, which is Len() if there is no such identifier.
+func (s *IdentifierSet) search(id Identifier) int {
+	return sort.Search(
+		len(s.ids), func(i int) bool {
+			return !s.ids[i].Less(&id)
+		},
+	)
+}
+
+// Insert adds id to the set.
+// Returns true if id was inserted, false if it was already a member of the set.
+func (s *IdentifierSet) Insert(id Identifier) bool {
+	i := s.search(id)
+	if i < len(s.ids) && s.ids[i] == id {
+		return false
+	}
+	s.ids = append(s.ids, Identifier{})
+	copy(s.ids[i+1:], s.ids[i:])
+	s.ids[i] = id
+	return true
+}
+
+// Delete removes id from the set.
+// Returns true if id was removed, false if it was not a member of the set.
+func (s *IdentifierSet) Delete(id Identifier) bool {
+	i := s.search(id)
+	if i == len(s.ids) || s.ids[i] != id {
+		return false
+	}
+	s.ids = append(s.ids[:i], s.ids[i+1:]...)
+	return true
+}
+
+// Contains returns true if id is a member of the set, false otherwise.
+func (s *IdentifierSet) Contains(id Identifier) bool {
+	i := s.search(id)
+	return i < len(s.ids) && s.ids[i] == id
+}
+
+// Len returns the number of identifiers in the set.
+func (s *IdentifierSet) Len() int {
+	return len(s.ids)
+}
+
+// At returns the identifier with the given rank, i.e., the index-th smallest identifier (0-indexed).
+// Returns false if index is out of range.
+func (s *IdentifierSet) At(index int) (Identifier, bool) {
+	if index < 0 || index >= len(s.ids) {
+		return Identifier{}, false
+	}
+	return s.ids[index], true
+}
+
+// Rank returns the number of identifiers in the set that are strictly less than id.
+// If id is a member of the set, it is the 0-indexed position of id, i.e., s.At(s.Rank(id)) returns id.
+func (s *IdentifierSet) Rank(id Identifier) int {
+	return s.search(id)
+}
+
+// Floor returns the greatest identifier in the set that is less than or equal to id.
+// Returns false if there is no such identifier.
+func (s *IdentifierSet) Floor(id Identifier) (Identifier, bool) {
+	i := s.search(id)
+	if i < len(s.ids) && s.ids[i] == id {
+		return id, true
+	}
+	if i == 0 {
+		return Identifier{}, false
+	}
+	return s.ids[i-1], true
+}
+
+// Ceiling returns the smallest identifier in the set that is greater than or equal to id.
+// Returns false if there is no such identifier.
+func (s *IdentifierSet) Ceiling(id Identifier) (Identifier, bool) {
+	i := s.search(id)
+	if i == len(s.ids) {
+		return Identifier{}, false
+	}
+	return s.ids[i], true
+}
+
+// Min returns the smallest identifier in the set, or false if the set is empty.
+func (s *IdentifierSet) Min() (Identifier, bool) {
+	return s.At(0)
+}
+
+// Max returns the greatest identifier in the set, or false if the set is empty.
+func (s *IdentifierSet) Max() (Identifier, bool) {
+	return s.At(len(s.ids) - 1)
+}
+
+// Range calls fn for each identifier of the set within the interval in ascending order.
+// Iteration stops early if fn returns false.
+// The set must not be modified by fn.
+func (s *IdentifierSet) Range(interval Interval, fn func(Identifier) bool) {
+	for i := s.search(interval.Lo()); i < len(s.ids) && interval.Contains(s.ids[i]); i++ {
+		if !fn(s.ids[i]) {
+			return
+		}
+	}
+}
+
+// List returns the identifiers of the set in ascending order.
+// The returned list is a copy and can be modified by the caller.
+func (s *IdentifierSet) List() IdentifierList {
+	ids := make(IdentifierList, len(s.ids))
+	copy(ids, s.ids)
+	return ids
+}
@@ -0,0 +1,141 @@
+package model_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/unittest"
+)
+
+// idOf returns the identifier whose big-endian numerical value is v.
+func idOf(t *testing.T, v byte) model.Identifier {
+	id, err := model.ByteToId([]byte{v})
+	require.NoError(t, err)
+	return id
+}
+
+// TestIdentifierSet_InsertDelete tests membership, ordering and de-duplication of the set.
+func TestIdentifierSet_InsertDelete(t *testing.T) {
+	set := &model.IdentifierSet{}
+	require.Equal(t, 0, set.Len())
+	_, ok := set.Min()
+	require.False(t, ok)
+
+	require.True(t, set.Insert(idOf(t, 30)))
+	require.True(t, set.Insert(idOf(t, 10)))
+	require.True(t, set.Insert(idOf(t, 20)))
+	require.False(t, set.Insert(idOf(t, 20)), "duplicate insert must be ignored")
+	require.Equal(t, 3, set.Len())
+	require.Equal(t, model.IdentifierList{idOf(t, 10), idOf(t, 20), idOf(t, 30)}, set.List())
+
+	require.True(t, set.Contains(idOf(t, 20)))
+	require.False(t, set.Contains(idOf(t, 25)))
+
+	require.True(t, set.Delete(idOf(t, 20)))
+	require.False(t, set.Delete(idOf(t, 20)), "deleting a non-member must be a no-op")
+	require.False(t, set.Contains(idOf(t, 20)))
+	require.Equal(t, model.IdentifierList{idOf(t, 10), idOf(t, 30)}, set.List())
+
+	minID, ok := set.Min()
+	require.True(t, ok)
+	require.Equal(t, idOf(t, 10), minID)
+	maxID, ok := set.Max()
+	require.True(t, ok)
+	require.Equal(t, idOf(t, 30), maxID)
+
+	// modifying the returned list must not affect the set
+	list := set.List()
+	list[0] = idOf(t, 99)
+	require.True(t, set.Contains(idOf(t, 10)))
+}
+
+// TestIdentifierSet_OrderedQueries tests floor, ceiling, rank and select against a sorted reference list.
+func TestIdentifierSet_OrderedQueries(t *testing.T) {
+	set := model.NewIdentifierSet()
+	reference := make(model.IdentifierList, 0)
+	for i := 0; i < 200; i++ {
+		id := unittest.IdentifierFixture(t)
+		require.True(t, set.Insert(id))
+		reference = append(reference, id)
+	}
+	sort.Slice(
+		reference, func(i, j int) bool {
+			return reference[i].Less(&reference[j])
+		},
+	)
+	require.Equal(t, reference, set.List())
+
+	for i, id := range reference {
+		require.Equal(t, i, set.Rank(id))
+		at, ok := set.At(i)
+		require.True(t, ok)
+		require.Equal(t, id, at)
+
+		floor, ok := set.Floor(id)
+		require.True(t, ok)
+		require.Equal(t, id, floor)
+		ceiling, ok := set.Ceiling(id)
+		require.True(t, ok)
+		require.Equal(t, id, ceiling)
+	}
+	_, ok := set.At(-1)
+	require.False(t, ok)
+	_, ok = set.At(len(reference))
+	require.False(t, ok)
+
+	for i := 0; i < 200; i++ {
+		target := unittest.IdentifierFixture(t)
+		rank := sort.Search(
+			len(reference), func(i int) bool {
+				return !reference[i].Less(&target)
+			},
+		)
+		require.Equal(t, rank, set.Rank(target))
+
+		floor, ok := set.Floor(target)
+		if rank == 0 {
+			require.False(t, ok)
+		} else {
+			require.True(t, ok)
+			require.Equal(t, reference[rank-1], floor)
+		}
+
+		ceiling, ok := set.Ceiling(target)
+		if rank == len(reference) {
+			require.False(t, ok)
+		} else {
+			require.True(t, ok)
+			require.Equal(t, reference[rank], ceiling)
+		}
+	}
+}
+
+// TestIdentifierSet_Range tests range iteration over bounded and unbounded intervals, including early termination.
+func TestIdentifierSet_Range(t *testing.T) {
+	set := model.NewIdentifierSet(idOf(t, 10), idOf(t, 20), idOf(t, 30), idOf(t, 40))
+
+	collect := func(iv model.Interval, limit int) model.IdentifierList {
+		res := model.IdentifierList{}
+		set.Range(
+			iv, func(id model.Identifier) bool {
+				res = append(res, id)
+				return len(res) < limit
+			},
+		)
+		return res
+	}
+
+	iv, err := model.NewInterval(idOf(t, 15), idOf(t, 40))
+	require.NoError(t, err)
+	require.Equal(t, model.IdentifierList{idOf(t, 20), idOf(t, 30)}, collect(iv, 10), "upper bound must be exclusive")
+
+	iv, err = model.NewInterval(idOf(t, 20), idOf(t, 21))
+	require.NoError(t, err)
+	require.Equal(t, model.IdentifierList{idOf(t, 20)}, collect(iv, 10), "lower bound must be inclusive")
+
+	require.Equal(t, model.IdentifierList{idOf(t, 30), idOf(t, 40)}, collect(model.NewUnboundedInterval(idOf(t, 25)), 10))
+	require.Equal(t, set.List(), collect(model.FullInterval(), 10))
+	require.Equal(t, model.IdentifierList{idOf(t, 10), idOf(t, 20)}, collect(model.FullInterval(), 2))
+}
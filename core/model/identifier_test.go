@@ -263,3 +263,109 @@ func TestIdentifier_IsZero(t *testing.T) {
 		require.False(t, id.IsZero(), "expected IsZero to return false for partially zero identifier")
 	})
 }
+
+// TestIdentifierCmp tests that Cmp and Less are consistent with Compare, including identifiers differing in each of
+// the eight-byte words compared by Cmp.
+func TestIdentifierCmp(t *testing.T) {
+	expected := func(id1, id2 *model.Identifier) int {
+		comparison := id1.Compare(id2)
+		switch comparison.GetComparisonResult() {
+		case model.CompareLess:
+			return -1
+		case model.CompareGreater:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		id1 := unittest.IdentifierFixture(t)
+		id2 := unittest.IdentifierFixture(t)
+		require.Equal(t, expected(&id1, &id2), id1.Cmp(&id2))
+		require.Equal(t, expected(&id2, &id1), id2.Cmp(&id1))
+		require.Equal(t, expected(&id1, &id2) < 0, id1.Less(&id2))
+		require.Equal(t, 0, id1.Cmp(&id1))
+		require.False(t, id1.Less(&id1))
+	}
+
+	for index := 0; index < model.IdentifierSizeBytes; index++ {
+		id1 := unittest.IdentifierFixture(t)
+		id2 := id1
+		id2[index]++
+		if id2[index] == 0 {
+			// overflowed; make id2 the smaller one instead
+			id1[index] = 1
+		}
+		require.Equal(t, expected(&id1, &id2), id1.Cmp(&id2), "mismatch at byte %d", index)
+		require.Equal(t, expected(&id2, &id1), id2.Cmp(&id1), "mismatch at byte %d", index)
+	}
+}
+
+// TestIdentifierCmp_NoAllocation tests that comparing identifiers via Cmp, Less, Compare, CompareKeys and
+// CompareIdentities performs no allocation.
+func TestIdentifierCmp_NoAllocation(t *testing.T) {
+	id1 := unittest.IdentifierFixture(t)
+	id2 := unittest.IdentifierFixture(t)
+	var key1, key2 model.Key = id1, id2
+	identity1, identity2 := unittest.IdentityFixture(t), unittest.IdentityFixture(t)
+
+	require.Zero(t, testing.AllocsPerRun(100, func() { _ = id1.Cmp(&id2) }))
+	require.Zero(t, testing.AllocsPerRun(100, func() { _ = id1.Less(&id2) }))
+	require.Zero(t, testing.AllocsPerRun(100, func() { _ = id1.Compare(&id2) }))
+	require.Zero(t, testing.AllocsPerRun(100, func() { _ = model.CompareKeys(key1, key2) }))
+	require.Zero(t, testing.AllocsPerRun(100, func() { _ = model.CompareIdentities(&identity1, &identity2) }))
+}
+
+// BenchmarkIdentifierCompare benchmarks the debugging comparison Compare against the fast paths Cmp and Less,
+// as well as comparing identifiers as generic keys.
+// Identifiers share a long common prefix so that the comparison has to inspect most bytes.
+func BenchmarkIdentifierCompare(b *testing.B) {
+	id1 := unittest.IdentifierFixture(b)
+	id2 := id1
+	id2[model.IdentifierSizeBytes-1]++
+	var key1, key2 model.Key = id1, id2
+
+	b.Run(
+		"Compare", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				c := id1.Compare(&id2)
+				_ = c.GetComparisonResult()
+			}
+		},
+	)
+	b.Run(
+		"Cmp", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = id1.Cmp(&id2)
+			}
+		},
+	)
+	b.Run(
+		"Less", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = id1.Less(&id2)
+			}
+		},
+	)
+	b.Run(
+		"CompareKeys", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = model.CompareKeys(key1, key2)
+			}
+		},
+	)
+	b.Run(
+		"ValidatedComparisonResult", func(b *testing.B) {
+			// baseline: the reflection-based validation that Compare used to run on every comparison
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = model.NewComparisonResult(model.CompareLess)
+			}
+		},
+	)
+}
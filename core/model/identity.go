@@ -1,50 +1,21 @@
 package model
 
-import "fmt"
-
-// Address contains network address information
-type Address struct {
-	hostName string
-	port     string
-}
-
-// NewAddress initializes and returns an instance of Address with the supplied inputs
-func NewAddress(hostname string, port string) Address {
-	return Address{
-		hostName: hostname,
-		port:     port,
-	}
-}
-
-// HostName returns the hostName
-func (a Address) HostName() string {
-	return a.hostName
-}
-
-// Port returns the port
-func (a Address) Port() string {
-	return a.port
-}
-
-// String stringifies an Address
-func (a Address) String() string {
-	s := fmt.Sprintf("host name: %s, port: %s", a.HostName(), a.Port())
-	return s
-}
-
 // Identity is a struct that contains the information of a node in the skip graph.
 // More specifically, it is the constituent element of the LookupTable.
 type Identity struct {
 	id        Identifier       // corresponds to numerical id in traditional skip graph.
 	memVector MembershipVector // corresponds to name id in traditional skip graph.
-	addr      Address          // holds network address like IP.
+	addrs     []Address        // holds network addresses of the node; the first one is the primary address.
+	key       Key              // position of the node in the key space; nil means the identifier is the key.
 }
 
 // NewIdentity constructs and returns an Identity.
-func NewIdentity(id Identifier, mv MembershipVector, addr Address) Identity {
+// addr is the primary address of the node, and others are any additional endpoints the node is reachable at,
+// e.g., for multi-homed nodes or nodes reachable over several transports.
+func NewIdentity(id Identifier, mv MembershipVector, addr Address, others ...Address) Identity {
 	i := Identity{}
 	i.SetMemVector(mv)
-	i.SetAddr(addr)
+	i.SetAddresses(append([]Address{addr}, others...)...)
 	i.SetId(id)
 	return i
 }
@@ -54,14 +25,60 @@ func (i Identity) GetIdentifier() Identifier {
 	return i.id
 }
 
+// GetKey returns the key of the node, which determines its position in the skip graph.
+// Unless a key is explicitly set via SetKey, the identifier of the node is its key.
+func (i Identity) GetKey() Key {
+	if i.key == nil {
+		return i.id
+	}
+	return i.key
+}
+
 // GetMembershipVector returns the MembershipVector field.
 func (i Identity) GetMembershipVector() MembershipVector {
 	return i.memVector
 }
 
-// GetAddress returns the Address field.
+// GetAddress returns the primary Address of the node.
+// Returns the zero Address if the identity has no address.
 func (i Identity) GetAddress() Address {
-	return i.addr
+	if len(i.addrs) == 0 {
+		return Address{}
+	}
+	return i.addrs[0]
+}
+
+// GetAddresses returns all addresses of the node, starting with the primary one.
+// The returned slice is a copy and can be modified by the caller.
+func (i Identity) GetAddresses() []Address {
+	addrs := make([]Address, len(i.addrs))
+	copy(addrs, i.addrs)
+	return addrs
+}
+
+// GetAddressesFor returns the addresses of the node that are reachable over the given transport, in order of preference.
+func (i Identity) GetAddressesFor(transport Transport) []Address {
+	addrs := make([]Address, 0, len(i.addrs))
+	for _, addr := range i.addrs {
+		if addr.Transport() == transport {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// IsZero returns true if the identity has a zero identifier, a zero membership vector, no explicit key
+// and no non-zero address.
+func (i Identity) IsZero() bool {
+	if !i.id.IsZero() || !i.memVector.IsZero() || i.key != nil {
+		return false
+	}
+	for _, addr := range i.addrs {
+		if !addr.IsZero() {
+			return false
+		}
+	}
+	return true
 }
 
 // SetId sets Identifier.
@@ -70,14 +87,36 @@ func (i *Identity) SetId(id Identifier) {
 	i.id = id
 }
 
+// SetKey sets the key of the node; a nil key resets the key to the identifier of the node.
+func (i *Identity) SetKey(key Key) {
+	i.key = key
+}
+
 // SetMemVector sets membershipVector.
 func (i *Identity) SetMemVector(mv MembershipVector) {
 	// TODO validation of the membershipVector mv may be needed.
 	i.memVector = mv
 }
 
-// SetAddr sets address.
+// SetAddr sets the primary address, keeping any additional addresses.
 func (i *Identity) SetAddr(addr Address) {
 	// TODO validation of the addr may be needed.
-	i.addr = addr
+	addrs := i.GetAddresses()
+	if len(addrs) == 0 {
+		addrs = append(addrs, addr)
+	} else {
+		addrs[0] = addr
+	}
+	i.addrs = addrs
+}
+
+// SetAddresses replaces all addresses of the node; the first supplied address becomes the primary one.
+func (i *Identity) SetAddresses(addrs ...Address) {
+	if len(addrs) == 0 {
+		i.addrs = nil
+		return
+	}
+	// copy so that identities never share the backing array of their addresses
+	i.addrs = make([]Address, len(addrs))
+	copy(i.addrs, addrs)
 }
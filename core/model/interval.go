@@ -0,0 +1,114 @@
+package model
+
+import (
+	"fmt"
+)
+
+// Interval is a non-empty half-open interval [lo, hi) of the identifier space.
+// An interval may be unbounded, i.e., [lo, +inf), so that it can cover the greatest identifier of the space.
+// Interval is comparable; two intervals are equal iff they cover the same identifiers.
+type Interval struct {
+	lo        Identifier
+	hi        Identifier // exclusive upper bound; meaningless if unbounded is true
+	unbounded bool       // true if the interval extends to the end of the identifier space
+}
+
+// NewInterval creates the half-open interval [lo, hi).
+// Returns an error wrapping ErrInvalidInterval if lo is not strictly less than hi, i.e., if the interval would be empty.
+func NewInterval(lo, hi Identifier) (Interval, error) {
+	if !lo.Less(&hi) {
+		return Interval{}, fmt.Errorf("%w: lo %s must be less than hi %s", ErrInvalidInterval, lo.String(), hi.String())
+	}
+	return Interval{lo: lo, hi: hi}, nil
+}
+
+// NewUnboundedInterval creates the interval [lo, +inf) that covers all identifiers greater than or equal to lo.
+func NewUnboundedInterval(lo Identifier) Interval {
+	return Interval{lo: lo, unbounded: true}
+}
+
+// FullInterval returns the interval that covers the entire identifier space.
+func FullInterval() Interval {
+	return NewUnboundedInterval(Identifier{})
+}
+
+// Lo returns the inclusive lower bound of the interval.
+func (iv Interval) Lo() Identifier {
+	return iv.lo
+}
+
+// Hi returns the exclusive upper bound of the interval.
+// Returns false if the interval is unbounded.
+func (iv Interval) Hi() (Identifier, bool) {
+	if iv.unbounded {
+		return Identifier{}, false
+	}
+	return iv.hi, true
+}
+
+// IsUnbounded returns true if the interval extends to the end of the identifier space.
+func (iv Interval) IsUnbounded() bool {
+	return iv.unbounded
+}
+
+// Contains returns true if lo <= id < hi.
+func (iv Interval) Contains(id Identifier) bool {
+	return !id.Less(&iv.lo) && iv.below(id)
+}
+
+// below returns true if id is strictly less than the upper bound of the interval.
+func (iv Interval) below(id Identifier) bool {
+	return iv.unbounded || id.Less(&iv.hi)
+}
+
+// Overlaps returns true if the two intervals share at least one identifier.
+func (iv Interval) Overlaps(other Interval) bool {
+	return iv.below(other.lo) && other.below(iv.lo)
+}
+
+// Adjacent returns true if the two intervals do not overlap, and one begins exactly where the other one ends,
+// so that their union is an interval.
+func (iv Interval) Adjacent(other Interval) bool {
+	return (!iv.unbounded && iv.hi == other.lo) || (!other.unbounded && other.hi == iv.lo)
+}
+
+// Split splits the interval at the supplied identifier into [lo, at) and [at, hi).
+// Returns an error wrapping ErrSplitOutOfRange if at is not strictly inside the interval, i.e., if either part would be
+// empty.
+func (iv Interval) Split(at Identifier) (Interval, Interval, error) {
+	if !iv.lo.Less(&at) || !iv.below(at) {
+		return Interval{}, Interval{}, fmt.Errorf("%w: %s is not strictly inside %s", ErrSplitOutOfRange, at.String(), iv)
+	}
+	left := Interval{lo: iv.lo, hi: at}
+	right := Interval{lo: at, hi: iv.hi, unbounded: iv.unbounded}
+	return left, right, nil
+}
+
+// Merge returns the union of the two intervals.
+// Returns an error wrapping ErrDisjointIntervals if the intervals neither overlap nor are adjacent, as their union
+// would not be an interval.
+func (iv Interval) Merge(other Interval) (Interval, error) {
+	if !iv.Overlaps(other) && !iv.Adjacent(other) {
+		return Interval{}, fmt.Errorf("%w: cannot merge %s and %s", ErrDisjointIntervals, iv, other)
+	}
+	merged := iv
+	if other.lo.Less(&merged.lo) {
+		merged.lo = other.lo
+	}
+	switch {
+	case merged.unbounded || other.unbounded:
+		merged.unbounded = true
+		merged.hi = Identifier{}
+	case merged.hi.Less(&other.hi):
+		merged.hi = other.hi
+	}
+	return merged, nil
+}
+
+// String returns the human-readable representation of the interval, e.g., [00..01, 00..ff) or [00..01, +inf).
+func (iv Interval) String() string {
+	if iv.unbounded {
+		return fmt.Sprintf("[%s, +inf)", iv.lo.String())
+	}
+	return fmt.Sprintf("[%s, %s)", iv.lo.String(), iv.hi.String())
+}
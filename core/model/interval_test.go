@@ -0,0 +1,111 @@
+package model_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/model"
+)
+
+// intervalOf returns the interval [lo, hi) of identifiers with the given numerical bounds.
+func intervalOf(t *testing.T, lo, hi byte) model.Interval {
+	iv, err := model.NewInterval(idOf(t, lo), idOf(t, hi))
+	require.NoError(t, err)
+	return iv
+}
+
+// TestNewInterval tests that empty intervals are rejected.
+func TestNewInterval(t *testing.T) {
+	iv := intervalOf(t, 10, 20)
+	require.Equal(t, idOf(t, 10), iv.Lo())
+	hi, ok := iv.Hi()
+	require.True(t, ok)
+	require.Equal(t, idOf(t, 20), hi)
+	require.False(t, iv.IsUnbounded())
+
+	_, err := model.NewInterval(idOf(t, 20), idOf(t, 20))
+	require.True(t, errors.Is(err, model.ErrInvalidInterval))
+	_, err = model.NewInterval(idOf(t, 20), idOf(t, 10))
+	require.True(t, errors.Is(err, model.ErrInvalidInterval))
+
+	unbounded := model.NewUnboundedInterval(idOf(t, 10))
+	require.True(t, unbounded.IsUnbounded())
+	_, ok = unbounded.Hi()
+	require.False(t, ok)
+}
+
+// TestInterval_Contains tests the half-open bounds of intervals.
+func TestInterval_Contains(t *testing.T) {
+	iv := intervalOf(t, 10, 20)
+	require.False(t, iv.Contains(idOf(t, 9)))
+	require.True(t, iv.Contains(idOf(t, 10)))
+	require.True(t, iv.Contains(idOf(t, 19)))
+	require.False(t, iv.Contains(idOf(t, 20)))
+
+	var maxID model.Identifier
+	for i := range maxID {
+		maxID[i] = 0xff
+	}
+	require.True(t, model.FullInterval().Contains(model.Identifier{}))
+	require.True(t, model.FullInterval().Contains(maxID), "unbounded interval must cover the greatest identifier")
+	require.False(t, model.NewUnboundedInterval(idOf(t, 10)).Contains(idOf(t, 9)))
+}
+
+// TestInterval_Overlaps tests overlap and adjacency of intervals.
+func TestInterval_Overlaps(t *testing.T) {
+	a := intervalOf(t, 10, 20)
+	require.True(t, a.Overlaps(intervalOf(t, 15, 25)))
+	require.True(t, a.Overlaps(intervalOf(t, 5, 11)))
+	require.True(t, a.Overlaps(intervalOf(t, 12, 13)))
+	require.True(t, a.Overlaps(model.FullInterval()))
+	require.False(t, a.Overlaps(intervalOf(t, 20, 30)), "half-open intervals sharing a bound must not overlap")
+	require.False(t, a.Overlaps(intervalOf(t, 1, 10)))
+	require.False(t, a.Overlaps(model.NewUnboundedInterval(idOf(t, 20))))
+
+	require.True(t, a.Adjacent(intervalOf(t, 20, 30)))
+	require.True(t, a.Adjacent(intervalOf(t, 1, 10)))
+	require.True(t, a.Adjacent(model.NewUnboundedInterval(idOf(t, 20))))
+	require.False(t, a.Adjacent(intervalOf(t, 21, 30)))
+}
+
+// TestInterval_SplitMerge tests that splitting and merging are inverse operations and reject invalid inputs.
+func TestInterval_SplitMerge(t *testing.T) {
+	iv := intervalOf(t, 10, 20)
+	left, right, err := iv.Split(idOf(t, 15))
+	require.NoError(t, err)
+	require.Equal(t, intervalOf(t, 10, 15), left)
+	require.Equal(t, intervalOf(t, 15, 20), right)
+
+	merged, err := left.Merge(right)
+	require.NoError(t, err)
+	require.Equal(t, iv, merged)
+	merged, err = right.Merge(left)
+	require.NoError(t, err)
+	require.Equal(t, iv, merged)
+
+	for _, at := range []byte{9, 10, 20, 21} {
+		_, _, err = iv.Split(idOf(t, at))
+		require.True(t, errors.Is(err, model.ErrSplitOutOfRange), "split at %d must fail", at)
+	}
+
+	// unbounded intervals keep their unbounded part
+	left, right, err = model.NewUnboundedInterval(idOf(t, 10)).Split(idOf(t, 50))
+	require.NoError(t, err)
+	require.Equal(t, intervalOf(t, 10, 50), left)
+	require.Equal(t, model.NewUnboundedInterval(idOf(t, 50)), right)
+	merged, err = left.Merge(right)
+	require.NoError(t, err)
+	require.Equal(t, model.NewUnboundedInterval(idOf(t, 10)), merged)
+
+	// overlapping intervals merge into their union
+	merged, err = intervalOf(t, 10, 20).Merge(intervalOf(t, 15, 30))
+	require.NoError(t, err)
+	require.Equal(t, intervalOf(t, 10, 30), merged)
+	merged, err = intervalOf(t, 10, 40).Merge(intervalOf(t, 15, 30))
+	require.NoError(t, err)
+	require.Equal(t, intervalOf(t, 10, 40), merged)
+
+	_, err = intervalOf(t, 10, 20).Merge(intervalOf(t, 21, 30))
+	require.True(t, errors.Is(err, model.ErrDisjointIntervals))
+}
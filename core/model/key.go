@@ -0,0 +1,100 @@
+package model
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// MaxKeySizeBytes is the upper bound for the size of a variable-length key.
+const MaxKeySizeBytes = 1024
+
+// Key is an element of the totally ordered key space of a skip graph.
+// Skip graphs only require their keys to be totally ordered; keys are ordered lexicographically by their byte
+// representation, where a key that is a proper prefix of another one is the smaller one.
+// The 32-byte Identifier is one implementation of Key whose lexicographic order coincides with its big-endian
+// numerical order. BytesKey is a variable-length implementation.
+// All nodes of the same skip graph are expected to use keys of the same kind.
+type Key interface {
+	// KeyBytes returns the byte representation of the key that defines its position in the key space.
+	// The returned slice must not be modified.
+	KeyBytes() []byte
+}
+
+// CompareKeys compares two keys lexicographically by their byte representation.
+// Returns -1 if a < b, 0 if a == b, and +1 if a > b.
+// Identifiers are compared via Identifier.Cmp, and variable-length keys without copying their bytes.
+func CompareKeys(a, b Key) int {
+	switch ka := a.(type) {
+	case Identifier:
+		if kb, ok := b.(Identifier); ok {
+			return ka.Cmp(&kb)
+		}
+	case BytesKey:
+		if kb, ok := b.(BytesKey); ok {
+			return strings.Compare(string(ka), string(kb))
+		}
+	}
+	return bytes.Compare(a.KeyBytes(), b.KeyBytes())
+}
+
+// CompareIdentities compares the keys of two identities as CompareKeys does.
+// Identities without explicit keys are compared by their identifiers via Identifier.Cmp, without boxing them into keys,
+// hence without allocation, e.g., when sorting the entries of a bootstrap.
+func CompareIdentities(a, b *Identity) int {
+	if a.key == nil && b.key == nil {
+		return a.id.Cmp(&b.id)
+	}
+	return CompareKeys(a.GetKey(), b.GetKey())
+}
+
+// KeyBytes returns the byte representation of the Identifier so that an Identifier can be used as a Key.
+func (i Identifier) KeyBytes() []byte {
+	return i[:]
+}
+
+var _ Key = Identifier{}
+
+// BytesKey is a variable-length Key, e.g., a composite key like tenant/path.
+// It is backed by a string so that it is immutable and comparable, hence it can be used as a map key.
+type BytesKey string
+
+var _ Key = BytesKey("")
+
+// NewBytesKey converts a byte slice to a BytesKey.
+// Returns an error wrapping ErrEmptyKey if b is empty, or ErrKeyTooLarge if b is longer than MaxKeySizeBytes.
+func NewBytesKey(b []byte) (BytesKey, error) {
+	if len(b) == 0 {
+		return "", ErrEmptyKey
+	}
+	if len(b) > MaxKeySizeBytes {
+		return "", fmt.Errorf("%w: must be at most %d bytes, found %d", ErrKeyTooLarge, MaxKeySizeBytes, len(b))
+	}
+	return BytesKey(b), nil
+}
+
+// ValidateKey checks that the key could have been constructed by its constructor, i.e., that it is non-nil and, if
+// it is a BytesKey, that it passes the checks of NewBytesKey.
+// Returns an error wrapping ErrEmptyKey or ErrKeyTooLarge if the key is invalid.
+func ValidateKey(k Key) error {
+	if k == nil {
+		return ErrEmptyKey
+	}
+	if bk, ok := k.(BytesKey); ok {
+		if _, err := NewBytesKey([]byte(bk)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KeyBytes returns the byte representation of the key.
+func (k BytesKey) KeyBytes() []byte {
+	return []byte(k)
+}
+
+// String converts BytesKey to its hex representation.
+func (k BytesKey) String() string {
+	return hex.EncodeToString([]byte(k))
+}
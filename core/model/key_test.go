@@ -0,0 +1,105 @@
+package model_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/unittest"
+)
+
+// TestCompareKeys_BytesKey tests the lexicographic order of variable-length keys.
+func TestCompareKeys_BytesKey(t *testing.T) {
+	ordered := []model.BytesKey{
+		"a",
+		"tenant-1/",
+		"tenant-1/a",
+		"tenant-1/a/b",
+		"tenant-1/b",
+		"tenant-2",
+		"tenant-2/a",
+	}
+	for i := range ordered {
+		require.Equal(t, 0, model.CompareKeys(ordered[i], ordered[i]))
+		for j := i + 1; j < len(ordered); j++ {
+			require.Equal(t, -1, model.CompareKeys(ordered[i], ordered[j]), "%s < %s", ordered[i], ordered[j])
+			require.Equal(t, 1, model.CompareKeys(ordered[j], ordered[i]), "%s > %s", ordered[j], ordered[i])
+		}
+	}
+}
+
+// TestCompareKeys_Identifier tests that ordering identifiers as keys is consistent with Identifier.Compare.
+func TestCompareKeys_Identifier(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		id1 := unittest.IdentifierFixture(t)
+		id2 := unittest.IdentifierFixture(t)
+
+		expected := 0
+		comparison := id1.Compare(&id2)
+		switch comparison.GetComparisonResult() {
+		case model.CompareLess:
+			expected = -1
+		case model.CompareGreater:
+			expected = 1
+		}
+		require.Equal(t, expected, model.CompareKeys(id1, id2))
+		require.Equal(t, 0, model.CompareKeys(id1, id1))
+	}
+}
+
+// TestNewBytesKey tests the validation of variable-length keys.
+func TestNewBytesKey(t *testing.T) {
+	b := unittest.RandomBytesFixture(t, model.MaxKeySizeBytes)
+	key, err := model.NewBytesKey(b)
+	require.NoError(t, err)
+	require.Equal(t, b, key.KeyBytes())
+
+	// the key must not be affected by changes to the input slice
+	b[0]++
+	require.NotEqual(t, b, key.KeyBytes())
+
+	_, err = model.NewBytesKey(nil)
+	require.True(t, errors.Is(err, model.ErrEmptyKey))
+
+	_, err = model.NewBytesKey(unittest.RandomBytesFixture(t, model.MaxKeySizeBytes+1))
+	require.True(t, errors.Is(err, model.ErrKeyTooLarge))
+}
+
+// TestIdentity_GetKey tests that an identity is positioned by its identifier unless an explicit key is set.
+func TestIdentity_GetKey(t *testing.T) {
+	identity := unittest.IdentityFixture(t)
+	require.Equal(t, model.Key(identity.GetIdentifier()), identity.GetKey())
+
+	key := unittest.BytesKeyFixture(t, 64)
+	identity.SetKey(key)
+	require.Equal(t, model.Key(key), identity.GetKey())
+
+	identity.SetKey(nil)
+	require.Equal(t, model.Key(identity.GetIdentifier()), identity.GetKey())
+}
+
+// TestNewKeySearchReq tests the validation of key search requests.
+func TestNewKeySearchReq(t *testing.T) {
+	key := unittest.BytesKeyFixture(t, 64)
+	req, err := model.NewKeySearchReq(key, 3, unittest.RandomDirectionFixture(t))
+	require.NoError(t, err)
+	require.Equal(t, model.Key(key), req.Target())
+
+	_, err = model.NewKeySearchReq(nil, 3, unittest.RandomDirectionFixture(t))
+	require.True(t, errors.Is(err, model.ErrEmptyKey))
+
+	// keys built without NewBytesKey are held to the same checks
+	_, err = model.NewKeySearchReq(model.BytesKey(""), 3, unittest.RandomDirectionFixture(t))
+	require.True(t, errors.Is(err, model.ErrEmptyKey))
+
+	tooLarge := model.BytesKey(unittest.RandomBytesFixture(t, model.MaxKeySizeBytes+1))
+	_, err = model.NewKeySearchReq(tooLarge, 3, unittest.RandomDirectionFixture(t))
+	require.True(t, errors.Is(err, model.ErrKeyTooLarge))
+
+	_, err = model.NewKeySearchReq(key, -1, unittest.RandomDirectionFixture(t))
+	require.True(t, errors.Is(err, model.ErrInvalidLevel))
+
+	_, err = model.NewKeySearchReq(key, model.MembershipVectorSize*8, unittest.RandomDirectionFixture(t))
+	require.True(t, errors.Is(err, model.ErrLevelExceedsMax))
+}
@@ -25,19 +25,32 @@ type IdSearchReq struct {
 //
 // Validation rules:
 //   - level must be >= 0
-//   - level must be < IdentifierSizeBytes * 8 (MaxLookupTableLevel)
+//   - level must be < MembershipVectorSize * 8 (MaxLookupTableLevel)
 //   - direction must be either DirectionLeft or DirectionRight
 func NewIdSearchReq(target Identifier, level types.Level, direction types.Direction) (
 	IdSearchReq,
 	error,
 ) {
+	if err := validateSearchParams(level, direction); err != nil {
+		return IdSearchReq{}, err
+	}
+
+	return IdSearchReq{
+		target:    target,
+		level:     level,
+		direction: direction,
+	}, nil
+}
+
+// validateSearchParams validates the level and direction of a search request.
+func validateSearchParams(level types.Level, direction types.Direction) error {
 	// Validate level bounds
-	const maxLookupTableLevel = IdentifierSizeBytes * 8
+	const maxLookupTableLevel = MembershipVectorSize * 8
 	if level < 0 {
-		return IdSearchReq{}, fmt.Errorf("%w: got %d", ErrInvalidLevel, level)
+		return fmt.Errorf("%w: got %d", ErrInvalidLevel, level)
 	}
 	if level >= maxLookupTableLevel {
-		return IdSearchReq{}, fmt.Errorf(
+		return fmt.Errorf(
 			"%w: must be less than %d, got %d",
 			ErrLevelExceedsMax,
 			maxLookupTableLevel,
@@ -47,14 +60,10 @@ func NewIdSearchReq(target Identifier, level types.Level, direction types.Direct
 
 	// Validate direction
 	if direction != types.DirectionLeft && direction != types.DirectionRight {
-		return IdSearchReq{}, fmt.Errorf("%w: got %s", ErrInvalidDirection, direction)
+		return fmt.Errorf("%w: got %s", ErrInvalidDirection, direction)
 	}
 
-	return IdSearchReq{
-		target:    target,
-		level:     level,
-		direction: direction,
-	}, nil
+	return nil
 }
 
 // Target returns the target identifier being searched for.
@@ -115,3 +124,92 @@ func (r IdSearchRes) TerminationLevel() types.Level {
 func (r IdSearchRes) Result() Identifier {
 	return r.result
 }
+
+// KeySearchReq represents a request to search for a key in the lookup table.
+// It is the key space counterpart of IdSearchReq, used when nodes are positioned by a Key other than their Identifier.
+type KeySearchReq struct {
+	target    Key             // The target key to search for
+	level     types.Level     // Maximum level to search (inclusive, 0-indexed)
+	direction types.Direction // Search direction (Left or Right)
+}
+
+// NewKeySearchReq creates a new KeySearchReq instance with input validation.
+// Args:
+//   - target: the key to search for
+//   - level: the maximum level to search up to (inclusive)
+//   - direction: the search direction (types.DirectionLeft or types.DirectionRight)
+//
+// Returns:
+//   - KeySearchReq: the constructed search request
+//   - error: validation error if inputs are invalid
+//
+// Validation rules are the same as NewIdSearchReq, additionally the target must pass ValidateKey.
+func NewKeySearchReq(target Key, level types.Level, direction types.Direction) (KeySearchReq, error) {
+	if err := ValidateKey(target); err != nil {
+		return KeySearchReq{}, err
+	}
+	if err := validateSearchParams(level, direction); err != nil {
+		return KeySearchReq{}, err
+	}
+
+	return KeySearchReq{
+		target:    target,
+		level:     level,
+		direction: direction,
+	}, nil
+}
+
+// Target returns the target key being searched for.
+func (r KeySearchReq) Target() Key {
+	return r.target
+}
+
+// Level returns the maximum level to search up to (inclusive).
+func (r KeySearchReq) Level() types.Level {
+	return r.level
+}
+
+// Direction returns the search direction (Left or Right).
+func (r KeySearchReq) Direction() types.Direction {
+	return r.direction
+}
+
+// KeySearchRes represents the result of a key search.
+// It contains the target key, the level where the search terminated,
+// and the identity of the node found (or own identity as fallback), so that the search can be forwarded to it.
+type KeySearchRes struct {
+	target           Key         // The target key that was searched for
+	terminationLevel types.Level // The level where the search terminated
+	result           Identity    // The identity found (or own identity as fallback)
+}
+
+// NewKeySearchRes creates a new KeySearchRes instance.
+// Args:
+//   - target: the key that was searched for
+//   - terminationLevel: the level where a match was found
+//   - result: the identity of the matched node (or fallback to own identity)
+//
+// Returns:
+//   - KeySearchRes: the constructed search result
+func NewKeySearchRes(target Key, terminationLevel types.Level, result Identity) KeySearchRes {
+	return KeySearchRes{
+		target:           target,
+		terminationLevel: terminationLevel,
+		result:           result,
+	}
+}
+
+// Target returns the target key that was searched for.
+func (r KeySearchRes) Target() Key {
+	return r.target
+}
+
+// TerminationLevel returns the level where the search terminated.
+func (r KeySearchRes) TerminationLevel() types.Level {
+	return r.terminationLevel
+}
+
+// Result returns the identity of the node found (or own identity as fallback).
+func (r KeySearchRes) Result() Identity {
+	return r.result
+}
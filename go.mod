@@ -6,6 +6,8 @@ require (
 	github.com/go-playground/validator/v10 v10.25.0
 	github.com/rs/zerolog v1.34.0
 	github.com/stretchr/testify v1.8.4
+	google.golang.org/grpc v1.69.4
+	google.golang.org/protobuf v1.36.1
 )
 
 require (
@@ -22,5 +24,6 @@ require (
 	golang.org/x/net v0.34.0 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20241015192408-796eee8c2d53 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
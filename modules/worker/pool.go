@@ -129,10 +129,7 @@ func (p *Pool) stopWorkers() {
 	p.logger.Trace().
 		Msg("initiating shutdown")
 
-	p.logger.Trace().
-		Msg("Closing job queue")
-	close(p.queue)
-
+	// the queue is not closed, as Submit may still be racing with the shutdown; workers exit on the context instead.
 	p.logger.Trace().
 		Msg("Waiting for all workers to finish")
 	p.wg.Wait()
@@ -157,13 +154,7 @@ func (p *Pool) worker(ctx modules.ThrowableContext, id int) {
 				Int("worker_id", id).
 				Msg("Worker received context done signal, and is shutting down")
 			return
-		case job, ok := <-p.queue:
-			if !ok {
-				p.logger.Trace().
-					Int("worker_id", id).
-					Msg("Worker exiting - queue closed")
-				return
-			}
+		case job := <-p.queue:
 			p.logger.Trace().
 				Int("worker_id", id).
 				Msg("Worker executing job")
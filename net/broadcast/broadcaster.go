@@ -0,0 +1,298 @@
+// Package broadcast implements level-scoped broadcast on top of a net.Network channel.
+//
+// A Broadcaster fans a message out to the neighbors of the node in its lookup table at a level, either only to the
+// immediate left and right neighbors (ModeNeighbors), or to every member of the level list (ModeLevel). In the latter
+// mode, each receiver relays the message to its own neighbor at the level, in the direction the message travels, so
+// that it reaches both ends of the list. Receivers suppress duplicates by the origin and identifier of the broadcast,
+// which also bounds propagation over inconsistent lookup tables.
+package broadcast
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+	"github.com/thep2p/skipgraph-go/core"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/internal/bounded"
+)
+
+// DefaultSeenCapacity is the default number of broadcasts remembered for duplicate suppression.
+const DefaultSeenCapacity = 4096
+
+// DefaultMaxConcurrentRelays is the default number of broadcasts a broadcaster relays at a time.
+const DefaultMaxConcurrentRelays = 64
+
+// Report is the outcome of sending a broadcast to the neighbors of its origin.
+type Report struct {
+	// Targets are the neighbors the broadcast was sent to, in the order it was sent.
+	Targets []model.Identifier
+	// Failures maps the targets the broadcast could not be sent to, to the error of sending it.
+	Failures map[model.Identifier]error
+}
+
+// Err returns the join of the errors of the failed targets, or nil if the broadcast was sent to every target.
+func (r Report) Err() error {
+	errs := make([]error, 0, len(r.Failures))
+	for _, target := range r.Targets {
+		if err, ok := r.Failures[target]; ok {
+			errs = append(errs, fmt.Errorf("could not send broadcast to %s: %w", target.String(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// seenKey identifies a broadcast.
+type seenKey struct {
+	origin model.Identifier
+	id     uint64
+}
+
+// Broadcaster broadcasts messages to the neighbors of a node at a level of its lookup table, and delivers the
+// broadcasts it receives to a processor.
+// It is safe for concurrent use.
+type Broadcaster struct {
+	logger    zerolog.Logger
+	self      model.Identifier
+	channel   net.Channel
+	conduit   net.Conduit
+	table     core.ImmutableLookupTable
+	processor net.MessageProcessor
+	nextID    atomic.Uint64
+	relaying  *bounded.Group
+
+	l        sync.Mutex
+	seen     map[seenKey]struct{}
+	seenRing []seenKey // broadcasts in the order they were seen, evicted oldest first
+	seenNext int       // index of the next slot of seenRing to fill
+}
+
+var _ net.MessageProcessor = (*Broadcaster)(nil)
+var _ net.Conduit = (*Broadcaster)(nil)
+
+// Option is a functional option for configuring a Broadcaster.
+type Option func(*Broadcaster)
+
+// WithSeenCapacity sets the number of broadcasts remembered for duplicate suppression; defaults to
+// DefaultSeenCapacity. A broadcast evicted from memory is delivered again if it is received again.
+func WithSeenCapacity(capacity int) Option {
+	return func(b *Broadcaster) {
+		if capacity > 0 {
+			b.seenRing = make([]seenKey, capacity)
+		}
+	}
+}
+
+// WithMaxConcurrentRelays sets the number of broadcasts the broadcaster relays at a time; defaults to
+// DefaultMaxConcurrentRelays. The broadcasts received while the broadcaster relays that many are relayed synchronously,
+// hence processing them waits for their relay.
+func WithMaxConcurrentRelays(max int) Option {
+	return func(b *Broadcaster) {
+		b.relaying = bounded.NewGroup(max)
+	}
+}
+
+// NewBroadcaster creates a Broadcaster and registers it as the processor of the channel.
+// The envelopes of the broadcaster must be registered in the codec registry of the network (see RegisterCodec).
+// Args:
+//   - logger: zerolog.Logger for logging
+//   - self: the identifier of the node, i.e., the origin of its broadcasts
+//   - network: the network to broadcast through
+//   - channel: the channel of the broadcasts; the broadcaster is its only processor
+//   - table: the lookup table of the node, whose neighbors the broadcasts are sent to
+//   - processor: the processor the received broadcasts are delivered to, with their origin as originID
+//   - opts: variadic options for configuring the broadcaster
+//
+// Returns the broadcaster, or the error of registering it on the channel, which must be treated as fatal.
+func NewBroadcaster(
+	logger zerolog.Logger,
+	self model.Identifier,
+	network net.Network,
+	channel net.Channel,
+	table core.ImmutableLookupTable,
+	processor net.MessageProcessor,
+	opts ...Option,
+) (*Broadcaster, error) {
+	b := &Broadcaster{
+		logger: logger.With().
+			Str("component", "broadcast").
+			Str("channel", string(channel)).
+			Logger(),
+		self:      self,
+		channel:   channel,
+		table:     table,
+		processor: processor,
+		seen:      make(map[seenKey]struct{}),
+		seenRing:  make([]seenKey, DefaultSeenCapacity),
+		relaying:  bounded.NewGroup(DefaultMaxConcurrentRelays),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	// broadcast identifiers start at a random offset so that a restarted node is not mistaken for a duplicate
+	var seed [8]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return nil, fmt.Errorf("could not seed broadcast identifiers: %w", err)
+	}
+	b.nextID.Store(binary.BigEndian.Uint64(seed[:]))
+
+	conduit, err := network.Register(channel, b)
+	if err != nil {
+		return nil, fmt.Errorf("could not register broadcaster: %w", err)
+	}
+	b.conduit = conduit
+	return b, nil
+}
+
+// Broadcast sends the message to the neighbors of the node at the level, according to the mode.
+// With ModeLevel, the report only covers the immediate neighbors; the failures of relays are logged by the relaying
+// nodes.
+// Returns the report of sending the message to each neighbor, or an error:
+//   - wrapping ErrInvalidLevel if the level is outside of the lookup table.
+//   - wrapping ErrInvalidMode if the mode is unknown.
+//   - the error of reading the lookup table.
+//
+// Any returned error is benign.
+func (b *Broadcaster) Broadcast(level types.Level, mode Mode, msg net.Message) (Report, error) {
+	if level < 0 || level >= core.MaxLookupTableLevel {
+		return Report{}, fmt.Errorf("%w: %d", ErrInvalidLevel, level)
+	}
+	if mode != ModeNeighbors && mode != ModeLevel {
+		return Report{}, fmt.Errorf("%w: %s", ErrInvalidMode, mode)
+	}
+
+	env := Envelope{origin: b.self, id: b.nextID.Add(1), mode: mode, level: level, payload: msg.Payload}
+	// the broadcast may come back to its origin over inconsistent lookup tables
+	b.markSeen(seenKey{origin: b.self, id: env.id})
+
+	report := Report{Failures: make(map[model.Identifier]error)}
+	for _, dir := range []types.Direction{types.DirectionLeft, types.DirectionRight} {
+		neighbor, err := b.table.GetEntry(dir, level)
+		if err != nil {
+			return Report{}, fmt.Errorf("could not read %s neighbor at level %d: %w", dir, level, err)
+		}
+		if neighbor == nil || neighbor.GetIdentifier() == b.self || contains(report.Targets, neighbor.GetIdentifier()) {
+			continue
+		}
+
+		target := neighbor.GetIdentifier()
+		if mode == ModeLevel {
+			env.direction = dir
+		}
+		report.Targets = append(report.Targets, target)
+		if err := b.conduit.Send(target, net.Message{Payload: env}); err != nil {
+			report.Failures[target] = err
+		}
+	}
+	return report, nil
+}
+
+// Send sends the message to the target only, so that the channel of the broadcaster can also carry unicast messages.
+// Any returned error is benign.
+func (b *Broadcaster) Send(target model.Identifier, msg net.Message) error {
+	env := Envelope{origin: b.self, id: b.nextID.Add(1), mode: modeDirect, payload: msg.Payload}
+	if err := b.conduit.Send(target, net.Message{Payload: env}); err != nil {
+		return fmt.Errorf("could not send message to %s: %w", target.String(), err)
+	}
+	return nil
+}
+
+// ProcessIncomingMessage delivers the broadcasts received on the channel of the broadcaster to its processor, and
+// relays those of ModeLevel to the next neighbor in their direction.
+// Duplicates, broadcasts of the node itself and messages other than envelopes are dropped.
+func (b *Broadcaster) ProcessIncomingMessage(_ net.Channel, originID model.Identifier, msg net.Message) {
+	env, ok := msg.Payload.(Envelope)
+	if !ok {
+		b.logger.Warn().
+			Str("origin", originID.String()).
+			Str("type", fmt.Sprintf("%T", msg.Payload)).
+			Msg("Dropping message that is not a broadcast envelope")
+		return
+	}
+
+	if env.mode == modeDirect {
+		// unicast messages are not relayed, hence their sender is their origin
+		b.processor.ProcessIncomingMessage(b.channel, originID, net.Message{Payload: env.payload})
+		return
+	}
+
+	if env.origin == b.self || !b.markSeen(seenKey{origin: env.origin, id: env.id}) {
+		b.logger.Trace().
+			Str("origin", env.origin.String()).
+			Uint64("broadcast_id", env.id).
+			Msg("Dropping duplicate broadcast")
+		return
+	}
+
+	if env.mode == ModeLevel {
+		// relayed asynchronously so that processing a message never blocks on sending one, unless too many relays are
+		// in flight already, as a broadcast that is not relayed misses the rest of the level list
+		relay := func() { b.relay(originID, env) }
+		if !b.relaying.Go(relay) {
+			relay()
+		}
+	}
+	b.processor.ProcessIncomingMessage(b.channel, env.origin, net.Message{Payload: env.payload})
+}
+
+// relay sends the envelope to the neighbor of the node at its level and in its direction, unless there is none or it
+// is the node the envelope was received from.
+func (b *Broadcaster) relay(from model.Identifier, env Envelope) {
+	neighbor, err := b.table.GetEntry(env.direction, env.level)
+	if err != nil {
+		b.logger.Error().Err(err).Int64("level", int64(env.level)).Msg("Could not read lookup table to relay broadcast")
+		return
+	}
+	if neighbor == nil {
+		// end of the level list
+		return
+	}
+	target := neighbor.GetIdentifier()
+	if target == from || target == env.origin || target == b.self {
+		return
+	}
+
+	if err := b.conduit.Send(target, net.Message{Payload: env}); err != nil {
+		b.logger.Warn().
+			Err(err).
+			Str("origin", env.origin.String()).
+			Str("target", target.String()).
+			Uint64("broadcast_id", env.id).
+			Msg("Could not relay broadcast")
+	}
+}
+
+// markSeen remembers the broadcast, evicting the oldest one if the memory is full.
+// Returns false if the broadcast was already remembered.
+func (b *Broadcaster) markSeen(key seenKey) bool {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if _, ok := b.seen[key]; ok {
+		return false
+	}
+	if evicted := b.seenRing[b.seenNext]; evicted != (seenKey{}) {
+		delete(b.seen, evicted)
+	}
+	b.seenRing[b.seenNext] = key
+	b.seenNext = (b.seenNext + 1) % len(b.seenRing)
+	b.seen[key] = struct{}{}
+	return true
+}
+
+// contains returns true if id is in ids.
+func contains(ids []model.Identifier, id model.Identifier) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
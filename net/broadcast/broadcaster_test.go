@@ -0,0 +1,242 @@
+package broadcast_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/broadcast"
+	"github.com/thep2p/skipgraph-go/net/codec"
+	"github.com/thep2p/skipgraph-go/unittest"
+	"github.com/thep2p/skipgraph-go/unittest/mocknet"
+)
+
+// node is a broadcaster built upon a skip graph node.
+type node struct {
+	*mocknet.SkipGraphNode
+	broadcaster *broadcast.Broadcaster
+}
+
+// nodes creates count broadcasters connected through a mock network, sorted by identifier, with empty lookup tables.
+func nodes(t *testing.T, count int, opts ...broadcast.Option) []*node {
+	registry := codec.NewDefaultRegistry()
+	require.NoError(t, broadcast.RegisterCodec(registry))
+	stub := mocknet.NewNetworkStubWithCodecs(registry)
+
+	res := make([]*node, count)
+	for i, n := range mocknet.NewSkipGraphNodes(t, count) {
+		b, err := broadcast.NewBroadcaster(
+			unittest.Logger(zerolog.WarnLevel),
+			n.ID,
+			stub.NewMockNetwork(t, n.ID),
+			net.TestChannel,
+			n.Table,
+			n.Processor,
+			opts...,
+		)
+		require.NoError(t, err)
+		res[i] = &node{SkipGraphNode: n, broadcaster: b}
+	}
+	return res
+}
+
+// link makes the nodes, in order, a level list at the level of their lookup tables.
+func link(t *testing.T, level types.Level, list ...*node) {
+	for i := 0; i+1 < len(list); i++ {
+		mocknet.LinkPair(t, level, list[i].SkipGraphNode, list[i+1].SkipGraphNode)
+	}
+}
+
+// requireReceived requires that each node receives the message from origin exactly once, and the others none.
+func requireReceived(t *testing.T, all []*node, origin model.Identifier, msg net.Message, receivers ...*node) {
+	for _, n := range receivers {
+		select {
+		case r := <-n.Received:
+			require.Equal(t, origin, r.Origin)
+			require.Equal(t, msg.Payload, r.Msg.Payload)
+		case <-time.After(unittest.DefaultReadyDoneTimeout):
+			require.Fail(t, "broadcast not received on time")
+		}
+	}
+
+	// leaves time for duplicates and stray relays to arrive
+	time.Sleep(10 * time.Millisecond)
+	for _, n := range all {
+		require.Empty(t, n.Received, "unexpected broadcast received")
+	}
+}
+
+// TestBroadcast_Neighbors tests that a broadcast in ModeNeighbors is only delivered to the neighbors at its level.
+func TestBroadcast_Neighbors(t *testing.T) {
+	all := nodes(t, 5)
+	link(t, 0, all...)
+	link(t, 1, all[0], all[2], all[4])
+
+	msg := unittest.TestMessageFixture(t)
+	report, err := all[2].broadcaster.Broadcast(0, broadcast.ModeNeighbors, *msg)
+	require.NoError(t, err)
+	require.Equal(t, []model.Identifier{all[1].ID, all[3].ID}, report.Targets)
+	require.NoError(t, report.Err())
+	requireReceived(t, all, all[2].ID, *msg, all[1], all[3])
+
+	report, err = all[2].broadcaster.Broadcast(1, broadcast.ModeNeighbors, *msg)
+	require.NoError(t, err)
+	require.Equal(t, []model.Identifier{all[0].ID, all[4].ID}, report.Targets)
+	requireReceived(t, all, all[2].ID, *msg, all[0], all[4])
+
+	// the end of a list only has one neighbor
+	report, err = all[0].broadcaster.Broadcast(0, broadcast.ModeNeighbors, *msg)
+	require.NoError(t, err)
+	require.Equal(t, []model.Identifier{all[1].ID}, report.Targets)
+	requireReceived(t, all, all[0].ID, *msg, all[1])
+}
+
+// TestBroadcast_Level tests that a broadcast in ModeLevel is delivered once to every member of the level list.
+func TestBroadcast_Level(t *testing.T) {
+	all := nodes(t, 6)
+	link(t, 0, all...)
+	link(t, 1, all[0], all[2], all[5])
+
+	msg := unittest.TestMessageFixture(t)
+	report, err := all[2].broadcaster.Broadcast(0, broadcast.ModeLevel, *msg)
+	require.NoError(t, err)
+	require.Equal(t, []model.Identifier{all[1].ID, all[3].ID}, report.Targets)
+	requireReceived(t, all, all[2].ID, *msg, all[0], all[1], all[3], all[4], all[5])
+
+	report, err = all[2].broadcaster.Broadcast(1, broadcast.ModeLevel, *msg)
+	require.NoError(t, err)
+	require.Equal(t, []model.Identifier{all[0].ID, all[5].ID}, report.Targets)
+	requireReceived(t, all, all[2].ID, *msg, all[0], all[5])
+}
+
+// TestBroadcast_MaxConcurrentRelays tests that broadcasts in ModeLevel reach the whole level list even when they
+// arrive while the broadcasters relay their maximum number of broadcasts.
+func TestBroadcast_MaxConcurrentRelays(t *testing.T) {
+	all := nodes(t, 8, broadcast.WithMaxConcurrentRelays(1))
+	link(t, 0, all...)
+
+	msg := unittest.TestMessageFixture(t)
+	errs := make(chan error, len(all))
+	for _, n := range all {
+		go func() {
+			_, err := n.broadcaster.Broadcast(0, broadcast.ModeLevel, *msg)
+			errs <- err
+		}()
+	}
+	for range all {
+		require.NoError(t, <-errs)
+	}
+	for _, n := range all {
+		origins := make(map[model.Identifier]struct{})
+		for len(origins) < len(all)-1 {
+			select {
+			case r := <-n.Received:
+				origins[r.Origin] = struct{}{}
+			case <-time.After(unittest.DefaultReadyDoneTimeout):
+				require.Fail(t, "broadcast not received on time", "%d of %d received", len(origins), len(all)-1)
+			}
+		}
+		require.NotContains(t, origins, n.ID)
+	}
+}
+
+// TestBroadcast_DuplicateSuppression tests that a broadcast in ModeLevel is delivered once to every member of a level
+// list that is inconsistently linked as a ring, over which it would otherwise circulate forever.
+func TestBroadcast_DuplicateSuppression(t *testing.T) {
+	all := nodes(t, 4)
+	link(t, 0, all...)
+	link(t, 0, all[3], all[0])
+
+	msg := unittest.TestMessageFixture(t)
+	_, err := all[1].broadcaster.Broadcast(0, broadcast.ModeLevel, *msg)
+	require.NoError(t, err)
+	requireReceived(t, all, all[1].ID, *msg, all[0], all[2], all[3])
+
+	// a second broadcast of the same message is a distinct broadcast
+	_, err = all[1].broadcaster.Broadcast(0, broadcast.ModeLevel, *msg)
+	require.NoError(t, err)
+	requireReceived(t, all, all[1].ID, *msg, all[0], all[2], all[3])
+}
+
+// TestBroadcast_Failures tests that the report of a broadcast covers the neighbors it could not be sent to.
+func TestBroadcast_Failures(t *testing.T) {
+	all := nodes(t, 2)
+	link(t, 0, all...)
+	unreachable := model.NewIdentity(unittest.IdentifierFixture(t), unittest.MembershipVectorFixture(t), unittest.AddressFixture(t))
+	require.NoError(t, all[0].Table.AddEntry(types.DirectionLeft, 0, unreachable))
+
+	msg := unittest.TestMessageFixture(t)
+	report, err := all[0].broadcaster.Broadcast(0, broadcast.ModeNeighbors, *msg)
+	require.NoError(t, err)
+	require.Equal(t, []model.Identifier{unreachable.GetIdentifier(), all[1].ID}, report.Targets)
+	require.Len(t, report.Failures, 1)
+	require.Contains(t, report.Failures, unreachable.GetIdentifier())
+	require.Error(t, report.Err())
+	requireReceived(t, all, all[0].ID, *msg, all[1])
+
+	// payloads that cannot be encoded fail for every target
+	report, err = all[0].broadcaster.Broadcast(0, broadcast.ModeNeighbors, net.Message{Payload: 42})
+	require.NoError(t, err)
+	require.Len(t, report.Failures, 2)
+	require.True(t, errors.Is(report.Failures[all[1].ID], codec.ErrUnknownType))
+}
+
+// TestBroadcast_InvalidArguments tests that broadcasts at levels outside of the lookup table, or with unknown modes,
+// are rejected.
+func TestBroadcast_InvalidArguments(t *testing.T) {
+	all := nodes(t, 1)
+	msg := unittest.TestMessageFixture(t)
+
+	for _, level := range []types.Level{-1, 256, 1 << 20} {
+		_, err := all[0].broadcaster.Broadcast(level, broadcast.ModeLevel, *msg)
+		require.True(t, errors.Is(err, broadcast.ErrInvalidLevel))
+	}
+	_, err := all[0].broadcaster.Broadcast(0, broadcast.Mode(9), *msg)
+	require.True(t, errors.Is(err, broadcast.ErrInvalidMode))
+
+	// without neighbors, nothing is sent
+	report, err := all[0].broadcaster.Broadcast(0, broadcast.ModeLevel, *msg)
+	require.NoError(t, err)
+	require.Empty(t, report.Targets)
+	require.NoError(t, report.Err())
+}
+
+// TestBroadcaster_Send tests that unicast messages are delivered to their target only, with their sender as origin.
+func TestBroadcaster_Send(t *testing.T) {
+	all := nodes(t, 3)
+	link(t, 0, all...)
+
+	msg := unittest.TestMessageFixture(t)
+	require.NoError(t, all[0].broadcaster.Send(all[2].ID, *msg))
+	requireReceived(t, all, all[0].ID, *msg, all[2])
+}
+
+// TestEnvelope_Malformed tests that malformed envelopes are rejected by the codec registry.
+func TestEnvelope_Malformed(t *testing.T) {
+	registry := codec.NewDefaultRegistry()
+	require.NoError(t, broadcast.RegisterCodec(registry))
+
+	header := func(mode, levelHi, direction byte) []byte {
+		b := binary.BigEndian.AppendUint16(nil, uint16(broadcast.CodeEnvelope))
+		b = append(b, make([]byte, model.IdentifierSizeBytes+8)...)
+		b = append(b, mode, levelHi, 0, direction)
+		return binary.BigEndian.AppendUint16(b, uint16(codec.CodeBytes))
+	}
+	malformed := [][]byte{
+		header(1, 0, 0)[:20], // too short
+		header(9, 0, 0),      // unknown mode
+		header(1, 0, 1),      // direction without ModeLevel
+		header(2, 0, 0),      // ModeLevel without direction
+		header(2, 1, 1),      // level out of the lookup table
+	}
+	for _, b := range malformed {
+		_, err := registry.Decode(b)
+		require.True(t, errors.Is(err, broadcast.ErrMalformedEnvelope), "unexpected error %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package broadcast
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/thep2p/skipgraph-go/core"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+	"github.com/thep2p/skipgraph-go/net/codec"
+)
+
+// CodeEnvelope is the codec type code of Envelope.
+const CodeEnvelope codec.Code = 0x300
+
+// envelopeHeaderSize is the size in bytes of the fixed part of an encoded envelope.
+const envelopeHeaderSize = model.IdentifierSizeBytes + 8 + 1 + 2 + 1
+
+// Mode determines the nodes a broadcast reaches.
+type Mode uint8
+
+const (
+	// ModeNeighbors delivers the message to the left and right neighbors of the origin at the level.
+	ModeNeighbors Mode = 1
+	// ModeLevel delivers the message to every member of the level list of the origin, by relaying it from neighbor to
+	// neighbor in both directions.
+	ModeLevel Mode = 2
+	// modeDirect delivers the message to a single target, see Broadcaster.Send.
+	modeDirect Mode = 3
+)
+
+// String returns the name of the mode.
+func (m Mode) String() string {
+	switch m {
+	case ModeNeighbors:
+		return "neighbors"
+	case ModeLevel:
+		return "level"
+	case modeDirect:
+		return "direct"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(m))
+	}
+}
+
+// wire values of the relay direction of an envelope.
+const (
+	directionNone  uint8 = 0
+	directionLeft  uint8 = 1
+	directionRight uint8 = 2
+)
+
+// Envelope wraps a broadcast message with its origin and the scope of its propagation.
+// Envelopes are created by Broadcaster; they must be registered in the codec registry of the network with
+// RegisterCodec.
+//
+// Wire layout: origin (32) | id (8) | mode (1) | level (2) | direction (1) | payload (encoded by the registry).
+type Envelope struct {
+	origin    model.Identifier // the node that broadcast the message
+	id        uint64           // identifies the broadcast among those of its origin
+	mode      Mode             // the scope of the broadcast
+	level     types.Level      // the level of the broadcast
+	direction types.Direction  // the direction the envelope is relayed in, only set for ModeLevel
+	payload   any              // the broadcast message
+}
+
+// RegisterCodec registers Envelope in the registry; the payloads of envelopes are encoded by the same registry.
+// Returns an error wrapping codec.ErrCodeRegistered or codec.ErrTypeRegistered if it is already registered.
+func RegisterCodec(r *codec.Registry) error {
+	return codec.Register(
+		r, CodeEnvelope,
+		func(e Envelope) ([]byte, error) {
+			return encodeEnvelope(r, e)
+		},
+		func(b []byte) (Envelope, error) {
+			return decodeEnvelope(r, b)
+		},
+	)
+}
+
+// encodeEnvelope returns the encoding of the envelope, with its payload encoded by the registry.
+func encodeEnvelope(r *codec.Registry, e Envelope) ([]byte, error) {
+	b := make([]byte, 0, envelopeHeaderSize)
+	b = append(b, e.origin[:]...)
+	b = binary.BigEndian.AppendUint64(b, e.id)
+	b = append(b, byte(e.mode))
+	b = binary.BigEndian.AppendUint16(b, uint16(e.level))
+	switch e.direction {
+	case types.DirectionLeft:
+		b = append(b, directionLeft)
+	case types.DirectionRight:
+		b = append(b, directionRight)
+	default:
+		b = append(b, directionNone)
+	}
+
+	payload, err := r.Encode(e.payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode payload of broadcast %d: %w", e.id, err)
+	}
+	return append(b, payload...), nil
+}
+
+// decodeEnvelope returns the envelope encoded in b, with its payload decoded by the registry.
+// Returns an error wrapping ErrMalformedEnvelope if b is malformed, or the decoding error of the payload.
+func decodeEnvelope(r *codec.Registry, b []byte) (Envelope, error) {
+	if len(b) < envelopeHeaderSize {
+		return Envelope{}, fmt.Errorf("%w: envelope of %d bytes is too short", ErrMalformedEnvelope, len(b))
+	}
+	e := Envelope{}
+	copy(e.origin[:], b)
+	b = b[model.IdentifierSizeBytes:]
+	e.id = binary.BigEndian.Uint64(b)
+	e.mode = Mode(b[8])
+	e.level = types.Level(binary.BigEndian.Uint16(b[9:]))
+	direction := b[11]
+	b = b[12:]
+
+	switch {
+	case e.mode == ModeLevel && direction == directionLeft:
+		e.direction = types.DirectionLeft
+	case e.mode == ModeLevel && direction == directionRight:
+		e.direction = types.DirectionRight
+	case (e.mode == ModeNeighbors || e.mode == modeDirect) && direction == directionNone:
+	default:
+		return Envelope{}, fmt.Errorf("%w: invalid direction %d for mode %s", ErrMalformedEnvelope, direction, e.mode)
+	}
+	if e.level >= core.MaxLookupTableLevel {
+		return Envelope{}, fmt.Errorf("%w: invalid level %d", ErrMalformedEnvelope, e.level)
+	}
+
+	payload, err := r.Decode(b)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("could not decode payload of broadcast %d: %w", e.id, err)
+	}
+	e.payload = payload
+	return e, nil
+}
@@ -0,0 +1,12 @@
+package broadcast
+
+import "errors"
+
+// ErrInvalidLevel is returned when broadcasting at a level outside of the lookup table.
+var ErrInvalidLevel = errors.New("invalid broadcast level")
+
+// ErrInvalidMode is returned when broadcasting with an unknown mode.
+var ErrInvalidMode = errors.New("invalid broadcast mode")
+
+// ErrMalformedEnvelope is returned when a received envelope cannot be decoded.
+var ErrMalformedEnvelope = errors.New("malformed broadcast envelope")
@@ -0,0 +1,121 @@
+// Package codec serializes the payloads of net.Message so that they can cross a real wire.
+//
+// Every payload type is registered in a Registry under a type code along with its encoder and decoder. An encoded
+// payload is its type code (2 bytes, big-endian) followed by the output of its encoder; decoding dispatches on the type
+// code, so that processors receive payloads of the same type they were sent with.
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Code identifies the type of an encoded payload.
+// Codes are part of the wire format and must never be reused for a different type.
+type Code uint16
+
+// codeSize is the size of the type code preceding every encoded payload.
+const codeSize = 2
+
+// codec encodes and decodes the payloads of a registered type.
+type codec struct {
+	typ    reflect.Type
+	encode func(payload any) ([]byte, error)
+	decode func(b []byte) (any, error)
+}
+
+// Registry maps payload types to their type codes, encoders and decoders.
+// It is safe for concurrent use.
+type Registry struct {
+	l      sync.RWMutex
+	byCode map[Code]codec
+	byType map[reflect.Type]Code
+}
+
+// NewRegistry creates an empty Registry; see NewDefaultRegistry for a registry of the types of this module.
+func NewRegistry() *Registry {
+	return &Registry{
+		byCode: make(map[Code]codec),
+		byType: make(map[reflect.Type]Code),
+	}
+}
+
+// Register registers the payload type T under the code.
+// Args:
+//   - r: the registry to register T in
+//   - code: the type code of T on the wire
+//   - encode: returns the encoding of a payload of type T
+//   - decode: returns the payload of type T encoded in b; it must validate b, which comes from untrusted peers
+//
+// Returns an error wrapping ErrCodeRegistered or ErrTypeRegistered if the code or T is already registered.
+func Register[T any](r *Registry, code Code, encode func(T) ([]byte, error), decode func([]byte) (T, error)) error {
+	typ := reflect.TypeFor[T]()
+
+	r.l.Lock()
+	defer r.l.Unlock()
+	if existing, ok := r.byCode[code]; ok {
+		return fmt.Errorf("%w: %d is registered for %s", ErrCodeRegistered, code, existing.typ)
+	}
+	if existing, ok := r.byType[typ]; ok {
+		return fmt.Errorf("%w: %s is registered under %d", ErrTypeRegistered, typ, existing)
+	}
+
+	r.byCode[code] = codec{
+		typ: typ,
+		encode: func(payload any) ([]byte, error) {
+			return encode(payload.(T))
+		},
+		decode: func(b []byte) (any, error) {
+			return decode(b)
+		},
+	}
+	r.byType[typ] = code
+	return nil
+}
+
+// Encode returns the type code of the payload followed by its encoding.
+// Returns an error wrapping ErrUnknownType if the type of the payload is not registered, or the encoder error.
+func (r *Registry) Encode(payload any) ([]byte, error) {
+	typ := reflect.TypeOf(payload)
+
+	r.l.RLock()
+	code, ok := r.byType[typ]
+	c := r.byCode[code]
+	r.l.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownType, typ)
+	}
+
+	body, err := c.encode(payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode %s: %w", typ, err)
+	}
+	b := make([]byte, codeSize, codeSize+len(body))
+	binary.BigEndian.PutUint16(b, uint16(code))
+	return append(b, body...), nil
+}
+
+// Decode returns the payload encoded in b by Encode, with the type it was registered with.
+// Returns an error wrapping ErrMalformedPayload if b is too short, ErrUnknownCode if the type code is not registered,
+// or the decoder error. Any returned error is benign and indicates a faulty or malicious sender.
+func (r *Registry) Decode(b []byte) (any, error) {
+	if len(b) < codeSize {
+		return nil, fmt.Errorf("%w: payload of %d bytes is shorter than its type code", ErrMalformedPayload, len(b))
+	}
+	code := Code(binary.BigEndian.Uint16(b))
+
+	r.l.RLock()
+	c, ok := r.byCode[code]
+	r.l.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownCode, code)
+	}
+
+	payload, err := c.decode(b[codeSize:])
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s: %w", c.typ, err)
+	}
+	return payload, nil
+}
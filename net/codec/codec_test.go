@@ -0,0 +1,115 @@
+package codec_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/types"
+	"github.com/thep2p/skipgraph-go/net/codec"
+	"github.com/thep2p/skipgraph-go/net/protocol"
+	"github.com/thep2p/skipgraph-go/unittest"
+)
+
+// counter is a payload type registered by the tests.
+type counter struct {
+	value uint64
+}
+
+const codeCounter codec.Code = 0x8000
+
+// registerCounter registers the counter type in the registry.
+func registerCounter(r *codec.Registry) error {
+	return codec.Register(
+		r, codeCounter,
+		func(c counter) ([]byte, error) {
+			return binary.BigEndian.AppendUint64(nil, c.value), nil
+		},
+		func(b []byte) (counter, error) {
+			if len(b) != 8 {
+				return counter{}, fmt.Errorf("expected 8 bytes, got %d", len(b))
+			}
+			return counter{value: binary.BigEndian.Uint64(b)}, nil
+		},
+	)
+}
+
+// TestRegistry_RoundTrip tests that registered payloads are decoded with the type they were encoded with.
+func TestRegistry_RoundTrip(t *testing.T) {
+	r := codec.NewDefaultRegistry()
+	require.NoError(t, registerCounter(r))
+
+	query, err := protocol.NewNeighborQuery(1, 2, types.DirectionRight)
+	require.NoError(t, err)
+	payloads := []any{
+		unittest.RandomBytesFixture(t, 100),
+		query,
+		protocol.NewPing(42),
+		counter{value: 7},
+	}
+	for _, payload := range payloads {
+		b, err := r.Encode(payload)
+		require.NoError(t, err)
+		decoded, err := r.Decode(b)
+		require.NoError(t, err)
+		require.Equal(t, payload, decoded)
+		require.IsType(t, payload, decoded)
+	}
+
+	// the type code prefixes the encoding
+	b, err := r.Encode(counter{value: 1})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x80, 0x00, 0, 0, 0, 0, 0, 0, 0, 1}, b)
+}
+
+// TestRegistry_Unknown tests that unregistered types and codes are rejected.
+func TestRegistry_Unknown(t *testing.T) {
+	r := codec.NewDefaultRegistry()
+
+	_, err := r.Encode(counter{value: 1})
+	require.True(t, errors.Is(err, codec.ErrUnknownType))
+	_, err = r.Encode(nil)
+	require.True(t, errors.Is(err, codec.ErrUnknownType))
+
+	_, err = r.Decode([]byte{0x80, 0x00, 0, 0, 0, 0, 0, 0, 0, 1})
+	require.True(t, errors.Is(err, codec.ErrUnknownCode))
+	_, err = r.Decode([]byte{0x01})
+	require.True(t, errors.Is(err, codec.ErrMalformedPayload))
+}
+
+// TestRegistry_DecodeErrors tests that decoder errors are returned rather than malformed payloads.
+func TestRegistry_DecodeErrors(t *testing.T) {
+	r := codec.NewDefaultRegistry()
+	require.NoError(t, registerCounter(r))
+
+	_, err := r.Decode([]byte{0x80, 0x00, 1})
+	require.Error(t, err)
+
+	// a protocol message registered under the code of another protocol message
+	b, err := r.Encode(protocol.NewPing(1))
+	require.NoError(t, err)
+	binary.BigEndian.PutUint16(b, uint16(codec.CodeProtocolBase+codec.Code(protocol.TypePong)))
+	_, err = r.Decode(b)
+	require.True(t, errors.Is(err, codec.ErrMalformedPayload))
+}
+
+// TestRegister_Duplicates tests that codes and types can only be registered once.
+func TestRegister_Duplicates(t *testing.T) {
+	r := codec.NewDefaultRegistry()
+
+	err := codec.Register(
+		r, codec.CodeBytes,
+		func(c counter) ([]byte, error) { return nil, nil },
+		func(b []byte) (counter, error) { return counter{}, nil },
+	)
+	require.True(t, errors.Is(err, codec.ErrCodeRegistered))
+
+	err = codec.Register(
+		r, codeCounter,
+		func(b []byte) ([]byte, error) { return b, nil },
+		func(b []byte) ([]byte, error) { return b, nil },
+	)
+	require.True(t, errors.Is(err, codec.ErrTypeRegistered))
+}
@@ -0,0 +1,82 @@
+package codec
+
+import (
+	"fmt"
+
+	"github.com/thep2p/skipgraph-go/net/protocol"
+)
+
+const (
+	// CodeBytes is the type code of raw []byte payloads.
+	CodeBytes Code = 1
+	// CodeProtocolBase is the type code offset of the messages of the protocol catalogue: a protocol message of type t
+	// is registered under CodeProtocolBase + Code(t).
+	CodeProtocolBase Code = 0x100
+)
+
+// NewDefaultRegistry creates a Registry with the payload types of this module registered: raw []byte payloads and
+// every message of the protocol catalogue.
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	if err := registerDefaults(r); err != nil {
+		// the defaults are registered in an empty registry, hence their codes and types cannot collide.
+		panic(fmt.Sprintf("could not register default codecs: %v", err))
+	}
+	return r
+}
+
+// registerDefaults registers the payload types of this module in r.
+func registerDefaults(r *Registry) error {
+	err := Register(
+		r, CodeBytes,
+		func(b []byte) ([]byte, error) {
+			return b, nil
+		},
+		func(b []byte) ([]byte, error) {
+			return b, nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, register := range []func(*Registry) error{
+		registerProtocol[protocol.SearchRequest],
+		registerProtocol[protocol.SearchReply],
+		registerProtocol[protocol.LinkRequest],
+		registerProtocol[protocol.LinkAck],
+		registerProtocol[protocol.Unlink],
+		registerProtocol[protocol.NeighborQuery],
+		registerProtocol[protocol.NeighborReply],
+		registerProtocol[protocol.Ping],
+		registerProtocol[protocol.Pong],
+		registerProtocol[protocol.Error],
+	} {
+		if err := register(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registerProtocol registers the protocol message type T, encoded with the stable protocol encoding.
+func registerProtocol[T protocol.Message](r *Registry) error {
+	var zero T
+	return Register(
+		r, CodeProtocolBase+Code(zero.Type()),
+		func(msg T) ([]byte, error) {
+			return protocol.Encode(msg), nil
+		},
+		func(b []byte) (T, error) {
+			msg, err := protocol.Decode(b)
+			if err != nil {
+				return zero, err
+			}
+			typed, ok := msg.(T)
+			if !ok {
+				return zero, fmt.Errorf("%w: expected %s, got %s", ErrMalformedPayload, zero.Type(), msg.Type())
+			}
+			return typed, nil
+		},
+	)
+}
@@ -0,0 +1,18 @@
+package codec
+
+import "errors"
+
+// ErrUnknownType is returned when encoding a payload whose type is not registered.
+var ErrUnknownType = errors.New("payload type not registered")
+
+// ErrUnknownCode is returned when decoding a payload whose type code is not registered.
+var ErrUnknownCode = errors.New("payload type code not registered")
+
+// ErrCodeRegistered is returned when registering a type code that is already registered.
+var ErrCodeRegistered = errors.New("type code already registered")
+
+// ErrTypeRegistered is returned when registering a type that is already registered.
+var ErrTypeRegistered = errors.New("type already registered")
+
+// ErrMalformedPayload is returned when an encoded payload is too short to carry a type code.
+var ErrMalformedPayload = errors.New("malformed payload")
@@ -0,0 +1,350 @@
+// Package dispatch implements the asynchronous dispatch of inbound messages to their net.MessageProcessor.
+//
+// A Dispatcher queues the messages of each channel in a bounded queue of its own, and drains the queues through a
+// worker.Pool, one worker per channel at a time, so that the messages of a channel are processed in the order they
+// were dispatched while channels are processed concurrently. Each priority class of channels has a pool of its own,
+// so that the channels of a class never wait for the workers of another, e.g., failure detection never waits behind
+// bulk transfers. When the queue of a channel is full, the message is dropped or the dispatch blocks until there is
+// room, depending on the policy of the channel. A processor that panics is logged, and the dispatcher moves on to the
+// next message.
+package dispatch
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/modules"
+	"github.com/thep2p/skipgraph-go/modules/component"
+	"github.com/thep2p/skipgraph-go/modules/worker"
+	"github.com/thep2p/skipgraph-go/net"
+)
+
+// DefaultQueueCapacity is the default capacity of the queue of each channel.
+const DefaultQueueCapacity = 1024
+
+// DefaultWorkerCount is the default number of workers processing messages of channels of normal priority.
+const DefaultWorkerCount = 8
+
+// DefaultPriorityWorkerCount is the default number of workers processing messages of channels of high or low priority.
+const DefaultPriorityWorkerCount = 2
+
+// DefaultPolicy is the default policy of channels whose queue is full.
+const DefaultPolicy = PolicyDrop
+
+// maxChannels bounds the number of channels waiting for a worker; beyond it, channels are drained by the workers
+// already draining other channels, or by the goroutines dispatching to them.
+const maxChannels = 1024
+
+// drainBatch is the number of messages of a channel processed before yielding the worker to other channels.
+const drainBatch = 64
+
+// Policy determines what happens to a message dispatched for a channel whose queue is full.
+type Policy uint8
+
+const (
+	// PolicyDrop drops the message, and Dispatch returns ErrQueueFull.
+	PolicyDrop Policy = 1
+	// PolicyBlock blocks Dispatch until there is room in the queue, which pushes back on the sender, e.g., through the
+	// flow control of its connection. A processor must not send to its own node on a channel with this policy, as it
+	// may wait on itself.
+	PolicyBlock Policy = 2
+)
+
+// String returns the name of the policy.
+func (p Policy) String() string {
+	switch p {
+	case PolicyDrop:
+		return "drop"
+	case PolicyBlock:
+		return "block"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(p))
+	}
+}
+
+// Stats is a snapshot of the counters of a Dispatcher.
+type Stats struct {
+	Dispatched uint64 // messages queued for processing
+	Processed  uint64 // messages whose processing is over, including those that panicked
+	Dropped    uint64 // messages dropped as the queue of their channel was full
+	Panics     uint64 // messages whose processor panicked
+}
+
+// channelConfig is the queue configuration of a channel.
+type channelConfig struct {
+	capacity int
+	policy   Policy
+}
+
+// delivery is a message queued for its processor.
+type delivery struct {
+	processor net.MessageProcessor
+	origin    model.Identifier
+	msg       net.Message
+}
+
+// queue is the queue of a channel.
+type queue struct {
+	channel    net.Channel
+	policy     Policy
+	pool       *worker.Pool // of the priority class of the channel
+	deliveries chan delivery
+	scheduled  atomic.Bool // true while a job is draining the queue or is submitted to do so
+}
+
+// Dispatcher dispatches inbound messages to their processor asynchronously.
+// It is safe for concurrent use.
+type Dispatcher struct {
+	*component.Manager
+	logger     zerolog.Logger
+	pools      map[net.Priority]*worker.Pool
+	defaults   channelConfig
+	channels   map[net.Channel]channelConfig
+	priorities map[net.Channel]net.Priority
+
+	dispatched atomic.Uint64
+	processed  atomic.Uint64
+	dropped    atomic.Uint64
+	panics     atomic.Uint64
+
+	l      sync.Mutex
+	ctx    modules.ThrowableContext
+	queues map[net.Channel]*queue
+}
+
+// Option is a functional option for configuring a Dispatcher.
+type Option func(*Dispatcher)
+
+// WithQueueCapacity sets the capacity of the queue of the channels without a configuration of their own; defaults to
+// DefaultQueueCapacity.
+func WithQueueCapacity(capacity int) Option {
+	return func(d *Dispatcher) {
+		d.defaults.capacity = capacity
+	}
+}
+
+// WithPolicy sets the policy of the channels without a configuration of their own; defaults to DefaultPolicy.
+func WithPolicy(policy Policy) Option {
+	return func(d *Dispatcher) {
+		d.defaults.policy = policy
+	}
+}
+
+// WithChannel sets the capacity of the queue of the channel, and its policy.
+func WithChannel(channel net.Channel, capacity int, policy Policy) Option {
+	return func(d *Dispatcher) {
+		d.channels[channel] = channelConfig{capacity: capacity, policy: policy}
+	}
+}
+
+// WithWorkerCount sets the number of workers processing messages of channels of normal priority; defaults to
+// DefaultWorkerCount.
+func WithWorkerCount(count int) Option {
+	return WithPriorityWorkerCount(net.PriorityNormal, count)
+}
+
+// WithPriorityWorkerCount sets the number of workers processing messages of channels of the priority class; defaults to
+// DefaultWorkerCount for normal priority, and DefaultPriorityWorkerCount otherwise.
+func WithPriorityWorkerCount(priority net.Priority, count int) Option {
+	return func(d *Dispatcher) {
+		if _, ok := d.pools[priority]; ok {
+			d.pools[priority] = worker.NewWorkerPool(d.logger, maxChannels, count)
+		}
+	}
+}
+
+// WithChannelPriority sets the priority class of the channel; channels are of normal priority by default.
+func WithChannelPriority(channel net.Channel, priority net.Priority) Option {
+	return func(d *Dispatcher) {
+		d.priorities[channel] = priority
+	}
+}
+
+// NewDispatcher creates a Dispatcher.
+// Args:
+//   - logger: zerolog.Logger for logging
+//   - opts: variadic options for configuring the dispatcher
+//
+// Returns initialized dispatcher (not started).
+func NewDispatcher(logger zerolog.Logger, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		logger:     logger.With().Str("component", "dispatcher").Logger(),
+		pools:      make(map[net.Priority]*worker.Pool, len(net.Priorities)),
+		defaults:   channelConfig{capacity: DefaultQueueCapacity, policy: DefaultPolicy},
+		channels:   make(map[net.Channel]channelConfig),
+		priorities: make(map[net.Channel]net.Priority),
+		queues:     make(map[net.Channel]*queue),
+	}
+	for _, priority := range net.Priorities {
+		count := DefaultPriorityWorkerCount
+		if priority == net.PriorityNormal {
+			count = DefaultWorkerCount
+		}
+		d.pools[priority] = worker.NewWorkerPool(d.logger.With().Str("priority", priority.String()).Logger(), maxChannels, count)
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	d.Manager = component.NewManager(
+		d.logger,
+		component.WithStartupLogic(
+			func(ctx modules.ThrowableContext) {
+				// the pools are started synchronously, so that they accept jobs as soon as the dispatcher does
+				for _, pool := range d.pools {
+					pool.Start(ctx)
+				}
+				d.l.Lock()
+				d.ctx = ctx
+				d.l.Unlock()
+			},
+		),
+		component.WithShutdownLogic(
+			func() {
+				for _, pool := range d.pools {
+					<-pool.Done()
+				}
+				d.logger.Debug().Msg("Dispatcher stopped, pending messages discarded")
+			},
+		),
+	)
+	return d
+}
+
+// Dispatch queues the message for processing by the processor of its channel.
+// Returns an error:
+//   - wrapping ErrQueueFull if the message is dropped as the queue of the channel is full.
+//   - wrapping ErrDispatcherNotRunning if the dispatcher is not started or is shutting down.
+//
+// Any returned error is benign.
+func (d *Dispatcher) Dispatch(channel net.Channel, processor net.MessageProcessor, origin model.Identifier, msg net.Message) error {
+	d.l.Lock()
+	ctx := d.ctx
+	if ctx == nil || ctx.Err() != nil {
+		d.l.Unlock()
+		return ErrDispatcherNotRunning
+	}
+	q, ok := d.queues[channel]
+	if !ok {
+		config, ok := d.channels[channel]
+		if !ok {
+			config = d.defaults
+		}
+		priority, ok := d.priorities[channel]
+		if _, known := d.pools[priority]; !ok || !known {
+			priority = net.PriorityNormal
+		}
+		q = &queue{
+			channel:    channel,
+			policy:     config.policy,
+			pool:       d.pools[priority],
+			deliveries: make(chan delivery, config.capacity),
+		}
+		d.queues[channel] = q
+	}
+	d.l.Unlock()
+
+	dl := delivery{processor: processor, origin: origin, msg: msg}
+	switch q.policy {
+	case PolicyBlock:
+		select {
+		case q.deliveries <- dl:
+		case <-ctx.Done():
+			return ErrDispatcherNotRunning
+		}
+	default:
+		select {
+		case q.deliveries <- dl:
+		default:
+			d.dropped.Add(1)
+			return fmt.Errorf("%w: %s", ErrQueueFull, channel)
+		}
+	}
+	d.dispatched.Add(1)
+	d.schedule(ctx, q)
+	return nil
+}
+
+// Stats returns a snapshot of the counters of the dispatcher.
+func (d *Dispatcher) Stats() Stats {
+	return Stats{
+		Dispatched: d.dispatched.Load(),
+		Processed:  d.processed.Load(),
+		Dropped:    d.dropped.Load(),
+		Panics:     d.panics.Load(),
+	}
+}
+
+// schedule submits a job draining the queue to the pool, unless one is already draining it.
+// If the pool does not accept the job, the queue is drained by the calling goroutine instead, so that a queue holding
+// messages is never left without a job draining it.
+func (d *Dispatcher) schedule(ctx modules.ThrowableContext, q *queue) {
+	if !q.scheduled.CompareAndSwap(false, true) {
+		return
+	}
+	job := &drainJob{dispatcher: d, queue: q}
+	if err := q.pool.Submit(job); err != nil {
+		d.logger.Debug().
+			Err(err).
+			Str("channel", string(q.channel)).
+			Msg("Could not schedule processing of channel, processing it inline")
+		job.Execute(ctx)
+	}
+}
+
+// drainJob processes the messages of a queue.
+type drainJob struct {
+	dispatcher *Dispatcher
+	queue      *queue
+}
+
+var _ modules.Job = (*drainJob)(nil)
+
+// Execute processes the queued messages of the channel in order, until the queue is empty or the dispatcher shuts
+// down. After a batch of messages, the worker is yielded to other channels if the pool accepts a job for the rest.
+func (j *drainJob) Execute(ctx modules.ThrowableContext) {
+	d, q := j.dispatcher, j.queue
+	for ctx.Err() == nil {
+		empty := false
+		for i := 0; i < drainBatch && !empty; i++ {
+			select {
+			case dl := <-q.deliveries:
+				d.process(q.channel, dl)
+			default:
+				empty = true
+			}
+		}
+
+		if !empty {
+			if q.pool.Submit(j) == nil {
+				return
+			}
+			continue
+		}
+
+		q.scheduled.Store(false)
+		// a message may have been queued after the queue was found empty, but before it was marked as not scheduled
+		if len(q.deliveries) == 0 || !q.scheduled.CompareAndSwap(false, true) {
+			return
+		}
+	}
+}
+
+// process passes the message to its processor, recovering the processor from a panic.
+func (d *Dispatcher) process(channel net.Channel, dl delivery) {
+	defer func() {
+		if r := recover(); r != nil {
+			d.panics.Add(1)
+			d.logger.Error().
+				Str("channel", string(channel)).
+				Str("origin", dl.origin.String()).
+				Interface("panic", r).
+				Msg("Message processor panicked, message dropped")
+		}
+		d.processed.Add(1)
+	}()
+	dl.processor.ProcessIncomingMessage(channel, dl.origin, dl.msg)
+}
@@ -0,0 +1,266 @@
+package dispatch_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/dispatch"
+	"github.com/thep2p/skipgraph-go/unittest"
+	"github.com/thep2p/skipgraph-go/unittest/mocknet"
+)
+
+const otherChannel = net.Channel("channel-other")
+
+// startDispatcher creates and starts a dispatcher, which is stopped when the test ends.
+func startDispatcher(t *testing.T, opts ...dispatch.Option) *dispatch.Dispatcher {
+	ctx := unittest.NewMockThrowableContext(t)
+	d := dispatch.NewDispatcher(unittest.Logger(zerolog.Disabled), opts...)
+	d.Start(ctx)
+	unittest.RequireAllReady(t, d)
+	t.Cleanup(
+		func() {
+			ctx.Cancel()
+			unittest.RequireAllDone(t, d)
+		},
+	)
+	return d
+}
+
+// blockingProcessor returns a processor that forwards the payload of every message to the returned channel, and then
+// blocks until release is closed.
+func blockingProcessor(release <-chan struct{}) (net.MessageProcessor, <-chan any) {
+	ch := make(chan any, 100)
+	return mocknet.NewMockMessageProcessor(
+		func(_ net.Channel, _ model.Identifier, msg net.Message) {
+			ch <- msg.Payload
+			<-release
+		},
+	), ch
+}
+
+// mustReceive waits for the next payload on ch and returns it.
+func mustReceive(t *testing.T, ch <-chan any) any {
+	select {
+	case p := <-ch:
+		return p
+	case <-time.After(unittest.DefaultReadyDoneTimeout):
+		require.Fail(t, "message not processed on time")
+		return nil
+	}
+}
+
+// TestDispatcher_Order tests that the messages of a channel are processed in order, and that a blocked channel does
+// not hold back other channels.
+func TestDispatcher_Order(t *testing.T) {
+	d := startDispatcher(t)
+	origin := unittest.IdentifierFixture(t)
+
+	release := make(chan struct{})
+	blocked, _ := blockingProcessor(release)
+	defer close(release)
+	require.NoError(t, d.Dispatch(otherChannel, blocked, origin, net.Message{Payload: -1}))
+
+	open := make(chan struct{})
+	close(open)
+	p, ch := blockingProcessor(open)
+	const count = 200
+	for i := 0; i < count; i++ {
+		require.NoError(t, d.Dispatch(net.TestChannel, p, origin, net.Message{Payload: i}))
+	}
+	for i := 0; i < count; i++ {
+		require.Equal(t, i, mustReceive(t, ch))
+	}
+}
+
+// TestDispatcher_PolicyDrop tests that messages for a channel whose queue is full are dropped and counted.
+func TestDispatcher_PolicyDrop(t *testing.T) {
+	d := startDispatcher(t, dispatch.WithChannel(net.TestChannel, 2, dispatch.PolicyDrop))
+	origin := unittest.IdentifierFixture(t)
+	release := make(chan struct{})
+	p, ch := blockingProcessor(release)
+
+	// the first message is being processed, and the next two fill the queue
+	require.NoError(t, d.Dispatch(net.TestChannel, p, origin, net.Message{Payload: 0}))
+	require.Equal(t, 0, mustReceive(t, ch))
+	require.NoError(t, d.Dispatch(net.TestChannel, p, origin, net.Message{Payload: 1}))
+	require.NoError(t, d.Dispatch(net.TestChannel, p, origin, net.Message{Payload: 2}))
+	err := d.Dispatch(net.TestChannel, p, origin, net.Message{Payload: 3})
+	require.True(t, errors.Is(err, dispatch.ErrQueueFull))
+
+	// other channels have queues of their own
+	require.NoError(t, d.Dispatch(otherChannel, p, origin, net.Message{Payload: 4}))
+
+	close(release)
+	require.ElementsMatch(t, []any{1, 2, 4}, []any{mustReceive(t, ch), mustReceive(t, ch), mustReceive(t, ch)})
+	require.Eventually(
+		t, func() bool {
+			return d.Stats() == dispatch.Stats{Dispatched: 4, Processed: 4, Dropped: 1}
+		}, unittest.DefaultReadyDoneTimeout, time.Millisecond,
+	)
+}
+
+// TestDispatcher_PolicyBlock tests that dispatching to a channel whose queue is full blocks until there is room.
+func TestDispatcher_PolicyBlock(t *testing.T) {
+	d := startDispatcher(t, dispatch.WithPolicy(dispatch.PolicyBlock), dispatch.WithQueueCapacity(1))
+	origin := unittest.IdentifierFixture(t)
+	release := make(chan struct{})
+	p, ch := blockingProcessor(release)
+
+	require.NoError(t, d.Dispatch(net.TestChannel, p, origin, net.Message{Payload: 0}))
+	require.Equal(t, 0, mustReceive(t, ch))
+	require.NoError(t, d.Dispatch(net.TestChannel, p, origin, net.Message{Payload: 1}))
+
+	dispatched := make(chan interface{})
+	go func() {
+		defer close(dispatched)
+		require.NoError(t, d.Dispatch(net.TestChannel, p, origin, net.Message{Payload: 2}))
+	}()
+	unittest.ChannelMustNotCloseWithinTimeout(t, dispatched, 50*time.Millisecond, "dispatch did not block on a full queue")
+
+	close(release)
+	unittest.ChannelMustCloseWithinTimeout(t, dispatched, unittest.DefaultReadyDoneTimeout, "dispatch did not resume")
+	require.Equal(t, 1, mustReceive(t, ch))
+	require.Equal(t, 2, mustReceive(t, ch))
+	require.Zero(t, d.Stats().Dropped)
+}
+
+// TestDispatcher_Panic tests that a panicking processor is recovered, and the following messages are processed.
+func TestDispatcher_Panic(t *testing.T) {
+	d := startDispatcher(t, dispatch.WithWorkerCount(1))
+	origin := unittest.IdentifierFixture(t)
+
+	processed := make(chan any, 10)
+	p := mocknet.NewMockMessageProcessor(
+		func(_ net.Channel, _ model.Identifier, msg net.Message) {
+			if msg.Payload == "boom" {
+				panic("boom")
+			}
+			processed <- msg.Payload
+		},
+	)
+	require.NoError(t, d.Dispatch(net.TestChannel, p, origin, net.Message{Payload: "boom"}))
+	require.NoError(t, d.Dispatch(net.TestChannel, p, origin, net.Message{Payload: "after"}))
+	require.Equal(t, "after", mustReceive(t, processed))
+	require.Equal(t, uint64(1), d.Stats().Panics)
+}
+
+// TestDispatcher_ManyChannels tests that the messages of every channel are processed, even when more channels wait for
+// a worker than the pool can queue.
+func TestDispatcher_ManyChannels(t *testing.T) {
+	d := startDispatcher(t, dispatch.WithWorkerCount(1))
+	origin := unittest.IdentifierFixture(t)
+
+	release := make(chan struct{})
+	blocked, started := blockingProcessor(release)
+	require.NoError(t, d.Dispatch(otherChannel, blocked, origin, net.Message{Payload: -1}))
+	mustReceive(t, started)
+
+	var processed sync.WaitGroup
+	p := mocknet.NewMockMessageProcessor(
+		func(net.Channel, model.Identifier, net.Message) {
+			processed.Done()
+		},
+	)
+	const count = 2000
+	processed.Add(count)
+	for i := 0; i < count; i++ {
+		channel := net.Channel(fmt.Sprintf("channel-%d", i))
+		require.NoError(t, d.Dispatch(channel, p, origin, net.Message{Payload: i}))
+	}
+	close(release)
+
+	done := make(chan interface{})
+	go func() {
+		processed.Wait()
+		close(done)
+	}()
+	unittest.ChannelMustCloseWithinTimeout(t, done, time.Second, "messages not processed on time")
+}
+
+// TestDispatcher_NotRunning tests that messages cannot be dispatched before start and after shutdown.
+func TestDispatcher_NotRunning(t *testing.T) {
+	d := dispatch.NewDispatcher(unittest.Logger(zerolog.Disabled))
+	origin := unittest.IdentifierFixture(t)
+	p, _ := blockingProcessor(nil)
+
+	err := d.Dispatch(net.TestChannel, p, origin, *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, dispatch.ErrDispatcherNotRunning))
+
+	ctx := unittest.NewMockThrowableContext(t)
+	d.Start(ctx)
+	unittest.RequireAllReady(t, d)
+	ctx.Cancel()
+	unittest.RequireAllDone(t, d)
+	err = d.Dispatch(net.TestChannel, p, origin, *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, dispatch.ErrDispatcherNotRunning))
+}
+
+// TestDispatcher_Concurrent tests that concurrent dispatches to several channels are all processed.
+func TestDispatcher_Concurrent(t *testing.T) {
+	d := startDispatcher(t, dispatch.WithWorkerCount(2))
+	origin := unittest.IdentifierFixture(t)
+
+	const count = 100
+	wg := sync.WaitGroup{}
+	wg.Add(count)
+	p := mocknet.NewMockMessageProcessor(
+		func(net.Channel, model.Identifier, net.Message) {
+			wg.Done()
+		},
+	)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			channel := net.TestChannel
+			if i%2 == 0 {
+				channel = otherChannel
+			}
+			require.NoError(t, d.Dispatch(channel, p, origin, net.Message{Payload: i}))
+		}(i)
+	}
+	unittest.CallMustReturnWithinTimeout(t, wg.Wait, time.Second, "messages not processed on time")
+}
+
+// TestDispatcher_Priority tests that channels of a priority class are processed by workers of their own, so that
+// channels of other classes occupying all their workers do not hold them back.
+func TestDispatcher_Priority(t *testing.T) {
+	control, bulk, bulkOther := net.Channel("channel-control"), net.Channel("channel-bulk"), net.Channel("channel-bulk-other")
+	d := startDispatcher(
+		t,
+		dispatch.WithWorkerCount(1),
+		dispatch.WithPriorityWorkerCount(net.PriorityLow, 1),
+		dispatch.WithChannelPriority(control, net.PriorityHigh),
+		dispatch.WithChannelPriority(bulk, net.PriorityLow),
+		dispatch.WithChannelPriority(bulkOther, net.PriorityLow),
+	)
+	origin := unittest.IdentifierFixture(t)
+
+	// the only workers of normal and low priority are blocked
+	release := make(chan struct{})
+	defer close(release)
+	normal, normalCh := blockingProcessor(release)
+	require.NoError(t, d.Dispatch(net.TestChannel, normal, origin, net.Message{Payload: 0}))
+	mustReceive(t, normalCh)
+	low, lowCh := blockingProcessor(release)
+	require.NoError(t, d.Dispatch(bulk, low, origin, net.Message{Payload: 0}))
+	mustReceive(t, lowCh)
+
+	// another channel of low priority waits for the worker of its class
+	require.NoError(t, d.Dispatch(bulkOther, low, origin, net.Message{Payload: 1}))
+
+	// channels of high priority are processed meanwhile
+	open := make(chan struct{})
+	close(open)
+	high, highCh := blockingProcessor(open)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, d.Dispatch(control, high, origin, net.Message{Payload: i}))
+		require.Equal(t, i, mustReceive(t, highCh))
+	}
+	require.Empty(t, lowCh)
+}
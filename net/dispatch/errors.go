@@ -0,0 +1,10 @@
+package dispatch
+
+import "errors"
+
+// ErrDispatcherNotRunning is returned when dispatching through a dispatcher that has not been started or is shutting
+// down.
+var ErrDispatcherNotRunning = errors.New("dispatcher is not running")
+
+// ErrQueueFull is returned when a message is dropped as the queue of its channel is full.
+var ErrQueueFull = errors.New("channel queue full")
@@ -0,0 +1,169 @@
+// Package events implements the delivery of net.PeerEvent to the consumers subscribed to a network.
+//
+// A Distributor holds a bounded queue of events for each consumer, drained by a goroutine of its own, so that
+// consumers receive the events in the order they were published, never concurrently, and never from the goroutine
+// publishing them. An event published while the queue of a consumer is full is dropped for that consumer.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+	"github.com/thep2p/skipgraph-go/net"
+)
+
+// DefaultQueueCapacity is the default capacity of the queue of each consumer.
+const DefaultQueueCapacity = 256
+
+// Stats is a snapshot of the counters of a Distributor.
+type Stats struct {
+	Published uint64 // events published
+	Delivered uint64 // events delivered to a consumer, including those whose consumer panicked
+	Dropped   uint64 // events dropped as the queue of their consumer was full
+}
+
+// subscription is a consumer along with its queue of events.
+type subscription struct {
+	consumer net.PeerEventConsumer
+	events   chan net.PeerEvent
+	once     sync.Once
+}
+
+// Distributor delivers peer events to the consumers subscribed to it.
+// It is safe for concurrent use.
+type Distributor struct {
+	logger   zerolog.Logger
+	capacity int
+
+	published atomic.Uint64
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+
+	wg     sync.WaitGroup
+	l      sync.Mutex
+	closed bool
+	subs   map[*subscription]struct{}
+}
+
+// Option is a functional option for configuring a Distributor.
+type Option func(*Distributor)
+
+// WithQueueCapacity sets the capacity of the queue of each consumer; defaults to DefaultQueueCapacity.
+func WithQueueCapacity(capacity int) Option {
+	return func(d *Distributor) {
+		if capacity > 0 {
+			d.capacity = capacity
+		}
+	}
+}
+
+// NewDistributor creates a Distributor without consumers.
+// Args:
+//   - logger: zerolog.Logger for logging
+//   - opts: variadic options for configuring the distributor
+func NewDistributor(logger zerolog.Logger, opts ...Option) *Distributor {
+	d := &Distributor{
+		logger:   logger.With().Str("component", "peer-events").Logger(),
+		capacity: DefaultQueueCapacity,
+		subs:     make(map[*subscription]struct{}),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Subscribe registers the consumer for the events published from now on, until the returned function is called or
+// the distributor is closed; the events queued for the consumer by then are still delivered. Subscribing to a closed
+// distributor is a no-op.
+func (d *Distributor) Subscribe(consumer net.PeerEventConsumer) (unsubscribe func()) {
+	d.l.Lock()
+	defer d.l.Unlock()
+	if d.closed {
+		return func() {}
+	}
+
+	s := &subscription{consumer: consumer, events: make(chan net.PeerEvent, d.capacity)}
+	d.subs[s] = struct{}{}
+	d.wg.Add(1)
+	go d.deliver(s)
+
+	return func() {
+		d.l.Lock()
+		defer d.l.Unlock()
+		delete(d.subs, s)
+		d.stop(s)
+	}
+}
+
+// Publish queues the event for every consumer, dropping it for those whose queue is full. It never blocks.
+func (d *Distributor) Publish(event net.PeerEvent) {
+	d.l.Lock()
+	defer d.l.Unlock()
+	if d.closed {
+		return
+	}
+	d.published.Add(1)
+	for s := range d.subs {
+		select {
+		case s.events <- event:
+		default:
+			d.dropped.Add(1)
+			d.logger.Warn().
+				Str("peer", event.Peer.String()).
+				Str("type", event.Type.String()).
+				Msg("Dropping peer event for consumer falling behind")
+		}
+	}
+}
+
+// Close unsubscribes all consumers, and waits for the events queued for them to be delivered.
+// Events published afterward are discarded.
+func (d *Distributor) Close() {
+	d.l.Lock()
+	d.closed = true
+	for s := range d.subs {
+		delete(d.subs, s)
+		d.stop(s)
+	}
+	d.l.Unlock()
+	d.wg.Wait()
+}
+
+// Stats returns a snapshot of the counters of the distributor.
+func (d *Distributor) Stats() Stats {
+	return Stats{
+		Published: d.published.Load(),
+		Delivered: d.delivered.Load(),
+		Dropped:   d.dropped.Load(),
+	}
+}
+
+// stop closes the queue of the subscription, once; the caller must hold the lock.
+func (d *Distributor) stop(s *subscription) {
+	s.once.Do(func() { close(s.events) })
+}
+
+// deliver delivers the events queued for the subscription to its consumer, until the queue is closed.
+func (d *Distributor) deliver(s *subscription) {
+	defer d.wg.Done()
+	for event := range s.events {
+		d.consume(s.consumer, event)
+	}
+}
+
+// consume delivers the event to the consumer, recovering it from panics.
+func (d *Distributor) consume(consumer net.PeerEventConsumer, event net.PeerEvent) {
+	defer d.delivered.Add(1)
+	defer func() {
+		if r := recover(); r != nil {
+			d.logger.Error().
+				Str("peer", event.Peer.String()).
+				Str("type", event.Type.String()).
+				Interface("panic", r).
+				Msg("Peer event consumer panicked")
+		}
+	}()
+	consumer.OnPeerEvent(event)
+}
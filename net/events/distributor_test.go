@@ -0,0 +1,133 @@
+package events_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/events"
+	"github.com/thep2p/skipgraph-go/unittest"
+)
+
+// collector is a consumer collecting the events it receives.
+type collector struct {
+	l      sync.Mutex
+	events []net.PeerEvent
+}
+
+func (c *collector) OnPeerEvent(event net.PeerEvent) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.events = append(c.events, event)
+}
+
+func (c *collector) received() []net.PeerEvent {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return append([]net.PeerEvent(nil), c.events...)
+}
+
+// eventsFixture returns count connected and disconnected events of random peers, alternately.
+func eventsFixture(t *testing.T, count int) []net.PeerEvent {
+	evts := make([]net.PeerEvent, count)
+	for i := range evts {
+		evts[i] = net.PeerEvent{Type: net.PeerConnected, Peer: unittest.IdentifierFixture(t)}
+		if i%2 == 1 {
+			evts[i].Type = net.PeerDisconnected
+		}
+	}
+	return evts
+}
+
+// TestDistributor_Publish tests that every consumer receives the events published while it is subscribed, in order.
+func TestDistributor_Publish(t *testing.T) {
+	d := events.NewDistributor(unittest.Logger(zerolog.WarnLevel))
+	c1, c2 := &collector{}, &collector{}
+	unsubscribe1 := d.Subscribe(c1)
+	d.Subscribe(c2)
+
+	evts := eventsFixture(t, 10)
+	for _, e := range evts[:5] {
+		d.Publish(e)
+	}
+	unsubscribe1()
+	unsubscribe1()
+	for _, e := range evts[5:] {
+		d.Publish(e)
+	}
+	late := &collector{}
+	d.Subscribe(late)
+
+	d.Close()
+	require.Equal(t, evts[:5], c1.received())
+	require.Equal(t, evts, c2.received())
+	require.Empty(t, late.received())
+
+	// closed
+	d.Publish(evts[0])
+	d.Subscribe(&collector{})()
+	require.Equal(t, events.Stats{Published: 10, Delivered: 15}, d.Stats())
+}
+
+// TestDistributor_SlowConsumer tests that the events a consumer falls behind on are dropped for it only, and that a
+// panicking consumer keeps receiving events.
+func TestDistributor_SlowConsumer(t *testing.T) {
+	d := events.NewDistributor(unittest.Logger(zerolog.Disabled), events.WithQueueCapacity(2))
+	release := make(chan struct{})
+	started := make(chan interface{})
+	slow := &collector{}
+	d.Subscribe(
+		net.PeerEventConsumerFunc(
+			func(e net.PeerEvent) {
+				if len(slow.received()) == 0 {
+					close(started)
+					<-release
+				}
+				slow.OnPeerEvent(e)
+			},
+		),
+	)
+	panicking := &collector{}
+	d.Subscribe(
+		net.PeerEventConsumerFunc(
+			func(e net.PeerEvent) {
+				panicking.OnPeerEvent(e)
+				panic("consumer failure")
+			},
+		),
+	)
+
+	evts := eventsFixture(t, 4)
+	d.Publish(evts[0])
+	unittest.ChannelMustCloseWithinTimeout(t, started, unittest.DefaultReadyDoneTimeout, "event not delivered")
+	// the first event is being delivered to the slow consumer, the next two are queued, and the last one is dropped;
+	// the panicking consumer takes each event before the next is published, so that none is dropped for it
+	for i, e := range evts {
+		if i > 0 {
+			d.Publish(e)
+		}
+		require.Eventually(
+			t,
+			func() bool { return len(panicking.received()) == i+1 },
+			unittest.DefaultReadyDoneTimeout,
+			time.Millisecond,
+		)
+	}
+	close(release)
+
+	d.Close()
+	require.Equal(t, evts[:3], slow.received())
+	require.Equal(t, evts, panicking.received())
+	require.Equal(t, uint64(1), d.Stats().Dropped)
+}
+
+// TestPeerEventType_String tests the names of the event types.
+func TestPeerEventType_String(t *testing.T) {
+	require.Equal(t, "connected", net.PeerConnected.String())
+	require.Equal(t, "disconnected", net.PeerDisconnected.String())
+	require.Equal(t, "handshake-failed", net.PeerHandshakeFailed.String())
+	require.Equal(t, "unknown(9)", net.PeerEventType(9).String())
+}
@@ -0,0 +1,34 @@
+// Package bounded runs the work triggered by received messages on goroutines of their own, up to a maximum number at a
+// time, so that peers flooding a node cannot make it spawn goroutines without bound.
+package bounded
+
+// Group runs functions on goroutines of their own, up to a maximum number at a time.
+// It is safe for concurrent use.
+type Group struct {
+	slots chan struct{}
+}
+
+// NewGroup creates a Group running up to limit functions at a time; a non-positive limit is treated as 1.
+func NewGroup(limit int) *Group {
+	return &Group{slots: make(chan struct{}, max(limit, 1))}
+}
+
+// Go runs f on a goroutine of its own, unless the group runs its maximum number of functions already.
+// Returns false if f was not run.
+func (g *Group) Go(f func()) bool {
+	select {
+	case g.slots <- struct{}{}:
+	default:
+		return false
+	}
+	go func() {
+		defer func() { <-g.slots }()
+		f()
+	}()
+	return true
+}
+
+// Running returns the number of functions the group is running.
+func (g *Group) Running() int {
+	return len(g.slots)
+}
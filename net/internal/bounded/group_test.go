@@ -0,0 +1,37 @@
+package bounded_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/net/internal/bounded"
+	"github.com/thep2p/skipgraph-go/unittest"
+)
+
+// TestGroup tests that a group runs up to its maximum number of functions at a time, and frees their slots once they
+// return.
+func TestGroup(t *testing.T) {
+	g := bounded.NewGroup(2)
+	release := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	for i := 0; i < 2; i++ {
+		require.True(
+			t, g.Go(
+				func() {
+					defer wg.Done()
+					<-release
+				},
+			),
+		)
+	}
+	require.False(t, g.Go(func() {}))
+	require.Equal(t, 2, g.Running())
+
+	close(release)
+	unittest.CallMustReturnWithinTimeout(t, wg.Wait, time.Second, "functions did not return on time")
+	require.Eventually(t, func() bool { return g.Running() == 0 }, time.Second, time.Millisecond)
+	require.True(t, g.Go(func() {}))
+}
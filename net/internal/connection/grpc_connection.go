@@ -1,29 +1,163 @@
 package connection
 
-import "github.com/thep2p/skipgraph-go/net/internal"
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative message.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net/internal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// IdentifierMetadataKey is the key of the stream metadata in which the dialing node announces its identifier.
+const IdentifierMetadataKey = "skipgraph-identifier"
+
+// ErrRemoteMismatch is returned when the remote peer of a connection presents a different identifier than expected.
+var ErrRemoteMismatch = errors.New("remote peer identifier mismatch")
+
+// messageStream is the part of a gRPC bidirectional stream used by a connection; it is implemented by both the
+// client and the server side of a Transport.Connect stream.
+type messageStream interface {
+	Send(*Message) error
+	Recv() (*Message, error)
+}
 
 // GRPCConnection represents a connection to a remote peer using gRPC.
+// Each connection is a single bidirectional Transport.Connect stream, and each frame is a Message envelope carrying
+// the identifier of its sender and its send timestamp.
+// Send and Next are safe for concurrent use; concurrent Sends (resp. Nexts) are serialized.
 type GRPCConnection struct {
+	stream     messageStream
+	localID    model.Identifier // identifier of the local node, stamped on sent envelopes
+	remoteID   model.Identifier // identifier of the remote peer, expected on received envelopes
+	remoteAddr string
+	sendLock   sync.Mutex // gRPC streams do not support concurrent Sends
+	recvLock   sync.Mutex // gRPC streams do not support concurrent Recvs
+	closed     atomic.Bool
+	closeOnce  sync.Once
+	closeFunc  func() error  // ends the underlying stream
+	done       chan struct{} // closed once the connection is closed
 }
 
 var _ internal.Connection = (*GRPCConnection)(nil)
 
-func (G GRPCConnection) RemoteAddr() string {
-	//TODO implement me
-	panic("implement me")
+// newGRPCConnection wraps the stream into a GRPCConnection.
+// Args:
+//   - stream: the client or server side of a Transport.Connect stream
+//   - localID: the identifier of the local node
+//   - remoteID: the identifier of the remote peer
+//   - remoteAddr: the address of the remote peer
+//   - closeFunc: ends the stream; it is called once when the connection is closed
+func newGRPCConnection(
+	stream messageStream,
+	localID model.Identifier,
+	remoteID model.Identifier,
+	remoteAddr string,
+	closeFunc func() error,
+) *GRPCConnection {
+	return &GRPCConnection{
+		stream:     stream,
+		localID:    localID,
+		remoteID:   remoteID,
+		remoteAddr: remoteAddr,
+		closeFunc:  closeFunc,
+		done:       make(chan struct{}),
+	}
+}
+
+// RemoteID returns the identifier of the remote peer.
+func (g *GRPCConnection) RemoteID() model.Identifier {
+	return g.remoteID
+}
+
+// RemoteAddr returns the address of the remote peer, or an empty string if the connection is closed.
+func (g *GRPCConnection) RemoteAddr() string {
+	if g.closed.Load() {
+		return ""
+	}
+	return g.remoteAddr
+}
+
+// Send sends b in an envelope stamped with the local identifier and the current time.
+// It blocks until the envelope is handed to the stream, which may wait on gRPC flow control.
+// Returns io.EOF if the connection is closed, or the stream error.
+func (g *GRPCConnection) Send(b []byte) error {
+	if g.closed.Load() {
+		return io.EOF
+	}
+	msg := &Message{
+		Data:      b,
+		Sender:    proto.String(g.localID.String()),
+		Timestamp: proto.String(time.Now().UTC().Format(time.RFC3339Nano)),
+	}
+	if msg.Data == nil {
+		// required fields must be set, even when empty
+		msg.Data = []byte{}
+	}
+
+	g.sendLock.Lock()
+	defer g.sendLock.Unlock()
+	if err := g.stream.Send(msg); err != nil {
+		return g.mapErr(fmt.Errorf("could not send envelope: %w", err))
+	}
+	return nil
+}
+
+// Next blocks until the next envelope is received and returns its data.
+// Returns io.EOF if the connection is closed (locally or by the remote peer), an error wrapping ErrRemoteMismatch if
+// the envelope is not sent by the remote peer, or the stream error.
+func (g *GRPCConnection) Next() ([]byte, error) {
+	if g.closed.Load() {
+		return nil, io.EOF
+	}
+
+	g.recvLock.Lock()
+	defer g.recvLock.Unlock()
+	msg, err := g.stream.Recv()
+	if err != nil {
+		return nil, g.mapErr(err)
+	}
+	if msg.GetSender() != g.remoteID.String() {
+		return nil, fmt.Errorf("%w: got %q", ErrRemoteMismatch, msg.GetSender())
+	}
+	return msg.GetData(), nil
 }
 
-func (G GRPCConnection) Send(bytes []byte) error {
-	//TODO implement me
-	panic("implement me")
+// Close ends the underlying stream; subsequent and pending Send and Next calls return io.EOF.
+// Close is idempotent, and returns the error of ending the stream.
+func (g *GRPCConnection) Close() error {
+	var err error
+	g.closeOnce.Do(
+		func() {
+			g.closed.Store(true)
+			close(g.done)
+			err = g.closeFunc()
+		},
+	)
+	return err
 }
 
-func (G GRPCConnection) Next() ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
+// Done returns a channel that is closed once the connection is closed.
+func (g *GRPCConnection) Done() <-chan struct{} {
+	return g.done
 }
 
-func (G GRPCConnection) Close() error {
-	//TODO implement me
-	panic("implement me")
+// mapErr maps the errors caused by an ended stream to io.EOF, and returns any other error as is.
+func (g *GRPCConnection) mapErr(err error) error {
+	if g.closed.Load() || errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
+		return io.EOF
+	}
+	if s, ok := status.FromError(err); ok && s.Code() == codes.Canceled {
+		return io.EOF
+	}
+	return err
 }
@@ -0,0 +1,159 @@
+package connection_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net/internal/connection"
+	"github.com/thep2p/skipgraph-go/unittest"
+	"google.golang.org/grpc"
+)
+
+// startGRPCServer starts a gRPC Transport server on loopback for the given identifier.
+// Returns the address of the server and the channel of the connections it accepts.
+// The server is stopped when the test ends.
+func startGRPCServer(t *testing.T, id model.Identifier) (model.Address, <-chan *connection.GRPCConnection) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	accepted := make(chan *connection.GRPCConnection, 10)
+	server := grpc.NewServer()
+	connection.RegisterTransportServer(
+		server, connection.NewGRPCServer(
+			id, func(conn *connection.GRPCConnection) {
+				accepted <- conn
+			},
+		),
+	)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	tcpAddr := listener.Addr().(*net.TCPAddr)
+	return model.NewAddress(tcpAddr.IP.String(), strconv.Itoa(tcpAddr.Port)), accepted
+}
+
+// staticResolve returns a ResolveFunc resolving the identifier to the address.
+func staticResolve(id model.Identifier, addr model.Address) connection.ResolveFunc {
+	return func(target model.Identifier) ([]model.Address, error) {
+		if target != id {
+			return nil, errors.New("unknown peer")
+		}
+		return []model.Address{addr}, nil
+	}
+}
+
+// mustAccept waits for the next accepted connection.
+func mustAccept(t *testing.T, accepted <-chan *connection.GRPCConnection) *connection.GRPCConnection {
+	select {
+	case conn := <-accepted:
+		return conn
+	case <-time.After(unittest.DefaultReadyDoneTimeout):
+		require.Fail(t, "connection not accepted on time")
+		return nil
+	}
+}
+
+// TestGRPCConnection_SendNext tests that frames are exchanged in both directions and in order over a gRPC connection.
+func TestGRPCConnection_SendNext(t *testing.T) {
+	serverID := unittest.IdentifierFixture(t)
+	clientID := unittest.IdentifierFixture(t)
+	addr, accepted := startGRPCServer(t, serverID)
+
+	dialer := connection.NewGRPCDialer(clientID, staticResolve(serverID, addr))
+	client, err := dialer.Dial(context.Background(), serverID)
+	require.NoError(t, err)
+	defer func() {
+		_ = client.Close()
+	}()
+	require.Equal(t, addr.String(), client.RemoteAddr())
+
+	frames := [][]byte{unittest.RandomBytesFixture(t, 100), {}, unittest.RandomBytesFixture(t, 1024)}
+	for _, f := range frames {
+		require.NoError(t, client.Send(f))
+	}
+
+	server := mustAccept(t, accepted)
+	require.Equal(t, clientID, server.RemoteID())
+	require.NotEmpty(t, server.RemoteAddr())
+	for _, f := range frames {
+		b, err := server.Next()
+		require.NoError(t, err)
+		require.Equal(t, len(f), len(b))
+		if len(f) > 0 {
+			require.Equal(t, f, b)
+		}
+	}
+
+	reply := unittest.RandomBytesFixture(t, 100)
+	require.NoError(t, server.Send(reply))
+	b, err := client.Next()
+	require.NoError(t, err)
+	require.Equal(t, reply, b)
+}
+
+// TestGRPCConnection_Close tests that closing either end of a gRPC connection makes both ends return io.EOF, and that
+// a closed connection reports an empty remote address.
+func TestGRPCConnection_Close(t *testing.T) {
+	serverID := unittest.IdentifierFixture(t)
+	clientID := unittest.IdentifierFixture(t)
+	addr, accepted := startGRPCServer(t, serverID)
+	dialer := connection.NewGRPCDialer(clientID, staticResolve(serverID, addr))
+
+	// closed by the client
+	client, err := dialer.Dial(context.Background(), serverID)
+	require.NoError(t, err)
+	require.NoError(t, client.Send([]byte("hello")))
+	server := mustAccept(t, accepted)
+	_, err = server.Next()
+	require.NoError(t, err)
+
+	require.NoError(t, client.Close())
+	require.NoError(t, client.Close()) // idempotent
+	require.Empty(t, client.RemoteAddr())
+	require.Equal(t, io.EOF, client.Send([]byte("hello")))
+	_, err = client.Next()
+	require.Equal(t, io.EOF, err)
+	_, err = server.Next()
+	require.Equal(t, io.EOF, err)
+
+	// closed by the server, while the client is waiting for a frame
+	client, err = dialer.Dial(context.Background(), serverID)
+	require.NoError(t, err)
+	require.NoError(t, client.Send([]byte("hello")))
+	server = mustAccept(t, accepted)
+
+	nextErr := make(chan error, 1)
+	go func() {
+		_, err := client.Next()
+		nextErr <- err
+	}()
+	require.NoError(t, server.Close())
+	require.Empty(t, server.RemoteAddr())
+	select {
+	case err := <-nextErr:
+		require.Equal(t, io.EOF, err)
+	case <-time.After(unittest.DefaultReadyDoneTimeout):
+		require.Fail(t, "client not notified of the closure on time")
+	}
+	require.NoError(t, client.Close())
+}
+
+// TestGRPCDialer_RemoteMismatch tests that dialing an address that belongs to another peer fails.
+func TestGRPCDialer_RemoteMismatch(t *testing.T) {
+	serverID := unittest.IdentifierFixture(t)
+	addr, _ := startGRPCServer(t, serverID)
+
+	expected := unittest.IdentifierFixture(t)
+	dialer := connection.NewGRPCDialer(unittest.IdentifierFixture(t), staticResolve(expected, addr))
+	_, err := dialer.Dial(context.Background(), expected)
+	require.True(t, errors.Is(err, connection.ErrRemoteMismatch))
+}
@@ -0,0 +1,157 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net/internal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ResolveFunc resolves the identifier of a peer to the addresses it can be reached at, in order of preference.
+type ResolveFunc func(model.Identifier) ([]model.Address, error)
+
+// GRPCServer is the server side of the Transport service.
+// Every Transport.Connect stream opened by a remote peer is handed over as a GRPCConnection to the accept function,
+// and lives until the connection is closed or the remote peer ends the stream.
+type GRPCServer struct {
+	UnimplementedTransportServer
+	localID model.Identifier
+	accept  func(*GRPCConnection)
+}
+
+var _ TransportServer = (*GRPCServer)(nil)
+
+// NewGRPCServer creates a new GRPCServer; register it on a grpc.Server with RegisterTransportServer.
+// Args:
+//   - localID: the identifier of the local node, announced to dialing peers in the stream header
+//   - accept: called with every inbound connection; it must not block
+func NewGRPCServer(localID model.Identifier, accept func(*GRPCConnection)) *GRPCServer {
+	return &GRPCServer{localID: localID, accept: accept}
+}
+
+// Connect serves an inbound stream as a GRPCConnection until the connection is closed or the stream ends.
+// Returns an InvalidArgument status if the dialing peer does not announce a valid identifier.
+func (s *GRPCServer) Connect(stream Transport_ConnectServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	values := md.Get(IdentifierMetadataKey)
+	if len(values) != 1 {
+		return status.Errorf(codes.InvalidArgument, "expected exactly one %s metadata value", IdentifierMetadataKey)
+	}
+	remoteID, err := model.StrToId(values[0])
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid remote identifier: %v", err)
+	}
+	if err := stream.SendHeader(metadata.Pairs(IdentifierMetadataKey, s.localID.String())); err != nil {
+		return fmt.Errorf("could not send header: %w", err)
+	}
+
+	remoteAddr := ""
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		remoteAddr = p.Addr.String()
+	}
+	// the stream ends when this handler returns, hence closing the connection only has to release the handler.
+	conn := newGRPCConnection(stream, s.localID, remoteID, remoteAddr, func() error { return nil })
+	s.accept(conn)
+
+	select {
+	case <-conn.Done():
+	case <-stream.Context().Done():
+		_ = conn.Close()
+	}
+	return nil
+}
+
+// GRPCDialer dials GRPCConnections to remote peers.
+type GRPCDialer struct {
+	localID  model.Identifier
+	resolve  ResolveFunc
+	dialOpts []grpc.DialOption
+}
+
+// NewGRPCDialer creates a new GRPCDialer.
+// Args:
+//   - localID: the identifier of the local node, announced to the remote peers
+//   - resolve: resolves the identifiers of the remote peers to their addresses
+//   - opts: gRPC dial options; when empty, connections are established without transport security
+func NewGRPCDialer(localID model.Identifier, resolve ResolveFunc, opts ...grpc.DialOption) *GRPCDialer {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	return &GRPCDialer{localID: localID, resolve: resolve, dialOpts: opts}
+}
+
+// Dial opens a Transport.Connect stream to the first reachable tcp address of the remote peer, and waits for the
+// peer to announce its identifier, aborting when ctx is done.
+// Returns the connection, an error wrapping ErrRemoteMismatch if the peer reached announces another identifier, or
+// the errors of every attempt.
+func (d *GRPCDialer) Dial(ctx context.Context, remoteID model.Identifier) (internal.Connection, error) {
+	addrs, err := d.resolve(remoteID)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve peer: %w", err)
+	}
+
+	var errs []error
+	for _, addr := range addrs {
+		if addr.Transport() != model.TransportTCP {
+			continue
+		}
+		conn, err := d.dialAddress(ctx, remoteID, addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return conn, nil
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no tcp address for %s", remoteID.String())
+	}
+	return nil, errors.Join(errs...)
+}
+
+// dialAddress opens a Transport.Connect stream to the remote peer at the address.
+func (d *GRPCDialer) dialAddress(ctx context.Context, remoteID model.Identifier, addr model.Address) (*GRPCConnection, error) {
+	cc, err := grpc.NewClient(addr.String(), d.dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create client for %s: %w", addr, err)
+	}
+
+	// the stream outlives ctx, which only bounds its establishment.
+	streamCtx, cancel := context.WithCancel(context.Background())
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+	closeFunc := func() error {
+		cancel()
+		return cc.Close()
+	}
+
+	streamCtx = metadata.AppendToOutgoingContext(streamCtx, IdentifierMetadataKey, d.localID.String())
+	stream, err := NewTransportClient(cc).Connect(streamCtx)
+	if err != nil {
+		_ = closeFunc()
+		return nil, fmt.Errorf("could not open stream to %s: %w", addr, err)
+	}
+	header, err := stream.Header()
+	if err != nil {
+		_ = closeFunc()
+		return nil, fmt.Errorf("could not receive header from %s: %w", addr, err)
+	}
+	if values := header.Get(IdentifierMetadataKey); len(values) != 1 || values[0] != remoteID.String() {
+		_ = closeFunc()
+		return nil, fmt.Errorf("%w: expected %s at %s, got %v", ErrRemoteMismatch, remoteID.String(), addr, values)
+	}
+
+	return newGRPCConnection(
+		stream, d.localID, remoteID, addr.String(), func() error {
+			_ = stream.CloseSend()
+			return closeFunc()
+		},
+	), nil
+}
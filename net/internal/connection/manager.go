@@ -0,0 +1,406 @@
+package connection
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net/internal"
+)
+
+// ErrManagerClosed is returned when connecting through a closed Manager.
+var ErrManagerClosed = errors.New("connection manager is closed")
+
+// ErrTooManyConnections is returned when a connection cannot be cached as the manager holds its maximum number of
+// connections, none of which can be evicted.
+var ErrTooManyConnections = errors.New("too many connections")
+
+// ErrBackoff is returned when connecting to a peer whose last dials failed, before its backoff delay is over.
+var ErrBackoff = errors.New("connection attempt backed off")
+
+// ErrNotDialed is wrapped by the errors of a Dialer that did not dial the peer, e.g., as no address is known for it.
+// Such errors are not failed dials, hence do not delay the next dial to the peer.
+var ErrNotDialed = errors.New("peer not dialed")
+
+// MaxFailures bounds the number of peers whose failed dials are remembered; beyond it, the peers whose backoff delay
+// is long over are forgotten, or else the peer whose backoff delay ends first.
+const MaxFailures = 4096
+
+// Dialer establishes connections to remote peers, e.g., GRPCDialer.
+type Dialer interface {
+	// Dial establishes a new connection to the remote peer, aborting when ctx is done.
+	// Errors from this method are expected to be treated as benign.
+	Dial(ctx context.Context, remoteID model.Identifier) (internal.Connection, error)
+}
+
+// DialerFunc is a function implementing Dialer.
+type DialerFunc func(ctx context.Context, remoteID model.Identifier) (internal.Connection, error)
+
+// Dial calls f.
+func (f DialerFunc) Dial(ctx context.Context, remoteID model.Identifier) (internal.Connection, error) {
+	return f(ctx, remoteID)
+}
+
+// Manager is an internal.ConnectionManager that caches at most one connection per remote peer.
+// Connections are established through its Dialer on demand; a cached connection that has been closed (i.e., whose
+// RemoteAddr is empty) is replaced by a new one on the next Connect. Concurrent Connects to the same peer share a
+// single dial.
+//
+// Optionally, the manager bounds the number of connections it caches by evicting the least recently used one, closes
+// connections idle for too long (see EvictIdle), and spaces the dials to a peer that failed by an exponential backoff.
+// Connections to protected peers, e.g., the neighbors of the node in its lookup table, are never evicted.
+type Manager struct {
+	dialer      Dialer
+	maxConns    int
+	idleTimeout time.Duration
+	protected   func(model.Identifier) bool
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	now         func() time.Time
+
+	l        sync.Mutex
+	closed   bool
+	conns    map[model.Identifier]*list.Element // elements of lru
+	lru      *list.List                         // cached connections, most recently used first
+	dials    map[model.Identifier]*dial         // in-flight dials
+	failures map[model.Identifier]*failure      // peers whose last dials failed
+}
+
+var _ internal.ConnectionManager = (*Manager)(nil)
+
+// cached is a connection cached by the manager.
+type cached struct {
+	remoteID model.Identifier
+	conn     internal.Connection
+	lastUsed time.Time
+}
+
+// dial is an in-flight dial to a remote peer.
+type dial struct {
+	done chan struct{} // closed once the dial is over
+	conn internal.Connection
+	err  error
+}
+
+// failure is the backoff state of a peer whose last dials failed.
+type failure struct {
+	attempts int       // consecutive failed dials
+	retryAt  time.Time // the time before which the peer is not dialed again
+}
+
+// Option is a functional option for configuring a Manager.
+type Option func(*Manager)
+
+// WithMaxConnections sets the maximum number of connections cached; unlimited if not positive, which is the default.
+func WithMaxConnections(max int) Option {
+	return func(m *Manager) {
+		m.maxConns = max
+	}
+}
+
+// WithIdleTimeout sets the time after which a connection that is not used is evicted by EvictIdle; connections are
+// never idle if not positive, which is the default.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(m *Manager) {
+		m.idleTimeout = timeout
+	}
+}
+
+// WithProtected sets the function telling the peers whose connection is never evicted; none by default.
+func WithProtected(protected func(model.Identifier) bool) Option {
+	return func(m *Manager) {
+		m.protected = protected
+	}
+}
+
+// WithBackoff spaces the dials to a peer that failed: after a failed dial, the peer is not dialed again for min, and
+// the delay doubles with every further failure, up to max. Disabled if min is not positive, which is the default.
+func WithBackoff(min time.Duration, max time.Duration) Option {
+	return func(m *Manager) {
+		m.minBackoff = min
+		m.maxBackoff = max
+	}
+}
+
+// WithClock sets the source of the current time of the manager; defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(m *Manager) {
+		m.now = now
+	}
+}
+
+// NewManager creates a new Manager establishing connections through the dialer.
+func NewManager(dialer Dialer, opts ...Option) *Manager {
+	m := &Manager{
+		dialer:    dialer,
+		protected: func(model.Identifier) bool { return false },
+		now:       time.Now,
+		conns:     make(map[model.Identifier]*list.Element),
+		lru:       list.New(),
+		dials:     make(map[model.Identifier]*dial),
+		failures:  make(map[model.Identifier]*failure),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Connect returns the cached connection to the remote peer, or dials a new one if there is none or it is closed.
+// Returns an error wrapping ErrManagerClosed if the manager is closed, ErrBackoff if the last dials to the peer failed
+// and its backoff delay is not over, ErrTooManyConnections if the new connection cannot be cached, or the dial error.
+func (m *Manager) Connect(ctx context.Context, remoteID model.Identifier) (internal.Connection, error) {
+	m.l.Lock()
+	if m.closed {
+		m.l.Unlock()
+		return nil, ErrManagerClosed
+	}
+	if conn, ok := m.lookup(remoteID); ok {
+		m.l.Unlock()
+		return conn, nil
+	}
+	if d, ok := m.dials[remoteID]; ok {
+		m.l.Unlock()
+		select {
+		case <-d.done:
+			return d.conn, d.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if f, ok := m.failures[remoteID]; ok && m.now().Before(f.retryAt) {
+		m.l.Unlock()
+		return nil, fmt.Errorf(
+			"%w: %d failed attempts, until %s",
+			ErrBackoff,
+			f.attempts,
+			f.retryAt.Format(time.RFC3339Nano),
+		)
+	}
+	d := &dial{done: make(chan struct{})}
+	m.dials[remoteID] = d
+	m.l.Unlock()
+
+	d.conn, d.err = m.dialer.Dial(ctx, remoteID)
+	if d.err != nil {
+		d.err = fmt.Errorf("could not dial: %w", d.err)
+	}
+
+	var evicted []internal.Connection
+	m.l.Lock()
+	delete(m.dials, remoteID)
+	switch {
+	case d.err != nil:
+		if !errors.Is(d.err, ErrNotDialed) {
+			m.fail(remoteID)
+		}
+	case m.closed:
+		// the manager has been closed while dialing
+		_ = d.conn.Close()
+		d.conn, d.err = nil, ErrManagerClosed
+	default:
+		delete(m.failures, remoteID)
+		evicted, d.err = m.add(remoteID, d.conn)
+		if d.err != nil {
+			_ = d.conn.Close()
+			d.conn = nil
+		}
+	}
+	m.l.Unlock()
+	closeAll(evicted)
+	close(d.done)
+	return d.conn, d.err
+}
+
+// Add caches a connection established by the remote peer, unless a connection to the peer is cached already, and
+// forgets the failed dials to the peer.
+// Returns an error wrapping ErrManagerClosed if the manager is closed, or ErrTooManyConnections if the connection
+// cannot be cached; the connection is left open in any case.
+func (m *Manager) Add(remoteID model.Identifier, conn internal.Connection) error {
+	m.l.Lock()
+	if m.closed {
+		m.l.Unlock()
+		return ErrManagerClosed
+	}
+	delete(m.failures, remoteID)
+	if _, ok := m.lookup(remoteID); ok {
+		m.l.Unlock()
+		return nil
+	}
+	evicted, err := m.add(remoteID, conn)
+	m.l.Unlock()
+	closeAll(evicted)
+	return err
+}
+
+// Remove forgets the connection if it is the one cached for the remote peer, without closing it.
+// Returns true if the connection was cached.
+func (m *Manager) Remove(remoteID model.Identifier, conn internal.Connection) bool {
+	m.l.Lock()
+	defer m.l.Unlock()
+	e, ok := m.conns[remoteID]
+	if !ok || e.Value.(*cached).conn != conn {
+		return false
+	}
+	m.lru.Remove(e)
+	delete(m.conns, remoteID)
+	return true
+}
+
+// Touch marks the connection cached for the remote peer, if any, as used, e.g., as it received a message.
+func (m *Manager) Touch(remoteID model.Identifier) {
+	m.l.Lock()
+	defer m.l.Unlock()
+	if e, ok := m.conns[remoteID]; ok {
+		e.Value.(*cached).lastUsed = m.now()
+		m.lru.MoveToFront(e)
+	}
+}
+
+// EvictIdle closes and forgets the connections to unprotected peers that were not used within the idle timeout.
+// Returns the number of connections evicted.
+func (m *Manager) EvictIdle() int {
+	if m.idleTimeout <= 0 {
+		return 0
+	}
+	var evicted []internal.Connection
+	m.l.Lock()
+	deadline := m.now().Add(-m.idleTimeout)
+	// connections are ordered by use, hence the idle ones are at the back
+	for e := m.lru.Back(); e != nil; {
+		c := e.Value.(*cached)
+		if !c.lastUsed.Before(deadline) {
+			break
+		}
+		prev := e.Prev()
+		if !m.protected(c.remoteID) {
+			m.lru.Remove(e)
+			delete(m.conns, c.remoteID)
+			evicted = append(evicted, c.conn)
+		}
+		e = prev
+	}
+	m.l.Unlock()
+	closeAll(evicted)
+	return len(evicted)
+}
+
+// Len returns the number of connections cached.
+func (m *Manager) Len() int {
+	m.l.Lock()
+	defer m.l.Unlock()
+	return m.lru.Len()
+}
+
+// lookup returns the open connection cached for the remote peer, marking it as used, and forgets it if it is closed.
+// The caller must hold the lock.
+func (m *Manager) lookup(remoteID model.Identifier) (internal.Connection, bool) {
+	e, ok := m.conns[remoteID]
+	if !ok {
+		return nil, false
+	}
+	c := e.Value.(*cached)
+	if c.conn.RemoteAddr() == "" {
+		m.lru.Remove(e)
+		delete(m.conns, remoteID)
+		return nil, false
+	}
+	c.lastUsed = m.now()
+	m.lru.MoveToFront(e)
+	return c.conn, true
+}
+
+// add caches the connection to the remote peer, evicting the least recently used connection to an unprotected peer if
+// the manager is full. A connection to a protected peer is cached even if no connection can be evicted.
+// The caller must hold the lock, and close the evicted connections once it is released.
+// Returns the evicted connections, or an error wrapping ErrTooManyConnections if the connection cannot be cached.
+func (m *Manager) add(remoteID model.Identifier, conn internal.Connection) ([]internal.Connection, error) {
+	var evicted []internal.Connection
+	if m.maxConns > 0 && m.lru.Len() >= m.maxConns {
+		e := m.lru.Back()
+		for e != nil && m.protected(e.Value.(*cached).remoteID) {
+			e = e.Prev()
+		}
+		switch {
+		case e != nil:
+			c := e.Value.(*cached)
+			m.lru.Remove(e)
+			delete(m.conns, c.remoteID)
+			evicted = append(evicted, c.conn)
+		case !m.protected(remoteID):
+			return nil, fmt.Errorf("%w: %d connections to protected peers", ErrTooManyConnections, m.lru.Len())
+		}
+	}
+	m.conns[remoteID] = m.lru.PushFront(&cached{remoteID: remoteID, conn: conn, lastUsed: m.now()})
+	return evicted, nil
+}
+
+// fail records a failed dial to the remote peer, doubling its backoff delay.
+// The caller must hold the lock.
+func (m *Manager) fail(remoteID model.Identifier) {
+	if m.minBackoff <= 0 {
+		return
+	}
+	f, ok := m.failures[remoteID]
+	if !ok {
+		if len(m.failures) >= MaxFailures {
+			stale := m.now().Add(-m.maxBackoff)
+			var oldest model.Identifier
+			var oldestRetryAt time.Time
+			for id, other := range m.failures {
+				if other.retryAt.Before(stale) {
+					delete(m.failures, id)
+					continue
+				}
+				if oldestRetryAt.IsZero() || other.retryAt.Before(oldestRetryAt) {
+					oldest, oldestRetryAt = id, other.retryAt
+				}
+			}
+			if len(m.failures) >= MaxFailures {
+				delete(m.failures, oldest)
+			}
+		}
+		f = &failure{}
+		m.failures[remoteID] = f
+	}
+	f.attempts++
+	delay := m.minBackoff
+	for i := 1; i < f.attempts && delay < m.maxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, max(m.maxBackoff, m.minBackoff))
+	f.retryAt = m.now().Add(delay)
+}
+
+// Close closes all cached connections; subsequent Connects fail.
+// Returns the errors of closing the connections, if any.
+func (m *Manager) Close() error {
+	m.l.Lock()
+	m.closed = true
+	conns := make(map[model.Identifier]internal.Connection, len(m.conns))
+	for id, e := range m.conns {
+		conns[id] = e.Value.(*cached).conn
+	}
+	m.conns = make(map[model.Identifier]*list.Element)
+	m.lru.Init()
+	m.l.Unlock()
+
+	var errs []error
+	for id, conn := range conns {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("could not close connection to %s: %w", id.String(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeAll closes the connections, ignoring errors as they are evicted anyway.
+func closeAll(conns []internal.Connection) {
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+}
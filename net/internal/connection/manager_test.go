@@ -0,0 +1,329 @@
+package connection_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net/internal"
+	"github.com/thep2p/skipgraph-go/net/internal/connection"
+	"github.com/thep2p/skipgraph-go/unittest"
+)
+
+// countingDialer dials in-memory pipes and counts the dials.
+type countingDialer struct {
+	dials   atomic.Int32
+	release chan struct{} // if not nil, dials block until it is closed
+}
+
+func (d *countingDialer) Dial(_ context.Context, _ model.Identifier) (internal.Connection, error) {
+	d.dials.Add(1)
+	if d.release != nil {
+		<-d.release
+	}
+	c1, c2 := net.Pipe()
+	_ = c2.Close()
+	return connection.NewStreamConnection(c1, connection.DefaultMaxFrameSize), nil
+}
+
+// failingDialer fails every dial with its error, counting the dials.
+type failingDialer struct {
+	dials atomic.Int32
+	err   error
+}
+
+func (d *failingDialer) Dial(_ context.Context, _ model.Identifier) (internal.Connection, error) {
+	d.dials.Add(1)
+	return nil, d.err
+}
+
+// clock is a manually advanced source of time.
+type clock struct {
+	l   sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// requireClosed requires the connection to be closed.
+func requireClosed(t *testing.T, conn internal.Connection) {
+	require.Empty(t, conn.RemoteAddr())
+}
+
+// requireOpen requires the connection to be open.
+func requireOpen(t *testing.T, conn internal.Connection) {
+	require.NotEmpty(t, conn.RemoteAddr())
+}
+
+// TestManager_Connect tests that the manager caches one connection per peer, and replaces closed connections.
+func TestManager_Connect(t *testing.T) {
+	dialer := &countingDialer{}
+	m := connection.NewManager(dialer)
+	id1 := unittest.IdentifierFixture(t)
+	id2 := unittest.IdentifierFixture(t)
+
+	c1, err := m.Connect(context.Background(), id1)
+	require.NoError(t, err)
+	cached, err := m.Connect(context.Background(), id1)
+	require.NoError(t, err)
+	require.Same(t, c1, cached)
+	require.Equal(t, int32(1), dialer.dials.Load())
+
+	c2, err := m.Connect(context.Background(), id2)
+	require.NoError(t, err)
+	require.NotSame(t, c1, c2)
+	require.Equal(t, int32(2), dialer.dials.Load())
+
+	// a closed connection is replaced
+	require.NoError(t, c1.Close())
+	replaced, err := m.Connect(context.Background(), id1)
+	require.NoError(t, err)
+	require.NotSame(t, c1, replaced)
+	require.Equal(t, int32(3), dialer.dials.Load())
+
+	// closing the manager closes all connections
+	require.NoError(t, m.Close())
+	require.Empty(t, replaced.RemoteAddr())
+	require.Empty(t, c2.RemoteAddr())
+	_, err = m.Connect(context.Background(), id1)
+	require.True(t, errors.Is(err, connection.ErrManagerClosed))
+}
+
+// TestManager_ConcurrentConnect tests that concurrent connects to the same peer share a single dial.
+func TestManager_ConcurrentConnect(t *testing.T) {
+	dialer := &countingDialer{release: make(chan struct{})}
+	m := connection.NewManager(dialer)
+	id := unittest.IdentifierFixture(t)
+
+	const count = 10
+	conns := make([]internal.Connection, count)
+	wg := sync.WaitGroup{}
+	wg.Add(count)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			defer wg.Done()
+			conn, err := m.Connect(context.Background(), id)
+			require.NoError(t, err)
+			conns[i] = conn
+		}(i)
+	}
+	close(dialer.release)
+	unittest.CallMustReturnWithinTimeout(t, wg.Wait, unittest.DefaultReadyDoneTimeout, "connects did not return on time")
+
+	for _, conn := range conns {
+		require.Same(t, conns[0], conn)
+	}
+	require.Equal(t, int32(1), dialer.dials.Load())
+	require.NoError(t, m.Close())
+}
+
+// TestManager_MaxConnections tests that the manager evicts the least recently used connection once it holds its maximum
+// number of connections.
+func TestManager_MaxConnections(t *testing.T) {
+	m := connection.NewManager(&countingDialer{}, connection.WithMaxConnections(2))
+	defer func() { require.NoError(t, m.Close()) }()
+	id1, id2, id3 := unittest.IdentifierFixture(t), unittest.IdentifierFixture(t), unittest.IdentifierFixture(t)
+
+	c1, err := m.Connect(context.Background(), id1)
+	require.NoError(t, err)
+	c2, err := m.Connect(context.Background(), id2)
+	require.NoError(t, err)
+	// using id1 makes id2 the least recently used peer
+	_, err = m.Connect(context.Background(), id1)
+	require.NoError(t, err)
+
+	c3, err := m.Connect(context.Background(), id3)
+	require.NoError(t, err)
+	require.Equal(t, 2, m.Len())
+	requireClosed(t, c2)
+	requireOpen(t, c1)
+	requireOpen(t, c3)
+
+	// touching id1 makes id3 the least recently used peer
+	m.Touch(id1)
+	c2, err = m.Connect(context.Background(), id2)
+	require.NoError(t, err)
+	requireClosed(t, c3)
+	requireOpen(t, c1)
+	requireOpen(t, c2)
+}
+
+// TestManager_Protected tests that the connections to protected peers are never evicted, and that only protected peers
+// are connected to once the manager is full of them.
+func TestManager_Protected(t *testing.T) {
+	id1, id2, id3, id4 := unittest.IdentifierFixture(t), unittest.IdentifierFixture(t), unittest.IdentifierFixture(t), unittest.IdentifierFixture(t)
+	protected := map[model.Identifier]bool{id1: true, id2: true, id4: true}
+	m := connection.NewManager(
+		&countingDialer{},
+		connection.WithMaxConnections(2),
+		connection.WithProtected(func(id model.Identifier) bool { return protected[id] }),
+	)
+	defer func() { require.NoError(t, m.Close()) }()
+
+	c1, err := m.Connect(context.Background(), id1)
+	require.NoError(t, err)
+	c2, err := m.Connect(context.Background(), id2)
+	require.NoError(t, err)
+
+	_, err = m.Connect(context.Background(), id3)
+	require.True(t, errors.Is(err, connection.ErrTooManyConnections))
+	requireOpen(t, c1)
+	requireOpen(t, c2)
+
+	// a protected peer exceeds the maximum rather than being refused
+	c4, err := m.Connect(context.Background(), id4)
+	require.NoError(t, err)
+	require.Equal(t, 3, m.Len())
+	requireOpen(t, c1)
+	requireOpen(t, c2)
+	requireOpen(t, c4)
+}
+
+// TestManager_EvictIdle tests that the manager closes the connections to unprotected peers that are idle for longer
+// than the idle timeout.
+func TestManager_EvictIdle(t *testing.T) {
+	clk := &clock{now: time.Now()}
+	id1, id2, id3 := unittest.IdentifierFixture(t), unittest.IdentifierFixture(t), unittest.IdentifierFixture(t)
+	m := connection.NewManager(
+		&countingDialer{},
+		connection.WithIdleTimeout(time.Minute),
+		connection.WithProtected(func(id model.Identifier) bool { return id == id3 }),
+		connection.WithClock(clk.Now),
+	)
+	defer func() { require.NoError(t, m.Close()) }()
+
+	c1, err := m.Connect(context.Background(), id1)
+	require.NoError(t, err)
+	c2, err := m.Connect(context.Background(), id2)
+	require.NoError(t, err)
+	c3, err := m.Connect(context.Background(), id3)
+	require.NoError(t, err)
+	require.Equal(t, 0, m.EvictIdle())
+
+	clk.Advance(45 * time.Second)
+	m.Touch(id2)
+	clk.Advance(30 * time.Second)
+
+	require.Equal(t, 1, m.EvictIdle())
+	requireClosed(t, c1)
+	requireOpen(t, c2)
+	requireOpen(t, c3)
+	require.Equal(t, 2, m.Len())
+}
+
+// TestManager_Backoff tests that the manager does not dial a peer whose last dials failed before an exponentially
+// growing delay, and that errors of dialers that did not dial are not failures.
+func TestManager_Backoff(t *testing.T) {
+	clk := &clock{now: time.Now()}
+	dialer := &failingDialer{err: errors.New("unreachable")}
+	m := connection.NewManager(dialer, connection.WithBackoff(time.Second, 3*time.Second), connection.WithClock(clk.Now))
+	defer func() { require.NoError(t, m.Close()) }()
+	id := unittest.IdentifierFixture(t)
+
+	// the delays are 1s, 2s, then capped at 3s
+	for _, delay := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		dials := dialer.dials.Load()
+		_, err := m.Connect(context.Background(), id)
+		require.True(t, errors.Is(err, dialer.err))
+		require.Equal(t, dials+1, dialer.dials.Load())
+
+		clk.Advance(delay - time.Millisecond)
+		_, err = m.Connect(context.Background(), id)
+		require.True(t, errors.Is(err, connection.ErrBackoff))
+		require.Equal(t, dials+1, dialer.dials.Load())
+		clk.Advance(time.Millisecond)
+	}
+
+	// an inbound connection from the peer resets its backoff
+	_, err := m.Connect(context.Background(), id)
+	require.True(t, errors.Is(err, dialer.err))
+	c1, c2 := net.Pipe()
+	defer func() { _ = c2.Close() }()
+	conn := connection.NewStreamConnection(c1, connection.DefaultMaxFrameSize)
+	require.NoError(t, m.Add(id, conn))
+	require.True(t, m.Remove(id, conn))
+	dials := dialer.dials.Load()
+	_, err = m.Connect(context.Background(), id)
+	require.True(t, errors.Is(err, dialer.err))
+	require.Equal(t, dials+1, dialer.dials.Load())
+
+	// a peer that was not dialed is not backed off
+	dialer.err = connection.ErrNotDialed
+	other := unittest.IdentifierFixture(t)
+	for i := 0; i < 2; i++ {
+		_, err = m.Connect(context.Background(), other)
+		require.True(t, errors.Is(err, connection.ErrNotDialed))
+	}
+}
+
+// TestManager_MaxFailures tests that the manager forgets the failures of the peer whose backoff delay ends first when it
+// remembers the failures of too many peers, even if none of their backoff delays is over.
+func TestManager_MaxFailures(t *testing.T) {
+	clk := &clock{now: time.Now()}
+	dialer := &failingDialer{err: errors.New("unreachable")}
+	m := connection.NewManager(dialer, connection.WithBackoff(time.Minute, time.Hour), connection.WithClock(clk.Now))
+	defer func() { require.NoError(t, m.Close()) }()
+
+	first := unittest.IdentifierFixture(t)
+	_, err := m.Connect(context.Background(), first)
+	require.True(t, errors.Is(err, dialer.err))
+	clk.Advance(time.Second)
+	for i := 0; i < connection.MaxFailures-1; i++ {
+		_, err = m.Connect(context.Background(), unittest.IdentifierFixture(t))
+		require.True(t, errors.Is(err, dialer.err))
+	}
+	_, err = m.Connect(context.Background(), first)
+	require.True(t, errors.Is(err, connection.ErrBackoff))
+
+	// one more peer makes the first one forgotten, hence dialed again
+	_, err = m.Connect(context.Background(), unittest.IdentifierFixture(t))
+	require.True(t, errors.Is(err, dialer.err))
+	dials := dialer.dials.Load()
+	_, err = m.Connect(context.Background(), first)
+	require.True(t, errors.Is(err, dialer.err))
+	require.Equal(t, dials+1, dialer.dials.Load())
+}
+
+// TestManager_AddRemove tests that added connections are cached unless a connection to the peer is cached already,
+// and that only the cached connection of a peer is removed.
+func TestManager_AddRemove(t *testing.T) {
+	dialer := &countingDialer{}
+	m := connection.NewManager(dialer)
+	defer func() { require.NoError(t, m.Close()) }()
+	id := unittest.IdentifierFixture(t)
+
+	a1, a2 := net.Pipe()
+	defer func() { _ = a2.Close() }()
+	added := connection.NewStreamConnection(a1, connection.DefaultMaxFrameSize)
+	require.NoError(t, m.Add(id, added))
+	conn, err := m.Connect(context.Background(), id)
+	require.NoError(t, err)
+	require.Same(t, added, conn)
+	require.Equal(t, int32(0), dialer.dials.Load())
+
+	b1, b2 := net.Pipe()
+	defer func() { _ = b2.Close() }()
+	other := connection.NewStreamConnection(b1, connection.DefaultMaxFrameSize)
+	require.NoError(t, m.Add(id, other))
+	require.False(t, m.Remove(id, other))
+	require.True(t, m.Remove(id, added))
+	require.Equal(t, 0, m.Len())
+	// removed connections are left open
+	requireOpen(t, added)
+}
@@ -0,0 +1,131 @@
+package connection
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/thep2p/skipgraph-go/net/internal"
+)
+
+// frameHeaderSize is the size of the big-endian length prefix of every frame.
+const frameHeaderSize = 4
+
+// DefaultMaxFrameSize is the default maximum size of a single frame payload in bytes.
+const DefaultMaxFrameSize = 4 << 20
+
+// ErrFrameTooLarge is returned when a frame exceeds the maximum frame size of the connection.
+var ErrFrameTooLarge = errors.New("frame exceeds maximum frame size")
+
+// StreamConnection is a Connection over a reliable byte stream, e.g., a TCP or unix socket.
+// Messages are delimited by length-prefixed framing: each frame is a 4-byte big-endian length followed by the payload.
+// Send and Next are safe for concurrent use; concurrent Sends (resp. Nexts) are serialized.
+type StreamConnection struct {
+	conn         net.Conn
+	maxFrameSize int
+	writeLock    sync.Mutex // serializes writes so that frames are not interleaved
+	readLock     sync.Mutex // serializes reads so that frames are not interleaved
+	closed       atomic.Bool
+	closeOnce    sync.Once
+	closeErr     error
+}
+
+var _ internal.Connection = (*StreamConnection)(nil)
+
+// NewStreamConnection wraps the stream into a StreamConnection.
+// Args:
+//   - conn: the underlying byte stream, owned by the returned connection from now on
+//   - maxFrameSize: the maximum size of a frame payload in bytes, in both directions
+//
+// Returns the connection, which is closed by closing the underlying stream.
+func NewStreamConnection(conn net.Conn, maxFrameSize int) *StreamConnection {
+	return &StreamConnection{
+		conn:         conn,
+		maxFrameSize: maxFrameSize,
+	}
+}
+
+// RemoteAddr returns the remote address of the underlying stream, or an empty string if the connection is closed.
+func (s *StreamConnection) RemoteAddr() string {
+	if s.closed.Load() {
+		return ""
+	}
+	return s.conn.RemoteAddr().String()
+}
+
+// Send writes b as a single frame.
+// Returns io.EOF if the connection is closed, ErrFrameTooLarge if b exceeds the maximum frame size, or the write error.
+func (s *StreamConnection) Send(b []byte) error {
+	if s.closed.Load() {
+		return io.EOF
+	}
+	if len(b) > s.maxFrameSize {
+		return fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, len(b), s.maxFrameSize)
+	}
+
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(b))
+	binary.BigEndian.PutUint32(frame, uint32(len(b)))
+	frame = append(frame, b...)
+
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	if _, err := s.conn.Write(frame); err != nil {
+		return s.mapErr(fmt.Errorf("could not write frame: %w", err))
+	}
+	return nil
+}
+
+// Next blocks until the next frame is read and returns its payload.
+// Returns io.EOF if the connection is closed (locally or by the remote peer), ErrFrameTooLarge if the remote peer
+// announces a frame exceeding the maximum frame size, or the read error.
+func (s *StreamConnection) Next() ([]byte, error) {
+	if s.closed.Load() {
+		return nil, io.EOF
+	}
+
+	s.readLock.Lock()
+	defer s.readLock.Unlock()
+
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(s.conn, header[:]); err != nil {
+		return nil, s.mapErr(err)
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if uint64(size) > uint64(s.maxFrameSize) {
+		return nil, fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, size, s.maxFrameSize)
+	}
+
+	b := make([]byte, size)
+	if _, err := io.ReadFull(s.conn, b); err != nil {
+		if errors.Is(err, io.EOF) {
+			// the stream ended in the middle of a frame
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, s.mapErr(err)
+	}
+	return b, nil
+}
+
+// Close closes the underlying stream; subsequent and pending Send and Next calls return io.EOF.
+// Close is idempotent, and returns the error of closing the underlying stream.
+func (s *StreamConnection) Close() error {
+	s.closeOnce.Do(
+		func() {
+			s.closed.Store(true)
+			s.closeErr = s.conn.Close()
+		},
+	)
+	return s.closeErr
+}
+
+// mapErr maps the errors caused by a closed stream to io.EOF, and returns any other error as is.
+func (s *StreamConnection) mapErr(err error) error {
+	if s.closed.Load() || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return io.EOF
+	}
+	return err
+}
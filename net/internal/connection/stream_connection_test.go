@@ -0,0 +1,95 @@
+package connection_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/net/internal/connection"
+	"github.com/thep2p/skipgraph-go/unittest"
+)
+
+// streamConnectionPair returns two stream connections connected to each other through an in-memory pipe.
+func streamConnectionPair(t *testing.T, maxFrameSize int) (*connection.StreamConnection, *connection.StreamConnection) {
+	c1, c2 := net.Pipe()
+	s1 := connection.NewStreamConnection(c1, maxFrameSize)
+	s2 := connection.NewStreamConnection(c2, maxFrameSize)
+	t.Cleanup(
+		func() {
+			_ = s1.Close()
+			_ = s2.Close()
+		},
+	)
+	return s1, s2
+}
+
+// TestStreamConnection_Framing tests that frames are delivered whole and in order, including empty frames.
+func TestStreamConnection_Framing(t *testing.T) {
+	s1, s2 := streamConnectionPair(t, connection.DefaultMaxFrameSize)
+
+	frames := [][]byte{
+		unittest.RandomBytesFixture(t, 100),
+		{},
+		unittest.RandomBytesFixture(t, 64*1024),
+	}
+	go func() {
+		for _, f := range frames {
+			require.NoError(t, s1.Send(f))
+		}
+	}()
+
+	for _, f := range frames {
+		b, err := s2.Next()
+		require.NoError(t, err)
+		require.Equal(t, f, b)
+	}
+	require.NotEmpty(t, s2.RemoteAddr())
+}
+
+// TestStreamConnection_FrameTooLarge tests that frames exceeding the maximum frame size are rejected on both ends.
+func TestStreamConnection_FrameTooLarge(t *testing.T) {
+	s1, _ := streamConnectionPair(t, 16)
+	err := s1.Send(make([]byte, 17))
+	require.True(t, errors.Is(err, connection.ErrFrameTooLarge))
+
+	// the receiver rejects a frame announced larger than its maximum frame size
+	c1, c2 := net.Pipe()
+	sender := connection.NewStreamConnection(c1, 32)
+	receiver := connection.NewStreamConnection(c2, 16)
+	defer func() {
+		_ = sender.Close()
+		_ = receiver.Close()
+	}()
+	go func() {
+		_ = sender.Send(make([]byte, 32))
+	}()
+	_, err = receiver.Next()
+	require.True(t, errors.Is(err, connection.ErrFrameTooLarge))
+}
+
+// TestStreamConnection_Close tests that a closed connection returns io.EOF and an empty remote address, and that the
+// remote end observes io.EOF.
+func TestStreamConnection_Close(t *testing.T) {
+	s1, s2 := streamConnectionPair(t, connection.DefaultMaxFrameSize)
+
+	// a pending Next is unblocked by closing the connection
+	nextErr := make(chan error, 1)
+	go func() {
+		_, err := s1.Next()
+		nextErr <- err
+	}()
+
+	require.NoError(t, s1.Close())
+	require.NoError(t, s1.Close()) // idempotent
+	require.Equal(t, io.EOF, <-nextErr)
+	require.Empty(t, s1.RemoteAddr())
+	require.Equal(t, io.EOF, s1.Send([]byte{1}))
+	_, err := s1.Next()
+	require.Equal(t, io.EOF, err)
+
+	// the remote end observes the closure
+	_, err = s2.Next()
+	require.Equal(t, io.EOF, err)
+}
@@ -2,6 +2,26 @@ package net
 
 // Message is a network message
 type Message struct {
-	// Payload denotes the content of a message
+	// Payload denotes the content of a message.
+	// Its type must be registered in the codec registry of the network (see the codec package), and it is received
+	// with the same type it was sent with.
 	Payload interface{}
+
+	// Version is the protocol version negotiated with the origin of a received message, e.g., to down-level the
+	// messages sent back to it; zero if the network does not negotiate versions. It is ignored on send.
+	Version ProtocolVersion
+
+	// Capabilities are the capabilities advertised by the origin of a received message for its channel, which must not
+	// be modified; nil if it advertises none. They are ignored on send.
+	Capabilities []string
+}
+
+// HasCapability returns true if the origin of the message advertises the capability for its channel.
+func (m Message) HasCapability(capability string) bool {
+	for _, c := range m.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
 }
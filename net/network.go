@@ -15,6 +15,20 @@ type Network interface {
 	// If a MessageProcessor is already registered for the given channel, an error is returned.
 	// Any returned error must be treated as fatal.
 	Register(Channel, MessageProcessor) (Conduit, error)
+
+	// Unregister removes the MessageProcessor of the channel, e.g., to register a new one when upgrading an engine.
+	// The conduit returned by its registration can no longer send; messages received for the channel are answered with
+	// a protocol.Error of code ErrorCodeChannelUnknown, until a MessageProcessor is registered again.
+	// Returns an error if no MessageProcessor is registered for the channel, which is benign.
+	Unregister(Channel) error
+
+	// Channels returns the channels that have a MessageProcessor registered, in lexicographic order.
+	Channels() []Channel
+
+	// Subscribe registers a consumer of the connection events of peers, until the returned function is called or the
+	// network shuts down. Events are delivered asynchronously; only the events that happen after the subscription are
+	// delivered.
+	Subscribe(PeerEventConsumer) (unsubscribe func())
 }
 
 type Channel string
@@ -0,0 +1,179 @@
+package network
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/internal"
+)
+
+// DefaultCompressionThreshold is the default size in bytes from which frames are compressed, when compression is
+// enabled (see WithCompression).
+const DefaultCompressionThreshold = 1024
+
+// connectionCapabilities is the channel under which hello frames carry the capabilities of the connection itself, as
+// opposed to those of a channel.
+const connectionCapabilities net.Channel = ""
+
+// capabilityFlate is the connection capability of the networks decompressing frames compressed with DEFLATE.
+const capabilityFlate = "compression/flate"
+
+// compressionFlate identifies DEFLATE (RFC 1951) in compressed frames.
+const compressionFlate = 1
+
+// CompressionStats is a snapshot of the compression counters of a Network.
+type CompressionStats struct {
+	Compressed        uint64 // frames sent compressed
+	Incompressible    uint64 // frames over the threshold sent uncompressed, as compression did not shrink them
+	UncompressedBytes uint64 // size of the frames sent compressed, before compression
+	CompressedBytes   uint64 // size of the frames sent compressed, after compression
+	Decompressed      uint64 // compressed frames received
+}
+
+// Ratio returns the compressed size of the frames sent compressed relative to their uncompressed size, e.g., 0.25 for
+// frames compressed to a quarter of their size; 1 if no frame is compressed.
+func (s CompressionStats) Ratio() float64 {
+	if s.UncompressedBytes == 0 {
+		return 1
+	}
+	return float64(s.CompressedBytes) / float64(s.UncompressedBytes)
+}
+
+// compressor compresses and decompresses the frames of the connections of a network.
+// It is safe for concurrent use.
+type compressor struct {
+	threshold int
+	writers   sync.Pool // *flate.Writer
+
+	compressed        atomic.Uint64
+	incompressible    atomic.Uint64
+	uncompressedBytes atomic.Uint64
+	compressedBytes   atomic.Uint64
+	decompressed      atomic.Uint64
+}
+
+// newCompressor creates a compressor of the frames of at least threshold bytes.
+func newCompressor(threshold int) *compressor {
+	return &compressor{
+		threshold: threshold,
+		writers: sync.Pool{
+			New: func() any {
+				// BestSpeed cannot fail to create a writer
+				w, _ := flate.NewWriter(nil, flate.BestSpeed)
+				return w
+			},
+		},
+	}
+}
+
+// compress returns the compressed frame wrapping the frame, or false if compression does not shrink it.
+func (c *compressor) compress(frame []byte) ([]byte, bool) {
+	buf := bytes.NewBuffer(make([]byte, 0, len(frame)/2))
+	buf.WriteByte(byte(frameCompressed))
+	buf.WriteByte(compressionFlate)
+	buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(frame))))
+
+	w := c.writers.Get().(*flate.Writer)
+	defer c.writers.Put(w)
+	w.Reset(buf)
+	if _, err := w.Write(frame); err != nil {
+		return nil, false
+	}
+	if err := w.Close(); err != nil {
+		return nil, false
+	}
+
+	if buf.Len() >= len(frame) {
+		c.incompressible.Add(1)
+		return nil, false
+	}
+	c.compressed.Add(1)
+	c.uncompressedBytes.Add(uint64(len(frame)))
+	c.compressedBytes.Add(uint64(buf.Len()))
+	return buf.Bytes(), true
+}
+
+// decompress returns the frame wrapped by the compressed frame.
+// Returns an error wrapping ErrMalformedFrame if b is not a well-formed compressed frame, or wraps a frame of more than
+// maxFrameSize bytes.
+func (c *compressor) decompress(b []byte, maxFrameSize int) ([]byte, error) {
+	if len(b) < 6 || frameKind(b[0]) != frameCompressed {
+		return nil, fmt.Errorf("%w: expected compressed frame", ErrMalformedFrame)
+	}
+	if b[1] != compressionFlate {
+		return nil, fmt.Errorf("%w: unknown compression %d", ErrMalformedFrame, b[1])
+	}
+	size := binary.BigEndian.Uint32(b[2:6])
+	if uint64(size) > uint64(maxFrameSize) {
+		return nil, fmt.Errorf("%w: compressed frame of %d bytes exceeds %d bytes", ErrMalformedFrame, size, maxFrameSize)
+	}
+
+	r := flate.NewReader(bytes.NewReader(b[6:]))
+	defer func() {
+		_ = r.Close()
+	}()
+	// the frame grows as it is inflated rather than being allocated at its announced size, which the peer may lie about,
+	// and inflating stops past the announced size, itself bounded by maxFrameSize
+	var frame bytes.Buffer
+	if _, err := frame.ReadFrom(io.LimitReader(r, int64(size)+1)); err != nil {
+		return nil, fmt.Errorf("%w: could not decompress frame: %w", ErrMalformedFrame, err)
+	}
+	// the frame must be exactly of the announced size
+	if frame.Len() != int(size) {
+		return nil, fmt.Errorf(
+			"%w: compressed frame of %d bytes announced %d bytes",
+			ErrMalformedFrame,
+			frame.Len(),
+			size,
+		)
+	}
+	c.decompressed.Add(1)
+	return frame.Bytes(), nil
+}
+
+// stats returns a snapshot of the counters of the compressor.
+func (c *compressor) stats() CompressionStats {
+	return CompressionStats{
+		Compressed:        c.compressed.Load(),
+		Incompressible:    c.incompressible.Load(),
+		UncompressedBytes: c.uncompressedBytes.Load(),
+		CompressedBytes:   c.compressedBytes.Load(),
+		Decompressed:      c.decompressed.Load(),
+	}
+}
+
+// compressingConnection is a connection decompressing the compressed frames it receives, and compressing the frames it
+// sends from the threshold of its compressor if its peer decompresses them.
+type compressingConnection struct {
+	internal.Connection
+	compressor   *compressor
+	compress     bool // true if the peer decompresses frames
+	maxFrameSize int
+}
+
+var _ internal.Connection = (*compressingConnection)(nil)
+
+// Send sends the frame, compressed if it is large enough and compression shrinks it.
+func (c *compressingConnection) Send(frame []byte) error {
+	if c.compress && len(frame) >= c.compressor.threshold {
+		if compressed, ok := c.compressor.compress(frame); ok {
+			return c.Connection.Send(compressed)
+		}
+	}
+	return c.Connection.Send(frame)
+}
+
+// Next returns the next frame received, decompressed if it is compressed.
+func (c *compressingConnection) Next() ([]byte, error) {
+	b, err := c.Connection.Next()
+	if err != nil || len(b) == 0 || frameKind(b[0]) != frameCompressed {
+		return b, err
+	}
+	return c.compressor.decompress(b, c.maxFrameSize)
+}
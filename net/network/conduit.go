@@ -0,0 +1,29 @@
+package network
+
+import (
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net"
+)
+
+// registration is the registration of a processor for a channel; the conduit of a registration sends as long as it is
+// the current registration of its channel.
+type registration struct {
+	processor net.MessageProcessor
+}
+
+// Conduit sends messages on a channel of a Network.
+type Conduit struct {
+	network      *Network
+	channel      net.Channel
+	registration *registration
+}
+
+var _ net.Conduit = (*Conduit)(nil)
+
+// Send sends the message to the target, connecting to it if there is no connection yet.
+// Returns an error if the payload cannot be serialized, the network is not running, the channel was unregistered since
+// the conduit was returned (wrapping ErrChannelNotRegistered), the target cannot be reached, or the message cannot be
+// written; any returned error is benign.
+func (c *Conduit) Send(target model.Identifier, msg net.Message) error {
+	return c.network.send(c.channel, c.registration, target, msg)
+}
@@ -0,0 +1,45 @@
+package network
+
+import "errors"
+
+// ErrNetworkNotRunning is returned when sending through a network that has not been started or is shutting down.
+var ErrNetworkNotRunning = errors.New("network is not running")
+
+// ErrChannelRegistered is returned when registering a processor for a channel that already has one.
+var ErrChannelRegistered = errors.New("message processor already registered for channel")
+
+// ErrChannelNotRegistered is returned when unregistering a channel without processor, or sending through the conduit of a
+// channel that was unregistered since.
+var ErrChannelNotRegistered = errors.New("no message processor registered for channel")
+
+// ErrUnknownPeer is returned when no address is known for the identifier of a peer.
+var ErrUnknownPeer = errors.New("no address known for peer")
+
+// ErrPeerMismatch is returned when the peer reached at an address presents a different identifier than expected.
+var ErrPeerMismatch = errors.New("peer identifier mismatch")
+
+// ErrHandshakeFailed is returned when a connection does not complete the handshake.
+var ErrHandshakeFailed = errors.New("connection handshake failed")
+
+// ErrMalformedFrame is returned when a received frame cannot be decoded.
+var ErrMalformedFrame = errors.New("malformed frame")
+
+// ErrKeyMismatch is returned when the identity key of a network is not the key its identifier is bound to.
+var ErrKeyMismatch = errors.New("identity key does not match identifier")
+
+// ErrDialBackoff is returned when sending to a peer whose last dials failed, before the delay to dial it again is over.
+var ErrDialBackoff = errors.New("dial to peer backed off")
+
+// ErrTooManyConnections is returned when sending to a peer while the network holds its maximum number of connections,
+// all of which are to protected peers.
+var ErrTooManyConnections = errors.New("too many connections")
+
+// ErrIncompatibleVersion is returned when a connection fails its handshake as its sides support no common protocol
+// version.
+var ErrIncompatibleVersion = errors.New("no common protocol version")
+
+// ErrEndpointInUse is returned when listening on the name of an in-memory endpoint that is listened on already.
+var ErrEndpointInUse = errors.New("in-memory endpoint already in use")
+
+// ErrEndpointNotFound is returned when dialing the name of an in-memory endpoint that nothing listens on.
+var ErrEndpointNotFound = errors.New("no in-memory endpoint listening")
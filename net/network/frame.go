@@ -0,0 +1,253 @@
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/codec"
+	"github.com/thep2p/skipgraph-go/net/protocol"
+)
+
+// frameKind identifies the kind of a frame exchanged over a connection.
+type frameKind uint8
+
+const (
+	// frameHello opens a connection and carries the identifier of the sender, the protocol versions it supports and the
+	// capabilities it advertises for its channels.
+	// Body layout: identifier (32) | version count (1) | versions (2 each) | channel count (2) | channels, each:
+	// channel length (2) | channel | capability count (1) | capabilities, each: length (1) | capability.
+	// A body of the identifier alone is the hello of the releases predating negotiation, which speak version 1 only.
+	frameHello frameKind = 1
+	// frameMessage carries a message sent on a channel.
+	// Body layout: channel length (2) | channel | payload (encoded by a codec.Registry).
+	frameMessage frameKind = 2
+	// frameError answers a message frame that could not be processed, e.g., as its channel has no processor.
+	// Body layout: channel length (2) | channel | protocol.Error (encoded by protocol.Encode).
+	// Error frames are never answered.
+	frameError frameKind = 3
+	// frameCompressed wraps another frame compressed, sent only to peers advertising they decompress it.
+	// Body layout: compression (1) | size of the frame (4) | compressed frame.
+	frameCompressed frameKind = 4
+)
+
+// legacyVersion is the protocol version spoken by the releases predating negotiation.
+const legacyVersion net.ProtocolVersion = 1
+
+// hello is the content of a hello frame.
+type hello struct {
+	id           model.Identifier
+	versions     []net.ProtocolVersion
+	capabilities map[net.Channel][]string
+}
+
+// encodeHello returns the hello frame announcing the hello.
+// Returns an error if the hello has no version, or too many versions, channels or capabilities to be encoded.
+func encodeHello(h hello) ([]byte, error) {
+	if len(h.versions) == 0 || len(h.versions) > math.MaxUint8 {
+		return nil, fmt.Errorf("hello must carry 1 to %d versions, got %d", math.MaxUint8, len(h.versions))
+	}
+	if len(h.capabilities) > math.MaxUint16 {
+		return nil, fmt.Errorf("hello carries capabilities of %d channels, exceeding %d", len(h.capabilities), math.MaxUint16)
+	}
+
+	b := make([]byte, 0, 1+model.IdentifierSizeBytes+1+2*len(h.versions)+2)
+	b = append(b, byte(frameHello))
+	b = append(b, h.id[:]...)
+	b = append(b, uint8(len(h.versions)))
+	for _, v := range h.versions {
+		b = binary.BigEndian.AppendUint16(b, uint16(v))
+	}
+
+	// channels in order, so that the frame is deterministic
+	channels := make([]net.Channel, 0, len(h.capabilities))
+	for channel := range h.capabilities {
+		channels = append(channels, channel)
+	}
+	slices.Sort(channels)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(channels)))
+	for _, channel := range channels {
+		capabilities := h.capabilities[channel]
+		if len(channel) > math.MaxUint16 || len(capabilities) > math.MaxUint8 {
+			return nil, fmt.Errorf("channel %s has too long a name or too many capabilities", channel)
+		}
+		b = binary.BigEndian.AppendUint16(b, uint16(len(channel)))
+		b = append(b, channel...)
+		b = append(b, uint8(len(capabilities)))
+		for _, c := range capabilities {
+			if len(c) > math.MaxUint8 {
+				return nil, fmt.Errorf("capability of %d bytes exceeds %d bytes", len(c), math.MaxUint8)
+			}
+			b = append(b, uint8(len(c)))
+			b = append(b, c...)
+		}
+	}
+	return b, nil
+}
+
+// decodeHello returns the hello carried by a hello frame.
+// Returns an error wrapping ErrMalformedFrame if b is not a well-formed hello frame.
+func decodeHello(b []byte) (hello, error) {
+	if len(b) < 1+model.IdentifierSizeBytes || frameKind(b[0]) != frameHello {
+		return hello{}, fmt.Errorf("%w: expected hello frame", ErrMalformedFrame)
+	}
+	var h hello
+	copy(h.id[:], b[1:])
+	b = b[1+model.IdentifierSizeBytes:]
+	if len(b) == 0 {
+		h.versions = []net.ProtocolVersion{legacyVersion}
+		return h, nil
+	}
+
+	r := &frameReader{b: b}
+	count := int(r.uint8())
+	for i := 0; i < count; i++ {
+		h.versions = append(h.versions, net.ProtocolVersion(r.uint16()))
+	}
+	channels := int(r.uint16())
+	if channels > 0 {
+		h.capabilities = make(map[net.Channel][]string, min(channels, len(b)))
+	}
+	for i := 0; i < channels && r.err == nil; i++ {
+		channel := net.Channel(r.next(int(r.uint16())))
+		count := int(r.uint8())
+		capabilities := make([]string, 0, count)
+		for j := 0; j < count && r.err == nil; j++ {
+			capabilities = append(capabilities, string(r.next(int(r.uint8()))))
+		}
+		h.capabilities[channel] = capabilities
+	}
+	if r.err != nil {
+		return hello{}, r.err
+	}
+	if len(r.b) > 0 {
+		return hello{}, fmt.Errorf("%w: %d trailing bytes in hello frame", ErrMalformedFrame, len(r.b))
+	}
+	if len(h.versions) == 0 {
+		return hello{}, fmt.Errorf("%w: hello frame without version", ErrMalformedFrame)
+	}
+	return h, nil
+}
+
+// frameReader reads the fields of a frame body, recording the first truncation.
+type frameReader struct {
+	b   []byte
+	err error
+}
+
+// next returns the next n bytes, or nil once the body is truncated.
+func (r *frameReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < n {
+		r.err = fmt.Errorf("%w: truncated frame", ErrMalformedFrame)
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *frameReader) uint8() uint8 {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *frameReader) uint16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+// negotiate returns the highest version supported by both sides, or false if there is none.
+func negotiate(local []net.ProtocolVersion, remote []net.ProtocolVersion) (net.ProtocolVersion, bool) {
+	var best net.ProtocolVersion
+	found := false
+	for _, v := range remote {
+		if slices.Contains(local, v) && (!found || v > best) {
+			best, found = v, true
+		}
+	}
+	return best, found
+}
+
+// encodeMessage returns the message frame carrying msg on the channel, with its payload encoded by the registry.
+// Returns an error wrapping codec.ErrUnknownType if the type of the payload is not registered, or an error if the
+// channel name is too long to be encoded.
+func encodeMessage(registry *codec.Registry, channel net.Channel, msg net.Message) ([]byte, error) {
+	if len(channel) > math.MaxUint16 {
+		return nil, fmt.Errorf("channel name of %d bytes exceeds %d bytes", len(channel), math.MaxUint16)
+	}
+	payload, err := registry.Encode(msg.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode payload: %w", err)
+	}
+
+	b := make([]byte, 0, 1+2+len(channel)+len(payload))
+	b = append(b, byte(frameMessage))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(channel)))
+	b = append(b, channel...)
+	return append(b, payload...), nil
+}
+
+// decodeMessage returns the channel and message carried by a message frame, with its payload decoded by the registry.
+// Returns an error wrapping ErrMalformedFrame if b is not a well-formed message frame, or the decoding error of the
+// payload, e.g., wrapping codec.ErrUnknownCode if its type is not registered.
+func decodeMessage(registry *codec.Registry, b []byte) (net.Channel, net.Message, error) {
+	if len(b) < 3 || frameKind(b[0]) != frameMessage {
+		return "", net.Message{}, fmt.Errorf("%w: expected message frame", ErrMalformedFrame)
+	}
+	size := int(binary.BigEndian.Uint16(b[1:3]))
+	b = b[3:]
+	if len(b) < size {
+		return "", net.Message{}, fmt.Errorf("%w: truncated message frame", ErrMalformedFrame)
+	}
+	channel := net.Channel(b[:size])
+
+	payload, err := registry.Decode(b[size:])
+	if err != nil {
+		return "", net.Message{}, fmt.Errorf("could not decode payload on channel %s: %w", channel, err)
+	}
+	return channel, net.Message{Payload: payload}, nil
+}
+
+// encodeError returns the error frame answering a message frame received on the channel.
+func encodeError(channel net.Channel, e protocol.Error) []byte {
+	body := protocol.Encode(e)
+	b := make([]byte, 0, 1+2+len(channel)+len(body))
+	b = append(b, byte(frameError))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(channel)))
+	b = append(b, channel...)
+	return append(b, body...)
+}
+
+// decodeError returns the channel and error carried by an error frame.
+// Returns an error wrapping ErrMalformedFrame if b is not a well-formed error frame.
+func decodeError(b []byte) (net.Channel, protocol.Error, error) {
+	if len(b) < 3 || frameKind(b[0]) != frameError {
+		return "", protocol.Error{}, fmt.Errorf("%w: expected error frame", ErrMalformedFrame)
+	}
+	size := int(binary.BigEndian.Uint16(b[1:3]))
+	b = b[3:]
+	if len(b) < size {
+		return "", protocol.Error{}, fmt.Errorf("%w: truncated error frame", ErrMalformedFrame)
+	}
+	channel := net.Channel(b[:size])
+
+	msg, err := protocol.Decode(b[size:])
+	if err != nil {
+		return "", protocol.Error{}, fmt.Errorf("%w: %w", ErrMalformedFrame, err)
+	}
+	e, ok := msg.(protocol.Error)
+	if !ok {
+		return "", protocol.Error{}, fmt.Errorf("%w: error frame carries %s", ErrMalformedFrame, msg.Type())
+	}
+	return channel, e, nil
+}
@@ -0,0 +1,424 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	stdnet "net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/thep2p/skipgraph-go/core/model"
+)
+
+// MemoryBufferSize is the number of bytes each direction of an in-memory stream buffers before writes block.
+const MemoryBufferSize = 64 << 10
+
+// MemoryTransport is the Transport over in-memory streams between the networks of a single process, e.g., to run many
+// skip graph nodes in a simulation or to embed several nodes in one binary.
+// Networks reach each other only if they share the same MemoryTransport, which acts as the switch between them; a
+// network over it behaves as one over TCP, i.e., with the same handshake, asynchronous dispatch, bounded queues and
+// lifecycle. It is safe for concurrent use.
+type MemoryTransport struct {
+	l         sync.Mutex
+	listeners map[string]*memoryListener
+	seq       uint64 // numbers the names picked for anonymous listeners and dialing endpoints
+}
+
+var _ Transport = (*MemoryTransport)(nil)
+
+// NewMemoryTransport creates a new MemoryTransport without listeners.
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{listeners: make(map[string]*memoryListener)}
+}
+
+// Name returns model.TransportInMemory.
+func (t *MemoryTransport) Name() model.Transport {
+	return model.TransportInMemory
+}
+
+// Listen starts listening on the endpoint name of the address; an empty name picks a free name.
+// Returns an error wrapping ErrEndpointInUse if another listener of the transport listens on the name.
+func (t *MemoryTransport) Listen(addr model.Address) (stdnet.Listener, error) {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	name := addr.Path()
+	if name == "" {
+		name = t.nextName("node")
+	}
+	if _, ok := t.listeners[name]; ok {
+		return nil, fmt.Errorf("could not listen on %s: %w", addr, ErrEndpointInUse)
+	}
+	l := &memoryListener{
+		transport: t,
+		addr:      memoryAddr(name),
+		conns:     make(chan stdnet.Conn),
+		closed:    make(chan struct{}),
+	}
+	t.listeners[name] = l
+	return l, nil
+}
+
+// Dial connects to the listener of the transport on the endpoint name of the address, waiting for the listener to
+// accept the stream.
+// Returns an error wrapping ErrEndpointNotFound if no listener of the transport listens on the name, or the error of
+// ctx if it is done before the stream is accepted.
+func (t *MemoryTransport) Dial(ctx context.Context, addr model.Address) (stdnet.Conn, error) {
+	t.l.Lock()
+	l, ok := t.listeners[addr.Path()]
+	local := memoryAddr(t.nextName("dialer"))
+	t.l.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("could not dial %s: %w", addr, ErrEndpointNotFound)
+	}
+
+	dialed, accepted := memoryPipe(local, l.addr)
+	select {
+	case l.conns <- accepted:
+		return dialed, nil
+	case <-l.closed:
+		_ = dialed.Close()
+		return nil, fmt.Errorf("could not dial %s: %w", addr, ErrEndpointNotFound)
+	case <-ctx.Done():
+		_ = dialed.Close()
+		return nil, fmt.Errorf("could not dial %s: %w", addr, ctx.Err())
+	}
+}
+
+// Address converts the address of a MemoryTransport listener to an in-memory model.Address.
+func (t *MemoryTransport) Address(addr stdnet.Addr) (model.Address, error) {
+	memAddr, ok := addr.(memoryAddr)
+	if !ok {
+		return model.Address{}, fmt.Errorf("%w: not an in-memory address: %s", model.ErrInvalidAddress, addr)
+	}
+	return model.NewInMemoryAddress(string(memAddr)), nil
+}
+
+// nextName returns a name with the prefix that no listener of the transport listens on.
+// The lock of the transport must be held.
+func (t *MemoryTransport) nextName(prefix string) string {
+	for {
+		t.seq++
+		name := prefix + "-" + strconv.FormatUint(t.seq, 10)
+		if _, ok := t.listeners[name]; !ok {
+			return name
+		}
+	}
+}
+
+// remove stops routing the dials to the name of the listener to it.
+func (t *MemoryTransport) remove(l *memoryListener) {
+	t.l.Lock()
+	defer t.l.Unlock()
+	if t.listeners[string(l.addr)] == l {
+		delete(t.listeners, string(l.addr))
+	}
+}
+
+// memoryListener is a stdnet.Listener accepting the streams dialed through a MemoryTransport.
+type memoryListener struct {
+	transport *MemoryTransport
+	addr      memoryAddr
+	conns     chan stdnet.Conn
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Accept waits for and returns the next stream dialed to the listener.
+// Returns stdnet.ErrClosed once the listener is closed.
+func (l *memoryListener) Accept() (stdnet.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, stdnet.ErrClosed
+	}
+}
+
+// Close stops the listener, freeing its name; dials waiting to be accepted fail.
+func (l *memoryListener) Close() error {
+	l.closeOnce.Do(
+		func() {
+			l.transport.remove(l)
+			close(l.closed)
+		},
+	)
+	return nil
+}
+
+// Addr returns the name of the listener.
+func (l *memoryListener) Addr() stdnet.Addr {
+	return l.addr
+}
+
+// memoryConn is one end of an in-memory stream between two endpoints of a MemoryTransport.
+// Each direction of the stream is buffered up to MemoryBufferSize bytes, as sockets are, so that both ends may write
+// before reading, e.g., their hello during the handshake; writes block while the buffer is full.
+type memoryConn struct {
+	local         memoryAddr
+	remote        memoryAddr
+	in            *memoryBuffer // read by this end, written by the other end
+	out           *memoryBuffer // written by this end, read by the other end
+	readDeadline  *memoryDeadline
+	writeDeadline *memoryDeadline
+	closeOnce     sync.Once
+	closed        chan struct{}
+}
+
+var _ stdnet.Conn = (*memoryConn)(nil)
+
+// memoryPipe returns both ends of a new in-memory stream between the local and remote endpoints.
+func memoryPipe(local memoryAddr, remote memoryAddr) (*memoryConn, *memoryConn) {
+	toRemote := newMemoryBuffer()
+	toLocal := newMemoryBuffer()
+	return newMemoryConn(local, remote, toLocal, toRemote), newMemoryConn(remote, local, toRemote, toLocal)
+}
+
+func newMemoryConn(local memoryAddr, remote memoryAddr, in *memoryBuffer, out *memoryBuffer) *memoryConn {
+	return &memoryConn{
+		local:         local,
+		remote:        remote,
+		in:            in,
+		out:           out,
+		readDeadline:  newMemoryDeadline(),
+		writeDeadline: newMemoryDeadline(),
+		closed:        make(chan struct{}),
+	}
+}
+
+// Read reads the bytes written by the other end, waiting for some to be written.
+// Returns io.EOF once the other end is closed and every byte it wrote is read.
+func (c *memoryConn) Read(b []byte) (int, error) {
+	for {
+		select {
+		case <-c.closed:
+			return 0, io.ErrClosedPipe
+		case <-c.readDeadline.wait():
+			return 0, os.ErrDeadlineExceeded
+		default:
+		}
+
+		n, closed := c.in.read(b)
+		if n > 0 || len(b) == 0 {
+			return n, nil
+		}
+		if closed {
+			return 0, io.EOF
+		}
+
+		select {
+		case <-c.in.readable:
+		case <-c.closed:
+		case <-c.readDeadline.wait():
+		}
+	}
+}
+
+// Write writes the bytes for the other end, waiting for room in the buffer of the stream.
+// Returns io.ErrClosedPipe if either end is closed before all bytes are written.
+func (c *memoryConn) Write(b []byte) (int, error) {
+	written := 0
+	for {
+		select {
+		case <-c.closed:
+			return written, io.ErrClosedPipe
+		case <-c.writeDeadline.wait():
+			return written, os.ErrDeadlineExceeded
+		default:
+		}
+
+		n, closed := c.out.write(b[written:])
+		written += n
+		if closed {
+			return written, io.ErrClosedPipe
+		}
+		if written == len(b) {
+			return written, nil
+		}
+
+		select {
+		case <-c.out.writable:
+		case <-c.closed:
+		case <-c.writeDeadline.wait():
+		}
+	}
+}
+
+// Close closes both directions of the stream: the other end reads the bytes buffered for it then io.EOF, and its
+// writes fail.
+func (c *memoryConn) Close() error {
+	c.closeOnce.Do(
+		func() {
+			close(c.closed)
+			c.in.close()
+			c.out.close()
+		},
+	)
+	return nil
+}
+
+// LocalAddr returns the name of the local endpoint.
+func (c *memoryConn) LocalAddr() stdnet.Addr {
+	return c.local
+}
+
+// RemoteAddr returns the name of the remote endpoint.
+func (c *memoryConn) RemoteAddr() stdnet.Addr {
+	return c.remote
+}
+
+// SetDeadline sets the deadlines of both reads and writes.
+func (c *memoryConn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// SetReadDeadline sets the deadline of reads.
+func (c *memoryConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline sets the deadline of writes.
+func (c *memoryConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// memoryBuffer holds the bytes of one direction of an in-memory stream, up to MemoryBufferSize bytes.
+type memoryBuffer struct {
+	l        sync.Mutex
+	buf      bytes.Buffer
+	closed   bool
+	readable chan struct{} // signaled when bytes are written or the buffer is closed
+	writable chan struct{} // signaled when bytes are read or the buffer is closed
+}
+
+func newMemoryBuffer() *memoryBuffer {
+	return &memoryBuffer{
+		readable: make(chan struct{}, 1),
+		writable: make(chan struct{}, 1),
+	}
+}
+
+// read moves the buffered bytes to b.
+// Returns the number of bytes read, and whether the buffer is closed.
+func (m *memoryBuffer) read(b []byte) (int, bool) {
+	m.l.Lock()
+	defer m.l.Unlock()
+	n, _ := m.buf.Read(b)
+	if n > 0 {
+		signal(m.writable)
+	}
+	return n, m.closed
+}
+
+// write buffers as many bytes of b as there is room for, unless the buffer is closed.
+// Returns the number of bytes written, and whether the buffer is closed.
+func (m *memoryBuffer) write(b []byte) (int, bool) {
+	m.l.Lock()
+	defer m.l.Unlock()
+	if m.closed {
+		return 0, true
+	}
+	n := min(len(b), MemoryBufferSize-m.buf.Len())
+	if n > 0 {
+		m.buf.Write(b[:n])
+		signal(m.readable)
+	}
+	return n, false
+}
+
+// close closes the buffer, waking up its reader and writer.
+func (m *memoryBuffer) close() {
+	m.l.Lock()
+	defer m.l.Unlock()
+	m.closed = true
+	signal(m.readable)
+	signal(m.writable)
+}
+
+// signal wakes up the goroutine waiting on ch, if any, without blocking.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// memoryDeadline is a deadline of a memoryConn, whose wait channel is closed once the deadline is exceeded.
+type memoryDeadline struct {
+	l        sync.Mutex
+	timer    *time.Timer
+	exceeded chan struct{}
+}
+
+func newMemoryDeadline() *memoryDeadline {
+	return &memoryDeadline{exceeded: make(chan struct{})}
+}
+
+// set sets the deadline to t; the zero time clears the deadline.
+func (d *memoryDeadline) set(t time.Time) {
+	d.l.Lock()
+	defer d.l.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		// the timer fired, wait for it to close the channel
+		<-d.exceeded
+	}
+	d.timer = nil
+
+	exceeded := isClosed(d.exceeded)
+	if t.IsZero() {
+		if exceeded {
+			d.exceeded = make(chan struct{})
+		}
+		return
+	}
+	if dur := time.Until(t); dur > 0 {
+		if exceeded {
+			d.exceeded = make(chan struct{})
+		}
+		ch := d.exceeded
+		d.timer = time.AfterFunc(dur, func() { close(ch) })
+		return
+	}
+	if !exceeded {
+		close(d.exceeded)
+	}
+}
+
+// wait returns a channel that is closed once the deadline is exceeded.
+func (d *memoryDeadline) wait() <-chan struct{} {
+	d.l.Lock()
+	defer d.l.Unlock()
+	return d.exceeded
+}
+
+// isClosed returns true if ch is closed, false otherwise.
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// memoryAddr is the stdnet.Addr of an endpoint of a MemoryTransport, i.e., its name.
+type memoryAddr string
+
+// Network returns model.TransportInMemory.
+func (a memoryAddr) Network() string {
+	return string(model.TransportInMemory)
+}
+
+// String returns the name of the endpoint.
+func (a memoryAddr) String() string {
+	return string(a)
+}
@@ -0,0 +1,25 @@
+package network
+
+import (
+	"github.com/thep2p/skipgraph-go/core"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+)
+
+// LookupTableNeighbors returns a function telling whether a peer is currently a neighbor of the node in its lookup
+// table, at any level and in any direction, e.g., to protect the connections to neighbors (see WithProtectedPeers).
+// The lookup table is read on every call, hence the function follows its changes; entries that cannot be read are
+// treated as empty.
+func LookupTableNeighbors(table core.ImmutableLookupTable) func(model.Identifier) bool {
+	return func(id model.Identifier) bool {
+		for level := types.Level(0); level < core.MaxLookupTableLevel; level++ {
+			for _, dir := range []types.Direction{types.DirectionLeft, types.DirectionRight} {
+				neighbor, err := table.GetEntry(dir, level)
+				if err == nil && neighbor != nil && neighbor.GetIdentifier() == id {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
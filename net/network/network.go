@@ -1 +1,953 @@
+// Package network implements net.Network over byte-stream transports, e.g., TCP or Unix domain sockets.
+//
+// Every connection starts with a handshake in which both sides send a hello frame carrying their identifier; the
+// dialing side verifies that the peer it reached is the one it intended to. After the handshake, each frame carries a
+// message for a channel and is dispatched asynchronously to the net.MessageProcessor registered for that channel (see
+// dispatch.Dispatcher).
+// Frames are delimited by length-prefixed framing (see connection.StreamConnection).
+//
+// A network given the identity key of its node (see WithIdentityKey) secures its connections with mutually
+// authenticated TLS 1.3 before the hello frames: each side proves the possession of the key its identifier is bound to
+// (see crypto.IdentifierOf), and a peer announcing another identifier than its key's is rejected. The origins reported
+// to processors are then authenticated; without a key, connections are neither encrypted nor authenticated.
 package network
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	stdnet "net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/thep2p/skipgraph-go/core/crypto"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/modules"
+	"github.com/thep2p/skipgraph-go/modules/component"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/codec"
+	"github.com/thep2p/skipgraph-go/net/dispatch"
+	"github.com/thep2p/skipgraph-go/net/events"
+	"github.com/thep2p/skipgraph-go/net/internal"
+	"github.com/thep2p/skipgraph-go/net/internal/connection"
+	"github.com/thep2p/skipgraph-go/net/protocol"
+	"github.com/thep2p/skipgraph-go/net/ratelimit"
+)
+
+// DefaultDialTimeout is the default time allowed to establish an outbound connection, including its handshake.
+const DefaultDialTimeout = 5 * time.Second
+
+// DefaultHandshakeTimeout is the default time allowed for a connection to complete its handshake.
+const DefaultHandshakeTimeout = 5 * time.Second
+
+// DefaultMaxConnections is the default number of peers a network keeps a connection to.
+const DefaultMaxConnections = 256
+
+// DefaultIdleTimeout is the default time after which a connection that carries no message is closed.
+const DefaultIdleTimeout = 5 * time.Minute
+
+// DefaultMinBackoff and DefaultMaxBackoff bound the default delay before dialing again a peer whose last dials failed.
+const (
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// Network is a net.Network over a byte-stream Transport.
+// It listens on its address once started (Ready is closed once listening), dials peers on demand when sending, and
+// caches one connection per peer, up to a maximum number of peers beyond which the least recently used connection is
+// closed, unless its peer is protected (see WithProtectedPeers). Idle connections are closed, and peers whose last
+// dials failed are not dialed again before an exponential backoff delay. On shutdown, it stops listening and closes
+// all connections; Done is closed once all connections are drained.
+type Network struct {
+	*component.Manager
+	logger           zerolog.Logger
+	id               model.Identifier // identifier of the node this network belongs to
+	listenAddr       model.Address    // the address to listen on, as configured
+	transport        Transport
+	resolver         Resolver
+	codecs           *codec.Registry
+	maxFrameSize     int
+	dialTimeout      time.Duration
+	handshakeTimeout time.Duration
+	dispatchOpts     []dispatch.Option
+	dispatcher       *dispatch.Dispatcher // dispatches inbound messages to their processor
+	limiter          *ratelimit.Limiter   // limits the rate of inbound messages, if set
+	key              ed25519.PrivateKey   // the identity key of the node, if connections are secured
+	tlsConfig        *tls.Config          // secures connections with the identity key, if set
+	idleTimeout      time.Duration
+	poolOpts         []connection.Option
+	pool             *connection.Manager // caches the connection used to send to each peer
+	peerEvents       *events.Distributor
+	versions         []net.ProtocolVersion    // supported, in decreasing order
+	capabilities     map[net.Channel][]string // advertised to peers
+	hello            []byte                   // the hello frame of the network
+	self             session                  // the session of the messages the network sends to itself
+	compressor       *compressor              // compresses frames, if enabled
+	priorities       map[net.Channel]net.Priority
+
+	l          sync.RWMutex
+	ctx        modules.ThrowableContext
+	closing    bool
+	listener   stdnet.Listener
+	addr       model.Address // the address actually listened on
+	processors map[net.Channel]*registration
+	served     map[internal.Connection]model.Identifier // every connection being read from, to its peer
+
+	wg sync.WaitGroup // tracks the accept loop and the goroutines reading from connections
+}
+
+var _ net.Network = (*Network)(nil)
+
+// Option is a functional option for configuring a Network.
+type Option func(*Network)
+
+// WithTransport sets the transport of the network; by default, the transport is picked by the listen address.
+// Networks listening on in-memory addresses have no default transport, they must share a MemoryTransport set here.
+func WithTransport(t Transport) Option {
+	return func(n *Network) {
+		n.transport = t
+	}
+}
+
+// WithCodecs sets the registry used to encode and decode message payloads; defaults to codec.NewDefaultRegistry.
+func WithCodecs(registry *codec.Registry) Option {
+	return func(n *Network) {
+		n.codecs = registry
+	}
+}
+
+// WithMaxFrameSize sets the maximum size of a frame in bytes; defaults to connection.DefaultMaxFrameSize.
+func WithMaxFrameSize(size int) Option {
+	return func(n *Network) {
+		n.maxFrameSize = size
+	}
+}
+
+// WithDialTimeout sets the time allowed to establish an outbound connection; defaults to DefaultDialTimeout.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(n *Network) {
+		n.dialTimeout = timeout
+	}
+}
+
+// WithHandshakeTimeout sets the time allowed to complete a handshake; defaults to DefaultHandshakeTimeout.
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(n *Network) {
+		n.handshakeTimeout = timeout
+	}
+}
+
+// WithDispatchOptions sets the options of the dispatcher of inbound messages, e.g., the capacity and policy of the
+// queues of channels (see dispatch.Option).
+func WithDispatchOptions(opts ...dispatch.Option) Option {
+	return func(n *Network) {
+		n.dispatchOpts = append(n.dispatchOpts, opts...)
+	}
+}
+
+// WithRateLimiter sets the limiter of the rate of inbound messages; messages over the limit are dropped, and the
+// connections of banned peers are closed and rejected while they are banned. Unlimited by default.
+func WithRateLimiter(limiter *ratelimit.Limiter) Option {
+	return func(n *Network) {
+		n.limiter = limiter
+	}
+}
+
+// WithIdentityKey secures the connections of the network with mutually authenticated TLS, proving the identifier of
+// the node with the key it is bound to; peers must do the same. Connections are not secured by default.
+func WithIdentityKey(key ed25519.PrivateKey) Option {
+	return func(n *Network) {
+		n.key = key
+	}
+}
+
+// WithMaxConnections sets the number of peers the network keeps a connection to; unlimited if not positive. Defaults
+// to DefaultMaxConnections.
+func WithMaxConnections(max int) Option {
+	return func(n *Network) {
+		n.poolOpts = append(n.poolOpts, connection.WithMaxConnections(max))
+	}
+}
+
+// WithIdleTimeout sets the time after which a connection that carries no message is closed; never if not positive.
+// Defaults to DefaultIdleTimeout.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(n *Network) {
+		n.idleTimeout = timeout
+	}
+}
+
+// WithProtectedPeers sets the function telling the peers whose connection is never closed to make room for others, or
+// for being idle, e.g., the neighbors of the node (see LookupTableNeighbors). None by default.
+func WithProtectedPeers(protected func(model.Identifier) bool) Option {
+	return func(n *Network) {
+		n.poolOpts = append(n.poolOpts, connection.WithProtected(protected))
+	}
+}
+
+// WithDialBackoff sets the delay before dialing again a peer whose last dial failed, which doubles with every further
+// failure up to max; disabled if min is not positive. Defaults to DefaultMinBackoff and DefaultMaxBackoff.
+func WithDialBackoff(min time.Duration, max time.Duration) Option {
+	return func(n *Network) {
+		n.poolOpts = append(n.poolOpts, connection.WithBackoff(min, max))
+	}
+}
+
+// WithProtocolVersions sets the protocol versions the network supports; a connection speaks the highest version
+// supported by both sides, and fails its handshake if there is none. Defaults to net.CurrentProtocolVersion only.
+func WithProtocolVersions(versions ...net.ProtocolVersion) Option {
+	return func(n *Network) {
+		n.versions = versions
+	}
+}
+
+// WithCapabilities sets the capabilities the network advertises to its peers for the channel, which their processors
+// receive along with the messages of the channel (see net.Message.Capabilities).
+func WithCapabilities(channel net.Channel, capabilities ...string) Option {
+	return func(n *Network) {
+		n.capabilities[channel] = capabilities
+	}
+}
+
+// WithCompression enables the compression of the frames of at least threshold bytes (e.g.,
+// DefaultCompressionThreshold) with DEFLATE, over the connections whose peer enables it too; frames are sent
+// uncompressed to other peers. Compression is disabled by default.
+func WithCompression(threshold int) Option {
+	return func(n *Network) {
+		n.compressor = newCompressor(threshold)
+	}
+}
+
+// WithChannelPriority sets the priority class of the channel, whose messages are sent and dispatched before those of
+// lower classes, e.g., net.PriorityHigh for failure detection and net.PriorityLow for bulk transfers. Channels are of
+// normal priority by default.
+func WithChannelPriority(channel net.Channel, priority net.Priority) Option {
+	return func(n *Network) {
+		n.priorities[channel] = priority
+		n.dispatchOpts = append(n.dispatchOpts, dispatch.WithChannelPriority(channel, priority))
+	}
+}
+
+// NewNetwork creates a new Network.
+// Args:
+//   - logger: zerolog.Logger for logging
+//   - id: the identifier of the node, announced to peers during the handshake
+//   - listenAddr: the address to listen on; a tcp port of 0 or an empty in-memory name picks a free one (see Address)
+//   - resolver: resolves the identifiers of peers to their addresses when dialing
+//   - opts: variadic options for configuring the network
+//
+// Returns initialized network (not started), or an error:
+//   - wrapping model.ErrUnknownTransport if there is no transport for the listen address.
+//   - wrapping ErrKeyMismatch if the identity key is not the one the identifier is bound to.
+func NewNetwork(
+	logger zerolog.Logger,
+	id model.Identifier,
+	listenAddr model.Address,
+	resolver Resolver,
+	opts ...Option,
+) (*Network, error) {
+	n := &Network{
+		logger: logger.With().
+			Str("component", "network").
+			Str("identifier", id.String()).
+			Logger(),
+		id:               id,
+		listenAddr:       listenAddr,
+		resolver:         resolver,
+		codecs:           codec.NewDefaultRegistry(),
+		maxFrameSize:     connection.DefaultMaxFrameSize,
+		dialTimeout:      DefaultDialTimeout,
+		handshakeTimeout: DefaultHandshakeTimeout,
+		idleTimeout:      DefaultIdleTimeout,
+		poolOpts: []connection.Option{
+			connection.WithMaxConnections(DefaultMaxConnections),
+			connection.WithBackoff(DefaultMinBackoff, DefaultMaxBackoff),
+		},
+		versions:     []net.ProtocolVersion{net.CurrentProtocolVersion},
+		capabilities: make(map[net.Channel][]string),
+		priorities:   make(map[net.Channel]net.Priority),
+		processors:   make(map[net.Channel]*registration),
+		served:       make(map[internal.Connection]model.Identifier),
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	n.pool = connection.NewManager(
+		connection.DialerFunc(n.dial),
+		append(n.poolOpts, connection.WithIdleTimeout(n.idleTimeout))...,
+	)
+
+	if n.transport == nil {
+		switch listenAddr.Transport() {
+		case model.TransportTCP:
+			n.transport = NewTCPTransport()
+		case model.TransportUnix:
+			n.transport = NewUnixTransport()
+		default:
+			return nil, fmt.Errorf("%w: no transport for %s", model.ErrUnknownTransport, listenAddr)
+		}
+	}
+	if n.transport.Name() != listenAddr.Transport() {
+		return nil, fmt.Errorf(
+			"%w: cannot listen on %s with a %s transport",
+			model.ErrUnknownTransport,
+			listenAddr,
+			n.transport.Name(),
+		)
+	}
+
+	if n.key != nil {
+		keyID, err := crypto.IdentifierOfKey(n.key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrKeyMismatch, err)
+		}
+		if keyID != id {
+			return nil, fmt.Errorf("%w: key of %s", ErrKeyMismatch, keyID.String())
+		}
+		n.tlsConfig, err = newTLSConfig(n.key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if n.compressor != nil {
+		n.capabilities[connectionCapabilities] = append(slices.Clone(n.capabilities[connectionCapabilities]), capabilityFlate)
+	}
+	n.versions = slices.Clone(n.versions)
+	slices.Sort(n.versions)
+	slices.Reverse(n.versions)
+	n.versions = slices.Compact(n.versions)
+	frame, err := encodeHello(hello{id: id, versions: n.versions, capabilities: n.capabilities})
+	if err != nil {
+		return nil, fmt.Errorf("invalid protocol versions or capabilities: %w", err)
+	}
+	n.hello = frame
+	n.self = session{peer: id, version: n.versions[0], capabilities: n.capabilities}
+
+	n.dispatcher = dispatch.NewDispatcher(n.logger, n.dispatchOpts...)
+	n.peerEvents = events.NewDistributor(n.logger)
+	n.Manager = component.NewManager(
+		n.logger,
+		component.WithStartupLogic(n.listen),
+		component.WithShutdownLogic(n.shutdown),
+	)
+	return n, nil
+}
+
+// Start starts listening; any failure to listen is thrown as irrecoverable on ctx.
+func (n *Network) Start(ctx modules.ThrowableContext) {
+	n.l.Lock()
+	n.ctx = ctx
+	n.l.Unlock()
+	n.Manager.Start(ctx)
+}
+
+// Subscribe registers a consumer of the connection events of peers, until the returned function is called or the
+// network shuts down; the events of the connections drained by the shutdown are delivered before Done is closed.
+func (n *Network) Subscribe(consumer net.PeerEventConsumer) (unsubscribe func()) {
+	return n.peerEvents.Subscribe(consumer)
+}
+
+// Address returns the address the network listens on, which differs from the configured address when it leaves the
+// choice of the port to the operating system.
+// Returns the zero address until the network is ready.
+func (n *Network) Address() model.Address {
+	n.l.RLock()
+	defer n.l.RUnlock()
+	return n.addr
+}
+
+// Register registers a MessageProcessor for a specific channel.
+// Returns the conduit to send messages on the channel, or an error wrapping ErrChannelRegistered if a processor is
+// already registered for the channel.
+func (n *Network) Register(channel net.Channel, processor net.MessageProcessor) (net.Conduit, error) {
+	n.l.Lock()
+	defer n.l.Unlock()
+	if _, exists := n.processors[channel]; exists {
+		return nil, fmt.Errorf("%w: %s", ErrChannelRegistered, channel)
+	}
+	reg := &registration{processor: processor}
+	n.processors[channel] = reg
+	return &Conduit{network: n, channel: channel, registration: reg}, nil
+}
+
+// Unregister removes the processor of the channel; the conduit returned by its registration can no longer send.
+// Messages received for the channel are answered with a protocol.Error of code ErrorCodeChannelUnknown until a
+// processor is registered again, while the messages already dispatched are still processed.
+// Returns an error wrapping ErrChannelNotRegistered if the channel has no processor, which is benign.
+func (n *Network) Unregister(channel net.Channel) error {
+	n.l.Lock()
+	defer n.l.Unlock()
+	if _, exists := n.processors[channel]; !exists {
+		return fmt.Errorf("%w: %s", ErrChannelNotRegistered, channel)
+	}
+	delete(n.processors, channel)
+	return nil
+}
+
+// Channels returns the channels that have a processor registered, in lexicographic order.
+func (n *Network) Channels() []net.Channel {
+	n.l.RLock()
+	defer n.l.RUnlock()
+	channels := make([]net.Channel, 0, len(n.processors))
+	for channel := range n.processors {
+		channels = append(channels, channel)
+	}
+	slices.Sort(channels)
+	return channels
+}
+
+// listen starts the dispatcher of inbound messages, then listening on the configured address and accepting inbound
+// connections.
+func (n *Network) listen(ctx modules.ThrowableContext) {
+	n.dispatcher.Start(ctx)
+	listener, err := n.transport.Listen(n.listenAddr)
+	if err != nil {
+		ctx.ThrowIrrecoverable(fmt.Errorf("could not start network: %w", err))
+		return
+	}
+	addr, err := n.transport.Address(listener.Addr())
+	if err != nil {
+		_ = listener.Close()
+		ctx.ThrowIrrecoverable(fmt.Errorf("could not start network: %w", err))
+		return
+	}
+
+	n.l.Lock()
+	if n.closing {
+		n.l.Unlock()
+		_ = listener.Close()
+		return
+	}
+	n.listener = listener
+	n.addr = addr
+	n.wg.Add(1)
+	if n.idleTimeout > 0 {
+		n.wg.Add(1)
+	}
+	n.l.Unlock()
+
+	n.logger.Info().Str("address", addr.String()).Msg("Network listening")
+	go n.acceptLoop(listener)
+	if n.idleTimeout > 0 {
+		go n.evictIdle(ctx)
+	}
+}
+
+// evictIdle closes the idle connections periodically, until ctx is done.
+func (n *Network) evictIdle(ctx modules.ThrowableContext) {
+	defer n.wg.Done()
+	ticker := time.NewTicker(n.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if evicted := n.pool.EvictIdle(); evicted > 0 {
+				n.logger.Debug().Int("evicted", evicted).Msg("Closed idle connections")
+			}
+		}
+	}
+}
+
+// shutdown stops listening, closes all connections and waits for them and the dispatcher to be drained.
+func (n *Network) shutdown() {
+	n.l.Lock()
+	n.closing = true
+	listener := n.listener
+	conns := make([]internal.Connection, 0, len(n.served))
+	for conn := range n.served {
+		conns = append(conns, conn)
+	}
+	n.l.Unlock()
+
+	if listener != nil {
+		if err := listener.Close(); err != nil {
+			n.logger.Warn().Err(err).Msg("Could not close listener")
+		}
+	}
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+	_ = n.pool.Close()
+
+	n.wg.Wait()
+	n.peerEvents.Close()
+	<-n.dispatcher.Done()
+	n.logger.Info().Msg("Network stopped, all connections drained")
+}
+
+// running returns true if the network has been started and is not shutting down.
+func (n *Network) running() bool {
+	n.l.RLock()
+	defer n.l.RUnlock()
+	return n.ctx != nil && !n.closing && n.ctx.Err() == nil
+}
+
+// acceptLoop accepts inbound connections until the listener is closed.
+func (n *Network) acceptLoop(listener stdnet.Listener) {
+	defer n.wg.Done()
+	for {
+		raw, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, stdnet.ErrClosed) {
+				n.logger.Debug().Msg("Listener closed, no longer accepting connections")
+				return
+			}
+			n.logger.Warn().Err(err).Msg("Could not accept inbound connection")
+			continue
+		}
+
+		// the accept loop holds the wait group, hence the handshake can be tracked without racing with shutdown.
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			n.accept(raw)
+		}()
+	}
+}
+
+// accept performs the handshake of an inbound connection and serves it.
+func (n *Network) accept(raw stdnet.Conn) {
+	s, conn, err := n.handshake(raw, nil)
+	if err != nil {
+		n.logger.Warn().Err(err).Str("remote", raw.RemoteAddr().String()).Msg("Rejected inbound connection")
+		return
+	}
+	peer := s.peer
+	if n.banned(peer) {
+		_ = conn.Close()
+		n.logger.Debug().Str("peer", peer.String()).Msg("Rejected inbound connection of banned peer")
+		return
+	}
+	if err := n.serve(s, conn); err != nil {
+		return
+	}
+	if err := n.pool.Add(peer, conn); err != nil {
+		_ = conn.Close()
+		n.logger.Debug().Err(err).Str("peer", peer.String()).Msg("Closed inbound connection")
+		return
+	}
+	n.logger.Debug().Str("peer", peer.String()).Msg("Accepted inbound connection")
+}
+
+// session is what a connection learns of its peer during the handshake.
+type session struct {
+	peer         model.Identifier
+	version      net.ProtocolVersion      // the highest version supported by both sides
+	capabilities map[net.Channel][]string // advertised by the peer
+}
+
+// handshake secures the connection if the network has an identity key, then exchanges hello frames over it, within
+// the handshake timeout, negotiating the protocol version. The dialing side passes the identifier it expects the peer
+// to announce; the connection is closed if the handshake fails.
+// Returns the session with the peer and the connection to exchange frames over, or an error wrapping
+// ErrHandshakeFailed, ErrPeerMismatch or ErrIncompatibleVersion.
+func (n *Network) handshake(raw stdnet.Conn, expected *model.Identifier) (session, internal.Connection, error) {
+	s, conn, err := n.exchangeHello(raw, expected)
+	if err != nil {
+		_ = raw.Close()
+		event := net.PeerEvent{Type: net.PeerHandshakeFailed, Err: err}
+		if expected != nil {
+			event.Peer = *expected
+		}
+		n.peerEvents.Publish(event)
+		return session{}, nil, err
+	}
+	return s, conn, nil
+}
+
+// exchangeHello performs the handshake of handshake, leaving the connection open on failure.
+func (n *Network) exchangeHello(raw stdnet.Conn, expected *model.Identifier) (session, internal.Connection, error) {
+	if err := raw.SetDeadline(time.Now().Add(n.handshakeTimeout)); err != nil {
+		return session{}, nil, fmt.Errorf("%w: could not set deadline: %w", ErrHandshakeFailed, err)
+	}
+
+	stream := raw
+	var authenticated *model.Identifier
+	if n.tlsConfig != nil {
+		secured, id, err := authenticate(raw, n.tlsConfig, expected != nil)
+		if err != nil {
+			return session{}, nil, err
+		}
+		stream, authenticated = secured, &id
+	}
+	if authenticated != nil && expected != nil && *authenticated != *expected {
+		return session{}, nil, fmt.Errorf(
+			"%w: expected %s, authenticated %s",
+			ErrPeerMismatch,
+			expected.String(),
+			authenticated.String(),
+		)
+	}
+
+	conn := connection.NewStreamConnection(stream, n.maxFrameSize)
+	if err := conn.Send(n.hello); err != nil {
+		return session{}, nil, fmt.Errorf("%w: could not send hello: %w", ErrHandshakeFailed, err)
+	}
+	b, err := conn.Next()
+	if err != nil {
+		return session{}, nil, fmt.Errorf("%w: could not receive hello: %w", ErrHandshakeFailed, err)
+	}
+	h, err := decodeHello(b)
+	if err != nil {
+		return session{}, nil, fmt.Errorf("%w: %w", ErrHandshakeFailed, err)
+	}
+	peer := h.id
+	if authenticated != nil && peer != *authenticated {
+		return session{}, nil, fmt.Errorf(
+			"%w: announced %s, authenticated %s",
+			ErrPeerMismatch,
+			peer.String(),
+			authenticated.String(),
+		)
+	}
+	if expected != nil && peer != *expected {
+		return session{}, nil, fmt.Errorf("%w: expected %s, got %s", ErrPeerMismatch, expected.String(), peer.String())
+	}
+	version, ok := negotiate(n.versions, h.versions)
+	if !ok {
+		return session{}, nil, fmt.Errorf(
+			"%w: %s supports versions %v, %v supported locally",
+			ErrIncompatibleVersion,
+			peer.String(),
+			h.versions,
+			n.versions,
+		)
+	}
+	if err := raw.SetDeadline(time.Time{}); err != nil {
+		return session{}, nil, fmt.Errorf("%w: could not clear deadline: %w", ErrHandshakeFailed, err)
+	}
+	var framed internal.Connection = conn
+	if n.compressor != nil {
+		framed = &compressingConnection{
+			Connection:   conn,
+			compressor:   n.compressor,
+			compress:     slices.Contains(h.capabilities[connectionCapabilities], capabilityFlate),
+			maxFrameSize: n.maxFrameSize,
+		}
+	}
+	return session{peer: peer, version: version, capabilities: h.capabilities}, newPrioritizedConnection(framed), nil
+}
+
+// serve tracks the connection and reads frames from it in the background until it is closed.
+// The peer is reported connected if it is its first connection.
+// Returns ErrNetworkNotRunning if the network is shutting down, in which case the connection is closed.
+func (n *Network) serve(s session, conn internal.Connection) error {
+	peer := s.peer
+	n.l.Lock()
+	if n.closing {
+		n.l.Unlock()
+		_ = conn.Close()
+		return ErrNetworkNotRunning
+	}
+	if !n.connected(peer) {
+		// published under the lock, so that the events of a peer are ordered
+		n.peerEvents.Publish(net.PeerEvent{Type: net.PeerConnected, Peer: peer})
+	}
+	n.served[conn] = peer
+	// added under the lock, after checking that shutdown has not started waiting for the group.
+	n.wg.Add(1)
+	n.l.Unlock()
+
+	go func() {
+		defer n.wg.Done()
+		defer n.untrack(peer, conn)
+		n.read(s, conn)
+	}()
+	return nil
+}
+
+// untrack closes the connection and forgets it; if it was used to send to the peer, another connection to the same
+// peer takes over, if any. The peer is reported disconnected if it was its last connection.
+func (n *Network) untrack(peer model.Identifier, conn internal.Connection) {
+	_ = conn.Close()
+
+	n.l.Lock()
+	delete(n.served, conn)
+	var other internal.Connection
+	for c, id := range n.served {
+		if id == peer {
+			other = c
+			break
+		}
+	}
+	if other == nil {
+		n.peerEvents.Publish(net.PeerEvent{Type: net.PeerDisconnected, Peer: peer})
+	}
+	n.l.Unlock()
+
+	if n.pool.Remove(peer, conn) && other != nil {
+		_ = n.pool.Add(peer, other)
+	}
+}
+
+// connected returns true if a connection with the peer is being served; the caller must hold the lock.
+func (n *Network) connected(peer model.Identifier) bool {
+	for _, id := range n.served {
+		if id == peer {
+			return true
+		}
+	}
+	return false
+}
+
+// read dispatches the frames of the connection until it is closed, or the peer gets banned.
+func (n *Network) read(s session, conn internal.Connection) {
+	peer := s.peer
+	lg := n.logger.With().Str("peer", peer.String()).Logger()
+	for {
+		b, err := conn.Next()
+		if err != nil {
+			lg.Debug().Err(err).Msg("Connection closed")
+			return
+		}
+		n.pool.Touch(peer)
+		n.receive(s, conn, b)
+		if n.banned(peer) {
+			lg.Info().Msg("Closing connection of banned peer")
+			return
+		}
+	}
+}
+
+// banned returns true if the rate limiter of the network bans the peer.
+func (n *Network) banned(peer model.Identifier) bool {
+	return n.limiter != nil && n.limiter.Banned(peer)
+}
+
+// receive handles a frame received over the connection of the session.
+func (n *Network) receive(s session, conn internal.Connection, frame []byte) {
+	peer := s.peer
+	if len(frame) == 0 {
+		n.logger.Warn().Str("origin", peer.String()).Msg("Dropping empty frame")
+		return
+	}
+	switch frameKind(frame[0]) {
+	case frameMessage:
+		n.dispatch(s, conn, frame)
+	case frameError:
+		n.dispatchError(peer, frame)
+	default:
+		n.logger.Warn().Str("origin", peer.String()).Uint8("kind", frame[0]).Msg("Dropping frame of unknown kind")
+	}
+}
+
+// dispatch decodes a message frame received from the peer of the session and dispatches it to the processor of its
+// channel, along with the version and capabilities negotiated in the session.
+// Malformed frames, payloads of unregistered types, messages over the rate limit and messages that the dispatcher drops
+// are logged and dropped. Messages for channels without a processor are answered over conn with an error frame, unless
+// conn is nil, i.e., the message is sent by the network to itself.
+func (n *Network) dispatch(s session, conn internal.Connection, frame []byte) {
+	origin := s.peer
+	channel, msg, err := decodeMessage(n.codecs, frame)
+	if err != nil {
+		n.logger.Warn().Err(err).Str("origin", origin.String()).Msg("Dropping undecodable frame")
+		return
+	}
+	msg.Version = s.version
+	msg.Capabilities = s.capabilities[channel]
+	if n.limiter != nil && origin != n.id {
+		if err := n.limiter.Allow(channel, origin); err != nil {
+			n.logger.Debug().Err(err).Str("origin", origin.String()).Msg("Dropping message over rate limit")
+			return
+		}
+	}
+
+	n.l.RLock()
+	reg, ok := n.processors[channel]
+	n.l.RUnlock()
+	if !ok {
+		n.logger.Debug().
+			Str("origin", origin.String()).
+			Str("channel", string(channel)).
+			Msg("Rejecting message for channel without processor")
+		if conn != nil {
+			n.reject(origin, conn, channel)
+		}
+		return
+	}
+	if err := n.dispatcher.Dispatch(channel, reg.processor, origin, msg); err != nil {
+		n.logger.Warn().
+			Err(err).
+			Str("origin", origin.String()).
+			Str("channel", string(channel)).
+			Msg("Dropping message that could not be dispatched")
+	}
+}
+
+// reject answers a message received on a channel without processor with an error frame over the connection it came
+// from.
+func (n *Network) reject(origin model.Identifier, conn internal.Connection, channel net.Channel) {
+	e, err := protocol.NewError(0, protocol.ErrorCodeChannelUnknown, "no processor registered for channel")
+	if err != nil {
+		n.logger.Error().Err(err).Msg("Could not create channel unknown error")
+		return
+	}
+	if err := conn.Send(encodeError(channel, e)); err != nil {
+		n.logger.Debug().Err(err).Str("origin", origin.String()).Msg("Could not answer message with error")
+	}
+}
+
+// dispatchError decodes the error frame and hands its error to the dispatcher for the processor of its channel, as a
+// message of the peer answering a message sent on the channel. Errors for a channel without processor are dropped.
+func (n *Network) dispatchError(origin model.Identifier, frame []byte) {
+	channel, e, err := decodeError(frame)
+	if err != nil {
+		n.logger.Warn().Err(err).Str("origin", origin.String()).Msg("Dropping undecodable error frame")
+		return
+	}
+	lg := n.logger.With().
+		Str("origin", origin.String()).
+		Str("channel", string(channel)).
+		Str("code", e.Code().String()).
+		Logger()
+	lg.Debug().Str("reason", e.Reason()).Msg("Peer could not process message")
+
+	n.l.RLock()
+	reg, ok := n.processors[channel]
+	n.l.RUnlock()
+	if !ok {
+		return
+	}
+	if err := n.dispatcher.Dispatch(channel, reg.processor, origin, net.Message{Payload: e}); err != nil {
+		lg.Warn().Err(err).Msg("Dropping error that could not be dispatched")
+	}
+}
+
+// CompressionStats returns a snapshot of the compression counters of the network; all zero if compression is disabled.
+func (n *Network) CompressionStats() CompressionStats {
+	if n.compressor == nil {
+		return CompressionStats{}
+	}
+	return n.compressor.stats()
+}
+
+// DispatchStats returns a snapshot of the counters of the dispatcher of inbound messages.
+func (n *Network) DispatchStats() dispatch.Stats {
+	return n.dispatcher.Stats()
+}
+
+// send sends the message to the target on the channel, connecting to the target if needed.
+// A message sent to the node itself is dispatched locally.
+// If writing to the connection fails, the connection is closed so that the next send establishes a new one.
+func (n *Network) send(channel net.Channel, reg *registration, target model.Identifier, msg net.Message) error {
+	frame, err := encodeMessage(n.codecs, channel, msg)
+	if err != nil {
+		return fmt.Errorf("could not encode message: %w", err)
+	}
+	if !n.running() {
+		return ErrNetworkNotRunning
+	}
+	if !n.registered(channel, reg) {
+		return fmt.Errorf("%w: %s", ErrChannelNotRegistered, channel)
+	}
+	if target == n.id {
+		n.dispatch(n.self, nil, frame)
+		return nil
+	}
+
+	conn, err := n.connect(target)
+	if err != nil {
+		return fmt.Errorf("could not connect to %s: %w", target.String(), err)
+	}
+	if err := n.write(conn, channel, frame); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("could not send to %s: %w", target.String(), err)
+	}
+	return nil
+}
+
+// write sends the frame over the connection with the priority of the channel.
+func (n *Network) write(conn internal.Connection, channel net.Channel, frame []byte) error {
+	priority, ok := n.priorities[channel]
+	if !ok {
+		priority = net.PriorityNormal
+	}
+	if pc, ok := conn.(*prioritizedConnection); ok {
+		return pc.SendWithPriority(frame, priority)
+	}
+	return conn.Send(frame)
+}
+
+// registered returns true if reg is the current registration of the channel.
+func (n *Network) registered(channel net.Channel, reg *registration) bool {
+	n.l.RLock()
+	defer n.l.RUnlock()
+	return n.processors[channel] == reg
+}
+
+// connect returns the connection to the target, dialing it if there is none.
+// Concurrent calls for the same target share a single dial.
+func (n *Network) connect(target model.Identifier) (internal.Connection, error) {
+	n.l.RLock()
+	ctx := n.ctx
+	n.l.RUnlock()
+
+	conn, err := n.pool.Connect(ctx, target)
+	switch {
+	case err == nil:
+		return conn, nil
+	case errors.Is(err, connection.ErrManagerClosed):
+		return nil, ErrNetworkNotRunning
+	case errors.Is(err, connection.ErrBackoff):
+		return nil, fmt.Errorf("%w: %w", ErrDialBackoff, err)
+	case errors.Is(err, connection.ErrTooManyConnections):
+		return nil, fmt.Errorf("%w: %w", ErrTooManyConnections, err)
+	default:
+		return nil, err
+	}
+}
+
+// dial connects to the first reachable address of the target and serves the connection.
+// Returns the connection to send to the target, or an error wrapping ErrUnknownPeer if the target has no address for
+// the transport of the network, or the errors of every dial attempt.
+func (n *Network) dial(ctx context.Context, target model.Identifier) (internal.Connection, error) {
+	addrs, err := n.resolver.Resolve(target)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve peer: %w: %w", connection.ErrNotDialed, err)
+	}
+
+	var errs []error
+	for _, addr := range addrs {
+		if addr.Transport() != n.transport.Name() {
+			continue
+		}
+		conn, err := n.dialAddress(ctx, target, addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return conn, nil
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("%w: %w: no %s address for %s", connection.ErrNotDialed, ErrUnknownPeer, n.transport.Name(), target.String())
+	}
+	return nil, errors.Join(errs...)
+}
+
+// dialAddress connects to the target at the address, performs the handshake and serves the connection.
+func (n *Network) dialAddress(ctx context.Context, target model.Identifier, addr model.Address) (internal.Connection, error) {
+	ctx, cancel := context.WithTimeout(ctx, n.dialTimeout)
+	defer cancel()
+
+	raw, err := n.transport.Dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	s, conn, err := n.handshake(raw, &target)
+	if err != nil {
+		return nil, fmt.Errorf("handshake with %s failed: %w", addr, err)
+	}
+
+	if err := n.serve(s, conn); err != nil {
+		return nil, err
+	}
+	n.logger.Debug().Str("peer", target.String()).Str("address", addr.String()).Msg("Established outbound connection")
+	return conn, nil
+}
@@ -1,10 +1,1000 @@
-package network
+package network_test
 
 import (
-	"github.com/stretchr/testify/require"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	stdnet "net"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/crypto"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/codec"
+	"github.com/thep2p/skipgraph-go/net/dispatch"
+	"github.com/thep2p/skipgraph-go/net/network"
+	"github.com/thep2p/skipgraph-go/net/protocol"
+	"github.com/thep2p/skipgraph-go/net/ratelimit"
+	"github.com/thep2p/skipgraph-go/unittest"
+	"github.com/thep2p/skipgraph-go/unittest/mocknet"
 )
 
-func TestNetwork(t *testing.T) {
-	require.True(t, true)
+// received is a message received by a processor.
+type received struct {
+	channel net.Channel
+	origin  model.Identifier
+	msg     net.Message
+}
+
+// collectingProcessor returns a processor forwarding every received message to the returned channel.
+func collectingProcessor() (net.MessageProcessor, <-chan received) {
+	ch := make(chan received, 100)
+	return mocknet.NewMockMessageProcessor(
+		func(channel net.Channel, originID model.Identifier, msg net.Message) {
+			ch <- received{channel: channel, origin: originID, msg: msg}
+		},
+	), ch
+}
+
+// startNetworks creates and starts count networks on loopback, resolving each other through a shared resolver.
+// The networks are stopped when the test ends.
+func startNetworks(t *testing.T, count int, opts ...network.Option) ([]*network.Network, []model.Identifier, *network.StaticResolver) {
+	ids := make([]model.Identifier, count)
+	for i := range ids {
+		ids[i] = unittest.IdentifierFixture(t)
+	}
+	return startNetworksOf(t, ids, func(int) []network.Option { return opts })
+}
+
+// startSecureNetworks is startNetworks for networks securing their connections with generated identity keys.
+func startSecureNetworks(t *testing.T, count int, opts ...network.Option) ([]*network.Network, []model.Identifier, *network.StaticResolver) {
+	keys := make([]ed25519.PrivateKey, count)
+	ids := make([]model.Identifier, count)
+	for i := range ids {
+		keys[i], ids[i] = unittest.IdentityKeyFixture(t)
+	}
+	return startNetworksOf(
+		t, ids, func(i int) []network.Option {
+			return append([]network.Option{network.WithIdentityKey(keys[i])}, opts...)
+		},
+	)
+}
+
+// startNetworksOf creates and starts a network on loopback for each identifier, with the options returned by opts for
+// its index, resolving each other through a shared resolver. The networks are stopped when the test ends.
+func startNetworksOf(
+	t *testing.T,
+	ids []model.Identifier,
+	opts func(i int) []network.Option,
+) ([]*network.Network, []model.Identifier, *network.StaticResolver) {
+	resolver := network.NewStaticResolver()
+	ctx := unittest.NewMockThrowableContext(t)
+
+	nets := make([]*network.Network, len(ids))
+	for i, id := range ids {
+		n, err := network.NewNetwork(
+			unittest.Logger(zerolog.WarnLevel),
+			id,
+			model.NewAddress("127.0.0.1", "0"),
+			resolver,
+			opts(i)...,
+		)
+		require.NoError(t, err)
+		n.Start(ctx)
+		nets[i] = n
+	}
+
+	for i, n := range nets {
+		unittest.ChannelMustCloseWithinTimeout(t, n.Ready(), unittest.DefaultReadyDoneTimeout, "network not ready")
+		resolver.Add(model.NewIdentity(ids[i], unittest.MembershipVectorFixture(t), n.Address()))
+	}
+
+	t.Cleanup(
+		func() {
+			ctx.Cancel()
+			for _, n := range nets {
+				unittest.ChannelMustCloseWithinTimeout(t, n.Done(), unittest.DefaultReadyDoneTimeout, "network not done")
+			}
+		},
+	)
+	return nets, ids, resolver
+}
+
+// mustReceive waits for the next message on ch and returns it.
+func mustReceive(t *testing.T, ch <-chan received) received {
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(unittest.DefaultReadyDoneTimeout):
+		require.Fail(t, "message not received on time")
+		return received{}
+	}
+}
+
+// TestNetwork_SendReceive tests that two networks exchange byte and protocol messages in both directions over loopback.
+func TestNetwork_SendReceive(t *testing.T) {
+	nets, ids, _ := startNetworks(t, 2)
+
+	p0, ch0 := collectingProcessor()
+	p1, ch1 := collectingProcessor()
+	c0, err := nets[0].Register(net.TestChannel, p0)
+	require.NoError(t, err)
+	c1, err := nets[1].Register(net.TestChannel, p1)
+	require.NoError(t, err)
+
+	// bytes from 0 -> 1
+	msg := unittest.TestMessageFixture(t)
+	require.NoError(t, c0.Send(ids[1], *msg))
+	r := mustReceive(t, ch1)
+	require.Equal(t, net.TestChannel, r.channel)
+	require.Equal(t, ids[0], r.origin)
+	require.Equal(t, msg.Payload, r.msg.Payload)
+
+	// protocol message from 1 -> 0, over the connection established by 0
+	query, err := protocol.NewNeighborQuery(7, 3, types.DirectionLeft)
+	require.NoError(t, err)
+	require.NoError(t, c1.Send(ids[0], net.Message{Payload: query}))
+	r = mustReceive(t, ch0)
+	require.Equal(t, ids[1], r.origin)
+	require.Equal(t, query, r.msg.Payload)
+
+	// a message to itself is delivered locally
+	require.NoError(t, c0.Send(ids[0], *msg))
+	r = mustReceive(t, ch0)
+	require.Equal(t, ids[0], r.origin)
+	require.Equal(t, msg.Payload, r.msg.Payload)
+}
+
+// TestNetwork_ConcurrentSends tests that concurrent sends to the same peer are all delivered.
+func TestNetwork_ConcurrentSends(t *testing.T) {
+	nets, ids, _ := startNetworks(t, 2)
+
+	p0, _ := collectingProcessor()
+	p1, ch1 := collectingProcessor()
+	c0, err := nets[0].Register(net.TestChannel, p0)
+	require.NoError(t, err)
+	_, err = nets[1].Register(net.TestChannel, p1)
+	require.NoError(t, err)
+
+	const count = 50
+	wg := sync.WaitGroup{}
+	wg.Add(count)
+	for i := 0; i < count; i++ {
+		go func() {
+			defer wg.Done()
+			require.NoError(t, c0.Send(ids[1], *unittest.TestMessageFixture(t)))
+		}()
+	}
+	unittest.CallMustReturnWithinTimeout(t, wg.Wait, unittest.DefaultReadyDoneTimeout, "sends did not return on time")
+	for i := 0; i < count; i++ {
+		mustReceive(t, ch1)
+	}
+}
+
+// TestNetwork_Register tests that a channel can only be registered once.
+func TestNetwork_Register(t *testing.T) {
+	nets, _, _ := startNetworks(t, 1)
+	p, _ := collectingProcessor()
+
+	_, err := nets[0].Register(net.TestChannel, p)
+	require.NoError(t, err)
+	_, err = nets[0].Register(net.TestChannel, p)
+	require.True(t, errors.Is(err, network.ErrChannelRegistered))
+}
+
+// TestNetwork_SendErrors tests that sends to unknown or mismatched peers, and unsupported payloads, fail benignly.
+func TestNetwork_SendErrors(t *testing.T) {
+	nets, ids, resolver := startNetworks(t, 2)
+	p, _ := collectingProcessor()
+	c, err := nets[0].Register(net.TestChannel, p)
+	require.NoError(t, err)
+
+	// unknown peer
+	err = c.Send(unittest.IdentifierFixture(t), *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, network.ErrUnknownPeer))
+
+	// the address of the peer belongs to another node
+	impostor := unittest.IdentifierFixture(t)
+	resolver.Add(model.NewIdentity(impostor, unittest.MembershipVectorFixture(t), nets[1].Address()))
+	err = c.Send(impostor, *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, network.ErrPeerMismatch))
+
+	// unsupported payload
+	err = c.Send(ids[1], net.Message{Payload: 42})
+	require.True(t, errors.Is(err, codec.ErrUnknownType))
+}
+
+// TestNetwork_Lifecycle tests that a network is not usable before start and after shutdown, and drains its connections.
+func TestNetwork_Lifecycle(t *testing.T) {
+	resolver := network.NewStaticResolver()
+	id1 := unittest.IdentifierFixture(t)
+	id2 := unittest.IdentifierFixture(t)
+
+	n1, err := network.NewNetwork(unittest.Logger(zerolog.WarnLevel), id1, model.NewAddress("127.0.0.1", "0"), resolver)
+	require.NoError(t, err)
+	n2, err := network.NewNetwork(unittest.Logger(zerolog.WarnLevel), id2, model.NewAddress("127.0.0.1", "0"), resolver)
+	require.NoError(t, err)
+
+	p1, _ := collectingProcessor()
+	p2, ch2 := collectingProcessor()
+	c1, err := n1.Register(net.TestChannel, p1)
+	require.NoError(t, err)
+	_, err = n2.Register(net.TestChannel, p2)
+	require.NoError(t, err)
+
+	// not started
+	err = c1.Send(id2, *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, network.ErrNetworkNotRunning))
+
+	ctx1 := unittest.NewMockThrowableContext(t)
+	ctx2 := unittest.NewMockThrowableContext(t)
+	n1.Start(ctx1)
+	n2.Start(ctx2)
+	unittest.RequireAllReady(t, n1, n2)
+	require.False(t, n1.Address().IsZero())
+	resolver.Add(model.NewIdentity(id2, unittest.MembershipVectorFixture(t), n2.Address()))
+
+	require.NoError(t, c1.Send(id2, *unittest.TestMessageFixture(t)))
+	mustReceive(t, ch2)
+
+	// stopping n2 drains the connection with n1
+	ctx2.Cancel()
+	unittest.RequireAllDone(t, n2)
+
+	// stopped
+	ctx1.Cancel()
+	unittest.RequireAllDone(t, n1)
+	err = c1.Send(id2, *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, network.ErrNetworkNotRunning))
+}
+
+// TestNewNetwork_UnknownTransport tests that a network cannot be created for an address without transport.
+func TestNewNetwork_UnknownTransport(t *testing.T) {
+	_, err := network.NewNetwork(
+		unittest.Logger(zerolog.WarnLevel),
+		unittest.IdentifierFixture(t),
+		model.NewInMemoryAddress("node"),
+		network.NewStaticResolver(),
+	)
+	require.True(t, errors.Is(err, model.ErrUnknownTransport))
+
+	_, err = network.NewNetwork(
+		unittest.Logger(zerolog.WarnLevel),
+		unittest.IdentifierFixture(t),
+		model.NewUnixAddress("/tmp/node.sock"),
+		network.NewStaticResolver(),
+		network.WithTransport(network.NewTCPTransport()),
+	)
+	require.True(t, errors.Is(err, model.ErrUnknownTransport))
+}
+
+// point is a payload type registered by TestNetwork_CustomCodecs.
+type point struct {
+	x, y uint8
+}
+
+// TestNetwork_CustomCodecs tests that payload types registered in the codec registry of the networks are received with
+// their type.
+func TestNetwork_CustomCodecs(t *testing.T) {
+	registry := codec.NewDefaultRegistry()
+	require.NoError(
+		t, codec.Register(
+			registry, 0x8000,
+			func(p point) ([]byte, error) {
+				return []byte{p.x, p.y}, nil
+			},
+			func(b []byte) (point, error) {
+				if len(b) != 2 {
+					return point{}, errors.New("expected 2 bytes")
+				}
+				return point{x: b[0], y: b[1]}, nil
+			},
+		),
+	)
+	nets, ids, _ := startNetworks(t, 2, network.WithCodecs(registry))
+
+	p0, _ := collectingProcessor()
+	p1, ch1 := collectingProcessor()
+	c0, err := nets[0].Register(net.TestChannel, p0)
+	require.NoError(t, err)
+	_, err = nets[1].Register(net.TestChannel, p1)
+	require.NoError(t, err)
+
+	require.NoError(t, c0.Send(ids[1], net.Message{Payload: point{x: 1, y: 2}}))
+	r := mustReceive(t, ch1)
+	require.Equal(t, point{x: 1, y: 2}, r.msg.Payload)
+}
+
+// TestNetwork_RateLimit tests that messages over the rate limit of their origin are dropped, and that the connections of
+// banned peers are closed and rejected.
+func TestNetwork_RateLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter(
+		ratelimit.WithPeerLimit(ratelimit.Limit{Burst: 3}),
+		ratelimit.WithBan(2, time.Minute, time.Minute),
+	)
+	nets, ids, _ := startNetworks(t, 2, network.WithRateLimiter(limiter))
+
+	p0, _ := collectingProcessor()
+	p1, ch1 := collectingProcessor()
+	c0, err := nets[0].Register(net.TestChannel, p0)
+	require.NoError(t, err)
+	_, err = nets[1].Register(net.TestChannel, p1)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, c0.Send(ids[1], *unittest.TestMessageFixture(t)))
+		mustReceive(t, ch1)
+	}
+
+	// the fourth and fifth messages exceed the limit, and the second excess gets 0 banned
+	require.NoError(t, c0.Send(ids[1], *unittest.TestMessageFixture(t)))
+	require.NoError(t, c0.Send(ids[1], *unittest.TestMessageFixture(t)))
+	require.Eventually(t, func() bool { return limiter.Banned(ids[0]) }, unittest.DefaultReadyDoneTimeout, time.Millisecond)
+
+	// later messages of 0 never reach the processor, whether over the closed connection or a new one
+	for i := 0; i < 3; i++ {
+		_ = c0.Send(ids[1], *unittest.TestMessageFixture(t))
+	}
+	select {
+	case <-ch1:
+		require.Fail(t, "message of banned peer delivered")
+	case <-time.After(50 * time.Millisecond):
+	}
+	stats := limiter.Stats()
+	require.Equal(t, uint64(3), stats.Allowed)
+	require.Equal(t, uint64(2), stats.PeerLimited)
+	require.Equal(t, uint64(1), stats.Bans)
+}
+
+// TestNetwork_Secure tests that networks with identity keys exchange messages over authenticated connections, and
+// report the authenticated identifiers of the origins.
+func TestNetwork_Secure(t *testing.T) {
+	nets, ids, _ := startSecureNetworks(t, 2)
+
+	p0, ch0 := collectingProcessor()
+	p1, ch1 := collectingProcessor()
+	c0, err := nets[0].Register(net.TestChannel, p0)
+	require.NoError(t, err)
+	c1, err := nets[1].Register(net.TestChannel, p1)
+	require.NoError(t, err)
+
+	msg := unittest.TestMessageFixture(t)
+	require.NoError(t, c0.Send(ids[1], *msg))
+	r := mustReceive(t, ch1)
+	require.Equal(t, ids[0], r.origin)
+	require.Equal(t, msg.Payload, r.msg.Payload)
+
+	require.NoError(t, c1.Send(ids[0], *msg))
+	r = mustReceive(t, ch0)
+	require.Equal(t, ids[1], r.origin)
+}
+
+// TestNetwork_SecureRejections tests that secured connections are rejected when the identity of the peer does not
+// match its key, or the peer does not secure its connection.
+func TestNetwork_SecureRejections(t *testing.T) {
+	nets, ids, resolver := startSecureNetworks(t, 2)
+	p, ch := collectingProcessor()
+	c, err := nets[0].Register(net.TestChannel, p)
+	require.NoError(t, err)
+	_, err = nets[1].Register(net.TestChannel, p)
+	require.NoError(t, err)
+
+	// a network cannot claim an identifier its key is not bound to
+	key, _ := unittest.IdentityKeyFixture(t)
+	_, err = network.NewNetwork(
+		unittest.Logger(zerolog.Disabled),
+		ids[0],
+		model.NewAddress("127.0.0.1", "0"),
+		resolver,
+		network.WithIdentityKey(key),
+	)
+	require.True(t, errors.Is(err, network.ErrKeyMismatch))
+
+	// the node reached at the address of an identifier proves another identifier
+	impostor := unittest.IdentifierFixture(t)
+	resolver.Add(model.NewIdentity(impostor, unittest.MembershipVectorFixture(t), nets[1].Address()))
+	err = c.Send(impostor, *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, network.ErrPeerMismatch), "unexpected error %v", err)
+
+	// a network without key cannot connect to a secured one
+	insecure, insecureIDs, insecureResolver := startNetworks(t, 1)
+	insecureResolver.Add(model.NewIdentity(ids[0], unittest.MembershipVectorFixture(t), nets[0].Address()))
+	ic, err := insecure[0].Register(net.TestChannel, p)
+	require.NoError(t, err)
+	err = ic.Send(ids[0], *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, network.ErrHandshakeFailed), "unexpected error %v", err)
+
+	// a peer proving its key, but announcing another identifier, is disconnected without delivering anything
+	cert, err := crypto.SelfSignedCertificate(key)
+	require.NoError(t, err)
+	conn, err := tls.Dial(
+		"tcp",
+		nets[0].Address().String(),
+		&tls.Config{MinVersion: tls.VersionTLS13, Certificates: []tls.Certificate{cert}, InsecureSkipVerify: true},
+	)
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	hello := append([]byte{1}, insecureIDs[0][:]...)
+	_, err = conn.Write(append(binary.BigEndian.AppendUint32(nil, uint32(len(hello))), hello...))
+	require.NoError(t, err)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(unittest.DefaultReadyDoneTimeout)))
+	_, err = io.ReadAll(conn)
+	require.NoError(t, err, "connection not closed by the network")
+	require.Empty(t, ch)
+}
+
+// TestNetwork_ConnectionLimits tests that a network holding its maximum number of connections closes the least
+// recently used one to send to another peer, unless the peers of all its connections are protected.
+func TestNetwork_ConnectionLimits(t *testing.T) {
+	ids := make([]model.Identifier, 4)
+	for i := range ids {
+		ids[i] = unittest.IdentifierFixture(t)
+	}
+	// 3 protects its connection with 1
+	nets, _, _ := startNetworksOf(
+		t, ids, func(i int) []network.Option {
+			if i == 3 {
+				return []network.Option{
+					network.WithMaxConnections(1),
+					network.WithProtectedPeers(func(id model.Identifier) bool { return id == ids[1] }),
+				}
+			}
+			return []network.Option{network.WithMaxConnections(1)}
+		},
+	)
+
+	chs := make([]<-chan received, len(nets))
+	conduits := make([]net.Conduit, len(nets))
+	for i, n := range nets {
+		var p net.MessageProcessor
+		p, chs[i] = collectingProcessor()
+		c, err := n.Register(net.TestChannel, p)
+		require.NoError(t, err)
+		conduits[i] = c
+	}
+
+	// 0 closes its connection with 1 to send to 2, then dials 1 again
+	for _, target := range []int{1, 2, 1} {
+		require.NoError(t, conduits[0].Send(ids[target], *unittest.TestMessageFixture(t)))
+		r := mustReceive(t, chs[target])
+		require.Equal(t, ids[0], r.origin)
+	}
+
+	// 3 does not close its connection with 1 to send to 2
+	require.NoError(t, conduits[3].Send(ids[1], *unittest.TestMessageFixture(t)))
+	mustReceive(t, chs[1])
+	err := conduits[3].Send(ids[2], *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, network.ErrTooManyConnections))
+	require.NoError(t, conduits[3].Send(ids[1], *unittest.TestMessageFixture(t)))
+	mustReceive(t, chs[1])
+}
+
+// TestNetwork_DialBackoff tests that a network does not dial again a peer it failed to dial before the backoff delay,
+// and that idle connections are closed and dialed again on demand.
+func TestNetwork_DialBackoff(t *testing.T) {
+	nets, ids, resolver := startNetworks(t, 2, network.WithDialBackoff(time.Minute, time.Minute), network.WithIdleTimeout(20*time.Millisecond))
+	p0, _ := collectingProcessor()
+	p1, ch1 := collectingProcessor()
+	c0, err := nets[0].Register(net.TestChannel, p0)
+	require.NoError(t, err)
+	_, err = nets[1].Register(net.TestChannel, p1)
+	require.NoError(t, err)
+
+	// nothing listens on the address of the peer
+	unreachable := unittest.IdentifierFixture(t)
+	resolver.Add(model.NewIdentity(unreachable, unittest.MembershipVectorFixture(t), model.NewAddress("127.0.0.1", "1")))
+	err = c0.Send(unreachable, *unittest.TestMessageFixture(t))
+	require.Error(t, err)
+	require.False(t, errors.Is(err, network.ErrDialBackoff))
+	err = c0.Send(unreachable, *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, network.ErrDialBackoff))
+
+	// the connection with 1 is closed once idle, and dialed again to send
+	require.NoError(t, c0.Send(ids[1], *unittest.TestMessageFixture(t)))
+	mustReceive(t, ch1)
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, c0.Send(ids[1], *unittest.TestMessageFixture(t)))
+	mustReceive(t, ch1)
+}
+
+// peerEventsOf subscribes to the peer events of the network, forwarding them to the returned channel.
+func peerEventsOf(n net.Network) <-chan net.PeerEvent {
+	ch := make(chan net.PeerEvent, 100)
+	n.Subscribe(net.PeerEventConsumerFunc(func(e net.PeerEvent) { ch <- e }))
+	return ch
+}
+
+// mustReceiveEvent waits for the next peer event of the given type on ch, skipping other events, and returns it.
+func mustReceiveEvent(t *testing.T, ch <-chan net.PeerEvent, typ net.PeerEventType) net.PeerEvent {
+	for {
+		select {
+		case e := <-ch:
+			if e.Type == typ {
+				return e
+			}
+		case <-time.After(unittest.DefaultReadyDoneTimeout):
+			require.Fail(t, "peer event not received on time", "type: %s", typ)
+			return net.PeerEvent{}
+		}
+	}
+}
+
+// TestNetwork_PeerEvents tests that networks report the peers they connect to and disconnect from, and the
+// connections failing their handshake.
+func TestNetwork_PeerEvents(t *testing.T) {
+	resolver := network.NewStaticResolver()
+	ids := []model.Identifier{unittest.IdentifierFixture(t), unittest.IdentifierFixture(t)}
+	ctxs := []*unittest.MockThrowableContext{unittest.NewMockThrowableContext(t), unittest.NewMockThrowableContext(t)}
+	nets := make([]*network.Network, 2)
+	evts := make([]<-chan net.PeerEvent, 2)
+	for i := range nets {
+		n, err := network.NewNetwork(unittest.Logger(zerolog.WarnLevel), ids[i], model.NewAddress("127.0.0.1", "0"), resolver)
+		require.NoError(t, err)
+		evts[i] = peerEventsOf(n)
+		n.Start(ctxs[i])
+		unittest.RequireAllReady(t, n)
+		resolver.Add(model.NewIdentity(ids[i], unittest.MembershipVectorFixture(t), n.Address()))
+		nets[i] = n
+	}
+	defer func() {
+		ctxs[0].Cancel()
+		unittest.RequireAllDone(t, nets[0])
+	}()
+
+	p, _ := collectingProcessor()
+	c0, err := nets[0].Register(net.TestChannel, p)
+	require.NoError(t, err)
+
+	// the peer presenting another identifier than the one dialed fails the handshake
+	impostor := unittest.IdentifierFixture(t)
+	resolver.Add(model.NewIdentity(impostor, unittest.MembershipVectorFixture(t), nets[1].Address()))
+	require.Error(t, c0.Send(impostor, *unittest.TestMessageFixture(t)))
+	e := mustReceiveEvent(t, evts[0], net.PeerHandshakeFailed)
+	require.Equal(t, impostor, e.Peer)
+	require.True(t, errors.Is(e.Err, network.ErrPeerMismatch))
+
+	// both networks report the other connected once 0 sends to 1
+	require.NoError(t, c0.Send(ids[1], *unittest.TestMessageFixture(t)))
+	require.Equal(t, ids[1], mustReceiveEvent(t, evts[0], net.PeerConnected).Peer)
+	require.Equal(t, ids[0], mustReceiveEvent(t, evts[1], net.PeerConnected).Peer)
+
+	// stopping 1 disconnects both
+	ctxs[1].Cancel()
+	unittest.RequireAllDone(t, nets[1])
+	require.Equal(t, ids[0], mustReceiveEvent(t, evts[1], net.PeerDisconnected).Peer)
+	require.Equal(t, ids[1], mustReceiveEvent(t, evts[0], net.PeerDisconnected).Peer)
+}
+
+// TestNetwork_Unregister tests that unregistered channels answer messages with a channel unknown error, that their
+// conduits can no longer send, and that they can be registered again.
+func TestNetwork_Unregister(t *testing.T) {
+	nets, ids, _ := startNetworks(t, 2)
+	p0, ch0 := collectingProcessor()
+	c0, err := nets[0].Register(net.TestChannel, p0)
+	require.NoError(t, err)
+	p1, _ := collectingProcessor()
+	c1, err := nets[1].Register(net.TestChannel, p1)
+	require.NoError(t, err)
+	other := net.Channel("channel-other")
+	_, err = nets[1].Register(other, p1)
+	require.NoError(t, err)
+	require.Equal(t, []net.Channel{other, net.TestChannel}, nets[1].Channels())
+
+	require.NoError(t, nets[1].Unregister(net.TestChannel))
+	require.True(t, errors.Is(nets[1].Unregister(net.TestChannel), network.ErrChannelNotRegistered))
+	require.Equal(t, []net.Channel{other}, nets[1].Channels())
+	err = c1.Send(ids[0], *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, network.ErrChannelNotRegistered))
+
+	// 1 answers the message of 0 with an error delivered to the processor of 0
+	require.NoError(t, c0.Send(ids[1], *unittest.TestMessageFixture(t)))
+	r := mustReceive(t, ch0)
+	require.Equal(t, ids[1], r.origin)
+	require.Equal(t, net.TestChannel, r.channel)
+	e, ok := r.msg.Payload.(protocol.Error)
+	require.True(t, ok)
+	require.Equal(t, protocol.ErrorCodeChannelUnknown, e.Code())
+
+	// a new processor takes over, while the conduit of the former one remains unusable
+	p2, ch2 := collectingProcessor()
+	c2, err := nets[1].Register(net.TestChannel, p2)
+	require.NoError(t, err)
+	require.NoError(t, c0.Send(ids[1], *unittest.TestMessageFixture(t)))
+	require.Equal(t, ids[0], mustReceive(t, ch2).origin)
+	require.NoError(t, c2.Send(ids[0], *unittest.TestMessageFixture(t)))
+	require.Equal(t, ids[1], mustReceive(t, ch0).origin)
+	err = c1.Send(ids[0], *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, network.ErrChannelNotRegistered))
+}
+
+// TestNetwork_ProtocolVersions tests that connections speak the highest protocol version of both sides, that messages
+// carry it along with the capabilities of their origin, and that peers without a common version are refused.
+func TestNetwork_ProtocolVersions(t *testing.T) {
+	versions := [][]net.ProtocolVersion{{1, 2}, {3, 2}, {3}}
+	nets, ids, _ := startNetworksOf(
+		t,
+		[]model.Identifier{unittest.IdentifierFixture(t), unittest.IdentifierFixture(t), unittest.IdentifierFixture(t)},
+		func(i int) []network.Option {
+			return []network.Option{
+				network.WithProtocolVersions(versions[i]...),
+				network.WithCapabilities(net.TestChannel, fmt.Sprintf("capability-%d", i)),
+			}
+		},
+	)
+	chs := make([]<-chan received, len(nets))
+	conduits := make([]net.Conduit, len(nets))
+	for i, n := range nets {
+		var p net.MessageProcessor
+		p, chs[i] = collectingProcessor()
+		c, err := n.Register(net.TestChannel, p)
+		require.NoError(t, err)
+		conduits[i] = c
+	}
+
+	require.NoError(t, conduits[0].Send(ids[1], *unittest.TestMessageFixture(t)))
+	r := mustReceive(t, chs[1])
+	require.Equal(t, net.ProtocolVersion(2), r.msg.Version)
+	require.True(t, r.msg.HasCapability("capability-0"))
+	require.False(t, r.msg.HasCapability("capability-1"))
+
+	require.NoError(t, conduits[2].Send(ids[1], *unittest.TestMessageFixture(t)))
+	r = mustReceive(t, chs[1])
+	require.Equal(t, net.ProtocolVersion(3), r.msg.Version)
+	require.Equal(t, []string{"capability-2"}, r.msg.Capabilities)
+
+	// messages a network sends to itself carry its highest version and its own capabilities
+	require.NoError(t, conduits[1].Send(ids[1], *unittest.TestMessageFixture(t)))
+	r = mustReceive(t, chs[1])
+	require.Equal(t, net.ProtocolVersion(3), r.msg.Version)
+	require.Equal(t, []string{"capability-1"}, r.msg.Capabilities)
+
+	err := conduits[0].Send(ids[2], *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, network.ErrIncompatibleVersion), "unexpected error %v", err)
+	require.Empty(t, chs[2])
+
+	// a network supports at least one version
+	_, err = network.NewNetwork(
+		unittest.Logger(zerolog.Disabled),
+		unittest.IdentifierFixture(t),
+		model.NewAddress("127.0.0.1", "0"),
+		network.NewStaticResolver(),
+		network.WithProtocolVersions(),
+	)
+	require.Error(t, err)
+}
+
+// TestNetwork_LegacyHello tests that peers of releases predating version negotiation are accepted as speaking version
+// 1, unless the network no longer supports it.
+func TestNetwork_LegacyHello(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		versions []net.ProtocolVersion
+		accepted bool
+	}{
+		{name: "supported", versions: []net.ProtocolVersion{1, 2}, accepted: true},
+		{name: "unsupported", versions: []net.ProtocolVersion{2}, accepted: false},
+	} {
+		t.Run(
+			tc.name, func(t *testing.T) {
+				nets, _, _ := startNetworks(t, 1, network.WithProtocolVersions(tc.versions...))
+				evts := peerEventsOf(nets[0])
+
+				conn, err := stdnet.Dial("tcp", nets[0].Address().String())
+				require.NoError(t, err)
+				defer func() {
+					_ = conn.Close()
+				}()
+				legacy := unittest.IdentifierFixture(t)
+				hello := append([]byte{1}, legacy[:]...)
+				_, err = conn.Write(append(binary.BigEndian.AppendUint32(nil, uint32(len(hello))), hello...))
+				require.NoError(t, err)
+
+				if tc.accepted {
+					e := mustReceiveEvent(t, evts, net.PeerConnected)
+					require.Equal(t, legacy, e.Peer)
+					return
+				}
+				e := mustReceiveEvent(t, evts, net.PeerHandshakeFailed)
+				require.True(t, errors.Is(e.Err, network.ErrIncompatibleVersion))
+				require.NoError(t, conn.SetReadDeadline(time.Now().Add(unittest.DefaultReadyDoneTimeout)))
+				_, err = io.ReadAll(conn)
+				require.NoError(t, err, "connection not closed by the network")
+			},
+		)
+	}
+}
+
+// TestNetwork_Compression tests that frames over the threshold are compressed between networks enabling compression,
+// unless compression does not shrink them, and are sent uncompressed to other networks.
+func TestNetwork_Compression(t *testing.T) {
+	nets, ids, _ := startNetworksOf(
+		t,
+		[]model.Identifier{unittest.IdentifierFixture(t), unittest.IdentifierFixture(t), unittest.IdentifierFixture(t)},
+		func(i int) []network.Option {
+			if i == 2 {
+				return nil
+			}
+			return []network.Option{network.WithCompression(network.DefaultCompressionThreshold)}
+		},
+	)
+	chs := make([]<-chan received, len(nets))
+	conduits := make([]net.Conduit, len(nets))
+	for i, n := range nets {
+		var p net.MessageProcessor
+		p, chs[i] = collectingProcessor()
+		c, err := n.Register(net.TestChannel, p)
+		require.NoError(t, err)
+		conduits[i] = c
+	}
+
+	compressible := bytes.Repeat([]byte("skipgraph lookup table snapshot "), 1024)
+	incompressible := unittest.RandomBytesFixture(t, 2*network.DefaultCompressionThreshold)
+	small := []byte("small")
+	for _, target := range []int{1, 2} {
+		for _, payload := range [][]byte{compressible, incompressible, small} {
+			require.NoError(t, conduits[0].Send(ids[target], net.Message{Payload: payload}))
+			require.Equal(t, payload, mustReceive(t, chs[target]).msg.Payload)
+		}
+	}
+
+	stats := nets[0].CompressionStats()
+	require.Equal(t, uint64(1), stats.Compressed)
+	require.Equal(t, uint64(1), stats.Incompressible)
+	require.Greater(t, stats.UncompressedBytes, uint64(len(compressible)))
+	require.Less(t, stats.Ratio(), 0.1)
+	require.Equal(t, uint64(1), nets[1].CompressionStats().Decompressed)
+	require.Equal(t, network.CompressionStats{}, nets[2].CompressionStats())
+}
+
+// TestNetwork_ChannelPriority tests that messages of high priority channels are delivered while the processing of bulk
+// channels is held back.
+func TestNetwork_ChannelPriority(t *testing.T) {
+	control, bulk := net.Channel("channel-control"), net.Channel("channel-bulk")
+	nets, ids, _ := startNetworks(
+		t, 2,
+		network.WithChannelPriority(control, net.PriorityHigh),
+		network.WithChannelPriority(bulk, net.PriorityLow),
+		network.WithDispatchOptions(dispatch.WithPriorityWorkerCount(net.PriorityLow, 1)),
+	)
+
+	release := make(chan struct{})
+	defer close(release)
+	blocked := make(chan struct{}, 100)
+	_, err := nets[1].Register(
+		bulk, mocknet.NewMockMessageProcessor(
+			func(net.Channel, model.Identifier, net.Message) {
+				blocked <- struct{}{}
+				<-release
+			},
+		),
+	)
+	require.NoError(t, err)
+	pc, chc := collectingProcessor()
+	_, err = nets[1].Register(control, pc)
+	require.NoError(t, err)
+
+	p0, _ := collectingProcessor()
+	cb, err := nets[0].Register(bulk, p0)
+	require.NoError(t, err)
+	cc, err := nets[0].Register(control, p0)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, cb.Send(ids[1], net.Message{Payload: unittest.RandomBytesFixture(t, 64*1024)}))
+	}
+	select {
+	case <-blocked:
+	case <-time.After(unittest.DefaultReadyDoneTimeout):
+		require.Fail(t, "bulk message not processed on time")
+	}
+	for i := 0; i < 10; i++ {
+		require.NoError(t, cc.Send(ids[1], *unittest.TestMessageFixture(t)))
+		require.Equal(t, ids[0], mustReceive(t, chc).origin)
+	}
+}
+
+// TestNetwork_InMemory tests that networks sharing a memory transport exchange messages within the process, that the
+// names of their endpoints are exclusive, and that stopped networks cannot be reached anymore.
+func TestNetwork_InMemory(t *testing.T) {
+	transport := network.NewMemoryTransport()
+	resolver := network.NewStaticResolver()
+	ctx := unittest.NewMockThrowableContext(t)
+
+	const count = 4
+	nets := make([]*network.Network, count)
+	ids := make([]model.Identifier, count)
+	channels := make([]<-chan received, count)
+	conduits := make([]net.Conduit, count)
+	for i := range nets {
+		// the first network listens on a fixed name, the others on free names
+		addr := model.NewInMemoryAddress("")
+		if i == 0 {
+			addr = model.NewInMemoryAddress("bootstrap")
+		}
+		ids[i] = unittest.IdentifierFixture(t)
+		n, err := network.NewNetwork(
+			unittest.Logger(zerolog.WarnLevel),
+			ids[i],
+			addr,
+			resolver,
+			network.WithTransport(transport),
+		)
+		require.NoError(t, err)
+		var p net.MessageProcessor
+		p, channels[i] = collectingProcessor()
+		conduits[i], err = n.Register(net.TestChannel, p)
+		require.NoError(t, err)
+		n.Start(ctx)
+		nets[i] = n
+	}
+	for i, n := range nets {
+		unittest.ChannelMustCloseWithinTimeout(t, n.Ready(), unittest.DefaultReadyDoneTimeout, "network not ready")
+		require.Equal(t, model.TransportInMemory, n.Address().Transport())
+		resolver.Add(model.NewIdentity(ids[i], unittest.MembershipVectorFixture(t), n.Address()))
+	}
+	require.Equal(t, model.NewInMemoryAddress("bootstrap"), nets[0].Address())
+
+	// the name of a listening network is taken
+	_, err := transport.Listen(model.NewInMemoryAddress("bootstrap"))
+	require.True(t, errors.Is(err, network.ErrEndpointInUse))
+
+	for i := range nets {
+		for j := range nets {
+			if i == j {
+				continue
+			}
+			msg := unittest.TestMessageFixture(t)
+			require.NoError(t, conduits[i].Send(ids[j], *msg))
+			r := mustReceive(t, channels[j])
+			require.Equal(t, ids[i], r.origin)
+			require.Equal(t, msg.Payload, r.msg.Payload)
+		}
+	}
+
+	ctx.Cancel()
+	for _, n := range nets {
+		unittest.ChannelMustCloseWithinTimeout(t, n.Done(), unittest.DefaultReadyDoneTimeout, "network not done")
+	}
+	// stopped networks free their names
+	_, err = transport.Dial(context.Background(), model.NewInMemoryAddress("bootstrap"))
+	require.True(t, errors.Is(err, network.ErrEndpointNotFound))
+	l, err := transport.Listen(model.NewInMemoryAddress("bootstrap"))
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+}
+
+// TestMemoryTransport tests that in-memory streams buffer writes until read, honor deadlines, and end with io.EOF once
+// their other end is closed.
+func TestMemoryTransport(t *testing.T) {
+	transport := network.NewMemoryTransport()
+	l, err := transport.Listen(model.NewInMemoryAddress(""))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, l.Close()) }()
+	addr, err := transport.Address(l.Addr())
+	require.NoError(t, err)
+
+	accepted := make(chan stdnet.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		require.NoError(t, err)
+		accepted <- conn
+	}()
+	dialed, err := transport.Dial(context.Background(), addr)
+	require.NoError(t, err)
+	var conn stdnet.Conn
+	select {
+	case conn = <-accepted:
+	case <-time.After(unittest.DefaultReadyDoneTimeout):
+		require.Fail(t, "stream not accepted on time")
+	}
+	require.Equal(t, addr.Path(), dialed.RemoteAddr().String())
+	require.Equal(t, dialed.LocalAddr().String(), conn.RemoteAddr().String())
+
+	// both ends write before reading
+	data := unittest.RandomBytesFixture(t, 1000)
+	_, err = dialed.Write(data)
+	require.NoError(t, err)
+	_, err = conn.Write(data)
+	require.NoError(t, err)
+	b := make([]byte, len(data))
+	_, err = io.ReadFull(conn, b)
+	require.NoError(t, err)
+	require.Equal(t, data, b)
+
+	// writes block once the buffer is full, until the deadline
+	require.NoError(t, conn.SetWriteDeadline(time.Now().Add(50*time.Millisecond)))
+	n, err := conn.Write(make([]byte, 2*network.MemoryBufferSize))
+	require.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+	require.Equal(t, network.MemoryBufferSize-len(data), n)
+	require.NoError(t, dialed.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	all, err := io.ReadAll(dialed)
+	require.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+	require.Len(t, all, network.MemoryBufferSize)
+
+	// closing an end ends the stream at the other end
+	require.NoError(t, dialed.SetReadDeadline(time.Time{}))
+	require.NoError(t, conn.SetWriteDeadline(time.Time{}))
+	_, err = dialed.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, dialed.Close())
+	b, err = io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, data, b)
+	_, err = conn.Write(data)
+	require.True(t, errors.Is(err, io.ErrClosedPipe))
+}
+
+// TestNetwork_Unix tests that networks listening on Unix domain sockets exchange messages, that sockets in use are not
+// listened on again while stale ones are replaced, and that stopped networks remove their sockets.
+func TestNetwork_Unix(t *testing.T) {
+	dir := t.TempDir()
+	resolver := network.NewStaticResolver()
+	ctx := unittest.NewMockThrowableContext(t)
+
+	// a socket left over by a crashed process is replaced
+	stale := filepath.Join(dir, "node-0.sock")
+	l, err := stdnet.Listen("unix", stale)
+	require.NoError(t, err)
+	l.(*stdnet.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, l.Close())
+
+	nets := make([]*network.Network, 2)
+	ids := make([]model.Identifier, 2)
+	channels := make([]<-chan received, 2)
+	conduits := make([]net.Conduit, 2)
+	for i := range nets {
+		ids[i] = unittest.IdentifierFixture(t)
+		n, err := network.NewNetwork(
+			unittest.Logger(zerolog.WarnLevel),
+			ids[i],
+			model.NewUnixAddress(filepath.Join(dir, fmt.Sprintf("node-%d.sock", i))),
+			resolver,
+		)
+		require.NoError(t, err)
+		var p net.MessageProcessor
+		p, channels[i] = collectingProcessor()
+		conduits[i], err = n.Register(net.TestChannel, p)
+		require.NoError(t, err)
+		n.Start(ctx)
+		nets[i] = n
+	}
+	for i, n := range nets {
+		unittest.ChannelMustCloseWithinTimeout(t, n.Ready(), unittest.DefaultReadyDoneTimeout, "network not ready")
+		require.Equal(t, model.NewUnixAddress(filepath.Join(dir, fmt.Sprintf("node-%d.sock", i))), n.Address())
+		resolver.Add(model.NewIdentity(ids[i], unittest.MembershipVectorFixture(t), n.Address()))
+	}
+
+	// a socket in use is not listened on again
+	_, err = network.NewUnixTransport().Listen(model.NewUnixAddress(stale))
+	require.Error(t, err)
+
+	msg := unittest.TestMessageFixture(t)
+	require.NoError(t, conduits[0].Send(ids[1], *msg))
+	r := mustReceive(t, channels[1])
+	require.Equal(t, ids[0], r.origin)
+	require.Equal(t, msg.Payload, r.msg.Payload)
+	require.NoError(t, conduits[1].Send(ids[0], *msg))
+	r = mustReceive(t, channels[0])
+	require.Equal(t, ids[1], r.origin)
+
+	ctx.Cancel()
+	for _, n := range nets {
+		unittest.ChannelMustCloseWithinTimeout(t, n.Done(), unittest.DefaultReadyDoneTimeout, "network not done")
+	}
+	_, err = os.Stat(stale)
+	require.True(t, errors.Is(err, os.ErrNotExist))
 }
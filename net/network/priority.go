@@ -0,0 +1,65 @@
+package network
+
+import (
+	"sync"
+
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/internal"
+)
+
+// prioritizedConnection is a connection whose concurrent sends are served by priority class: while a frame is being
+// written, the frames of the highest class waiting are written first, in no particular order within a class.
+// A frame being written is never interrupted, hence large messages should be sent in chunks, e.g., through a
+// stream.Streamer, so as not to delay frames of higher classes for long.
+type prioritizedConnection struct {
+	internal.Connection
+
+	l       sync.Mutex
+	cond    *sync.Cond
+	busy    bool                 // true while a frame is being written
+	waiting map[net.Priority]int // number of frames waiting to be written, by class
+}
+
+var _ internal.Connection = (*prioritizedConnection)(nil)
+
+// newPrioritizedConnection wraps the connection into a prioritizedConnection.
+func newPrioritizedConnection(conn internal.Connection) *prioritizedConnection {
+	c := &prioritizedConnection{Connection: conn, waiting: make(map[net.Priority]int, len(net.Priorities))}
+	c.cond = sync.NewCond(&c.l)
+	return c
+}
+
+// Send sends the frame with normal priority.
+func (c *prioritizedConnection) Send(frame []byte) error {
+	return c.SendWithPriority(frame, net.PriorityNormal)
+}
+
+// SendWithPriority sends the frame once no frame is being written, nor waiting with a higher priority.
+func (c *prioritizedConnection) SendWithPriority(frame []byte, priority net.Priority) error {
+	c.l.Lock()
+	c.waiting[priority]++
+	for c.busy || c.preempted(priority) {
+		c.cond.Wait()
+	}
+	c.waiting[priority]--
+	c.busy = true
+	c.l.Unlock()
+
+	defer func() {
+		c.l.Lock()
+		c.busy = false
+		c.l.Unlock()
+		c.cond.Broadcast()
+	}()
+	return c.Connection.Send(frame)
+}
+
+// preempted returns true if frames of a higher priority than the given one are waiting; the caller must hold the lock.
+func (c *prioritizedConnection) preempted(priority net.Priority) bool {
+	for p, count := range c.waiting {
+		if p > priority && count > 0 {
+			return true
+		}
+	}
+	return false
+}
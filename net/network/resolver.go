@@ -0,0 +1,61 @@
+package network
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/thep2p/skipgraph-go/core/model"
+)
+
+// Resolver resolves the identifier of a peer to the addresses it can be reached at.
+type Resolver interface {
+	// Resolve returns the known addresses of the peer, in order of preference.
+	// Returns an error wrapping ErrUnknownPeer if no address is known for the peer.
+	Resolve(id model.Identifier) ([]model.Address, error)
+}
+
+// StaticResolver is a Resolver backed by a fixed set of identities, e.g., the bootstrap nodes of a deployment.
+// It is safe for concurrent use.
+type StaticResolver struct {
+	l          sync.RWMutex
+	identities map[model.Identifier]model.Identity
+}
+
+var _ Resolver = (*StaticResolver)(nil)
+
+// NewStaticResolver creates a StaticResolver that resolves the given identities to their addresses.
+func NewStaticResolver(identities ...model.Identity) *StaticResolver {
+	r := &StaticResolver{identities: make(map[model.Identifier]model.Identity, len(identities))}
+	for _, identity := range identities {
+		r.Add(identity)
+	}
+	return r
+}
+
+// Add adds the identity to the resolver, replacing any identity previously known for the same identifier.
+func (r *StaticResolver) Add(identity model.Identity) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.identities[identity.GetIdentifier()] = identity
+}
+
+// Resolve returns the non-zero addresses of the identity known for id.
+// Returns an error wrapping ErrUnknownPeer if the identity is unknown or has no address.
+func (r *StaticResolver) Resolve(id model.Identifier) ([]model.Address, error) {
+	r.l.RLock()
+	identity, ok := r.identities[id]
+	r.l.RUnlock()
+
+	addrs := make([]model.Address, 0)
+	if ok {
+		for _, addr := range identity.GetAddresses() {
+			if !addr.IsZero() {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPeer, id.String())
+	}
+	return addrs, nil
+}
@@ -0,0 +1,64 @@
+package network
+
+import (
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	stdnet "net"
+
+	"github.com/thep2p/skipgraph-go/core/crypto"
+	"github.com/thep2p/skipgraph-go/core/model"
+)
+
+// newTLSConfig returns the TLS 1.3 configuration of a network whose identity is bound to the key, for both sides of
+// its connections. Peers must present a self-signed certificate of an ed25519 key; chains are not verified, as the
+// identity of a peer is the identifier bound to its key (see crypto.IdentifierOfCertificate).
+// Returns an error if the certificate of the key cannot be created.
+func newTLSConfig(key ed25519.PrivateKey) (*tls.Config, error) {
+	cert, err := crypto.SelfSignedCertificate(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not create certificate: %w", err)
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS13,
+		Certificates: []tls.Certificate{cert},
+		// the certificate of the peer is checked by verifyPeer, on both sides
+		ClientAuth:            tls.RequireAnyClientCert,
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: verifyPeer,
+	}, nil
+}
+
+// verifyPeer checks that the peer presents exactly one self-signed certificate of an ed25519 key.
+func verifyPeer(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) != 1 {
+		return fmt.Errorf("%w: expected one certificate, got %d", crypto.ErrInvalidCertificate, len(rawCerts))
+	}
+	_, err := crypto.IdentifierOfCertificate(rawCerts[0])
+	return err
+}
+
+// authenticate performs the TLS handshake of the connection, as the client or the server.
+// Returns the secured connection along with the identifier bound to the key of the peer, or an error wrapping
+// ErrHandshakeFailed.
+func authenticate(raw stdnet.Conn, config *tls.Config, client bool) (*tls.Conn, model.Identifier, error) {
+	var conn *tls.Conn
+	if client {
+		conn = tls.Client(raw, config)
+	} else {
+		conn = tls.Server(raw, config)
+	}
+	if err := conn.Handshake(); err != nil {
+		return nil, model.Identifier{}, fmt.Errorf("%w: tls: %w", ErrHandshakeFailed, err)
+	}
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) != 1 {
+		return nil, model.Identifier{}, fmt.Errorf("%w: peer presented no certificate", ErrHandshakeFailed)
+	}
+	peer, err := crypto.IdentifierOfCertificate(certs[0].Raw)
+	if err != nil {
+		return nil, model.Identifier{}, fmt.Errorf("%w: %w", ErrHandshakeFailed, err)
+	}
+	return conn, peer, nil
+}
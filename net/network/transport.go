@@ -0,0 +1,152 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	stdnet "net"
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/thep2p/skipgraph-go/core/model"
+)
+
+// Transport establishes the byte streams the network runs over.
+// The network is agnostic of the transport: framing, handshake and dispatch are the same for every transport.
+type Transport interface {
+	// Name returns the model.Transport of the addresses this transport listens on and dials.
+	Name() model.Transport
+
+	// Listen starts listening on the address.
+	// Returns the listener, or an error if the address cannot be listened on.
+	Listen(addr model.Address) (stdnet.Listener, error)
+
+	// Dial connects to the address, aborting when ctx is done.
+	// Returns the established stream, or an error if the address cannot be reached.
+	Dial(ctx context.Context, addr model.Address) (stdnet.Conn, error)
+
+	// Address converts the local address of a listener of this transport to a model.Address.
+	Address(addr stdnet.Addr) (model.Address, error)
+}
+
+// TCPTransport is the Transport over TCP.
+type TCPTransport struct {
+	dialer stdnet.Dialer
+}
+
+var _ Transport = (*TCPTransport)(nil)
+
+// NewTCPTransport creates a new TCPTransport.
+func NewTCPTransport() *TCPTransport {
+	return &TCPTransport{}
+}
+
+// Name returns model.TransportTCP.
+func (t *TCPTransport) Name() model.Transport {
+	return model.TransportTCP
+}
+
+// Listen starts listening on the host:port of the address; port 0 picks a free port.
+func (t *TCPTransport) Listen(addr model.Address) (stdnet.Listener, error) {
+	l, err := stdnet.Listen(string(model.TransportTCP), addr.String())
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on %s: %w", addr, err)
+	}
+	return l, nil
+}
+
+// Dial connects to the host:port of the address.
+func (t *TCPTransport) Dial(ctx context.Context, addr model.Address) (stdnet.Conn, error) {
+	conn, err := t.dialer.DialContext(ctx, string(model.TransportTCP), addr.String())
+	if err != nil {
+		return nil, fmt.Errorf("could not dial %s: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// Address converts a *net.TCPAddr to a tcp model.Address.
+func (t *TCPTransport) Address(addr stdnet.Addr) (model.Address, error) {
+	tcpAddr, ok := addr.(*stdnet.TCPAddr)
+	if !ok {
+		return model.Address{}, fmt.Errorf("%w: not a tcp address: %s", model.ErrInvalidAddress, addr)
+	}
+	return model.NewAddress(tcpAddr.IP.String(), strconv.Itoa(tcpAddr.Port)), nil
+}
+
+// UnixTransport is the Transport over Unix domain sockets, e.g., between nodes sharing a host as sidecars.
+type UnixTransport struct {
+	dialer stdnet.Dialer
+}
+
+var _ Transport = (*UnixTransport)(nil)
+
+// NewUnixTransport creates a new UnixTransport.
+func NewUnixTransport() *UnixTransport {
+	return &UnixTransport{}
+}
+
+// Name returns model.TransportUnix.
+func (t *UnixTransport) Name() model.Transport {
+	return model.TransportUnix
+}
+
+// Listen starts listening on the socket path of the address; the socket file is removed once the listener is closed.
+// A socket file left over by a process that did not close its listener is replaced, while a socket that is listened
+// on is not.
+func (t *UnixTransport) Listen(addr model.Address) (stdnet.Listener, error) {
+	if addr.Path() == "" {
+		return nil, fmt.Errorf("could not listen on %s: %w: empty socket path", addr, model.ErrInvalidAddress)
+	}
+	if err := removeStaleSocket(addr.Path()); err != nil {
+		return nil, fmt.Errorf("could not listen on %s: %w", addr, err)
+	}
+	l, err := stdnet.Listen(string(model.TransportUnix), addr.Path())
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on %s: %w", addr, err)
+	}
+	return l, nil
+}
+
+// Dial connects to the socket path of the address.
+func (t *UnixTransport) Dial(ctx context.Context, addr model.Address) (stdnet.Conn, error) {
+	conn, err := t.dialer.DialContext(ctx, string(model.TransportUnix), addr.Path())
+	if err != nil {
+		return nil, fmt.Errorf("could not dial %s: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// Address converts a *net.UnixAddr to a unix model.Address.
+func (t *UnixTransport) Address(addr stdnet.Addr) (model.Address, error) {
+	unixAddr, ok := addr.(*stdnet.UnixAddr)
+	if !ok || unixAddr.Name == "" {
+		return model.Address{}, fmt.Errorf("%w: not a unix socket address: %s", model.ErrInvalidAddress, addr)
+	}
+	return model.NewUnixAddress(unixAddr.Name), nil
+}
+
+// removeStaleSocket removes the socket file at path if nothing listens on it anymore.
+// Returns an error if the file is not a socket, or if the socket is listened on.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	conn, err := stdnet.Dial(string(model.TransportUnix), path)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("%s is in use", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return err
+	}
+	return os.Remove(path)
+}
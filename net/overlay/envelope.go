@@ -0,0 +1,139 @@
+package overlay
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net/codec"
+)
+
+// CodeEnvelope is the codec type code of Envelope.
+const CodeEnvelope codec.Code = 0x400
+
+// envelopeHeaderSize is the size in bytes of the fixed part of an encoded envelope.
+const envelopeHeaderSize = 2*model.IdentifierSizeBytes + 8 + 1 + 1
+
+// envelopeKind identifies the kind of an Envelope.
+type envelopeKind uint8
+
+const (
+	// kindData carries a message to its target.
+	kindData envelopeKind = 1
+	// kindAck acknowledges the delivery of a message to its origin.
+	kindAck envelopeKind = 2
+	// kindNack reports to its origin that a message could not be delivered.
+	kindNack envelopeKind = 3
+)
+
+// nackReason is the reason a message could not be delivered.
+type nackReason uint8
+
+const (
+	reasonUnreachable      nackReason = 1
+	reasonHopLimitExceeded nackReason = 2
+	reasonLoopDetected     nackReason = 3
+	reasonBusy             nackReason = 4
+)
+
+// err returns the sentinel error of the reason.
+func (r nackReason) err() error {
+	switch r {
+	case reasonHopLimitExceeded:
+		return ErrHopLimitExceeded
+	case reasonLoopDetected:
+		return ErrLoopDetected
+	case reasonBusy:
+		return ErrBusy
+	default:
+		return ErrUnreachable
+	}
+}
+
+// Envelope wraps a message routed through the overlay, and the acknowledgements of its delivery.
+// Envelopes are created by Router; they must be registered in the codec registry of the network with RegisterCodec.
+//
+// Wire layout: origin (32) | target (32) | id (8) | kind (1) | hops (1) | payload (encoded by the registry) for data,
+// nothing for acks, or reason (1) for nacks.
+type Envelope struct {
+	origin  model.Identifier // the node that sent the envelope
+	target  model.Identifier // the node the envelope is routed to
+	id      uint64           // identifies the message among those of its origin; acks carry the id of the message
+	kind    envelopeKind     // data, ack or nack
+	hops    uint8            // the number of hops the envelope may still take
+	payload any              // the message, only set for data
+	reason  nackReason       // the reason of a nack
+}
+
+// RegisterCodec registers Envelope in the registry; the payloads of envelopes are encoded by the same registry.
+// Returns an error wrapping codec.ErrCodeRegistered or codec.ErrTypeRegistered if it is already registered.
+func RegisterCodec(r *codec.Registry) error {
+	return codec.Register(
+		r, CodeEnvelope,
+		func(e Envelope) ([]byte, error) {
+			return encodeEnvelope(r, e)
+		},
+		func(b []byte) (Envelope, error) {
+			return decodeEnvelope(r, b)
+		},
+	)
+}
+
+// encodeEnvelope returns the encoding of the envelope, with its payload encoded by the registry.
+func encodeEnvelope(r *codec.Registry, e Envelope) ([]byte, error) {
+	b := make([]byte, 0, envelopeHeaderSize)
+	b = append(b, e.origin[:]...)
+	b = append(b, e.target[:]...)
+	b = binary.BigEndian.AppendUint64(b, e.id)
+	b = append(b, byte(e.kind), e.hops)
+
+	switch e.kind {
+	case kindData:
+		payload, err := r.Encode(e.payload)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode payload of message %d: %w", e.id, err)
+		}
+		return append(b, payload...), nil
+	case kindNack:
+		return append(b, byte(e.reason)), nil
+	default:
+		return b, nil
+	}
+}
+
+// decodeEnvelope returns the envelope encoded in b, with its payload decoded by the registry.
+// Returns an error wrapping ErrMalformedEnvelope if b is malformed, or the decoding error of the payload.
+func decodeEnvelope(r *codec.Registry, b []byte) (Envelope, error) {
+	if len(b) < envelopeHeaderSize {
+		return Envelope{}, fmt.Errorf("%w: envelope of %d bytes is too short", ErrMalformedEnvelope, len(b))
+	}
+	e := Envelope{}
+	copy(e.origin[:], b)
+	copy(e.target[:], b[model.IdentifierSizeBytes:])
+	b = b[2*model.IdentifierSizeBytes:]
+	e.id = binary.BigEndian.Uint64(b)
+	e.kind = envelopeKind(b[8])
+	e.hops = b[9]
+	b = b[10:]
+
+	switch e.kind {
+	case kindData:
+		payload, err := r.Decode(b)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("could not decode payload of message %d: %w", e.id, err)
+		}
+		e.payload = payload
+	case kindAck:
+		if len(b) != 0 {
+			return Envelope{}, fmt.Errorf("%w: ack with %d trailing bytes", ErrMalformedEnvelope, len(b))
+		}
+	case kindNack:
+		if len(b) != 1 || b[0] < byte(reasonUnreachable) || b[0] > byte(reasonBusy) {
+			return Envelope{}, fmt.Errorf("%w: invalid nack reason", ErrMalformedEnvelope)
+		}
+		e.reason = nackReason(b[0])
+	default:
+		return Envelope{}, fmt.Errorf("%w: unknown kind %d", ErrMalformedEnvelope, e.kind)
+	}
+	return e, nil
+}
@@ -0,0 +1,22 @@
+package overlay
+
+import "errors"
+
+// ErrUnreachable is returned when a message cannot make progress toward its target, e.g., as the target is not in the
+// skip graph or the next hop cannot be reached.
+var ErrUnreachable = errors.New("target unreachable through the overlay")
+
+// ErrHopLimitExceeded is returned when a message exhausts its hop limit before reaching its target.
+var ErrHopLimitExceeded = errors.New("hop limit exceeded")
+
+// ErrLoopDetected is returned when a message is routed back to a node it already went through.
+var ErrLoopDetected = errors.New("routing loop detected")
+
+// ErrBusy is returned when a relay of a message sends its maximum number of envelopes at a time already.
+var ErrBusy = errors.New("relay too busy")
+
+// ErrAckTimeout is returned when the delivery of a message is not acknowledged on time.
+var ErrAckTimeout = errors.New("delivery not acknowledged on time")
+
+// ErrMalformedEnvelope is returned when a received envelope cannot be decoded.
+var ErrMalformedEnvelope = errors.New("malformed overlay envelope")
@@ -0,0 +1,421 @@
+// Package overlay implements unicast to any identifier of the skip graph on top of a net.Network channel.
+//
+// A Router sends a message directly to its target when the target is a neighbor in its lookup table, and otherwise
+// routes it through the skip graph: each node forwards the message to the neighbor in its lookup table that is the
+// closest to the target without passing it, i.e., the next hop of a search for the identifier of the target. Messages carry a hop limit, and
+// nodes remember the messages they relay so that a message coming back to a node is detected as a loop. The target
+// acknowledges the delivery of each message to its origin, and nodes that cannot make progress report it to the
+// origin, hence Send returns once the delivery is acknowledged or known to have failed.
+package overlay
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/thep2p/skipgraph-go/core"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/internal/bounded"
+)
+
+// DefaultHopLimit is the default number of relays a message may go through.
+const DefaultHopLimit = 32
+
+// DefaultAckTimeout is the default time Send waits for the delivery of a message to be acknowledged.
+const DefaultAckTimeout = 5 * time.Second
+
+// DefaultSeenCapacity is the default number of relayed messages remembered for loop detection.
+const DefaultSeenCapacity = 4096
+
+// DefaultMaxConcurrentSends is the default number of envelopes a router relays or acknowledges at a time.
+const DefaultMaxConcurrentSends = 64
+
+// seenKey identifies an envelope.
+type seenKey struct {
+	origin model.Identifier
+	id     uint64
+	kind   envelopeKind
+}
+
+// pendingSend is a send waiting for the acknowledgement of its message.
+type pendingSend struct {
+	target  model.Identifier
+	results chan Envelope
+}
+
+// Router sends messages to any node of the skip graph, routing them through the overlay when the target is not a
+// neighbor of the node, and delivers the messages it receives to a processor.
+// It is safe for concurrent use.
+type Router struct {
+	logger     zerolog.Logger
+	self       model.Identifier
+	channel    net.Channel
+	conduit    net.Conduit
+	table      core.ImmutableLookupTable
+	processor  net.MessageProcessor
+	hopLimit   uint8
+	ackTimeout time.Duration
+	nextID     atomic.Uint64
+	sending    *bounded.Group
+
+	l        sync.Mutex
+	pending  map[uint64]pendingSend
+	seen     map[seenKey]struct{}
+	seenRing []seenKey // envelopes in the order they were seen, evicted oldest first
+	seenNext int       // index of the next slot of seenRing to fill
+}
+
+var _ net.MessageProcessor = (*Router)(nil)
+var _ net.Conduit = (*Router)(nil)
+
+// Option is a functional option for configuring a Router.
+type Option func(*Router)
+
+// WithHopLimit sets the number of relays a message may go through; defaults to DefaultHopLimit.
+func WithHopLimit(limit uint8) Option {
+	return func(r *Router) {
+		r.hopLimit = limit
+	}
+}
+
+// WithAckTimeout sets the time Send waits for the delivery of a message to be acknowledged; defaults to
+// DefaultAckTimeout.
+func WithAckTimeout(timeout time.Duration) Option {
+	return func(r *Router) {
+		r.ackTimeout = timeout
+	}
+}
+
+// WithSeenCapacity sets the number of relayed messages remembered for loop detection; defaults to
+// DefaultSeenCapacity.
+func WithSeenCapacity(capacity int) Option {
+	return func(r *Router) {
+		if capacity > 0 {
+			r.seenRing = make([]seenKey, capacity)
+		}
+	}
+}
+
+// WithMaxConcurrentSends sets the number of envelopes the router relays or acknowledges at a time; defaults to
+// DefaultMaxConcurrentSends. The messages received while the router sends that many are not relayed, and their
+// origin is answered with a nack wrapping ErrBusy instead, while acknowledgements are sent synchronously.
+func WithMaxConcurrentSends(max int) Option {
+	return func(r *Router) {
+		r.sending = bounded.NewGroup(max)
+	}
+}
+
+// NewRouter creates a Router and registers it as the processor of the channel.
+// The envelopes of the router must be registered in the codec registry of the network (see RegisterCodec).
+// Args:
+//   - logger: zerolog.Logger for logging
+//   - self: the identifier of the node, i.e., the origin of its messages
+//   - network: the network to send messages through
+//   - channel: the channel of the messages; the router is its only processor
+//   - table: the lookup table of the node, whose neighbors are the next hops of routed messages
+//   - processor: the processor the messages targeting the node are delivered to, with their origin as originID
+//   - opts: variadic options for configuring the router
+//
+// Returns the router, or the error of registering it on the channel, which must be treated as fatal.
+func NewRouter(
+	logger zerolog.Logger,
+	self model.Identifier,
+	network net.Network,
+	channel net.Channel,
+	table core.ImmutableLookupTable,
+	processor net.MessageProcessor,
+	opts ...Option,
+) (*Router, error) {
+	r := &Router{
+		logger: logger.With().
+			Str("component", "overlay").
+			Str("channel", string(channel)).
+			Logger(),
+		self:       self,
+		channel:    channel,
+		table:      table,
+		processor:  processor,
+		hopLimit:   DefaultHopLimit,
+		ackTimeout: DefaultAckTimeout,
+		pending:    make(map[uint64]pendingSend),
+		seen:       make(map[seenKey]struct{}),
+		seenRing:   make([]seenKey, DefaultSeenCapacity),
+		sending:    bounded.NewGroup(DefaultMaxConcurrentSends),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	// message identifiers start at a random offset so that a restarted node is not mistaken for a loop
+	var seed [8]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return nil, fmt.Errorf("could not seed message identifiers: %w", err)
+	}
+	r.nextID.Store(binary.BigEndian.Uint64(seed[:]))
+
+	conduit, err := network.Register(channel, r)
+	if err != nil {
+		return nil, fmt.Errorf("could not register router: %w", err)
+	}
+	r.conduit = conduit
+	return r, nil
+}
+
+// Send sends the message to the target, directly if it is a neighbor of the node and through the overlay otherwise,
+// and waits for its delivery to be acknowledged.
+// Returns an error:
+//   - wrapping ErrUnreachable if the message cannot make progress toward the target, at this node or a relay.
+//   - wrapping ErrHopLimitExceeded if the message exhausts its hop limit.
+//   - wrapping ErrLoopDetected if the message is routed back to a node it already went through.
+//   - wrapping ErrBusy if a relay of the message sends its maximum number of envelopes at a time already.
+//   - wrapping ErrAckTimeout if the delivery is not acknowledged on time; the message may still have been delivered.
+//
+// Any returned error is benign.
+func (r *Router) Send(target model.Identifier, msg net.Message) error {
+	if target == r.self {
+		r.processor.ProcessIncomingMessage(r.channel, r.self, msg)
+		return nil
+	}
+
+	env := Envelope{
+		origin:  r.self,
+		target:  target,
+		id:      r.nextID.Add(1),
+		kind:    kindData,
+		hops:    r.hopLimit,
+		payload: msg.Payload,
+	}
+	results := make(chan Envelope, 1)
+	r.l.Lock()
+	r.pending[env.id] = pendingSend{target: target, results: results}
+	r.l.Unlock()
+	defer func() {
+		r.l.Lock()
+		delete(r.pending, env.id)
+		r.l.Unlock()
+	}()
+	// a message routed back to its origin is a loop
+	r.markSeen(seenKey{origin: env.origin, id: env.id, kind: env.kind})
+
+	if err := r.route(env); err != nil {
+		return fmt.Errorf("could not send message to %s: %w", target.String(), err)
+	}
+
+	timer := time.NewTimer(r.ackTimeout)
+	defer timer.Stop()
+	select {
+	case res := <-results:
+		if res.kind == kindNack {
+			return fmt.Errorf("could not deliver message to %s: %w (reported by %s)", target.String(), res.reason.err(), res.origin.String())
+		}
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("%w: message %d to %s", ErrAckTimeout, env.id, target.String())
+	}
+}
+
+// ProcessIncomingMessage delivers the messages targeting the node to its processor and acknowledges them, completes
+// the pending sends of the node, and relays the other envelopes toward their target.
+// Messages other than envelopes are logged and dropped.
+func (r *Router) ProcessIncomingMessage(_ net.Channel, originID model.Identifier, msg net.Message) {
+	env, ok := msg.Payload.(Envelope)
+	if !ok {
+		r.logger.Warn().
+			Str("origin", originID.String()).
+			Str("type", fmt.Sprintf("%T", msg.Payload)).
+			Msg("Dropping message that is not an overlay envelope")
+		return
+	}
+	key := seenKey{origin: env.origin, id: env.id, kind: env.kind}
+
+	if env.target != r.self {
+		if !r.markSeen(key) {
+			r.logger.Warn().
+				Str("origin", env.origin.String()).
+				Str("target", env.target.String()).
+				Uint64("message_id", env.id).
+				Msg("Dropping message routed in a loop")
+			if env.kind == kindData {
+				nack := func() { r.reply(env, kindNack, reasonLoopDetected) }
+				r.send(nack, nack)
+			}
+			return
+		}
+		r.send(
+			func() { r.relay(env) },
+			func() {
+				if env.kind != kindData {
+					// acknowledgements are relayed anyway, as their origin waits for them
+					r.relay(env)
+					return
+				}
+				r.logger.Warn().
+					Str("origin", env.origin.String()).
+					Str("target", env.target.String()).
+					Uint64("message_id", env.id).
+					Msg("Not relaying message, too many sends in flight")
+				r.reply(env, kindNack, reasonBusy)
+			},
+		)
+		return
+	}
+
+	switch env.kind {
+	case kindData:
+		// a duplicate is acknowledged again, as the previous acknowledgement may have been lost
+		if r.markSeen(key) {
+			r.processor.ProcessIncomingMessage(r.channel, env.origin, net.Message{Payload: env.payload})
+		}
+		ack := func() { r.reply(env, kindAck, 0) }
+		r.send(ack, ack)
+	default:
+		r.complete(env)
+	}
+}
+
+// send runs the sending of a relay or an acknowledgement f asynchronously, so that processing a message never blocks
+// on sending one. When the router sends its maximum number of envelopes already, fallback runs synchronously instead,
+// so that the origin of the message is answered anyway.
+func (r *Router) send(f func(), fallback func()) {
+	if !r.sending.Go(f) {
+		fallback()
+	}
+}
+
+// relay routes the envelope toward its target; a message that cannot make progress is reported to its origin.
+func (r *Router) relay(env Envelope) {
+	err := r.route(env)
+	if err == nil {
+		return
+	}
+	r.logger.Debug().
+		Err(err).
+		Str("origin", env.origin.String()).
+		Str("target", env.target.String()).
+		Uint64("message_id", env.id).
+		Msg("Could not relay message")
+	if env.kind != kindData {
+		return
+	}
+	reason := reasonUnreachable
+	if errors.Is(err, ErrHopLimitExceeded) {
+		reason = reasonHopLimitExceeded
+	}
+	r.reply(env, kindNack, reason)
+}
+
+// reply sends an acknowledgement of the given kind of the message to its origin.
+func (r *Router) reply(msg Envelope, kind envelopeKind, reason nackReason) {
+	env := Envelope{origin: r.self, target: msg.origin, id: msg.id, kind: kind, hops: r.hopLimit, reason: reason}
+	if env.target == r.self {
+		r.complete(env)
+		return
+	}
+	if err := r.route(env); err != nil {
+		r.logger.Debug().
+			Err(err).
+			Str("target", env.target.String()).
+			Uint64("message_id", env.id).
+			Msg("Could not acknowledge message")
+	}
+}
+
+// complete hands the acknowledgement over to the pending send of its message, if any.
+// Acks are only accepted from the target of the message, while nacks are accepted from any node the message went
+// through.
+func (r *Router) complete(env Envelope) {
+	r.l.Lock()
+	p, ok := r.pending[env.id]
+	r.l.Unlock()
+	if !ok {
+		r.logger.Debug().Uint64("message_id", env.id).Msg("Dropping acknowledgement of unknown message")
+		return
+	}
+	if env.kind == kindAck && env.origin != p.target {
+		r.logger.Warn().
+			Str("origin", env.origin.String()).
+			Str("target", p.target.String()).
+			Uint64("message_id", env.id).
+			Msg("Dropping acknowledgement of message from other node than its target")
+		return
+	}
+	select {
+	case p.results <- env:
+	default:
+		// the message has already been acknowledged
+	}
+}
+
+// route sends the envelope to the next hop toward its target, which is the target itself if it is a neighbor of the
+// node; only the hops to other nodes than the target count against the hop limit of the envelope.
+// Returns an error wrapping ErrHopLimitExceeded if the envelope cannot take another hop, or ErrUnreachable if there is
+// no next hop or it cannot be reached.
+func (r *Router) route(env Envelope) error {
+	next, err := r.nextHop(env.target)
+	if err != nil {
+		return fmt.Errorf("could not find next hop: %w", err)
+	}
+	if next == nil {
+		return fmt.Errorf("%w: no next hop toward %s", ErrUnreachable, env.target.String())
+	}
+	if *next != env.target {
+		if env.hops == 0 {
+			return fmt.Errorf("%w: next hop toward %s is %s", ErrHopLimitExceeded, env.target.String(), next.String())
+		}
+		env.hops--
+	}
+	if err := r.conduit.Send(*next, net.Message{Payload: env}); err != nil {
+		return fmt.Errorf("%w: could not send to next hop %s: %w", ErrUnreachable, next.String(), err)
+	}
+	return nil
+}
+
+// nextHop returns the neighbor at the highest level of the lookup table that is between the node and the target, or
+// is the target, i.e., the next hop of a search for the target. Returns nil if there is none.
+func (r *Router) nextHop(target model.Identifier) (*model.Identifier, error) {
+	dir := types.DirectionLeft
+	if target.Cmp(&r.self) > 0 {
+		dir = types.DirectionRight
+	}
+
+	for level := core.MaxLookupTableLevel - 1; level >= 0; level-- {
+		neighbor, err := r.table.GetEntry(dir, level)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s neighbor at level %d: %w", dir, level, err)
+		}
+		if neighbor == nil {
+			continue
+		}
+		id := neighbor.GetIdentifier()
+		cmp := id.Cmp(&target)
+		if (dir == types.DirectionRight && cmp <= 0) || (dir == types.DirectionLeft && cmp >= 0) {
+			return &id, nil
+		}
+	}
+	return nil, nil
+}
+
+// markSeen remembers the envelope, evicting the oldest one if the memory is full.
+// Returns false if the envelope was already remembered.
+func (r *Router) markSeen(key seenKey) bool {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if _, ok := r.seen[key]; ok {
+		return false
+	}
+	if evicted := r.seenRing[r.seenNext]; evicted != (seenKey{}) {
+		delete(r.seen, evicted)
+	}
+	r.seenRing[r.seenNext] = key
+	r.seenNext = (r.seenNext + 1) % len(r.seenRing)
+	r.seen[key] = struct{}{}
+	return true
+}
@@ -0,0 +1,334 @@
+package overlay_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/codec"
+	"github.com/thep2p/skipgraph-go/net/overlay"
+	"github.com/thep2p/skipgraph-go/unittest"
+	"github.com/thep2p/skipgraph-go/unittest/mocknet"
+)
+
+// restrictedNetwork is a network whose conduits can only send to the reachable peers of the node, record the
+// messages they send, and hold the messages to gated peers until their gate is closed.
+type restrictedNetwork struct {
+	net.Network
+	reachable map[model.Identifier]bool
+	sent      chan net.Message
+	gates     map[model.Identifier]chan struct{}
+}
+
+func (n *restrictedNetwork) Register(channel net.Channel, processor net.MessageProcessor) (net.Conduit, error) {
+	c, err := n.Network.Register(channel, processor)
+	if err != nil {
+		return nil, err
+	}
+	return &restrictedConduit{conduit: c, reachable: n.reachable, sent: n.sent, gates: n.gates}, nil
+}
+
+// restrictedConduit fails to send to the peers that are not reachable.
+type restrictedConduit struct {
+	conduit   net.Conduit
+	reachable map[model.Identifier]bool
+	sent      chan net.Message
+	gates     map[model.Identifier]chan struct{}
+}
+
+func (c *restrictedConduit) Send(target model.Identifier, msg net.Message) error {
+	if !c.reachable[target] {
+		return fmt.Errorf("%s is not reachable", target.String())
+	}
+	select {
+	case c.sent <- msg:
+	default:
+	}
+	if gate, ok := c.gates[target]; ok {
+		<-gate
+	}
+	return c.conduit.Send(target, msg)
+}
+
+// node is a router built upon a skip graph node, along with the peers it can send to.
+type node struct {
+	*mocknet.SkipGraphNode
+	reachable map[model.Identifier]bool
+	sent      chan net.Message                   // the envelopes the node sent to reachable peers
+	gates     map[model.Identifier]chan struct{} // set before sending, sends to gated peers wait for their gate
+	router    *overlay.Router
+}
+
+// nodes creates count routers connected through a mock network, sorted by identifier, with empty lookup tables.
+// Nodes can only send to the peers added to their lookup tables by link.
+func nodes(t *testing.T, count int, opts ...overlay.Option) []*node {
+	registry := codec.NewDefaultRegistry()
+	require.NoError(t, overlay.RegisterCodec(registry))
+	stub := mocknet.NewNetworkStubWithCodecs(registry)
+
+	res := make([]*node, count)
+	for i, n := range mocknet.NewSkipGraphNodes(t, count) {
+		reachable := make(map[model.Identifier]bool)
+		sent := make(chan net.Message, 100)
+		gates := make(map[model.Identifier]chan struct{})
+		r, err := overlay.NewRouter(
+			unittest.Logger(zerolog.Disabled),
+			n.ID,
+			&restrictedNetwork{Network: stub.NewMockNetwork(t, n.ID), reachable: reachable, sent: sent, gates: gates},
+			net.TestChannel,
+			n.Table,
+			n.Processor,
+			opts...,
+		)
+		require.NoError(t, err)
+		res[i] = &node{SkipGraphNode: n, reachable: reachable, sent: sent, gates: gates, router: r}
+	}
+	return res
+}
+
+// link makes the nodes, in order, a level list at the level of their lookup tables, and reachable by their neighbors.
+func link(t *testing.T, level types.Level, list ...*node) {
+	for i := 0; i+1 < len(list); i++ {
+		linkPair(t, level, list[i], list[i+1])
+	}
+}
+
+// linkPair makes right the right neighbor of left, and left the left neighbor of right, at the level, and each
+// reachable by the other.
+func linkPair(t *testing.T, level types.Level, left *node, right *node) {
+	mocknet.LinkPair(t, level, left.SkipGraphNode, right.SkipGraphNode)
+	left.reachable[right.ID] = true
+	right.reachable[left.ID] = true
+}
+
+// skipGraph returns 8 nodes forming a skip graph of 3 levels: all nodes at level 0, every other node at level 1 and
+// every fourth node at level 2.
+func skipGraph(t *testing.T, opts ...overlay.Option) []*node {
+	all := nodes(t, 8, opts...)
+	link(t, 0, all...)
+	link(t, 1, all[0], all[2], all[4], all[6])
+	link(t, 2, all[0], all[4])
+	return all
+}
+
+// requireReceived requires that the node receives the message from origin.
+func requireReceived(t *testing.T, n *node, origin model.Identifier, msg net.Message) {
+	select {
+	case r := <-n.Received:
+		require.Equal(t, origin, r.Origin)
+		require.Equal(t, msg.Payload, r.Msg.Payload)
+	case <-time.After(unittest.DefaultReadyDoneTimeout):
+		require.Fail(t, "message not received on time")
+	}
+}
+
+// TestRouter_Send tests that messages are delivered to their target, directly or through the overlay, and that their
+// delivery is acknowledged.
+func TestRouter_Send(t *testing.T) {
+	all := skipGraph(t)
+	msg := unittest.TestMessageFixture(t)
+
+	for _, pair := range [][2]int{{0, 7}, {7, 0}, {3, 6}, {5, 1}, {0, 1}, {2, 2}} {
+		origin, target := all[pair[0]], all[pair[1]]
+		require.NoError(t, origin.router.Send(target.ID, *msg))
+		requireReceived(t, target, origin.ID, *msg)
+	}
+
+	// every message is delivered once
+	time.Sleep(10 * time.Millisecond)
+	for _, n := range all {
+		require.Empty(t, n.Received)
+	}
+}
+
+// TestRouter_Unreachable tests that messages to identifiers outside of the skip graph fail with ErrUnreachable.
+func TestRouter_Unreachable(t *testing.T) {
+	all := skipGraph(t)
+
+	// greater than every node, routed to the last node which has no right neighbor
+	target := unittest.IdentifierFixture(t, unittest.WithIdsGreaterThan(all[7].ID))
+	err := all[0].router.Send(target, *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, overlay.ErrUnreachable), "unexpected error %v", err)
+
+	// a node without neighbors cannot route at all
+	isolated := nodes(t, 1)
+	err = isolated[0].router.Send(all[0].ID, *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, overlay.ErrUnreachable), "unexpected error %v", err)
+}
+
+// TestRouter_HopLimit tests that messages exhausting their hop limit fail with ErrHopLimitExceeded, and that the hop to
+// the target does not count against the limit.
+func TestRouter_HopLimit(t *testing.T) {
+	all := skipGraph(t, overlay.WithHopLimit(1))
+
+	// 0 -> 4 -> 6 -> 7 takes two relays
+	err := all[0].router.Send(all[7].ID, *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, overlay.ErrHopLimitExceeded), "unexpected error %v", err)
+
+	// 0 -> 4 -> 5 takes one
+	msg := unittest.TestMessageFixture(t)
+	require.NoError(t, all[0].router.Send(all[5].ID, *msg))
+	requireReceived(t, all[5], all[0].ID, *msg)
+
+	// messages to nodes that are not neighbors are relayed, even if the network can reach them
+	all = nodes(t, 3, overlay.WithHopLimit(0))
+	link(t, 0, all...)
+	all[0].reachable[all[2].ID] = true
+	err = all[0].router.Send(all[2].ID, *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, overlay.ErrHopLimitExceeded), "unexpected error %v", err)
+	require.NoError(t, all[0].router.Send(all[1].ID, *msg))
+	requireReceived(t, all[1], all[0].ID, *msg)
+}
+
+// TestRouter_LoopDetection tests that messages routed back to a node they went through fail with ErrLoopDetected.
+func TestRouter_LoopDetection(t *testing.T) {
+	all := nodes(t, 2)
+	linkPair(t, 0, all[0], all[1])
+	// inconsistent lookup table of 1, pointing back to 0 on its right
+	back := model.NewIdentity(all[0].ID, unittest.MembershipVectorFixture(t), unittest.AddressFixture(t))
+	require.NoError(t, all[1].Table.AddEntry(types.DirectionRight, 1, back))
+
+	target := unittest.IdentifierFixture(t, unittest.WithIdsGreaterThan(all[1].ID))
+	err := all[0].router.Send(target, *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, overlay.ErrLoopDetected), "unexpected error %v", err)
+}
+
+// TestRouter_Busy tests that messages reaching a relay that sends its maximum number of envelopes already fail with
+// ErrBusy, rather than waiting for their acknowledgement.
+func TestRouter_Busy(t *testing.T) {
+	all := skipGraph(t, overlay.WithMaxConcurrentSends(1))
+	gate := make(chan struct{})
+	all[4].gates[all[6].ID] = gate
+
+	// 0 -> 4 -> 6 -> 7, held by 4 until the gate is closed
+	slow := unittest.TestMessageFixture(t)
+	done := make(chan error, 1)
+	go func() {
+		done <- all[0].router.Send(all[7].ID, *slow)
+	}()
+	select {
+	case <-all[4].sent:
+	case <-time.After(unittest.DefaultReadyDoneTimeout):
+		require.Fail(t, "message not relayed on time")
+	}
+
+	// 0 -> 4 -> 5 is not relayed by 4
+	err := all[0].router.Send(all[5].ID, *unittest.TestMessageFixture(t))
+	require.True(t, errors.Is(err, overlay.ErrBusy), "unexpected error %v", err)
+
+	close(gate)
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(unittest.DefaultReadyDoneTimeout):
+		require.Fail(t, "send not completed on time")
+	}
+	requireReceived(t, all[7], all[0].ID, *slow)
+}
+
+// TestRouter_AckTimeout tests that messages whose delivery is not acknowledged on time fail with ErrAckTimeout.
+func TestRouter_AckTimeout(t *testing.T) {
+	all := nodes(t, 2, overlay.WithAckTimeout(50*time.Millisecond))
+	// 0 reaches 1, but 1 cannot reach 0 back
+	linkPair(t, 0, all[0], all[1])
+	all[1].reachable[all[0].ID] = false
+
+	msg := unittest.TestMessageFixture(t)
+	err := all[0].router.Send(all[1].ID, *msg)
+	require.True(t, errors.Is(err, overlay.ErrAckTimeout), "unexpected error %v", err)
+	requireReceived(t, all[1], all[0].ID, *msg)
+}
+
+// TestRouter_ForgedAck tests that the delivery of a message is only acknowledged by its target.
+func TestRouter_ForgedAck(t *testing.T) {
+	registry := codec.NewDefaultRegistry()
+	require.NoError(t, overlay.RegisterCodec(registry))
+	ackTimeout := 50 * time.Millisecond
+	all := nodes(t, 2, overlay.WithAckTimeout(ackTimeout))
+	// 0 reaches 1, but 1 cannot reach 0 back, hence only forged acknowledgements reach 0
+	linkPair(t, 0, all[0], all[1])
+	all[1].reachable[all[0].ID] = false
+
+	// forge returns an acknowledgement by origin of the message last sent by 0
+	forge := func(origin model.Identifier) net.Message {
+		var data []byte
+		select {
+		case msg := <-all[0].sent:
+			var err error
+			data, err = registry.Encode(msg.Payload)
+			require.NoError(t, err)
+		case <-time.After(unittest.DefaultReadyDoneTimeout):
+			require.Fail(t, "message not sent on time")
+		}
+		b := binary.BigEndian.AppendUint16(nil, uint16(overlay.CodeEnvelope))
+		b = append(b, origin[:]...)
+		b = append(b, all[0].ID[:]...)
+		b = append(b, data[2+2*model.IdentifierSizeBytes:][:8]...) // the id of the message
+		b = append(b, 2, 1)                                        // an ack with one hop left
+		ack, err := registry.Decode(b)
+		require.NoError(t, err)
+		return net.Message{Payload: ack}
+	}
+
+	for _, tc := range []struct {
+		origin model.Identifier
+		err    error
+	}{
+		{origin: unittest.IdentifierFixture(t), err: overlay.ErrAckTimeout},
+		{origin: all[1].ID, err: nil},
+	} {
+		done := make(chan error, 1)
+		go func() {
+			done <- all[0].router.Send(all[1].ID, *unittest.TestMessageFixture(t))
+		}()
+		all[0].router.ProcessIncomingMessage(net.TestChannel, tc.origin, forge(tc.origin))
+		select {
+		case err := <-done:
+			if tc.err == nil {
+				require.NoError(t, err)
+			} else {
+				require.True(t, errors.Is(err, tc.err), "unexpected error %v", err)
+			}
+		case <-time.After(ackTimeout + unittest.DefaultReadyDoneTimeout):
+			require.Fail(t, "send not completed on time")
+		}
+	}
+}
+
+// TestRouter_UnsupportedPayload tests that messages whose payload cannot be encoded are rejected.
+func TestRouter_UnsupportedPayload(t *testing.T) {
+	all := skipGraph(t)
+	err := all[0].router.Send(all[1].ID, net.Message{Payload: 42})
+	require.True(t, errors.Is(err, codec.ErrUnknownType), "unexpected error %v", err)
+}
+
+// TestEnvelope_Malformed tests that malformed envelopes are rejected by the codec registry.
+func TestEnvelope_Malformed(t *testing.T) {
+	registry := codec.NewDefaultRegistry()
+	require.NoError(t, overlay.RegisterCodec(registry))
+
+	header := func(kind byte, rest ...byte) []byte {
+		b := binary.BigEndian.AppendUint16(nil, uint16(overlay.CodeEnvelope))
+		b = append(b, make([]byte, 2*model.IdentifierSizeBytes+8)...)
+		b = append(b, kind, 1)
+		return append(b, rest...)
+	}
+	malformed := [][]byte{
+		header(1)[:20], // too short
+		header(9),      // unknown kind
+		header(2, 0),   // ack with trailing bytes
+		header(3),      // nack without reason
+		header(3, 9),   // unknown reason
+	}
+	for _, b := range malformed {
+		_, err := registry.Decode(b)
+		require.True(t, errors.Is(err, overlay.ErrMalformedEnvelope), "unexpected error %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package net
+
+import (
+	"fmt"
+
+	"github.com/thep2p/skipgraph-go/core/model"
+)
+
+// PeerEventType is the type of a PeerEvent.
+type PeerEventType uint8
+
+const (
+	// PeerConnected is emitted when a first connection with a peer is established, once its handshake is over.
+	PeerConnected PeerEventType = 1
+	// PeerDisconnected is emitted when the last connection with a peer is closed, by either side.
+	PeerDisconnected PeerEventType = 2
+	// PeerHandshakeFailed is emitted when a connection fails its handshake. Its peer is the one dialed, or zero for an
+	// inbound connection, whose peer is not known before the handshake.
+	PeerHandshakeFailed PeerEventType = 3
+)
+
+// String returns the name of the event type.
+func (t PeerEventType) String() string {
+	switch t {
+	case PeerConnected:
+		return "connected"
+	case PeerDisconnected:
+		return "disconnected"
+	case PeerHandshakeFailed:
+		return "handshake-failed"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(t))
+	}
+}
+
+// PeerEvent is a change of the connection of the node with a peer.
+type PeerEvent struct {
+	Type PeerEventType
+	Peer model.Identifier
+	Err  error // the cause of a handshake failure; nil for other events
+}
+
+// PeerEventConsumer is the interface of an Engine that reacts to the connection events of peers, e.g., for failure
+// detection, metrics or repair of the lookup table.
+type PeerEventConsumer interface {
+	// OnPeerEvent is called by the network layer for every peer event, in the order the events happened, and never
+	// concurrently for the same consumer. It is not called from the goroutine of the connection, yet it must return
+	// promptly, as the events a consumer falls behind on are dropped.
+	// Panics must be avoided, as for MessageProcessor.
+	OnPeerEvent(event PeerEvent)
+}
+
+// PeerEventConsumerFunc is a function implementing PeerEventConsumer.
+type PeerEventConsumerFunc func(event PeerEvent)
+
+// OnPeerEvent calls f.
+func (f PeerEventConsumerFunc) OnPeerEvent(event PeerEvent) {
+	f(event)
+}
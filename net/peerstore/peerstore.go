@@ -0,0 +1,450 @@
+// Package peerstore implements an address book mapping the identifiers of peers to the addresses they can be reached
+// at.
+//
+// A Store learns identities from the lookup table of the node, and from the identities carried by the protocol
+// messages it receives, e.g., the origin of searches and the requesters of join traffic. Each address is remembered
+// for a time-to-live, after which it is no longer resolved unless it is learned again. The store implements
+// network.Resolver, hence it is consulted by the network when dialing peers.
+//
+// The identities carried by messages are not authenticated, as any peer may claim any address for any identifier.
+// Hence, the addresses learned from messages never take precedence over, nor displace, the addresses added explicitly
+// or learned from the lookup table, and the store remembers a bounded number of peers.
+package peerstore
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/thep2p/skipgraph-go/core"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/network"
+	"github.com/thep2p/skipgraph-go/net/protocol"
+)
+
+// DefaultTTL is the default time-to-live of learned addresses.
+const DefaultTTL = 30 * time.Minute
+
+// PermanentTTL is the time-to-live of addresses that never expire, e.g., those of bootstrap nodes.
+const PermanentTTL time.Duration = 1<<63 - 1
+
+// DefaultMaxAddresses is the default maximum number of addresses remembered per peer.
+const DefaultMaxAddresses = 8
+
+// DefaultMaxPeers is the default maximum number of peers remembered from messages.
+const DefaultMaxPeers = 10_000
+
+// pruneInterval is the minimum interval between the prunings of a full store to make room for new peers.
+const pruneInterval = time.Minute
+
+// entry is an address of a peer along with its expiry; a zero expiry never expires.
+type entry struct {
+	addr    model.Address
+	expires time.Time
+	learned bool // learned from a message, hence unauthenticated
+}
+
+// record is what the store knows about a peer.
+type record struct {
+	memVector model.MembershipVector
+	entries   []entry // in order of preference, the most recently learned first
+}
+
+// Store is an address book of peers.
+// It is safe for concurrent use.
+type Store struct {
+	ttl          time.Duration
+	maxAddresses int
+	maxPeers     int
+	now          func() time.Time
+
+	l         sync.RWMutex
+	peers     map[model.Identifier]*record
+	nextPrune time.Time // the earliest time a full store is pruned again
+}
+
+var _ network.Resolver = (*Store)(nil)
+
+// Option is a functional option for configuring a Store.
+type Option func(*Store)
+
+// WithTTL sets the time-to-live of the addresses learned from lookup tables and messages; defaults to DefaultTTL.
+func WithTTL(ttl time.Duration) Option {
+	return func(s *Store) {
+		s.ttl = ttl
+	}
+}
+
+// WithMaxAddresses sets the maximum number of addresses remembered per peer; defaults to DefaultMaxAddresses.
+// When a peer has more addresses, the least recently learned ones are forgotten.
+func WithMaxAddresses(max int) Option {
+	return func(s *Store) {
+		if max > 0 {
+			s.maxAddresses = max
+		}
+	}
+}
+
+// WithMaxPeers sets the maximum number of peers remembered; defaults to DefaultMaxPeers.
+// Once the store holds that many peers, it forgets the expired addresses, at most once per minute, and ignores the
+// identities of new peers carried by messages until there is room again. Peers added explicitly or learned from the
+// lookup table are always remembered, even if they exceed the maximum.
+func WithMaxPeers(max int) Option {
+	return func(s *Store) {
+		if max > 0 {
+			s.maxPeers = max
+		}
+	}
+}
+
+// WithClock sets the source of the current time of the store; defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(s *Store) {
+		s.now = now
+	}
+}
+
+// New creates an empty Store.
+func New(opts ...Option) *Store {
+	s := &Store{
+		ttl:          DefaultTTL,
+		maxAddresses: DefaultMaxAddresses,
+		maxPeers:     DefaultMaxPeers,
+		now:          time.Now,
+		peers:        make(map[model.Identifier]*record),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// AddIdentity remembers the non-zero addresses of the identity for ttl, along with its membership vector.
+// The addresses take precedence over those previously known for the peer, in the order of the identity.
+// Identities with a zero identifier are ignored.
+func (s *Store) AddIdentity(identity model.Identity, ttl time.Duration) {
+	id := identity.GetIdentifier()
+	if id.IsZero() {
+		return
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.add(id, ttl, false, identity.GetAddresses()...) {
+		if mv := identity.GetMembershipVector(); !mv.IsZero() {
+			s.peers[id].memVector = mv
+		}
+	}
+}
+
+// learn remembers the non-zero addresses of an identity carried by a message for the time-to-live of the store, after
+// the addresses of the peer that are not learned from messages. Its membership vector is only remembered if none is
+// known for the peer. Identities with a zero identifier are ignored.
+func (s *Store) learn(identity model.Identity) {
+	id := identity.GetIdentifier()
+	if id.IsZero() {
+		return
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.add(id, s.ttl, true, identity.GetAddresses()...) {
+		if r := s.peers[id]; r.memVector.IsZero() {
+			r.memVector = identity.GetMembershipVector()
+		}
+	}
+}
+
+// AddAddresses remembers the non-zero addresses for the peer for ttl.
+// The addresses take precedence over those previously known for the peer, in the given order.
+func (s *Store) AddAddresses(id model.Identifier, ttl time.Duration, addrs ...model.Address) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.add(id, ttl, false, addrs...)
+}
+
+// add remembers the addresses for the peer; the caller must hold the write lock.
+// Addresses learned from messages come after the other addresses of the peer, hence are forgotten first, and do not
+// alter the other addresses, e.g., their time-to-live.
+// Returns false if the peer is new and the store is full of peers, in which case learned addresses are ignored.
+func (s *Store) add(id model.Identifier, ttl time.Duration, learned bool, addrs ...model.Address) bool {
+	now := s.now()
+	expires := time.Time{}
+	if ttl != PermanentTTL {
+		expires = now.Add(ttl)
+	}
+
+	r, ok := s.peers[id]
+	if !ok {
+		if learned && !s.makeRoom(now) {
+			return false
+		}
+		r = &record{}
+		s.peers[id] = r
+	}
+
+	entries := make([]entry, 0, len(addrs)+len(r.entries))
+	for _, addr := range addrs {
+		if addr.IsZero() || indexOf(entries, addr) >= 0 {
+			continue
+		}
+		e := entry{addr: addr, expires: expires, learned: learned}
+		if i := indexOf(r.entries, addr); i >= 0 && !expired(r.entries[i], now) {
+			if learned && !r.entries[i].learned {
+				continue
+			}
+			// an address never outlives the longest time-to-live it was learned with
+			if outlives(r.entries[i].expires, expires) {
+				e.expires = r.entries[i].expires
+			}
+		}
+		entries = append(entries, e)
+	}
+	for _, e := range r.entries {
+		if indexOf(entries, e.addr) < 0 && !expired(e, now) {
+			entries = append(entries, e)
+		}
+	}
+	// the addresses not learned from messages come first, in their order
+	slices.SortStableFunc(
+		entries, func(a, b entry) int {
+			return cmp.Compare(boolToInt(a.learned), boolToInt(b.learned))
+		},
+	)
+	if len(entries) > s.maxAddresses {
+		entries = entries[:s.maxAddresses]
+	}
+	r.entries = entries
+	return true
+}
+
+// makeRoom returns true if the store has room for a new peer, pruning it first if it is full and its last pruning is
+// older than pruneInterval; the caller must hold the write lock.
+func (s *Store) makeRoom(now time.Time) bool {
+	if len(s.peers) < s.maxPeers {
+		return true
+	}
+	if now.Before(s.nextPrune) {
+		return false
+	}
+	s.nextPrune = now.Add(pruneInterval)
+	s.prune(now)
+	return len(s.peers) < s.maxPeers
+}
+
+// RemoveAddress forgets the address of the peer, e.g., after it turned out to be unreachable.
+func (s *Store) RemoveAddress(id model.Identifier, addr model.Address) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	r, ok := s.peers[id]
+	if !ok {
+		return
+	}
+	if i := indexOf(r.entries, addr); i >= 0 {
+		r.entries = append(r.entries[:i:i], r.entries[i+1:]...)
+	}
+}
+
+// Remove forgets the peer.
+func (s *Store) Remove(id model.Identifier) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	delete(s.peers, id)
+}
+
+// Resolve returns the unexpired addresses of the peer, in order of preference.
+// Returns an error wrapping network.ErrUnknownPeer if no unexpired address is known for the peer.
+func (s *Store) Resolve(id model.Identifier) ([]model.Address, error) {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	addrs := s.addresses(id)
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("%w: %s", network.ErrUnknownPeer, id.String())
+	}
+	return addrs, nil
+}
+
+// Identity returns the identity of the peer with its unexpired addresses and its membership vector, if known.
+// Returns false if no unexpired address is known for the peer.
+func (s *Store) Identity(id model.Identifier) (model.Identity, bool) {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	addrs := s.addresses(id)
+	if len(addrs) == 0 {
+		return model.Identity{}, false
+	}
+	return model.NewIdentity(id, s.peers[id].memVector, addrs[0], addrs[1:]...), true
+}
+
+// addresses returns the unexpired addresses of the peer; the caller must hold the lock.
+func (s *Store) addresses(id model.Identifier) []model.Address {
+	r, ok := s.peers[id]
+	if !ok {
+		return nil
+	}
+	now := s.now()
+	addrs := make([]model.Address, 0, len(r.entries))
+	for _, e := range r.entries {
+		if !expired(e, now) {
+			addrs = append(addrs, e.addr)
+		}
+	}
+	return addrs
+}
+
+// Peers returns the identifiers of the peers with at least one unexpired address, in no particular order.
+func (s *Store) Peers() []model.Identifier {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	ids := make([]model.Identifier, 0, len(s.peers))
+	for id := range s.peers {
+		if len(s.addresses(id)) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Prune forgets the expired addresses, and the peers left without any.
+// Returns the number of peers forgotten.
+func (s *Store) Prune() int {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.prune(s.now())
+}
+
+// prune forgets the expired addresses at now, and the peers left without any; the caller must hold the write lock.
+// Returns the number of peers forgotten.
+func (s *Store) prune(now time.Time) int {
+	pruned := 0
+	for id, r := range s.peers {
+		entries := r.entries[:0]
+		for _, e := range r.entries {
+			if !expired(e, now) {
+				entries = append(entries, e)
+			}
+		}
+		r.entries = entries
+		if len(entries) == 0 {
+			delete(s.peers, id)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// LearnLookupTable remembers the identities of the neighbors in the lookup table, with the time-to-live of the store.
+// Returns the error of reading the lookup table.
+func (s *Store) LearnLookupTable(table core.ImmutableLookupTable) error {
+	for level := types.Level(0); level < core.MaxLookupTableLevel; level++ {
+		for _, dir := range []types.Direction{types.DirectionLeft, types.DirectionRight} {
+			neighbor, err := table.GetEntry(dir, level)
+			if err != nil {
+				return fmt.Errorf("could not read %s neighbor at level %d: %w", dir, level, err)
+			}
+			if neighbor != nil {
+				s.AddIdentity(*neighbor, s.ttl)
+			}
+		}
+	}
+	return nil
+}
+
+// LearnPayload remembers the identities carried by the payload of a message, with the time-to-live of the store.
+// As the identities are not authenticated, their addresses come after those added explicitly or learned from the
+// lookup table, and the identities of new peers are ignored while the store is full (see WithMaxPeers).
+// Payloads other than protocol messages carrying identities are ignored.
+// Returns the number of identities carried by the payload.
+func (s *Store) LearnPayload(payload any) int {
+	identities := Identities(payload)
+	for _, identity := range identities {
+		s.learn(identity)
+	}
+	return len(identities)
+}
+
+// Processor returns a processor that learns the identities carried by the messages it receives before passing them
+// on to next.
+func (s *Store) Processor(next net.MessageProcessor) net.MessageProcessor {
+	return &learningProcessor{store: s, next: next}
+}
+
+// learningProcessor learns the identities carried by messages before passing them on.
+type learningProcessor struct {
+	store *Store
+	next  net.MessageProcessor
+}
+
+// ProcessIncomingMessage learns the identities carried by the message and passes it on.
+func (p *learningProcessor) ProcessIncomingMessage(channel net.Channel, originID model.Identifier, msg net.Message) {
+	p.store.LearnPayload(msg.Payload)
+	p.next.ProcessIncomingMessage(channel, originID, msg)
+}
+
+// Identities returns the identities carried by a protocol message, e.g., the origin of a search or the requester of a
+// link. Returns nil for other payloads.
+func Identities(payload any) []model.Identity {
+	switch m := payload.(type) {
+	case protocol.SearchRequest:
+		return []model.Identity{m.Origin()}
+	case protocol.SearchReply:
+		return []model.Identity{m.Result()}
+	case protocol.LinkRequest:
+		return []model.Identity{m.Requester()}
+	case protocol.LinkAck:
+		return append([]model.Identity{m.Responder()}, optional(m.Previous())...)
+	case protocol.Unlink:
+		return append([]model.Identity{m.Sender()}, optional(m.Replacement())...)
+	case protocol.NeighborReply:
+		return optional(m.Neighbor())
+	default:
+		return nil
+	}
+}
+
+// optional returns the identity as a slice, empty if it is nil.
+func optional(identity *model.Identity) []model.Identity {
+	if identity == nil {
+		return nil
+	}
+	return []model.Identity{*identity}
+}
+
+// indexOf returns the index of the entry of the address, or -1 if there is none.
+func indexOf(entries []entry, addr model.Address) int {
+	for i, e := range entries {
+		if e.addr == addr {
+			return i
+		}
+	}
+	return -1
+}
+
+// expired returns true if the entry is expired at now.
+func expired(e entry, now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// boolToInt returns 1 if b is true, 0 otherwise.
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// outlives returns true if expiry a is later than expiry b, a zero expiry being the latest.
+func outlives(a, b time.Time) bool {
+	if b.IsZero() {
+		return false
+	}
+	return a.IsZero() || a.After(b)
+}
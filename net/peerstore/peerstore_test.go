@@ -0,0 +1,287 @@
+package peerstore_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/network"
+	"github.com/thep2p/skipgraph-go/net/peerstore"
+	"github.com/thep2p/skipgraph-go/net/protocol"
+	"github.com/thep2p/skipgraph-go/unittest"
+	"github.com/thep2p/skipgraph-go/unittest/mocknet"
+)
+
+// clock is a manually advanced time source.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// TestStore_TTL tests that addresses are only resolved until their time-to-live expires, unless learned again.
+func TestStore_TTL(t *testing.T) {
+	c := &clock{now: time.Unix(1000, 0)}
+	s := peerstore.New(peerstore.WithClock(c.Now))
+
+	identity := unittest.IdentityFixture(t)
+	id := identity.GetIdentifier()
+	bootstrap := unittest.IdentifierFixture(t)
+	bootstrapAddr := unittest.AddressFixture(t)
+	s.AddIdentity(identity, time.Minute)
+	s.AddAddresses(bootstrap, peerstore.PermanentTTL, bootstrapAddr)
+
+	addrs, err := s.Resolve(id)
+	require.NoError(t, err)
+	require.Equal(t, identity.GetAddresses(), addrs)
+	require.ElementsMatch(t, []model.Identifier{id, bootstrap}, s.Peers())
+
+	// refreshed with a shorter time-to-live, the address keeps its longest one
+	c.Advance(30 * time.Second)
+	s.AddIdentity(identity, time.Second)
+	c.Advance(20 * time.Second)
+	_, err = s.Resolve(id)
+	require.NoError(t, err)
+
+	c.Advance(10 * time.Second)
+	_, err = s.Resolve(id)
+	require.True(t, errors.Is(err, network.ErrUnknownPeer))
+	_, ok := s.Identity(id)
+	require.False(t, ok)
+	require.Equal(t, []model.Identifier{bootstrap}, s.Peers())
+
+	// learned again
+	s.AddIdentity(identity, time.Minute)
+	_, err = s.Resolve(id)
+	require.NoError(t, err)
+
+	// permanent addresses survive pruning
+	c.Advance(time.Hour)
+	require.Equal(t, 1, s.Prune())
+	addrs, err = s.Resolve(bootstrap)
+	require.NoError(t, err)
+	require.Equal(t, []model.Address{bootstrapAddr}, addrs)
+}
+
+// TestStore_MultipleAddresses tests that the most recently learned addresses of a peer take precedence, up to the
+// maximum number of addresses per peer.
+func TestStore_MultipleAddresses(t *testing.T) {
+	s := peerstore.New(peerstore.WithMaxAddresses(3))
+	id := unittest.IdentifierFixture(t)
+	a1, a2, a3, a4 := unittest.AddressFixture(t), unittest.AddressFixture(t), unittest.AddressFixture(t), unittest.AddressFixture(t)
+
+	s.AddAddresses(id, time.Minute, a1, model.Address{}, a2)
+	addrs, err := s.Resolve(id)
+	require.NoError(t, err)
+	require.Equal(t, []model.Address{a1, a2}, addrs)
+
+	s.AddAddresses(id, time.Minute, a3, a2)
+	addrs, err = s.Resolve(id)
+	require.NoError(t, err)
+	require.Equal(t, []model.Address{a3, a2, a1}, addrs)
+
+	// the least recently learned address is forgotten
+	s.AddAddresses(id, time.Minute, a4)
+	addrs, err = s.Resolve(id)
+	require.NoError(t, err)
+	require.Equal(t, []model.Address{a4, a3, a2}, addrs)
+
+	s.RemoveAddress(id, a3)
+	addrs, err = s.Resolve(id)
+	require.NoError(t, err)
+	require.Equal(t, []model.Address{a4, a2}, addrs)
+
+	s.Remove(id)
+	_, err = s.Resolve(id)
+	require.True(t, errors.Is(err, network.ErrUnknownPeer))
+}
+
+// TestStore_Identity tests that the identity of a peer is rebuilt from its addresses and membership vector.
+func TestStore_Identity(t *testing.T) {
+	s := peerstore.New()
+	identity := model.NewIdentity(
+		unittest.IdentifierFixture(t),
+		unittest.MembershipVectorFixture(t),
+		unittest.AddressFixture(t),
+		unittest.AddressFixture(t),
+	)
+	s.AddIdentity(identity, time.Minute)
+
+	// addresses learned without a membership vector keep the known one
+	s.AddAddresses(identity.GetIdentifier(), time.Minute, identity.GetAddress())
+
+	res, ok := s.Identity(identity.GetIdentifier())
+	require.True(t, ok)
+	require.Equal(t, identity, res)
+
+	// zero identifiers are ignored
+	s.AddIdentity(model.Identity{}, time.Minute)
+	require.Len(t, s.Peers(), 1)
+}
+
+// TestStore_LearnLookupTable tests that the neighbors of a lookup table are learned.
+func TestStore_LearnLookupTable(t *testing.T) {
+	table := unittest.RandomLookupTable(t)
+	s := peerstore.New()
+	require.NoError(t, s.LearnLookupTable(table))
+
+	count := 0
+	for level := types.Level(0); level < core.MaxLookupTableLevel; level++ {
+		for _, dir := range []types.Direction{types.DirectionLeft, types.DirectionRight} {
+			neighbor, err := table.GetEntry(dir, level)
+			require.NoError(t, err)
+			if neighbor == nil {
+				continue
+			}
+			count++
+			res, ok := s.Identity(neighbor.GetIdentifier())
+			require.True(t, ok)
+			require.Equal(t, neighbor.GetAddresses(), res.GetAddresses())
+		}
+	}
+	require.Positive(t, count)
+}
+
+// TestStore_Processor tests that the identities carried by received protocol messages are learned before the messages
+// are passed on.
+func TestStore_Processor(t *testing.T) {
+	s := peerstore.New()
+	stub := mocknet.NewNetworkStub()
+	id1, id2 := unittest.IdentifierFixture(t), unittest.IdentifierFixture(t)
+
+	received := make(chan net.Message, 10)
+	next := mocknet.NewMockMessageProcessor(
+		func(_ net.Channel, _ model.Identifier, msg net.Message) {
+			received <- msg
+		},
+	)
+	_, err := stub.NewMockNetwork(t, id1).Register(net.TestChannel, s.Processor(next))
+	require.NoError(t, err)
+	c, err := stub.NewMockNetwork(t, id2).Register(net.TestChannel, next)
+	require.NoError(t, err)
+
+	requester := unittest.IdentityFixture(t)
+	join, err := protocol.NewLinkRequest(requester, 0, types.DirectionRight)
+	require.NoError(t, err)
+	origin := unittest.IdentityFixture(t)
+	search, err := protocol.NewSearchRequest(1, origin, unittest.BytesKeyFixture(t, 8), 0, types.DirectionLeft)
+	require.NoError(t, err)
+
+	for _, payload := range []any{join, search, protocol.NewPing(1)} {
+		require.NoError(t, c.Send(id1, net.Message{Payload: payload}))
+		require.Equal(t, payload, (<-received).Payload)
+	}
+	for _, identity := range []model.Identity{requester, origin} {
+		addrs, err := s.Resolve(identity.GetIdentifier())
+		require.NoError(t, err)
+		require.Equal(t, identity.GetAddresses(), addrs)
+	}
+	require.Len(t, s.Peers(), 2)
+}
+
+// unlink returns an unlink message sent by the identity.
+func unlink(t *testing.T, sender model.Identity) protocol.Unlink {
+	msg, err := protocol.NewUnlink(sender, 0, types.DirectionLeft, nil)
+	require.NoError(t, err)
+	return msg
+}
+
+// TestStore_ForgedIdentities tests that the addresses carried by messages do not displace the addresses of a neighbor
+// learned from the lookup table, nor those of permanent peers, and that the peers learned from messages are bounded.
+func TestStore_ForgedIdentities(t *testing.T) {
+	c := &clock{now: time.Unix(1000, 0)}
+	s := peerstore.New(peerstore.WithMaxAddresses(2), peerstore.WithMaxPeers(3), peerstore.WithTTL(time.Minute), peerstore.WithClock(c.Now))
+	neighbor := unittest.IdentityFixture(t)
+	bootstrap := unittest.IdentifierFixture(t)
+	bootstrapAddr := unittest.AddressFixture(t)
+	s.AddIdentity(neighbor, time.Minute)
+	s.AddAddresses(bootstrap, peerstore.PermanentTTL, bootstrapAddr)
+
+	// a peer claims two addresses of its own for each of them
+	for _, id := range []model.Identifier{neighbor.GetIdentifier(), bootstrap} {
+		forged := model.NewIdentity(id, unittest.MembershipVectorFixture(t), unittest.AddressFixture(t), unittest.AddressFixture(t))
+		req, err := protocol.NewLinkRequest(forged, 0, types.DirectionLeft)
+		require.NoError(t, err)
+		require.Equal(t, 1, s.LearnPayload(req))
+	}
+	res, ok := s.Identity(neighbor.GetIdentifier())
+	require.True(t, ok)
+	require.Equal(t, neighbor.GetMembershipVector(), res.GetMembershipVector())
+	require.Equal(t, neighbor.GetAddresses(), res.GetAddresses()[:len(neighbor.GetAddresses())])
+	addrs, err := s.Resolve(bootstrap)
+	require.NoError(t, err)
+	require.Equal(t, bootstrapAddr, addrs[0])
+
+	// only one more peer is learned from messages until the store is pruned
+	learned := make([]model.Identity, 3)
+	for i := range learned {
+		learned[i] = unittest.IdentityFixture(t)
+		s.LearnPayload(unlink(t, learned[i]))
+	}
+	require.Len(t, s.Peers(), 3)
+	_, ok = s.Identity(learned[1].GetIdentifier())
+	require.False(t, ok)
+
+	// once their addresses expire, peers make room for new ones, while peers added explicitly are always remembered
+	c.Advance(2 * time.Minute)
+	s.LearnPayload(unlink(t, learned[2]))
+	require.ElementsMatch(t, []model.Identifier{bootstrap, learned[2].GetIdentifier()}, s.Peers())
+	s.AddIdentity(learned[0], time.Minute)
+	s.AddIdentity(learned[1], time.Minute)
+	require.Len(t, s.Peers(), 4)
+}
+
+// TestStore_NetworkResolver tests that a network dials the peers whose addresses are learned by its store.
+func TestStore_NetworkResolver(t *testing.T) {
+	ctx := unittest.NewMockThrowableContext(t)
+	s := peerstore.New()
+
+	id1, id2 := unittest.IdentifierFixture(t), unittest.IdentifierFixture(t)
+	n1, err := network.NewNetwork(unittest.Logger(zerolog.WarnLevel), id1, model.NewAddress("127.0.0.1", "0"), s)
+	require.NoError(t, err)
+	n2, err := network.NewNetwork(unittest.Logger(zerolog.WarnLevel), id2, model.NewAddress("127.0.0.1", "0"), s)
+	require.NoError(t, err)
+	n1.Start(ctx)
+	n2.Start(ctx)
+	unittest.RequireAllReady(t, n1, n2)
+	defer func() {
+		ctx.Cancel()
+		unittest.RequireAllDone(t, n1, n2)
+	}()
+
+	received := make(chan net.Message, 1)
+	c1, err := n1.Register(net.TestChannel, mocknet.NewMockMessageProcessor(func(net.Channel, model.Identifier, net.Message) {}))
+	require.NoError(t, err)
+	_, err = n2.Register(
+		net.TestChannel, mocknet.NewMockMessageProcessor(
+			func(_ net.Channel, _ model.Identifier, msg net.Message) {
+				received <- msg
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	msg := unittest.TestMessageFixture(t)
+	require.True(t, errors.Is(c1.Send(id2, *msg), network.ErrUnknownPeer))
+
+	s.AddIdentity(model.NewIdentity(id2, unittest.MembershipVectorFixture(t), n2.Address()), time.Minute)
+	require.NoError(t, c1.Send(id2, *msg))
+	select {
+	case r := <-received:
+		require.Equal(t, msg.Payload, r.Payload)
+	case <-time.After(unittest.DefaultReadyDoneTimeout):
+		require.Fail(t, "message not received on time")
+	}
+}
@@ -0,0 +1,35 @@
+package net
+
+import "fmt"
+
+// Priority is the priority class of the messages of a channel.
+// The network serves the messages of higher classes first, both when sending them over a connection and when
+// dispatching them to their processor, so that bulk transfers do not delay control messages, e.g., of failure
+// detection.
+type Priority uint8
+
+const (
+	// PriorityLow is the class of bulk data, e.g., the transfer of key ranges.
+	PriorityLow Priority = 1
+	// PriorityNormal is the class of the channels that declare none.
+	PriorityNormal Priority = 2
+	// PriorityHigh is the class of control messages, e.g., pings and the links and unlinks of repairs.
+	PriorityHigh Priority = 3
+)
+
+// Priorities are the priority classes, from the highest to the lowest.
+var Priorities = []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// String returns the name of the priority class.
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(p))
+	}
+}
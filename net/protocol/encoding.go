@@ -0,0 +1,307 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+)
+
+// Wire representation of types.Direction.
+const (
+	directionLeft  uint8 = 0
+	directionRight uint8 = 1
+)
+
+// Wire representation of the kind of a model.Key.
+const (
+	keyKindIdentifier uint8 = 0 // a 32-byte identifier
+	keyKindBytes      uint8 = 1 // a length-prefixed variable-length key
+)
+
+// encoder appends big-endian encoded values to a byte slice.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) bytes() []byte {
+	return e.buf
+}
+
+func (e *encoder) putUint8(v uint8) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *encoder) putUint16(v uint16) {
+	e.buf = binary.BigEndian.AppendUint16(e.buf, v)
+}
+
+func (e *encoder) putUint64(v uint64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, v)
+}
+
+func (e *encoder) putBool(v bool) {
+	if v {
+		e.putUint8(1)
+	} else {
+		e.putUint8(0)
+	}
+}
+
+func (e *encoder) putFixed(b []byte) {
+	e.buf = append(e.buf, b...)
+}
+
+// putBytes appends b prefixed by its 2-byte length; b is truncated to math.MaxUint16 bytes, hence callers must
+// validate the length of variable-length fields beforehand.
+func (e *encoder) putBytes(b []byte) {
+	if len(b) > math.MaxUint16 {
+		b = b[:math.MaxUint16]
+	}
+	e.putUint16(uint16(len(b)))
+	e.putFixed(b)
+}
+
+func (e *encoder) putString(s string) {
+	e.putBytes([]byte(s))
+}
+
+func (e *encoder) putLevel(l types.Level) {
+	e.putUint16(uint16(l))
+}
+
+func (e *encoder) putDirection(dir types.Direction) {
+	if dir == types.DirectionRight {
+		e.putUint8(directionRight)
+	} else {
+		e.putUint8(directionLeft)
+	}
+}
+
+func (e *encoder) putIdentifier(id model.Identifier) {
+	e.putFixed(id[:])
+}
+
+// putKey appends the kind of the key followed by its content.
+// Keys other than identifiers are encoded by their byte representation, which preserves their order.
+func (e *encoder) putKey(key model.Key) {
+	if id, ok := key.(model.Identifier); ok {
+		e.putUint8(keyKindIdentifier)
+		e.putIdentifier(id)
+		return
+	}
+	e.putUint8(keyKindBytes)
+	e.putBytes(key.KeyBytes())
+}
+
+// putIdentity appends the identifier, membership vector, optional explicit key and addresses of the identity.
+func (e *encoder) putIdentity(identity model.Identity) {
+	id := identity.GetIdentifier()
+	mv := identity.GetMembershipVector()
+	e.putIdentifier(id)
+	e.putFixed(mv[:])
+
+	key := identity.GetKey()
+	if keyID, ok := key.(model.Identifier); ok && keyID == id {
+		e.putBool(false)
+	} else {
+		e.putBool(true)
+		e.putKey(key)
+	}
+
+	addrs := identity.GetAddresses()
+	if len(addrs) > math.MaxUint8 {
+		addrs = addrs[:math.MaxUint8]
+	}
+	e.putUint8(uint8(len(addrs)))
+	for _, addr := range addrs {
+		if addr.IsZero() {
+			e.putString("")
+			continue
+		}
+		e.putString(addr.String())
+	}
+}
+
+// putOptionalIdentity appends a presence flag followed by the identity if it is not nil.
+func (e *encoder) putOptionalIdentity(identity *model.Identity) {
+	e.putBool(identity != nil)
+	if identity != nil {
+		e.putIdentity(*identity)
+	}
+}
+
+// decoder reads big-endian encoded values from a byte slice.
+// The first error encountered is sticky: once an error occurs, all subsequent reads return zero values and
+// err returns the error.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func newDecoder(b []byte) *decoder {
+	return &decoder{b: b}
+}
+
+// next consumes and returns the next n bytes, or nil if fewer than n bytes are left.
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.b) < n {
+		d.err = fmt.Errorf("%w: expected %d more bytes, found %d", ErrMalformedMessage, n, len(d.b))
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+// fail records a decoding error unless an error is already recorded.
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// finish returns the first decoding error, or an error if there are unread trailing bytes.
+func (d *decoder) finish() error {
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.b) != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrMalformedMessage, len(d.b))
+	}
+	return nil
+}
+
+func (d *decoder) uint8() uint8 {
+	b := d.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *decoder) uint16() uint16 {
+	b := d.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (d *decoder) uint64() uint64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (d *decoder) bool() bool {
+	v := d.uint8()
+	if v > 1 {
+		d.fail(fmt.Errorf("%w: invalid boolean %d", ErrMalformedMessage, v))
+	}
+	return v == 1
+}
+
+// bytes returns a copy of the next length-prefixed byte slice.
+func (d *decoder) bytes() []byte {
+	n := d.uint16()
+	b := d.next(int(n))
+	if b == nil {
+		return nil
+	}
+	res := make([]byte, len(b))
+	copy(res, b)
+	return res
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) level() types.Level {
+	return types.Level(d.uint16())
+}
+
+func (d *decoder) direction() types.Direction {
+	switch v := d.uint8(); v {
+	case directionLeft:
+		return types.DirectionLeft
+	case directionRight:
+		return types.DirectionRight
+	default:
+		d.fail(fmt.Errorf("%w: invalid direction %d", ErrMalformedMessage, v))
+		return ""
+	}
+}
+
+func (d *decoder) identifier() model.Identifier {
+	var id model.Identifier
+	copy(id[:], d.next(model.IdentifierSizeBytes))
+	return id
+}
+
+func (d *decoder) key() model.Key {
+	switch kind := d.uint8(); kind {
+	case keyKindIdentifier:
+		return d.identifier()
+	case keyKindBytes:
+		key, err := model.NewBytesKey(d.bytes())
+		if err != nil {
+			d.fail(fmt.Errorf("%w: %w", ErrInvalidMessage, err))
+			return nil
+		}
+		return key
+	default:
+		d.fail(fmt.Errorf("%w: invalid key kind %d", ErrMalformedMessage, kind))
+		return nil
+	}
+}
+
+func (d *decoder) identity() model.Identity {
+	id := d.identifier()
+	var mv model.MembershipVector
+	copy(mv[:], d.next(model.MembershipVectorSize))
+
+	var key model.Key
+	if d.bool() {
+		key = d.key()
+	}
+
+	count := int(d.uint8())
+	addrs := make([]model.Address, 0, count)
+	for i := 0; i < count && d.err == nil; i++ {
+		s := d.string()
+		if s == "" {
+			addrs = append(addrs, model.Address{})
+			continue
+		}
+		addr, err := model.ParseAddress(s)
+		if err != nil {
+			d.fail(fmt.Errorf("%w: %w", ErrInvalidMessage, err))
+			break
+		}
+		addrs = append(addrs, addr)
+	}
+
+	identity := model.Identity{}
+	identity.SetId(id)
+	identity.SetMemVector(mv)
+	identity.SetKey(key)
+	identity.SetAddresses(addrs...)
+	return identity
+}
+
+func (d *decoder) optionalIdentity() *model.Identity {
+	if !d.bool() {
+		return nil
+	}
+	identity := d.identity()
+	return &identity
+}
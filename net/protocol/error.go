@@ -0,0 +1,105 @@
+package protocol
+
+import "fmt"
+
+// MaxErrorReasonSize is the upper bound for the size of the human-readable reason of an Error message.
+const MaxErrorReasonSize = 1024
+
+// ErrorCode classifies the failure reported by an Error message.
+// Error codes are part of the stable encoding and must never be reused for a different failure.
+type ErrorCode uint16
+
+const (
+	// ErrorCodeInternal indicates that the recipient failed to process a message for reasons unrelated to the message.
+	ErrorCodeInternal ErrorCode = 1
+	// ErrorCodeInvalidMessage indicates that the recipient could not decode or validate a message.
+	ErrorCodeInvalidMessage ErrorCode = 2
+	// ErrorCodeUnsupportedVersion indicates that the recipient does not speak the protocol version of a message.
+	ErrorCodeUnsupportedVersion ErrorCode = 3
+	// ErrorCodeChannelUnknown indicates that the recipient has no processor registered for the channel of a message.
+	ErrorCodeChannelUnknown ErrorCode = 4
+	// ErrorCodeNotFound indicates that the recipient has no data matching a query.
+	ErrorCodeNotFound ErrorCode = 5
+)
+
+// String returns the human-readable name of the error code.
+func (c ErrorCode) String() string {
+	switch c {
+	case ErrorCodeInternal:
+		return "internal"
+	case ErrorCodeInvalidMessage:
+		return "invalid-message"
+	case ErrorCodeUnsupportedVersion:
+		return "unsupported-version"
+	case ErrorCodeChannelUnknown:
+		return "channel-unknown"
+	case ErrorCodeNotFound:
+		return "not-found"
+	default:
+		return fmt.Sprintf("unknown-%d", uint16(c))
+	}
+}
+
+// Error reports to the sender of a message that the message could not be processed.
+//
+// Body layout: request id (8) | code (2) | reason (length-prefixed string).
+type Error struct {
+	requestID uint64    // the request id of the failed message, or 0 if it has none
+	code      ErrorCode // classifies the failure
+	reason    string    // human-readable description of the failure
+}
+
+var _ Message = Error{}
+
+// NewError creates a new Error instance with input validation.
+// Args:
+//   - requestID: the request id of the failed message, or 0 if it has none
+//   - code: the classification of the failure
+//   - reason: the human-readable description of the failure, at most MaxErrorReasonSize bytes
+//
+// Returns an error wrapping ErrInvalidMessage if the code is zero or the reason is too long.
+func NewError(requestID uint64, code ErrorCode, reason string) (Error, error) {
+	if code == 0 {
+		return Error{}, errorf("error code must be non-zero")
+	}
+	if len(reason) > MaxErrorReasonSize {
+		return Error{}, errorf("reason must be at most %d bytes, got %d", MaxErrorReasonSize, len(reason))
+	}
+	return Error{requestID: requestID, code: code, reason: reason}, nil
+}
+
+// Type returns TypeError.
+func (m Error) Type() Type {
+	return TypeError
+}
+
+// RequestID returns the request id of the failed message, or 0 if it has none.
+func (m Error) RequestID() uint64 {
+	return m.requestID
+}
+
+// Code returns the classification of the failure.
+func (m Error) Code() ErrorCode {
+	return m.code
+}
+
+// Reason returns the human-readable description of the failure.
+func (m Error) Reason() string {
+	return m.reason
+}
+
+func (m Error) encodeBody(e *encoder) {
+	e.putUint64(m.requestID)
+	e.putUint16(uint16(m.code))
+	e.putString(m.reason)
+}
+
+func decodeError(d *decoder) (Message, error) {
+	requestID := d.uint64()
+	code := ErrorCode(d.uint16())
+	reason := d.string()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return NewError(requestID, code, reason)
+}
@@ -0,0 +1,15 @@
+package protocol
+
+import "errors"
+
+// ErrUnsupportedVersion is returned when decoding a message encoded with a protocol version this node does not speak.
+var ErrUnsupportedVersion = errors.New("unsupported protocol version")
+
+// ErrUnknownMessageType is returned when decoding a message whose type is not part of the catalogue.
+var ErrUnknownMessageType = errors.New("unknown message type")
+
+// ErrMalformedMessage is returned when a message cannot be decoded, e.g., because it is truncated or has trailing bytes.
+var ErrMalformedMessage = errors.New("malformed message")
+
+// ErrInvalidMessage is returned when the content of a message violates its validation rules.
+var ErrInvalidMessage = errors.New("invalid message")
@@ -0,0 +1,253 @@
+package protocol
+
+import (
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+)
+
+// LinkRequest asks the recipient to link the requester as its neighbor at the given level, on the given side of the
+// recipient, e.g., a request with DirectionLeft asks the recipient to make the requester its left neighbor.
+// It is sent while a node joins the skip graph (Algorithm 2 of the Skip Graph paper), or while repairing the lookup table.
+//
+// Body layout: requester (identity) | level (2) | direction (1).
+type LinkRequest struct {
+	requester model.Identity  // the node asking to be linked
+	level     types.Level     // the level to link at
+	direction types.Direction // the side of the recipient to link the requester on
+}
+
+var _ Message = LinkRequest{}
+
+// NewLinkRequest creates a new LinkRequest instance with input validation.
+// Returns an error wrapping ErrInvalidMessage if the requester has a zero identifier, the level is out of the lookup
+// table bounds, or the direction is invalid.
+func NewLinkRequest(requester model.Identity, level types.Level, direction types.Direction) (LinkRequest, error) {
+	if err := validateIdentity("requester", requester); err != nil {
+		return LinkRequest{}, err
+	}
+	if err := validateLevel(level); err != nil {
+		return LinkRequest{}, err
+	}
+	if err := validateDirection(direction); err != nil {
+		return LinkRequest{}, err
+	}
+	return LinkRequest{requester: requester, level: level, direction: direction}, nil
+}
+
+// Type returns TypeLinkRequest.
+func (m LinkRequest) Type() Type {
+	return TypeLinkRequest
+}
+
+// Requester returns the identity of the node asking to be linked.
+func (m LinkRequest) Requester() model.Identity {
+	return m.requester
+}
+
+// Level returns the level to link at.
+func (m LinkRequest) Level() types.Level {
+	return m.level
+}
+
+// Direction returns the side of the recipient to link the requester on.
+func (m LinkRequest) Direction() types.Direction {
+	return m.direction
+}
+
+func (m LinkRequest) encodeBody(e *encoder) {
+	e.putIdentity(m.requester)
+	e.putLevel(m.level)
+	e.putDirection(m.direction)
+}
+
+func decodeLinkRequest(d *decoder) (Message, error) {
+	requester := d.identity()
+	level := d.level()
+	direction := d.direction()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return NewLinkRequest(requester, level, direction)
+}
+
+// LinkAck answers a LinkRequest.
+// If the link is accepted, previous is the neighbor the responder had on the requested side before linking the
+// requester (nil if it had none), so that the requester can link with it on the opposite side.
+//
+// Body layout: responder (identity) | level (2) | direction (1) | accepted (1) | previous (optional identity).
+type LinkAck struct {
+	responder model.Identity  // the node that received the LinkRequest
+	level     types.Level     // the level of the LinkRequest
+	direction types.Direction // the direction of the LinkRequest
+	accepted  bool            // whether the requester has been linked
+	previous  *model.Identity // the neighbor replaced by the requester, if any
+}
+
+var _ Message = LinkAck{}
+
+// NewLinkAck creates a new LinkAck instance with input validation.
+// Returns an error wrapping ErrInvalidMessage if the responder or previous has a zero identifier, the level is out of
+// the lookup table bounds, the direction is invalid, or previous is set on a rejected link.
+func NewLinkAck(
+	responder model.Identity,
+	level types.Level,
+	direction types.Direction,
+	accepted bool,
+	previous *model.Identity,
+) (LinkAck, error) {
+	if err := validateIdentity("responder", responder); err != nil {
+		return LinkAck{}, err
+	}
+	if err := validateLevel(level); err != nil {
+		return LinkAck{}, err
+	}
+	if err := validateDirection(direction); err != nil {
+		return LinkAck{}, err
+	}
+	if previous != nil {
+		if !accepted {
+			return LinkAck{}, errorf("previous neighbor must not be set on a rejected link")
+		}
+		if err := validateIdentity("previous", *previous); err != nil {
+			return LinkAck{}, err
+		}
+	}
+	return LinkAck{
+		responder: responder,
+		level:     level,
+		direction: direction,
+		accepted:  accepted,
+		previous:  previous,
+	}, nil
+}
+
+// Type returns TypeLinkAck.
+func (m LinkAck) Type() Type {
+	return TypeLinkAck
+}
+
+// Responder returns the identity of the node that received the LinkRequest.
+func (m LinkAck) Responder() model.Identity {
+	return m.responder
+}
+
+// Level returns the level of the LinkRequest.
+func (m LinkAck) Level() types.Level {
+	return m.level
+}
+
+// Direction returns the direction of the LinkRequest.
+func (m LinkAck) Direction() types.Direction {
+	return m.direction
+}
+
+// Accepted returns true if the requester has been linked.
+func (m LinkAck) Accepted() bool {
+	return m.accepted
+}
+
+// Previous returns the neighbor replaced by the requester, or nil if there was none.
+func (m LinkAck) Previous() *model.Identity {
+	return m.previous
+}
+
+func (m LinkAck) encodeBody(e *encoder) {
+	e.putIdentity(m.responder)
+	e.putLevel(m.level)
+	e.putDirection(m.direction)
+	e.putBool(m.accepted)
+	e.putOptionalIdentity(m.previous)
+}
+
+func decodeLinkAck(d *decoder) (Message, error) {
+	responder := d.identity()
+	level := d.level()
+	direction := d.direction()
+	accepted := d.bool()
+	previous := d.optionalIdentity()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return NewLinkAck(responder, level, direction, accepted, previous)
+}
+
+// Unlink notifies the recipient that the sender is no longer its neighbor at the given level, on the given side of the
+// recipient, e.g., because the sender leaves the skip graph.
+// If set, replacement is the node that the recipient should link on that side instead.
+//
+// Body layout: sender (identity) | level (2) | direction (1) | replacement (optional identity).
+type Unlink struct {
+	sender      model.Identity  // the node unlinking
+	level       types.Level     // the level to unlink at
+	direction   types.Direction // the side of the recipient the sender is unlinked from
+	replacement *model.Identity // the new neighbor of the recipient on that side, if any
+}
+
+var _ Message = Unlink{}
+
+// NewUnlink creates a new Unlink instance with input validation.
+// Returns an error wrapping ErrInvalidMessage if the sender or replacement has a zero identifier, the replacement is
+// the sender itself, the level is out of the lookup table bounds, or the direction is invalid.
+func NewUnlink(sender model.Identity, level types.Level, direction types.Direction, replacement *model.Identity) (Unlink, error) {
+	if err := validateIdentity("sender", sender); err != nil {
+		return Unlink{}, err
+	}
+	if err := validateLevel(level); err != nil {
+		return Unlink{}, err
+	}
+	if err := validateDirection(direction); err != nil {
+		return Unlink{}, err
+	}
+	if replacement != nil {
+		if err := validateIdentity("replacement", *replacement); err != nil {
+			return Unlink{}, err
+		}
+		if replacement.GetIdentifier() == sender.GetIdentifier() {
+			return Unlink{}, errorf("replacement must differ from the sender")
+		}
+	}
+	return Unlink{sender: sender, level: level, direction: direction, replacement: replacement}, nil
+}
+
+// Type returns TypeUnlink.
+func (m Unlink) Type() Type {
+	return TypeUnlink
+}
+
+// Sender returns the identity of the node unlinking.
+func (m Unlink) Sender() model.Identity {
+	return m.sender
+}
+
+// Level returns the level to unlink at.
+func (m Unlink) Level() types.Level {
+	return m.level
+}
+
+// Direction returns the side of the recipient the sender is unlinked from.
+func (m Unlink) Direction() types.Direction {
+	return m.direction
+}
+
+// Replacement returns the new neighbor of the recipient on the unlinked side, or nil if there is none.
+func (m Unlink) Replacement() *model.Identity {
+	return m.replacement
+}
+
+func (m Unlink) encodeBody(e *encoder) {
+	e.putIdentity(m.sender)
+	e.putLevel(m.level)
+	e.putDirection(m.direction)
+	e.putOptionalIdentity(m.replacement)
+}
+
+func decodeUnlink(d *decoder) (Message, error) {
+	sender := d.identity()
+	level := d.level()
+	direction := d.direction()
+	replacement := d.optionalIdentity()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return NewUnlink(sender, level, direction, replacement)
+}
@@ -0,0 +1,73 @@
+package protocol
+
+// Ping checks the liveness of the recipient, which is expected to answer with a Pong carrying the same nonce.
+//
+// Body layout: nonce (8).
+type Ping struct {
+	nonce uint64 // echoed back in the Pong
+}
+
+var _ Message = Ping{}
+
+// NewPing creates a new Ping instance with the given nonce.
+func NewPing(nonce uint64) Ping {
+	return Ping{nonce: nonce}
+}
+
+// Type returns TypePing.
+func (m Ping) Type() Type {
+	return TypePing
+}
+
+// Nonce returns the nonce to be echoed back in the Pong.
+func (m Ping) Nonce() uint64 {
+	return m.nonce
+}
+
+func (m Ping) encodeBody(e *encoder) {
+	e.putUint64(m.nonce)
+}
+
+func decodePing(d *decoder) (Message, error) {
+	nonce := d.uint64()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return NewPing(nonce), nil
+}
+
+// Pong answers a Ping.
+//
+// Body layout: nonce (8).
+type Pong struct {
+	nonce uint64 // the nonce of the corresponding Ping
+}
+
+var _ Message = Pong{}
+
+// NewPong creates a new Pong instance answering the Ping with the given nonce.
+func NewPong(nonce uint64) Pong {
+	return Pong{nonce: nonce}
+}
+
+// Type returns TypePong.
+func (m Pong) Type() Type {
+	return TypePong
+}
+
+// Nonce returns the nonce of the corresponding Ping.
+func (m Pong) Nonce() uint64 {
+	return m.nonce
+}
+
+func (m Pong) encodeBody(e *encoder) {
+	e.putUint64(m.nonce)
+}
+
+func decodePong(d *decoder) (Message, error) {
+	nonce := d.uint64()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return NewPong(nonce), nil
+}
@@ -0,0 +1,139 @@
+package protocol
+
+import (
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+)
+
+// NeighborQuery asks the recipient for its neighbor at the given level in the given direction, e.g., to verify or
+// repair lookup table entries.
+//
+// Body layout: request id (8) | level (2) | direction (1).
+type NeighborQuery struct {
+	requestID uint64          // correlates the query with its reply
+	level     types.Level     // the level of the queried neighbor
+	direction types.Direction // the direction of the queried neighbor
+}
+
+var _ Message = NeighborQuery{}
+
+// NewNeighborQuery creates a new NeighborQuery instance with input validation.
+// Returns an error wrapping ErrInvalidMessage if the level is out of the lookup table bounds, or the direction is invalid.
+func NewNeighborQuery(requestID uint64, level types.Level, direction types.Direction) (NeighborQuery, error) {
+	if err := validateLevel(level); err != nil {
+		return NeighborQuery{}, err
+	}
+	if err := validateDirection(direction); err != nil {
+		return NeighborQuery{}, err
+	}
+	return NeighborQuery{requestID: requestID, level: level, direction: direction}, nil
+}
+
+// Type returns TypeNeighborQuery.
+func (m NeighborQuery) Type() Type {
+	return TypeNeighborQuery
+}
+
+// RequestID returns the identifier correlating the query with its reply.
+func (m NeighborQuery) RequestID() uint64 {
+	return m.requestID
+}
+
+// Level returns the level of the queried neighbor.
+func (m NeighborQuery) Level() types.Level {
+	return m.level
+}
+
+// Direction returns the direction of the queried neighbor.
+func (m NeighborQuery) Direction() types.Direction {
+	return m.direction
+}
+
+func (m NeighborQuery) encodeBody(e *encoder) {
+	e.putUint64(m.requestID)
+	e.putLevel(m.level)
+	e.putDirection(m.direction)
+}
+
+func decodeNeighborQuery(d *decoder) (Message, error) {
+	requestID := d.uint64()
+	level := d.level()
+	direction := d.direction()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return NewNeighborQuery(requestID, level, direction)
+}
+
+// NeighborReply answers a NeighborQuery with the queried neighbor, or nil if the responder has no neighbor there.
+//
+// Body layout: request id (8) | level (2) | direction (1) | neighbor (optional identity).
+type NeighborReply struct {
+	requestID uint64          // the request id of the corresponding NeighborQuery
+	level     types.Level     // the level of the queried neighbor
+	direction types.Direction // the direction of the queried neighbor
+	neighbor  *model.Identity // the queried neighbor, if any
+}
+
+var _ Message = NeighborReply{}
+
+// NewNeighborReply creates a new NeighborReply instance with input validation.
+// Returns an error wrapping ErrInvalidMessage if the level is out of the lookup table bounds, the direction is invalid,
+// or the neighbor has a zero identifier.
+func NewNeighborReply(requestID uint64, level types.Level, direction types.Direction, neighbor *model.Identity) (NeighborReply, error) {
+	if err := validateLevel(level); err != nil {
+		return NeighborReply{}, err
+	}
+	if err := validateDirection(direction); err != nil {
+		return NeighborReply{}, err
+	}
+	if neighbor != nil {
+		if err := validateIdentity("neighbor", *neighbor); err != nil {
+			return NeighborReply{}, err
+		}
+	}
+	return NeighborReply{requestID: requestID, level: level, direction: direction, neighbor: neighbor}, nil
+}
+
+// Type returns TypeNeighborReply.
+func (m NeighborReply) Type() Type {
+	return TypeNeighborReply
+}
+
+// RequestID returns the request id of the corresponding NeighborQuery.
+func (m NeighborReply) RequestID() uint64 {
+	return m.requestID
+}
+
+// Level returns the level of the queried neighbor.
+func (m NeighborReply) Level() types.Level {
+	return m.level
+}
+
+// Direction returns the direction of the queried neighbor.
+func (m NeighborReply) Direction() types.Direction {
+	return m.direction
+}
+
+// Neighbor returns the queried neighbor, or nil if the responder has no neighbor there.
+func (m NeighborReply) Neighbor() *model.Identity {
+	return m.neighbor
+}
+
+func (m NeighborReply) encodeBody(e *encoder) {
+	e.putUint64(m.requestID)
+	e.putLevel(m.level)
+	e.putDirection(m.direction)
+	e.putOptionalIdentity(m.neighbor)
+}
+
+func decodeNeighborReply(d *decoder) (Message, error) {
+	requestID := d.uint64()
+	level := d.level()
+	direction := d.direction()
+	neighbor := d.optionalIdentity()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return NewNeighborReply(requestID, level, direction, neighbor)
+}
@@ -0,0 +1,140 @@
+// Package protocol defines the catalogue of messages exchanged between skip graph nodes, along with their stable
+// binary encoding, so that different implementations can interoperate.
+//
+// Every encoded message starts with a 3-byte header: the protocol version (1 byte) followed by the message type
+// (2 bytes, big-endian). The header is followed by the message body whose layout is documented on each message type.
+// All integers are big-endian; variable-length fields are prefixed by their length.
+package protocol
+
+import (
+	"fmt"
+)
+
+// Version is the version of the protocol implemented by this package.
+// It is incremented whenever the encoding of an existing message changes in an incompatible way.
+const Version uint8 = 1
+
+// headerSize is the size of the header preceding the body of every encoded message.
+const headerSize = 3
+
+// Type identifies the type of message on the wire.
+// Type codes are part of the stable encoding and must never be reused for a different message.
+type Type uint16
+
+const (
+	// TypeSearchRequest is the type of SearchRequest.
+	TypeSearchRequest Type = 1
+	// TypeSearchReply is the type of SearchReply.
+	TypeSearchReply Type = 2
+	// TypeLinkRequest is the type of LinkRequest.
+	TypeLinkRequest Type = 3
+	// TypeLinkAck is the type of LinkAck.
+	TypeLinkAck Type = 4
+	// TypeUnlink is the type of Unlink.
+	TypeUnlink Type = 5
+	// TypeNeighborQuery is the type of NeighborQuery.
+	TypeNeighborQuery Type = 6
+	// TypeNeighborReply is the type of NeighborReply.
+	TypeNeighborReply Type = 7
+	// TypePing is the type of Ping.
+	TypePing Type = 8
+	// TypePong is the type of Pong.
+	TypePong Type = 9
+	// TypeError is the type of Error.
+	TypeError Type = 10
+)
+
+// String returns the human-readable name of the message type.
+func (t Type) String() string {
+	switch t {
+	case TypeSearchRequest:
+		return "search-request"
+	case TypeSearchReply:
+		return "search-reply"
+	case TypeLinkRequest:
+		return "link-request"
+	case TypeLinkAck:
+		return "link-ack"
+	case TypeUnlink:
+		return "unlink"
+	case TypeNeighborQuery:
+		return "neighbor-query"
+	case TypeNeighborReply:
+		return "neighbor-reply"
+	case TypePing:
+		return "ping"
+	case TypePong:
+		return "pong"
+	case TypeError:
+		return "error"
+	default:
+		return fmt.Sprintf("unknown-%d", uint16(t))
+	}
+}
+
+// Message is implemented by all messages of the catalogue.
+// The set of messages is closed; messages are created through their validating constructors, e.g., NewSearchRequest.
+type Message interface {
+	// Type returns the type of the message on the wire.
+	Type() Type
+
+	// encodeBody appends the body of the message to the encoder.
+	encodeBody(e *encoder)
+}
+
+// decoders maps each message type of the catalogue to the function decoding its body.
+// Decoders must validate the decoded content through the constructor of the message.
+var decoders = map[Type]func(d *decoder) (Message, error){
+	TypeSearchRequest: decodeSearchRequest,
+	TypeSearchReply:   decodeSearchReply,
+	TypeLinkRequest:   decodeLinkRequest,
+	TypeLinkAck:       decodeLinkAck,
+	TypeUnlink:        decodeUnlink,
+	TypeNeighborQuery: decodeNeighborQuery,
+	TypeNeighborReply: decodeNeighborReply,
+	TypePing:          decodePing,
+	TypePong:          decodePong,
+	TypeError:         decodeError,
+}
+
+// Encode returns the binary encoding of the message, prefixed by the protocol version and the message type.
+func Encode(msg Message) []byte {
+	e := &encoder{}
+	e.putUint8(Version)
+	e.putUint16(uint16(msg.Type()))
+	msg.encodeBody(e)
+	return e.bytes()
+}
+
+// Decode decodes a message from its binary encoding as produced by Encode.
+// Returns an error wrapping:
+//   - ErrUnsupportedVersion if the message is encoded with a different protocol version.
+//   - ErrUnknownMessageType if the message type is not part of the catalogue.
+//   - ErrMalformedMessage if the encoding is truncated or has trailing bytes.
+//   - ErrInvalidMessage if the decoded content violates the validation rules of the message.
+//
+// Any returned error is benign and indicates a faulty or malicious sender.
+func Decode(b []byte) (Message, error) {
+	if len(b) < headerSize {
+		return nil, fmt.Errorf("%w: message of %d bytes is shorter than its header", ErrMalformedMessage, len(b))
+	}
+	d := newDecoder(b)
+	version := d.uint8()
+	if version != Version {
+		return nil, fmt.Errorf("%w: got %d, expected %d", ErrUnsupportedVersion, version, Version)
+	}
+	t := Type(d.uint16())
+	decode, ok := decoders[t]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownMessageType, uint16(t))
+	}
+
+	msg, err := decode(d)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s: %w", t, err)
+	}
+	if err := d.finish(); err != nil {
+		return nil, fmt.Errorf("could not decode %s: %w", t, err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,223 @@
+package protocol_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+	"github.com/thep2p/skipgraph-go/net/protocol"
+	"github.com/thep2p/skipgraph-go/unittest"
+)
+
+// multiHomedIdentityFixture returns a random identity with a variable-length key and several addresses.
+func multiHomedIdentityFixture(t *testing.T) model.Identity {
+	identity := model.NewIdentity(
+		unittest.IdentifierFixture(t),
+		unittest.MembershipVectorFixture(t),
+		model.NewAddress("::1", "5555"),
+		model.NewUnixAddress("/tmp/node.sock"),
+		model.NewInMemoryAddress("node"),
+	)
+	identity.SetKey(unittest.BytesKeyFixture(t, 32))
+	return identity
+}
+
+// messagesFixture returns one valid instance of each message of the catalogue.
+func messagesFixture(t *testing.T) []protocol.Message {
+	origin := unittest.IdentityFixture(t)
+	neighbor := multiHomedIdentityFixture(t)
+
+	search, err := protocol.NewSearchRequest(1, origin, unittest.IdentifierFixture(t), 5, types.DirectionLeft)
+	require.NoError(t, err)
+	keySearch, err := protocol.NewSearchRequest(2, neighbor, unittest.BytesKeyFixture(t, 64), 0, types.DirectionRight)
+	require.NoError(t, err)
+	reply, err := protocol.NewSearchReply(1, unittest.IdentifierFixture(t), core.MaxLookupTableLevel-1, neighbor)
+	require.NoError(t, err)
+	linkReq, err := protocol.NewLinkRequest(origin, 3, types.DirectionRight)
+	require.NoError(t, err)
+	linkAck, err := protocol.NewLinkAck(neighbor, 3, types.DirectionRight, true, &origin)
+	require.NoError(t, err)
+	linkNack, err := protocol.NewLinkAck(neighbor, 3, types.DirectionRight, false, nil)
+	require.NoError(t, err)
+	unlink, err := protocol.NewUnlink(origin, 7, types.DirectionLeft, &neighbor)
+	require.NoError(t, err)
+	unlinkNoReplacement, err := protocol.NewUnlink(origin, 7, types.DirectionLeft, nil)
+	require.NoError(t, err)
+	query, err := protocol.NewNeighborQuery(42, 2, types.DirectionLeft)
+	require.NoError(t, err)
+	neighborReply, err := protocol.NewNeighborReply(42, 2, types.DirectionLeft, &neighbor)
+	require.NoError(t, err)
+	emptyReply, err := protocol.NewNeighborReply(42, 2, types.DirectionLeft, nil)
+	require.NoError(t, err)
+	errMsg, err := protocol.NewError(42, protocol.ErrorCodeChannelUnknown, "no processor for channel")
+	require.NoError(t, err)
+
+	return []protocol.Message{
+		search,
+		keySearch,
+		reply,
+		linkReq,
+		linkAck,
+		linkNack,
+		unlink,
+		unlinkNoReplacement,
+		query,
+		neighborReply,
+		emptyReply,
+		protocol.NewPing(7),
+		protocol.NewPong(7),
+		errMsg,
+	}
+}
+
+// TestEncodeDecode_RoundTrip tests that every message of the catalogue decodes back to itself.
+func TestEncodeDecode_RoundTrip(t *testing.T) {
+	for _, msg := range messagesFixture(t) {
+		b := protocol.Encode(msg)
+		require.Equal(t, protocol.Version, b[0])
+
+		decoded, err := protocol.Decode(b)
+		require.NoError(t, err, "failed to decode %s", msg.Type())
+		require.Equal(t, msg, decoded)
+		require.Equal(t, msg.Type(), decoded.Type())
+	}
+}
+
+// TestEncode_Stable pins the encoding of messages so that any change to the wire format is detected.
+func TestEncode_Stable(t *testing.T) {
+	require.Equal(
+		t,
+		[]byte{0x01, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x02},
+		protocol.Encode(protocol.NewPing(0x0102)),
+	)
+
+	query, err := protocol.NewNeighborQuery(0x0a, 0xff, types.DirectionRight)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[]byte{0x01, 0x00, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0a, 0x00, 0xff, 0x01},
+		protocol.Encode(query),
+	)
+
+	errMsg, err := protocol.NewError(0, protocol.ErrorCodeNotFound, "ab")
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[]byte{0x01, 0x00, 0x0a, 0, 0, 0, 0, 0, 0, 0, 0, 0x00, 0x05, 0x00, 0x02, 'a', 'b'},
+		protocol.Encode(errMsg),
+	)
+}
+
+// TestDecode_Malformed tests that truncated, padded, unknown and mis-versioned encodings are rejected without panic.
+func TestDecode_Malformed(t *testing.T) {
+	for _, msg := range messagesFixture(t) {
+		b := protocol.Encode(msg)
+
+		// every strict prefix must be rejected
+		for i := 0; i < len(b); i++ {
+			_, err := protocol.Decode(b[:i])
+			require.Error(t, err, "prefix of %d bytes of %s must be rejected", i, msg.Type())
+		}
+
+		// trailing bytes must be rejected
+		_, err := protocol.Decode(append(append([]byte{}, b...), 0x00))
+		require.True(t, errors.Is(err, protocol.ErrMalformedMessage))
+	}
+
+	_, err := protocol.Decode([]byte{protocol.Version, 0xff, 0xff})
+	require.True(t, errors.Is(err, protocol.ErrUnknownMessageType))
+
+	b := protocol.Encode(protocol.NewPing(1))
+	b[0] = protocol.Version + 1
+	_, err = protocol.Decode(b)
+	require.True(t, errors.Is(err, protocol.ErrUnsupportedVersion))
+
+	// invalid direction
+	query, err := protocol.NewNeighborQuery(1, 1, types.DirectionLeft)
+	require.NoError(t, err)
+	b = protocol.Encode(query)
+	b[len(b)-1] = 0x07
+	_, err = protocol.Decode(b)
+	require.True(t, errors.Is(err, protocol.ErrMalformedMessage))
+
+	// level out of bounds is decoded but rejected by validation
+	b = protocol.Encode(query)
+	b[len(b)-3], b[len(b)-2] = 0x01, 0x00 // level 256
+	_, err = protocol.Decode(b)
+	require.True(t, errors.Is(err, protocol.ErrInvalidMessage))
+}
+
+// TestConstructors_Validation tests that constructors reject invalid content.
+func TestConstructors_Validation(t *testing.T) {
+	identity := unittest.IdentityFixture(t)
+	zero := model.Identity{}
+	target := unittest.IdentifierFixture(t)
+
+	invalid := []error{}
+	_, err := protocol.NewSearchRequest(1, zero, target, 0, types.DirectionLeft)
+	invalid = append(invalid, err)
+	_, err = protocol.NewSearchRequest(1, identity, nil, 0, types.DirectionLeft)
+	invalid = append(invalid, err)
+	_, err = protocol.NewSearchRequest(1, identity, target, -1, types.DirectionLeft)
+	invalid = append(invalid, err)
+	_, err = protocol.NewSearchRequest(1, identity, target, core.MaxLookupTableLevel, types.DirectionLeft)
+	invalid = append(invalid, err)
+	_, err = protocol.NewSearchRequest(1, identity, target, 0, types.Direction("up"))
+	invalid = append(invalid, err)
+	_, err = protocol.NewSearchReply(1, target, 0, zero)
+	invalid = append(invalid, err)
+	_, err = protocol.NewLinkRequest(zero, 0, types.DirectionLeft)
+	invalid = append(invalid, err)
+	for _, addr := range []model.Address{
+		model.NewAddress("localhost", "abc"),
+		model.NewAddress("localhost", ""),
+		model.NewUnixAddress(""),
+	} {
+		unparsable := model.NewIdentity(target, unittest.MembershipVectorFixture(t), addr)
+		_, err = protocol.NewLinkRequest(unparsable, 0, types.DirectionLeft)
+		invalid = append(invalid, err)
+	}
+	_, err = protocol.NewLinkAck(identity, 0, types.DirectionLeft, false, &identity)
+	invalid = append(invalid, err)
+	_, err = protocol.NewUnlink(identity, 0, types.DirectionLeft, &identity)
+	invalid = append(invalid, err)
+	_, err = protocol.NewUnlink(identity, 0, types.DirectionLeft, &zero)
+	invalid = append(invalid, err)
+	_, err = protocol.NewNeighborQuery(1, core.MaxLookupTableLevel, types.DirectionLeft)
+	invalid = append(invalid, err)
+	_, err = protocol.NewNeighborReply(1, 0, types.DirectionLeft, &zero)
+	invalid = append(invalid, err)
+	_, err = protocol.NewError(1, 0, "no code")
+	invalid = append(invalid, err)
+	_, err = protocol.NewError(1, protocol.ErrorCodeInternal, strings.Repeat("x", protocol.MaxErrorReasonSize+1))
+	invalid = append(invalid, err)
+
+	for i, err := range invalid {
+		require.Error(t, err, "case %d must be rejected", i)
+		require.True(t, errors.Is(err, protocol.ErrInvalidMessage), "case %d: unexpected error %v", i, err)
+	}
+
+	// an empty address is the zero address, which round trips
+	unaddressed := model.NewIdentity(target, unittest.MembershipVectorFixture(t), model.NewAddress("", ""))
+	msg, err := protocol.NewLinkRequest(unaddressed, 0, types.DirectionLeft)
+	require.NoError(t, err)
+	decoded, err := protocol.Decode(protocol.Encode(msg))
+	require.NoError(t, err)
+	require.Equal(t, msg, decoded)
+
+	// validation errors of the model are preserved
+	_, err = protocol.NewLinkRequest(
+		model.NewIdentity(target, unittest.MembershipVectorFixture(t), model.NewAddress("localhost", "abc")),
+		0,
+		types.DirectionLeft,
+	)
+	require.True(t, errors.Is(err, model.ErrInvalidAddress))
+	_, err = protocol.NewSearchRequest(1, identity, target, -1, types.DirectionLeft)
+	require.True(t, errors.Is(err, model.ErrInvalidLevel))
+	_, err = protocol.NewSearchRequest(1, identity, target, 0, types.Direction("up"))
+	require.True(t, errors.Is(err, model.ErrInvalidDirection))
+}
@@ -0,0 +1,194 @@
+package protocol
+
+import (
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+)
+
+// SearchRequest asks the recipient to continue a search for the target key, starting from the given level in the given
+// direction (Algorithm 1 of the Skip Graph paper).
+// The target is usually an identifier, but may be any model.Key when nodes are positioned by variable-length keys.
+//
+// Body layout: request id (8) | origin (identity) | target (key) | level (2) | direction (1).
+type SearchRequest struct {
+	requestID uint64          // correlates the request with its reply at the origin
+	origin    model.Identity  // the node that initiated the search, to which the reply is sent
+	target    model.Key       // the key being searched for
+	level     types.Level     // the level to continue the search at
+	direction types.Direction // the direction of the search
+}
+
+var _ Message = SearchRequest{}
+
+// NewSearchRequest creates a new SearchRequest instance with input validation.
+// Args:
+//   - requestID: the identifier correlating the request with its reply at the origin
+//   - origin: the identity of the node that initiated the search
+//   - target: the key to search for
+//   - level: the level to continue the search at
+//   - direction: the search direction (types.DirectionLeft or types.DirectionRight)
+//
+// Returns an error wrapping ErrInvalidMessage if:
+//   - origin has a zero identifier
+//   - target is nil or exceeds model.MaxKeySizeBytes
+//   - level is negative or >= core.MaxLookupTableLevel
+//   - direction is neither DirectionLeft nor DirectionRight
+func NewSearchRequest(
+	requestID uint64,
+	origin model.Identity,
+	target model.Key,
+	level types.Level,
+	direction types.Direction,
+) (SearchRequest, error) {
+	if err := validateIdentity("origin", origin); err != nil {
+		return SearchRequest{}, err
+	}
+	if err := validateKey("target", target); err != nil {
+		return SearchRequest{}, err
+	}
+	if err := validateLevel(level); err != nil {
+		return SearchRequest{}, err
+	}
+	if err := validateDirection(direction); err != nil {
+		return SearchRequest{}, err
+	}
+	return SearchRequest{
+		requestID: requestID,
+		origin:    origin,
+		target:    target,
+		level:     level,
+		direction: direction,
+	}, nil
+}
+
+// Type returns TypeSearchRequest.
+func (m SearchRequest) Type() Type {
+	return TypeSearchRequest
+}
+
+// RequestID returns the identifier correlating the request with its reply.
+func (m SearchRequest) RequestID() uint64 {
+	return m.requestID
+}
+
+// Origin returns the identity of the node that initiated the search.
+func (m SearchRequest) Origin() model.Identity {
+	return m.origin
+}
+
+// Target returns the key being searched for.
+func (m SearchRequest) Target() model.Key {
+	return m.target
+}
+
+// Level returns the level to continue the search at.
+func (m SearchRequest) Level() types.Level {
+	return m.level
+}
+
+// Direction returns the direction of the search.
+func (m SearchRequest) Direction() types.Direction {
+	return m.direction
+}
+
+func (m SearchRequest) encodeBody(e *encoder) {
+	e.putUint64(m.requestID)
+	e.putIdentity(m.origin)
+	e.putKey(m.target)
+	e.putLevel(m.level)
+	e.putDirection(m.direction)
+}
+
+func decodeSearchRequest(d *decoder) (Message, error) {
+	requestID := d.uint64()
+	origin := d.identity()
+	target := d.key()
+	level := d.level()
+	direction := d.direction()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return NewSearchRequest(requestID, origin, target, level, direction)
+}
+
+// SearchReply carries the result of a search back to its origin.
+//
+// Body layout: request id (8) | target (key) | termination level (2) | result (identity).
+type SearchReply struct {
+	requestID        uint64         // the request id of the corresponding SearchRequest
+	target           model.Key      // the key that was searched for
+	terminationLevel types.Level    // the level where the search terminated
+	result           model.Identity // the identity of the node found
+}
+
+var _ Message = SearchReply{}
+
+// NewSearchReply creates a new SearchReply instance with input validation.
+// Args:
+//   - requestID: the request id of the corresponding SearchRequest
+//   - target: the key that was searched for
+//   - terminationLevel: the level where the search terminated
+//   - result: the identity of the node found
+//
+// Returns an error wrapping ErrInvalidMessage if target is invalid, terminationLevel is out of the lookup table bounds,
+// or result has a zero identifier.
+func NewSearchReply(requestID uint64, target model.Key, terminationLevel types.Level, result model.Identity) (SearchReply, error) {
+	if err := validateKey("target", target); err != nil {
+		return SearchReply{}, err
+	}
+	if err := validateLevel(terminationLevel); err != nil {
+		return SearchReply{}, err
+	}
+	if err := validateIdentity("result", result); err != nil {
+		return SearchReply{}, err
+	}
+	return SearchReply{
+		requestID:        requestID,
+		target:           target,
+		terminationLevel: terminationLevel,
+		result:           result,
+	}, nil
+}
+
+// Type returns TypeSearchReply.
+func (m SearchReply) Type() Type {
+	return TypeSearchReply
+}
+
+// RequestID returns the request id of the corresponding SearchRequest.
+func (m SearchReply) RequestID() uint64 {
+	return m.requestID
+}
+
+// Target returns the key that was searched for.
+func (m SearchReply) Target() model.Key {
+	return m.target
+}
+
+// TerminationLevel returns the level where the search terminated.
+func (m SearchReply) TerminationLevel() types.Level {
+	return m.terminationLevel
+}
+
+// Result returns the identity of the node found.
+func (m SearchReply) Result() model.Identity {
+	return m.result
+}
+
+func (m SearchReply) encodeBody(e *encoder) {
+	e.putUint64(m.requestID)
+	e.putKey(m.target)
+	e.putLevel(m.terminationLevel)
+	e.putIdentity(m.result)
+}
+
+func decodeSearchReply(d *decoder) (Message, error) {
+	requestID := d.uint64()
+	target := d.key()
+	level := d.level()
+	result := d.identity()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return NewSearchReply(requestID, target, level, result)
+}
@@ -0,0 +1,69 @@
+package protocol
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/thep2p/skipgraph-go/core"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+)
+
+// validateLevel returns an error wrapping ErrInvalidMessage if the level is out of the lookup table bounds.
+func validateLevel(level types.Level) error {
+	if level < 0 {
+		return fmt.Errorf("%w: %w: got %d", ErrInvalidMessage, model.ErrInvalidLevel, level)
+	}
+	if level >= core.MaxLookupTableLevel {
+		return fmt.Errorf("%w: %w: must be less than %d, got %d", ErrInvalidMessage, model.ErrLevelExceedsMax, core.MaxLookupTableLevel, level)
+	}
+	return nil
+}
+
+// validateDirection returns an error wrapping ErrInvalidMessage if the direction is neither left nor right.
+func validateDirection(dir types.Direction) error {
+	if dir != types.DirectionLeft && dir != types.DirectionRight {
+		return fmt.Errorf("%w: %w: got %s", ErrInvalidMessage, model.ErrInvalidDirection, dir)
+	}
+	return nil
+}
+
+// validateIdentity returns an error wrapping ErrInvalidMessage if the identity has a zero identifier, an invalid key,
+// more addresses than can be encoded, or an address that does not parse back from its encoding.
+func validateIdentity(name string, identity model.Identity) error {
+	id := identity.GetIdentifier()
+	if id.IsZero() {
+		return fmt.Errorf("%w: %s must have a non-zero identifier", ErrInvalidMessage, name)
+	}
+	if err := validateKey(name+" key", identity.GetKey()); err != nil {
+		return err
+	}
+	if n := len(identity.GetAddresses()); n > math.MaxUint8 {
+		return fmt.Errorf("%w: %s must have at most %d addresses, got %d", ErrInvalidMessage, name, math.MaxUint8, n)
+	}
+	for i, addr := range identity.GetAddresses() {
+		if err := addr.Validate(); err != nil {
+			return fmt.Errorf("%w: %s address %d: %w", ErrInvalidMessage, name, i, err)
+		}
+	}
+	return nil
+}
+
+// validateKey returns an error wrapping ErrInvalidMessage if the key is nil or cannot be encoded.
+func validateKey(name string, key model.Key) error {
+	if key == nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidMessage, name, model.ErrEmptyKey)
+	}
+	if _, ok := key.(model.Identifier); ok {
+		return nil
+	}
+	if _, err := model.NewBytesKey(key.KeyBytes()); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidMessage, name, err)
+	}
+	return nil
+}
+
+// errorf returns an error wrapping ErrInvalidMessage with the formatted description.
+func errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidMessage, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,12 @@
+package ratelimit
+
+import "errors"
+
+// ErrPeerRateLimited is returned when a message exceeds the rate limit of its origin.
+var ErrPeerRateLimited = errors.New("peer rate limit exceeded")
+
+// ErrChannelRateLimited is returned when a message exceeds the rate limit of its channel.
+var ErrChannelRateLimited = errors.New("channel rate limit exceeded")
+
+// ErrPeerBanned is returned when a message originates from a temporarily banned peer.
+var ErrPeerBanned = errors.New("peer banned")
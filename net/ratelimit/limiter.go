@@ -0,0 +1,297 @@
+// Package ratelimit implements token-bucket rate limiting of inbound messages, per origin and per channel.
+//
+// A Limiter holds a token bucket for every origin and for every channel. Each message takes one token from the bucket
+// of its origin and one from the bucket of its channel; a message finding either bucket empty is dropped. Buckets
+// refill continuously at the rate of their limit, up to its burst. Optionally, an origin exceeding its limit too often
+// within a window is banned for a while, during which all its messages are dropped.
+package ratelimit
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net"
+)
+
+// DefaultMaxPeers is the default number of origins whose buckets are remembered; beyond it, the least recently seen
+// origin is forgotten to make room for a new one if it is back to a full bucket, which is equivalent to keeping it.
+const DefaultMaxPeers = 10_000
+
+// Limit is the limit of a token bucket; the zero Limit is unlimited.
+type Limit struct {
+	Rate  float64 // tokens added to the bucket per second
+	Burst int     // capacity of the bucket, i.e., the number of messages allowed at once
+}
+
+// unlimited returns true if the limit does not limit anything.
+func (l Limit) unlimited() bool {
+	return l.Rate <= 0 && l.Burst <= 0
+}
+
+// Stats is a snapshot of the counters of a Limiter.
+type Stats struct {
+	Allowed        uint64 // messages allowed
+	PeerLimited    uint64 // messages dropped as their origin exceeded its limit
+	ChannelLimited uint64 // messages dropped as their channel exceeded its limit
+	BannedDropped  uint64 // messages dropped as their origin was banned
+	Bans           uint64 // origins banned
+}
+
+// bucket is a token bucket.
+type bucket struct {
+	tokens float64
+	last   time.Time // the last time tokens were added
+}
+
+// newBucket returns a full bucket of the limit.
+func newBucket(limit Limit, now time.Time) *bucket {
+	return &bucket{tokens: float64(limit.Burst), last: now}
+}
+
+// refill adds the tokens accrued since the last refill, up to the burst of the limit.
+func (b *bucket) refill(limit Limit, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * limit.Rate
+		b.last = now
+	}
+	if b.tokens > float64(limit.Burst) {
+		b.tokens = float64(limit.Burst)
+	}
+}
+
+// take takes a token from the bucket, if there is one.
+// Returns false if the bucket is empty.
+func (b *bucket) take(limit Limit, now time.Time) bool {
+	b.refill(limit, now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// peer is the state of an origin.
+type peer struct {
+	id          model.Identifier
+	bucket      *bucket
+	strikes     int       // times the origin exceeded its limit since strikeStart
+	strikeStart time.Time // start of the window in which strikes are counted
+	bannedUntil time.Time
+}
+
+// Limiter limits the rate of inbound messages per origin and per channel.
+// It is safe for concurrent use.
+type Limiter struct {
+	peerLimit      Limit
+	channelLimit   Limit
+	channelLimits  map[net.Channel]Limit
+	banThreshold   int
+	banWindow      time.Duration
+	banDuration    time.Duration
+	maxPeers       int
+	now            func() time.Time
+	allowed        atomic.Uint64
+	peerLimited    atomic.Uint64
+	channelLimited atomic.Uint64
+	bannedDropped  atomic.Uint64
+	bans           atomic.Uint64
+
+	l        sync.Mutex
+	peers    map[model.Identifier]*list.Element // of recent, holding the *peer of the origin
+	recent   *list.List                         // origins from the most to the least recently seen
+	channels map[net.Channel]*bucket
+}
+
+// Option is a functional option for configuring a Limiter.
+type Option func(*Limiter)
+
+// WithPeerLimit sets the limit of every origin; unlimited by default.
+func WithPeerLimit(limit Limit) Option {
+	return func(l *Limiter) {
+		l.peerLimit = limit
+	}
+}
+
+// WithChannelLimit sets the limit of the channel, across all origins.
+func WithChannelLimit(channel net.Channel, limit Limit) Option {
+	return func(l *Limiter) {
+		l.channelLimits[channel] = limit
+	}
+}
+
+// WithDefaultChannelLimit sets the limit of the channels without a limit of their own; unlimited by default.
+func WithDefaultChannelLimit(limit Limit) Option {
+	return func(l *Limiter) {
+		l.channelLimit = limit
+	}
+}
+
+// WithBan bans an origin for duration once it exceeds its limit threshold times within window; disabled by default.
+func WithBan(threshold int, window time.Duration, duration time.Duration) Option {
+	return func(l *Limiter) {
+		l.banThreshold = threshold
+		l.banWindow = window
+		l.banDuration = duration
+	}
+}
+
+// WithMaxPeers sets the number of origins whose buckets are remembered; defaults to DefaultMaxPeers.
+// Once that many origins are remembered, the least recently seen one is forgotten to make room for a new one if it is
+// neither banned nor recently limited; otherwise, the messages of the new origin are limited.
+func WithMaxPeers(max int) Option {
+	return func(l *Limiter) {
+		if max > 0 {
+			l.maxPeers = max
+		}
+	}
+}
+
+// WithClock sets the source of the current time of the limiter; defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(l *Limiter) {
+		l.now = now
+	}
+}
+
+// NewLimiter creates a Limiter; without options, it allows every message.
+func NewLimiter(opts ...Option) *Limiter {
+	l := &Limiter{
+		channelLimits: make(map[net.Channel]Limit),
+		maxPeers:      DefaultMaxPeers,
+		now:           time.Now,
+		peers:         make(map[model.Identifier]*list.Element),
+		recent:        list.New(),
+		channels:      make(map[net.Channel]*bucket),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Allow takes a token for the message from the bucket of its origin, then from the bucket of its channel.
+// Returns nil if the message is allowed, or an error:
+//   - wrapping ErrPeerBanned if the origin is banned.
+//   - wrapping ErrPeerRateLimited if the origin exceeds its limit; this may get the origin banned.
+//   - wrapping ErrChannelRateLimited if the channel exceeds its limit.
+func (l *Limiter) Allow(channel net.Channel, origin model.Identifier) error {
+	now := l.now()
+	l.l.Lock()
+	defer l.l.Unlock()
+
+	p, ok := l.peer(origin, now)
+	if !ok {
+		l.peerLimited.Add(1)
+		return fmt.Errorf("%w: %s, too many origins", ErrPeerRateLimited, origin.String())
+	}
+	if now.Before(p.bannedUntil) {
+		l.bannedDropped.Add(1)
+		return fmt.Errorf("%w: %s until %s", ErrPeerBanned, origin.String(), p.bannedUntil.Format(time.RFC3339))
+	}
+	if !l.peerLimit.unlimited() && !p.bucket.take(l.peerLimit, now) {
+		l.peerLimited.Add(1)
+		l.strike(p, now)
+		return fmt.Errorf("%w: %s", ErrPeerRateLimited, origin.String())
+	}
+
+	limit, ok := l.channelLimits[channel]
+	if !ok {
+		limit = l.channelLimit
+	}
+	if !limit.unlimited() {
+		b, ok := l.channels[channel]
+		if !ok {
+			b = newBucket(limit, now)
+			l.channels[channel] = b
+		}
+		if !b.take(limit, now) {
+			l.channelLimited.Add(1)
+			return fmt.Errorf("%w: %s", ErrChannelRateLimited, channel)
+		}
+	}
+
+	l.allowed.Add(1)
+	return nil
+}
+
+// Banned returns true if the origin is banned.
+func (l *Limiter) Banned(origin model.Identifier) bool {
+	now := l.now()
+	l.l.Lock()
+	defer l.l.Unlock()
+	e, ok := l.peers[origin]
+	return ok && now.Before(e.Value.(*peer).bannedUntil)
+}
+
+// Peers returns the number of origins whose state the limiter remembers.
+func (l *Limiter) Peers() int {
+	l.l.Lock()
+	defer l.l.Unlock()
+	return l.recent.Len()
+}
+
+// Stats returns a snapshot of the counters of the limiter.
+func (l *Limiter) Stats() Stats {
+	return Stats{
+		Allowed:        l.allowed.Load(),
+		PeerLimited:    l.peerLimited.Load(),
+		ChannelLimited: l.channelLimited.Load(),
+		BannedDropped:  l.bannedDropped.Load(),
+		Bans:           l.bans.Load(),
+	}
+}
+
+// strike counts an excess of the origin over its limit, and bans it once it reaches the threshold within the window.
+// The caller must hold the lock.
+func (l *Limiter) strike(p *peer, now time.Time) {
+	if l.banThreshold <= 0 {
+		return
+	}
+	if now.Sub(p.strikeStart) > l.banWindow {
+		p.strikes, p.strikeStart = 0, now
+	}
+	p.strikes++
+	if p.strikes >= l.banThreshold {
+		p.bannedUntil = now.Add(l.banDuration)
+		p.strikes = 0
+		l.bans.Add(1)
+	}
+}
+
+// peer returns the state of the origin, marking it as the most recently seen origin. The state of an unknown origin
+// is created, forgetting the least recently seen origin if the limiter remembers its maximum number of origins already
+// and that origin is idle; otherwise, the least recently seen origin is marked as the most recently seen, so that the
+// next unknown origin checks the next one.
+// Returns false if the origin is unknown and there is no room for it. The caller must hold the lock.
+func (l *Limiter) peer(origin model.Identifier, now time.Time) (*peer, bool) {
+	if e, ok := l.peers[origin]; ok {
+		l.recent.MoveToFront(e)
+		return e.Value.(*peer), true
+	}
+	if l.recent.Len() >= l.maxPeers {
+		oldest := l.recent.Back()
+		if !l.idle(oldest.Value.(*peer), now) {
+			l.recent.MoveToFront(oldest)
+			return nil, false
+		}
+		l.recent.Remove(oldest)
+		delete(l.peers, oldest.Value.(*peer).id)
+	}
+	p := &peer{id: origin, bucket: newBucket(l.peerLimit, now)}
+	l.peers[origin] = l.recent.PushFront(p)
+	return p, true
+}
+
+// idle returns true if the origin is neither banned nor recently limited, i.e., is back to a full bucket, hence
+// forgetting it is equivalent to keeping it. The caller must hold the lock.
+func (l *Limiter) idle(p *peer, now time.Time) bool {
+	if now.Before(p.bannedUntil) || (p.strikes > 0 && now.Sub(p.strikeStart) <= l.banWindow) {
+		return false
+	}
+	p.bucket.refill(l.peerLimit, now)
+	return p.bucket.tokens >= float64(l.peerLimit.Burst)
+}
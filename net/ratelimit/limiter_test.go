@@ -0,0 +1,167 @@
+package ratelimit_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/ratelimit"
+	"github.com/thep2p/skipgraph-go/unittest"
+)
+
+const otherChannel = net.Channel("channel-other")
+
+// clock is a fake clock, advanced manually.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+// TestLimiter_Unlimited tests that a limiter without limits allows every message.
+func TestLimiter_Unlimited(t *testing.T) {
+	l := ratelimit.NewLimiter()
+	origin := unittest.IdentifierFixture(t)
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, l.Allow(net.TestChannel, origin))
+	}
+	require.Equal(t, ratelimit.Stats{Allowed: 1000}, l.Stats())
+}
+
+// TestLimiter_PeerLimit tests that each origin is limited to its burst, and that its bucket refills over time.
+func TestLimiter_PeerLimit(t *testing.T) {
+	c := &clock{now: time.Now()}
+	l := ratelimit.NewLimiter(ratelimit.WithPeerLimit(ratelimit.Limit{Rate: 10, Burst: 3}), ratelimit.WithClock(c.Now))
+	origin := unittest.IdentifierFixture(t)
+	other := unittest.IdentifierFixture(t)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, l.Allow(net.TestChannel, origin))
+	}
+	err := l.Allow(net.TestChannel, origin)
+	require.True(t, errors.Is(err, ratelimit.ErrPeerRateLimited), "unexpected error %v", err)
+
+	// other origins have buckets of their own
+	require.NoError(t, l.Allow(net.TestChannel, other))
+
+	// one token is added every 100ms
+	c.now = c.now.Add(100 * time.Millisecond)
+	require.NoError(t, l.Allow(net.TestChannel, origin))
+	require.Error(t, l.Allow(net.TestChannel, origin))
+
+	// the bucket refills up to its burst
+	c.now = c.now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, l.Allow(net.TestChannel, origin))
+	}
+	require.Error(t, l.Allow(net.TestChannel, origin))
+	require.Equal(t, ratelimit.Stats{Allowed: 8, PeerLimited: 3}, l.Stats())
+}
+
+// TestLimiter_ChannelLimit tests that each channel is limited across origins, and that channels without a limit of
+// their own fall back to the default channel limit.
+func TestLimiter_ChannelLimit(t *testing.T) {
+	c := &clock{now: time.Now()}
+	l := ratelimit.NewLimiter(
+		ratelimit.WithChannelLimit(net.TestChannel, ratelimit.Limit{Rate: 1, Burst: 2}),
+		ratelimit.WithDefaultChannelLimit(ratelimit.Limit{Rate: 1, Burst: 1}),
+		ratelimit.WithClock(c.Now),
+	)
+
+	require.NoError(t, l.Allow(net.TestChannel, unittest.IdentifierFixture(t)))
+	require.NoError(t, l.Allow(net.TestChannel, unittest.IdentifierFixture(t)))
+	err := l.Allow(net.TestChannel, unittest.IdentifierFixture(t))
+	require.True(t, errors.Is(err, ratelimit.ErrChannelRateLimited), "unexpected error %v", err)
+
+	require.NoError(t, l.Allow(otherChannel, unittest.IdentifierFixture(t)))
+	err = l.Allow(otherChannel, unittest.IdentifierFixture(t))
+	require.True(t, errors.Is(err, ratelimit.ErrChannelRateLimited), "unexpected error %v", err)
+
+	c.now = c.now.Add(time.Second)
+	require.NoError(t, l.Allow(net.TestChannel, unittest.IdentifierFixture(t)))
+	require.NoError(t, l.Allow(otherChannel, unittest.IdentifierFixture(t)))
+	require.Equal(t, ratelimit.Stats{Allowed: 5, ChannelLimited: 2}, l.Stats())
+}
+
+// TestLimiter_Ban tests that an origin exceeding its limit too often within the window is banned for the ban duration.
+func TestLimiter_Ban(t *testing.T) {
+	c := &clock{now: time.Now()}
+	l := ratelimit.NewLimiter(
+		ratelimit.WithPeerLimit(ratelimit.Limit{Rate: 1, Burst: 1}),
+		ratelimit.WithBan(3, time.Second, time.Minute),
+		ratelimit.WithClock(c.Now),
+	)
+	origin := unittest.IdentifierFixture(t)
+
+	// strikes spread beyond the window do not get the origin banned
+	require.NoError(t, l.Allow(net.TestChannel, origin))
+	require.Error(t, l.Allow(net.TestChannel, origin))
+	require.Error(t, l.Allow(net.TestChannel, origin))
+	c.now = c.now.Add(2 * time.Second)
+	require.NoError(t, l.Allow(net.TestChannel, origin))
+	require.Error(t, l.Allow(net.TestChannel, origin))
+	require.False(t, l.Banned(origin))
+
+	// three strikes within the window get it banned
+	require.Error(t, l.Allow(net.TestChannel, origin))
+	require.Error(t, l.Allow(net.TestChannel, origin))
+	require.True(t, l.Banned(origin))
+
+	// while banned, even a refilled bucket does not help
+	c.now = c.now.Add(30 * time.Second)
+	err := l.Allow(net.TestChannel, origin)
+	require.True(t, errors.Is(err, ratelimit.ErrPeerBanned), "unexpected error %v", err)
+
+	// the ban expires
+	c.now = c.now.Add(31 * time.Second)
+	require.False(t, l.Banned(origin))
+	require.NoError(t, l.Allow(net.TestChannel, origin))
+	require.Equal(t, ratelimit.Stats{Allowed: 3, PeerLimited: 5, BannedDropped: 1, Bans: 1}, l.Stats())
+}
+
+// TestLimiter_MaxPeers tests that the limiter remembers up to its maximum number of origins, that forgetting idle
+// origins keeps their limits in effect, and that new origins are limited rather than making the limiter forget banned
+// or limited origins.
+func TestLimiter_MaxPeers(t *testing.T) {
+	c := &clock{now: time.Now()}
+	l := ratelimit.NewLimiter(
+		ratelimit.WithPeerLimit(ratelimit.Limit{Rate: 1, Burst: 1}),
+		ratelimit.WithBan(1, time.Second, time.Minute),
+		ratelimit.WithMaxPeers(3),
+		ratelimit.WithClock(c.Now),
+	)
+	abusive := unittest.IdentifierFixture(t)
+	limited := unittest.IdentifierFixture(t)
+	idle := unittest.IdentifierFixture(t)
+
+	require.NoError(t, l.Allow(net.TestChannel, abusive))
+	require.Error(t, l.Allow(net.TestChannel, abusive))
+	require.True(t, l.Banned(abusive))
+	require.NoError(t, l.Allow(net.TestChannel, limited))
+	c.now = c.now.Add(2 * time.Second)
+	require.NoError(t, l.Allow(net.TestChannel, idle))
+	require.NoError(t, l.Allow(net.TestChannel, limited))
+	require.Equal(t, 3, l.Peers())
+
+	// new origins do not evict the banned origin, nor the one with an empty bucket, and are limited meanwhile
+	for i := 0; i < 10; i++ {
+		err := l.Allow(net.TestChannel, unittest.IdentifierFixture(t))
+		require.True(t, errors.Is(err, ratelimit.ErrPeerRateLimited), "unexpected error %v", err)
+		require.Equal(t, 3, l.Peers())
+	}
+	require.True(t, l.Banned(abusive))
+	err := l.Allow(net.TestChannel, limited)
+	require.True(t, errors.Is(err, ratelimit.ErrPeerRateLimited), "unexpected error %v", err)
+
+	// once its bucket is full again, an origin is forgotten to make room for a new one
+	c.now = c.now.Add(2 * time.Second)
+	for i := 0; i < 10; i++ {
+		_ = l.Allow(net.TestChannel, unittest.IdentifierFixture(t))
+		require.LessOrEqual(t, l.Peers(), 3)
+	}
+	require.True(t, l.Banned(abusive))
+}
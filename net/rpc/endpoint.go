@@ -0,0 +1,254 @@
+// Package rpc implements request/response calls on top of a net.Network channel.
+//
+// An Endpoint registers itself as the processor of a channel. Calls are correlated with their responses by a call
+// identifier unique to the caller, and a response is only accepted from the target of the call. On the serving side,
+// requests are dispatched to the handler registered for their type, and its result is sent back to the caller.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/internal/bounded"
+)
+
+// DefaultTimeout is the default timeout of calls whose context has no deadline, and of handlers.
+const DefaultTimeout = 5 * time.Second
+
+// DefaultMaxConcurrentRequests is the default number of requests an endpoint serves at a time.
+const DefaultMaxConcurrentRequests = 64
+
+// callKey identifies a pending call.
+type callKey struct {
+	target model.Identifier
+	callID uint64
+}
+
+// handlerFunc serves a request of the type it is registered for.
+type handlerFunc func(ctx context.Context, origin model.Identifier, request any) (any, error)
+
+// Endpoint makes and serves calls on a channel of a network.
+// It is safe for concurrent use.
+type Endpoint struct {
+	logger  zerolog.Logger
+	channel net.Channel
+	conduit net.Conduit
+	timeout time.Duration
+	nextID  atomic.Uint64
+	serving *bounded.Group
+
+	l        sync.RWMutex
+	pending  map[callKey]chan Envelope
+	handlers map[reflect.Type]handlerFunc
+}
+
+var _ net.MessageProcessor = (*Endpoint)(nil)
+
+// Option is a functional option for configuring an Endpoint.
+type Option func(*Endpoint)
+
+// WithTimeout sets the timeout of calls whose context has no deadline, and of handlers; defaults to DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *Endpoint) {
+		e.timeout = timeout
+	}
+}
+
+// WithMaxConcurrentRequests sets the number of requests the endpoint serves at a time; defaults to
+// DefaultMaxConcurrentRequests. The requests received while the endpoint serves that many are answered with ErrBusy.
+func WithMaxConcurrentRequests(max int) Option {
+	return func(e *Endpoint) {
+		e.serving = bounded.NewGroup(max)
+	}
+}
+
+// NewEndpoint creates an Endpoint and registers it as the processor of the channel.
+// The envelopes of the endpoint must be registered in the codec registry of the network (see RegisterCodec).
+// Args:
+//   - logger: zerolog.Logger for logging
+//   - network: the network to make and serve calls through
+//   - channel: the channel of the calls; the endpoint is its only processor
+//   - opts: variadic options for configuring the endpoint
+//
+// Returns the endpoint, or the error of registering it on the channel, which must be treated as fatal.
+func NewEndpoint(logger zerolog.Logger, network net.Network, channel net.Channel, opts ...Option) (*Endpoint, error) {
+	e := &Endpoint{
+		logger: logger.With().
+			Str("component", "rpc").
+			Str("channel", string(channel)).
+			Logger(),
+		channel:  channel,
+		timeout:  DefaultTimeout,
+		serving:  bounded.NewGroup(DefaultMaxConcurrentRequests),
+		pending:  make(map[callKey]chan Envelope),
+		handlers: make(map[reflect.Type]handlerFunc),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	conduit, err := network.Register(channel, e)
+	if err != nil {
+		return nil, fmt.Errorf("could not register rpc endpoint: %w", err)
+	}
+	e.conduit = conduit
+	return e, nil
+}
+
+// Handle registers the handler serving requests of type Req received by the endpoint.
+// The handler is called with a context bounded by the timeout of the endpoint; its result, or its error, is sent back
+// to the caller. The type of a non-nil result must be registered in the codec registry of the network, while a nil
+// result is delivered to the caller as is.
+// Returns an error wrapping ErrHandlerRegistered if a handler is already registered for Req.
+func Handle[Req any](e *Endpoint, handler func(ctx context.Context, origin model.Identifier, request Req) (any, error)) error {
+	typ := reflect.TypeFor[Req]()
+
+	e.l.Lock()
+	defer e.l.Unlock()
+	if _, ok := e.handlers[typ]; ok {
+		return fmt.Errorf("%w: %s", ErrHandlerRegistered, typ)
+	}
+	e.handlers[typ] = func(ctx context.Context, origin model.Identifier, request any) (any, error) {
+		return handler(ctx, origin, request.(Req))
+	}
+	return nil
+}
+
+// Call sends the request to the target and waits for its response.
+// If ctx has no deadline, the call is bounded by the timeout of the endpoint.
+// Returns the response, or an error:
+//   - wrapping ErrCallTimeout if no response is received before the deadline, or ctx is canceled.
+//   - wrapping ErrRemote if the target answers with an error; the error also wraps ErrNoHandler if the target has no
+//     handler for the request type, or ErrBusy if it is busy.
+//   - the error of sending the request.
+//
+// Any returned error is benign.
+func (e *Endpoint) Call(ctx context.Context, target model.Identifier, request any) (any, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	key := callKey{target: target, callID: e.nextID.Add(1)}
+	responses := make(chan Envelope, 1)
+	e.l.Lock()
+	e.pending[key] = responses
+	e.l.Unlock()
+	defer func() {
+		e.l.Lock()
+		delete(e.pending, key)
+		e.l.Unlock()
+	}()
+
+	err := e.conduit.Send(target, net.Message{Payload: Envelope{callID: key.callID, kind: kindRequest, payload: request}})
+	if err != nil {
+		return nil, fmt.Errorf("could not send request of call %d: %w", key.callID, err)
+	}
+
+	select {
+	case res := <-responses:
+		if res.kind == kindError {
+			return nil, fmt.Errorf("%w: %w", ErrRemote, remoteError{code: res.code, msg: res.err})
+		}
+		return res.payload, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: call %d to %s: %w", ErrCallTimeout, key.callID, target.String(), ctx.Err())
+	}
+}
+
+// ProcessIncomingMessage serves the requests and delivers the responses received on the channel of the endpoint.
+// Requests are served asynchronously, up to the maximum number of concurrent requests of the endpoint, beyond which they
+// are answered with ErrBusy; messages other than envelopes and responses to unknown calls, e.g., that timed out, are
+// logged and dropped.
+func (e *Endpoint) ProcessIncomingMessage(_ net.Channel, originID model.Identifier, msg net.Message) {
+	env, ok := msg.Payload.(Envelope)
+	if !ok {
+		e.logger.Warn().
+			Str("origin", originID.String()).
+			Str("type", fmt.Sprintf("%T", msg.Payload)).
+			Msg("Dropping message that is not an rpc envelope")
+		return
+	}
+
+	switch env.kind {
+	case kindRequest:
+		if !e.serving.Go(func() { e.serve(originID, env) }) {
+			e.logger.Debug().Str("origin", originID.String()).Uint64("call_id", env.callID).Msg("Rejecting request, busy")
+			e.respond(originID, Envelope{callID: env.callID, kind: kindError, code: codeBusy, err: ErrBusy.Error()})
+		}
+	default:
+		e.l.RLock()
+		responses, ok := e.pending[callKey{target: originID, callID: env.callID}]
+		e.l.RUnlock()
+		if !ok {
+			e.logger.Debug().
+				Str("origin", originID.String()).
+				Uint64("call_id", env.callID).
+				Msg("Dropping response to unknown call")
+			return
+		}
+		select {
+		case responses <- env:
+		default:
+			// a response has already been delivered for this call
+		}
+	}
+}
+
+// serve runs the handler of the request and sends its result back to the origin.
+func (e *Endpoint) serve(origin model.Identifier, req Envelope) {
+	res := Envelope{callID: req.callID, kind: kindResponse}
+	payload, err := e.handle(origin, req.payload)
+	if err != nil {
+		res.kind, res.code, res.err = kindError, errorCodeOf(err), err.Error()
+	} else {
+		res.payload = payload
+	}
+
+	if res.kind == kindError {
+		e.respond(origin, res)
+		return
+	}
+	if err := e.conduit.Send(origin, net.Message{Payload: res}); err != nil {
+		// the result may not be encodable, in which case the caller is told so rather than left waiting
+		e.respond(origin, Envelope{callID: req.callID, kind: kindError, err: fmt.Sprintf("could not send response: %v", err)})
+	}
+}
+
+// respond sends the error response to the origin, logging the failure to send it.
+func (e *Endpoint) respond(origin model.Identifier, res Envelope) {
+	if err := e.conduit.Send(origin, net.Message{Payload: res}); err != nil {
+		e.logger.Warn().Err(err).Str("origin", origin.String()).Uint64("call_id", res.callID).Msg("Could not send response")
+	}
+}
+
+// handle runs the handler registered for the type of the request, bounded by the timeout of the endpoint.
+// A panicking handler is recovered and reported as an error.
+func (e *Endpoint) handle(origin model.Identifier, request any) (res any, err error) {
+	e.l.RLock()
+	handler, ok := e.handlers[reflect.TypeOf(request)]
+	e.l.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNoHandler, request)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			e.logger.Error().Str("origin", origin.String()).Interface("panic", r).Msg("Handler panicked")
+			res, err = nil, errors.New("handler failed")
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	return handler(ctx, origin, request)
+}
@@ -0,0 +1,247 @@
+package rpc_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/codec"
+	"github.com/thep2p/skipgraph-go/net/protocol"
+	"github.com/thep2p/skipgraph-go/net/rpc"
+	"github.com/thep2p/skipgraph-go/unittest"
+	"github.com/thep2p/skipgraph-go/unittest/mocknet"
+)
+
+// endpointPair returns two endpoints connected through a mock network, along with their identifiers.
+func endpointPair(t *testing.T, opts ...rpc.Option) (*rpc.Endpoint, *rpc.Endpoint, model.Identifier, model.Identifier) {
+	registry := codec.NewDefaultRegistry()
+	require.NoError(t, rpc.RegisterCodec(registry))
+	stub := mocknet.NewNetworkStubWithCodecs(registry)
+
+	id1 := unittest.IdentifierFixture(t)
+	id2 := unittest.IdentifierFixture(t)
+	e1, err := rpc.NewEndpoint(unittest.Logger(zerolog.WarnLevel), stub.NewMockNetwork(t, id1), net.TestChannel, opts...)
+	require.NoError(t, err)
+	e2, err := rpc.NewEndpoint(unittest.Logger(zerolog.WarnLevel), stub.NewMockNetwork(t, id2), net.TestChannel, opts...)
+	require.NoError(t, err)
+	return e1, e2, id1, id2
+}
+
+// TestEndpoint_Call tests that calls are served by the handler of their request type and answered to the caller.
+func TestEndpoint_Call(t *testing.T) {
+	e1, e2, id1, id2 := endpointPair(t)
+
+	require.NoError(
+		t, rpc.Handle(
+			e2, func(ctx context.Context, origin model.Identifier, ping protocol.Ping) (any, error) {
+				require.Equal(t, id1, origin)
+				return protocol.NewPong(ping.Nonce()), nil
+			},
+		),
+	)
+	require.NoError(
+		t, rpc.Handle(
+			e2, func(ctx context.Context, origin model.Identifier, b []byte) (any, error) {
+				return append([]byte("echo:"), b...), nil
+			},
+		),
+	)
+
+	res, err := e1.Call(context.Background(), id2, protocol.NewPing(42))
+	require.NoError(t, err)
+	require.Equal(t, protocol.NewPong(42), res)
+
+	res, err = e1.Call(context.Background(), id2, []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("echo:hello"), res)
+
+	// e1 has no handler
+	_, err = e2.Call(context.Background(), id1, protocol.NewPing(1))
+	require.True(t, errors.Is(err, rpc.ErrRemote))
+	require.True(t, errors.Is(err, rpc.ErrNoHandler), "unexpected error %v", err)
+}
+
+// TestEndpoint_NilResult tests that a nil result of a handler is answered to the caller as a nil response.
+func TestEndpoint_NilResult(t *testing.T) {
+	e1, e2, _, id2 := endpointPair(t)
+	require.NoError(
+		t, rpc.Handle(
+			e2, func(ctx context.Context, origin model.Identifier, ping protocol.Ping) (any, error) {
+				return nil, nil
+			},
+		),
+	)
+
+	res, err := e1.Call(context.Background(), id2, protocol.NewPing(7))
+	require.NoError(t, err)
+	require.Nil(t, res)
+}
+
+// TestEndpoint_Busy tests that the requests received while an endpoint serves its maximum number of requests are
+// answered with ErrBusy.
+func TestEndpoint_Busy(t *testing.T) {
+	e1, e2, _, id2 := endpointPair(t, rpc.WithMaxConcurrentRequests(1))
+	serving := make(chan interface{})
+	release := make(chan struct{})
+	require.NoError(
+		t, rpc.Handle(
+			e2, func(ctx context.Context, origin model.Identifier, b []byte) (any, error) {
+				close(serving)
+				<-release
+				return b, nil
+			},
+		),
+	)
+
+	done := make(chan interface{})
+	go func() {
+		defer close(done)
+		res, err := e1.Call(context.Background(), id2, []byte("first"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("first"), res)
+	}()
+	unittest.ChannelMustCloseWithinTimeout(t, serving, time.Second, "request not served on time")
+
+	_, err := e1.Call(context.Background(), id2, []byte("second"))
+	require.True(t, errors.Is(err, rpc.ErrRemote))
+	require.True(t, errors.Is(err, rpc.ErrBusy), "unexpected error %v", err)
+
+	close(release)
+	unittest.ChannelMustCloseWithinTimeout(t, done, time.Second, "call did not return on time")
+}
+
+// TestEndpoint_ConcurrentCalls tests that concurrent calls are correlated with their own responses.
+func TestEndpoint_ConcurrentCalls(t *testing.T) {
+	e1, e2, _, id2 := endpointPair(t)
+	require.NoError(
+		t, rpc.Handle(
+			e2, func(ctx context.Context, origin model.Identifier, b []byte) (any, error) {
+				// answers out of order
+				time.Sleep(time.Duration(b[7]%5) * time.Millisecond)
+				return b, nil
+			},
+		),
+	)
+
+	const count = 50
+	wg := sync.WaitGroup{}
+	wg.Add(count)
+	for i := 0; i < count; i++ {
+		go func(i uint64) {
+			defer wg.Done()
+			req := binary.BigEndian.AppendUint64(nil, i)
+			res, err := e1.Call(context.Background(), id2, req)
+			require.NoError(t, err)
+			require.Equal(t, req, res)
+		}(uint64(i))
+	}
+	unittest.CallMustReturnWithinTimeout(t, wg.Wait, time.Second, "calls did not return on time")
+}
+
+// TestEndpoint_HandlerErrors tests that handler errors and panics are reported to the caller.
+func TestEndpoint_HandlerErrors(t *testing.T) {
+	e1, e2, _, id2 := endpointPair(t)
+	require.NoError(
+		t, rpc.Handle(
+			e2, func(ctx context.Context, origin model.Identifier, ping protocol.Ping) (any, error) {
+				return nil, fmt.Errorf("nonce %d rejected", ping.Nonce())
+			},
+		),
+	)
+	require.NoError(
+		t, rpc.Handle(
+			e2, func(ctx context.Context, origin model.Identifier, pong protocol.Pong) (any, error) {
+				panic("boom")
+			},
+		),
+	)
+	require.NoError(
+		t, rpc.Handle(
+			e2, func(ctx context.Context, origin model.Identifier, b []byte) (any, error) {
+				// the response cannot be encoded
+				return struct{}{}, nil
+			},
+		),
+	)
+
+	_, err := e1.Call(context.Background(), id2, protocol.NewPing(7))
+	require.True(t, errors.Is(err, rpc.ErrRemote))
+	require.Contains(t, err.Error(), "nonce 7 rejected")
+	require.False(t, errors.Is(err, rpc.ErrNoHandler))
+	require.False(t, errors.Is(err, rpc.ErrBusy))
+
+	_, err = e1.Call(context.Background(), id2, protocol.NewPong(7))
+	require.True(t, errors.Is(err, rpc.ErrRemote))
+
+	_, err = e1.Call(context.Background(), id2, []byte{1})
+	require.True(t, errors.Is(err, rpc.ErrRemote))
+
+	// the request cannot be encoded
+	_, err = e1.Call(context.Background(), id2, struct{}{})
+	require.True(t, errors.Is(err, codec.ErrUnknownType))
+}
+
+// TestEndpoint_Timeout tests that calls whose response is not received on time fail with ErrCallTimeout.
+func TestEndpoint_Timeout(t *testing.T) {
+	e1, e2, _, id2 := endpointPair(t, rpc.WithTimeout(100*time.Millisecond))
+	release := make(chan struct{})
+	defer close(release)
+	require.NoError(
+		t, rpc.Handle(
+			e2, func(ctx context.Context, origin model.Identifier, b []byte) (any, error) {
+				<-release
+				return b, nil
+			},
+		),
+	)
+
+	// bounded by the timeout of the endpoint
+	start := time.Now()
+	_, err := e1.Call(context.Background(), id2, []byte{1})
+	require.True(t, errors.Is(err, rpc.ErrCallTimeout))
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Less(t, time.Since(start), time.Second)
+
+	// bounded by the context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = e1.Call(ctx, id2, []byte{1})
+	require.True(t, errors.Is(err, rpc.ErrCallTimeout))
+}
+
+// TestHandle_Duplicate tests that a request type can only have one handler.
+func TestHandle_Duplicate(t *testing.T) {
+	e1, _, _, _ := endpointPair(t)
+	handler := func(ctx context.Context, origin model.Identifier, b []byte) (any, error) {
+		return b, nil
+	}
+	require.NoError(t, rpc.Handle(e1, handler))
+	require.True(t, errors.Is(rpc.Handle(e1, handler), rpc.ErrHandlerRegistered))
+}
+
+// TestEnvelope_Malformed tests that malformed envelopes are rejected by the codec registry.
+func TestEnvelope_Malformed(t *testing.T) {
+	registry := codec.NewDefaultRegistry()
+	require.NoError(t, rpc.RegisterCodec(registry))
+
+	code := binary.BigEndian.AppendUint16(nil, uint16(rpc.CodeEnvelope))
+	malformed := [][]byte{
+		{0, 0, 0, 0},                              // too short
+		{0, 0, 0, 0, 0, 0, 0, 1, 9},               // unknown kind
+		{0, 0, 0, 0, 0, 0, 0, 1, 3, 0},            // truncated error
+		{0, 0, 0, 0, 0, 0, 0, 1, 3, 9, 0, 0},      // unknown error code
+		{0, 0, 0, 0, 0, 0, 0, 1, 3, 0, 0, 5, 'a'}, // error shorter than announced
+	}
+	for _, b := range malformed {
+		_, err := registry.Decode(append(append([]byte{}, code...), b...))
+		require.True(t, errors.Is(err, rpc.ErrMalformedEnvelope), "unexpected error %v", err)
+	}
+}
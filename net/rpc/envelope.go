@@ -0,0 +1,176 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/thep2p/skipgraph-go/net/codec"
+)
+
+// CodeEnvelope is the codec type code of Envelope.
+const CodeEnvelope codec.Code = 0x200
+
+// MaxErrorSize is the maximum size in bytes of the error message carried by an error response; longer messages are
+// truncated.
+const MaxErrorSize = 1024
+
+// envelopeKind identifies the kind of an Envelope.
+type envelopeKind uint8
+
+const (
+	// kindRequest carries a request.
+	kindRequest envelopeKind = 1
+	// kindResponse carries the response to a request.
+	kindResponse envelopeKind = 2
+	// kindError carries the error answering a request.
+	kindError envelopeKind = 3
+)
+
+// errorCode identifies the sentinel error carried by an error response, if any.
+type errorCode uint8
+
+const (
+	// codeUnspecified carries any other error, e.g., returned by a handler.
+	codeUnspecified errorCode = 0
+	// codeNoHandler carries ErrNoHandler.
+	codeNoHandler errorCode = 1
+	// codeBusy carries ErrBusy.
+	codeBusy errorCode = 2
+)
+
+// errorCodeOf returns the code of the sentinel error wrapped by err, or codeUnspecified if there is none.
+func errorCodeOf(err error) errorCode {
+	switch {
+	case errors.Is(err, ErrNoHandler):
+		return codeNoHandler
+	case errors.Is(err, ErrBusy):
+		return codeBusy
+	default:
+		return codeUnspecified
+	}
+}
+
+// err returns the sentinel error of the code, or nil for codeUnspecified.
+func (c errorCode) err() error {
+	switch c {
+	case codeNoHandler:
+		return ErrNoHandler
+	case codeBusy:
+		return ErrBusy
+	default:
+		return nil
+	}
+}
+
+// remoteError is the error answering a call, as sent by its target.
+type remoteError struct {
+	code errorCode
+	msg  string
+}
+
+func (e remoteError) Error() string {
+	return e.msg
+}
+
+// Is reports whether target is the sentinel error of the code of the error, so that callers can match the errors of
+// the target with errors.Is.
+func (e remoteError) Is(target error) bool {
+	sentinel := e.code.err()
+	return sentinel != nil && target == sentinel
+}
+
+// Envelope wraps the requests and responses of calls with their correlation identifier.
+// Envelopes are created by Endpoint; they must be registered in the codec registry of the network with RegisterCodec.
+//
+// Wire layout: call id (8) | kind (1) | payload (encoded by the registry) or, for errors, code (1) | message length
+// (2) | message. The payload of a response is empty if the response is nil.
+type Envelope struct {
+	callID  uint64       // correlates a response with its request
+	kind    envelopeKind // request, response or error
+	payload any          // the request or response
+	code    errorCode    // the sentinel error of an error response, if any
+	err     string       // the error message of an error response
+}
+
+// RegisterCodec registers Envelope in the registry; the payloads of envelopes are encoded by the same registry.
+// Returns an error wrapping codec.ErrCodeRegistered or codec.ErrTypeRegistered if it is already registered.
+func RegisterCodec(r *codec.Registry) error {
+	return codec.Register(
+		r, CodeEnvelope,
+		func(e Envelope) ([]byte, error) {
+			return encodeEnvelope(r, e)
+		},
+		func(b []byte) (Envelope, error) {
+			return decodeEnvelope(r, b)
+		},
+	)
+}
+
+// encodeEnvelope returns the encoding of the envelope, with its payload encoded by the registry.
+func encodeEnvelope(r *codec.Registry, e Envelope) ([]byte, error) {
+	b := binary.BigEndian.AppendUint64(nil, e.callID)
+	b = append(b, byte(e.kind))
+
+	if e.kind == kindError {
+		msg := e.err
+		if len(msg) > MaxErrorSize {
+			msg = msg[:MaxErrorSize]
+		}
+		b = append(b, byte(e.code))
+		b = binary.BigEndian.AppendUint16(b, uint16(len(msg)))
+		return append(b, msg...), nil
+	}
+
+	if e.kind == kindResponse && e.payload == nil {
+		return b, nil
+	}
+	payload, err := r.Encode(e.payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode payload of call %d: %w", e.callID, err)
+	}
+	return append(b, payload...), nil
+}
+
+// decodeEnvelope returns the envelope encoded in b, with its payload decoded by the registry.
+// Returns an error wrapping ErrMalformedEnvelope if b is malformed, or the decoding error of the payload.
+func decodeEnvelope(r *codec.Registry, b []byte) (Envelope, error) {
+	if len(b) < 9 {
+		return Envelope{}, fmt.Errorf("%w: envelope of %d bytes is too short", ErrMalformedEnvelope, len(b))
+	}
+	e := Envelope{
+		callID: binary.BigEndian.Uint64(b),
+		kind:   envelopeKind(b[8]),
+	}
+	b = b[9:]
+
+	switch e.kind {
+	case kindResponse:
+		if len(b) == 0 {
+			break
+		}
+		fallthrough
+	case kindRequest:
+		payload, err := r.Decode(b)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("could not decode payload of call %d: %w", e.callID, err)
+		}
+		e.payload = payload
+	case kindError:
+		if len(b) < 3 {
+			return Envelope{}, fmt.Errorf("%w: truncated error", ErrMalformedEnvelope)
+		}
+		e.code = errorCode(b[0])
+		if e.code > codeBusy {
+			return Envelope{}, fmt.Errorf("%w: unknown error code %d", ErrMalformedEnvelope, e.code)
+		}
+		size := int(binary.BigEndian.Uint16(b[1:]))
+		if size > MaxErrorSize || len(b) != 3+size {
+			return Envelope{}, fmt.Errorf("%w: invalid error of %d bytes", ErrMalformedEnvelope, len(b)-3)
+		}
+		e.err = string(b[3:])
+	default:
+		return Envelope{}, fmt.Errorf("%w: unknown kind %d", ErrMalformedEnvelope, e.kind)
+	}
+	return e, nil
+}
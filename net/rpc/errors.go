@@ -0,0 +1,21 @@
+package rpc
+
+import "errors"
+
+// ErrCallTimeout is returned when a call does not receive its response before its deadline.
+var ErrCallTimeout = errors.New("call timed out")
+
+// ErrRemote is returned when the target of a call answers with an error.
+var ErrRemote = errors.New("remote error")
+
+// ErrNoHandler is returned by the target of a call when no handler is registered for the type of the request.
+var ErrNoHandler = errors.New("no handler registered for request type")
+
+// ErrHandlerRegistered is returned when registering a handler for a request type that already has one.
+var ErrHandlerRegistered = errors.New("handler already registered for request type")
+
+// ErrMalformedEnvelope is returned when a received envelope cannot be decoded.
+var ErrMalformedEnvelope = errors.New("malformed rpc envelope")
+
+// ErrBusy is returned by the target of a call when it serves its maximum number of concurrent requests already.
+var ErrBusy = errors.New("endpoint busy")
@@ -0,0 +1,109 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/thep2p/skipgraph-go/core/crypto"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net/codec"
+)
+
+// CodeEnvelope is the codec type code of Envelope.
+const CodeEnvelope codec.Code = 0x500
+
+// headerSize is the size of the fields of an envelope preceding its payload.
+const headerSize = ed25519.PublicKeySize + model.IdentifierSizeBytes + 8 + 8 + ed25519.SignatureSize
+
+// domain separates the signatures of envelopes from the signatures of other data by the same keys.
+const domain = "skipgraph/signed-envelope/v1"
+
+// Envelope is a message payload signed by the key of its origin for a single target.
+// Envelopes are created by Signer and opened by Verifier; they must be registered in the codec registry of the network
+// with RegisterCodec.
+//
+// Wire layout: origin key (32) | target (32) | nonce (8) | timestamp (8, unix nanoseconds) | signature (64) | payload
+// (encoded by the registry). The signature covers every other field, the payload as encoded.
+type Envelope struct {
+	key       ed25519.PublicKey // the key of the origin, which its identifier is bound to
+	target    model.Identifier  // the node the envelope is signed for
+	nonce     uint64            // strictly increasing per origin
+	timestamp int64             // unix nanoseconds at signing
+	signature []byte
+	payload   any    // the signed payload
+	encoded   []byte // the encoding of the payload, as signed
+}
+
+// Origin returns the identifier bound to the key that signed the envelope; it is only authenticated once the
+// envelope is verified.
+func (e Envelope) Origin() model.Identifier {
+	return crypto.IdentifierOf(e.key)
+}
+
+// RegisterCodec registers Envelope in the registry; the payloads of envelopes are encoded by the same registry.
+// Returns an error wrapping codec.ErrCodeRegistered or codec.ErrTypeRegistered if it is already registered.
+func RegisterCodec(r *codec.Registry) error {
+	return codec.Register(
+		r, CodeEnvelope,
+		func(e Envelope) ([]byte, error) {
+			return encodeEnvelope(e)
+		},
+		func(b []byte) (Envelope, error) {
+			return decodeEnvelope(r, b)
+		},
+	)
+}
+
+// signedBytes returns the bytes covered by the signature of the envelope.
+func (e Envelope) signedBytes() []byte {
+	b := make([]byte, 0, len(domain)+headerSize-ed25519.SignatureSize+len(e.encoded))
+	b = append(b, domain...)
+	b = append(b, e.key...)
+	b = append(b, e.target[:]...)
+	b = binary.BigEndian.AppendUint64(b, e.nonce)
+	b = binary.BigEndian.AppendUint64(b, uint64(e.timestamp))
+	return append(b, e.encoded...)
+}
+
+// encodeEnvelope returns the encoding of the envelope, with its payload as signed.
+// Returns an error if the envelope was not created by a Signer.
+func encodeEnvelope(e Envelope) ([]byte, error) {
+	if len(e.key) != ed25519.PublicKeySize || len(e.signature) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("envelope is not signed")
+	}
+	b := make([]byte, 0, headerSize+len(e.encoded))
+	b = append(b, e.key...)
+	b = append(b, e.target[:]...)
+	b = binary.BigEndian.AppendUint64(b, e.nonce)
+	b = binary.BigEndian.AppendUint64(b, uint64(e.timestamp))
+	b = append(b, e.signature...)
+	return append(b, e.encoded...), nil
+}
+
+// decodeEnvelope returns the envelope encoded in b, with its payload decoded by the registry; the signature is not
+// verified.
+// Returns an error wrapping ErrMalformedEnvelope if b is malformed, or the decoding error of the payload.
+func decodeEnvelope(r *codec.Registry, b []byte) (Envelope, error) {
+	if len(b) < headerSize {
+		return Envelope{}, fmt.Errorf("%w: envelope of %d bytes is too short", ErrMalformedEnvelope, len(b))
+	}
+	// the fields of the envelope outlive b, which may be reused by the caller
+	b = bytes.Clone(b)
+	e := Envelope{key: ed25519.PublicKey(b[:ed25519.PublicKeySize])}
+	b = b[ed25519.PublicKeySize:]
+	copy(e.target[:], b)
+	b = b[model.IdentifierSizeBytes:]
+	e.nonce = binary.BigEndian.Uint64(b)
+	e.timestamp = int64(binary.BigEndian.Uint64(b[8:]))
+	e.signature = b[16 : 16+ed25519.SignatureSize]
+	e.encoded = b[16+ed25519.SignatureSize:]
+
+	payload, err := r.Decode(e.encoded)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("could not decode payload of envelope %d: %w", e.nonce, err)
+	}
+	e.payload = payload
+	return e, nil
+}
@@ -0,0 +1,26 @@
+package signing
+
+import "errors"
+
+// ErrMalformedEnvelope is returned when a received envelope cannot be decoded.
+var ErrMalformedEnvelope = errors.New("malformed signed envelope")
+
+// ErrInvalidSignature is returned when the signature of an envelope does not verify against its origin key.
+var ErrInvalidSignature = errors.New("invalid envelope signature")
+
+// ErrWrongTarget is returned when an envelope is signed for another target than the verifying node.
+var ErrWrongTarget = errors.New("envelope signed for another target")
+
+// ErrExpired is returned when the timestamp of an envelope is too far from the clock of the verifying node.
+var ErrExpired = errors.New("envelope timestamp outside of the clock skew window")
+
+// ErrReplayed is returned when the nonce of an envelope was already seen from its origin, or is too old to be told
+// apart from a replay.
+var ErrReplayed = errors.New("envelope replayed")
+
+// ErrTooManyOrigins is returned when an envelope comes from a new origin while the verifier tracks as many origins as
+// it can, none of which can be forgotten yet.
+var ErrTooManyOrigins = errors.New("too many origins tracked")
+
+// ErrOriginMismatch is returned when the origin of an envelope differs from the origin reported by the network.
+var ErrOriginMismatch = errors.New("envelope origin mismatch")
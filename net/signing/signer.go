@@ -0,0 +1,97 @@
+// Package signing implements end-to-end signed envelopes around message payloads, with replay protection.
+//
+// A secured connection only authenticates the node at its other end, whereas messages relayed through intermediate
+// nodes, e.g., by an overlay.Router, may be altered or forged by any of them. A Signer wraps the payloads sent by a
+// node in an Envelope signed by the identity key of the node (see crypto.IdentifierOf) for a single target, along with
+// a strictly increasing nonce and a timestamp. The Verifier of the target checks the signature, that the timestamp is
+// within the clock skew window, and that the nonce was not seen before from the same origin, within a sliding window
+// of ReplayWindow nonces.
+//
+// Signing is optional, and enabled per channel by sending through Signer.Conduit and receiving through
+// Verifier.Processor, which drops the messages that are not signed envelopes.
+package signing
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/thep2p/skipgraph-go/core/crypto"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/codec"
+)
+
+// Signer signs the payloads of messages with the identity key of a node.
+// It is safe for concurrent use.
+type Signer struct {
+	key      ed25519.PrivateKey
+	origin   model.Identifier
+	registry *codec.Registry
+	// the last nonce used; nonces start at the current time in nanoseconds, so that they keep increasing across
+	// restarts of the node
+	nonce atomic.Uint64
+}
+
+// NewSigner creates a Signer.
+// Args:
+//   - key: the identity key of the node
+//   - registry: the codec registry of the network, which encodes the payloads to sign
+//
+// Returns the signer, or an error wrapping crypto.ErrInvalidKey if the key is malformed.
+func NewSigner(key ed25519.PrivateKey, registry *codec.Registry) (*Signer, error) {
+	origin, err := crypto.IdentifierOfKey(key)
+	if err != nil {
+		return nil, err
+	}
+	s := &Signer{key: key, origin: origin, registry: registry}
+	s.nonce.Store(uint64(time.Now().UnixNano()))
+	return s, nil
+}
+
+// Origin returns the identifier bound to the key of the signer.
+func (s *Signer) Origin() model.Identifier {
+	return s.origin
+}
+
+// Sign wraps the payload of the message in an envelope signed for the target.
+// Returns the message carrying the envelope, or an error wrapping codec.ErrUnknownType if the type of the payload is
+// not registered.
+func (s *Signer) Sign(target model.Identifier, msg net.Message) (net.Message, error) {
+	encoded, err := s.registry.Encode(msg.Payload)
+	if err != nil {
+		return net.Message{}, fmt.Errorf("could not encode payload: %w", err)
+	}
+	e := Envelope{
+		key:       s.key.Public().(ed25519.PublicKey),
+		target:    target,
+		nonce:     s.nonce.Add(1),
+		timestamp: time.Now().UnixNano(),
+		payload:   msg.Payload,
+		encoded:   encoded,
+	}
+	e.signature = ed25519.Sign(s.key, e.signedBytes())
+	return net.Message{Payload: e}, nil
+}
+
+// Conduit returns a conduit signing the messages it sends through conduit.
+func (s *Signer) Conduit(conduit net.Conduit) net.Conduit {
+	return &signingConduit{signer: s, conduit: conduit}
+}
+
+// signingConduit signs messages before sending them.
+type signingConduit struct {
+	signer  *Signer
+	conduit net.Conduit
+}
+
+// Send signs the message for the target and sends it.
+// Returns an error if the message cannot be signed, or the error of the underlying conduit.
+func (c *signingConduit) Send(target model.Identifier, msg net.Message) error {
+	signed, err := c.signer.Sign(target, msg)
+	if err != nil {
+		return err
+	}
+	return c.conduit.Send(target, signed)
+}
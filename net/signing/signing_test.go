@@ -0,0 +1,238 @@
+package signing_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/codec"
+	"github.com/thep2p/skipgraph-go/net/signing"
+	"github.com/thep2p/skipgraph-go/unittest"
+	"github.com/thep2p/skipgraph-go/unittest/mocknet"
+)
+
+// registry returns a default codec registry with signed envelopes registered.
+func registry(t *testing.T) *codec.Registry {
+	r := codec.NewDefaultRegistry()
+	require.NoError(t, signing.RegisterCodec(r))
+	return r
+}
+
+// signer returns a signer with a fresh identity key.
+func signer(t *testing.T, r *codec.Registry) *signing.Signer {
+	key, _ := unittest.IdentityKeyFixture(t)
+	s, err := signing.NewSigner(key, r)
+	require.NoError(t, err)
+	return s
+}
+
+// transmit encodes the envelope carried by msg with the registry, lets tamper alter its encoding, and decodes it.
+func transmit(t *testing.T, r *codec.Registry, msg net.Message, tamper func(b []byte)) signing.Envelope {
+	b, err := r.Encode(msg.Payload)
+	require.NoError(t, err)
+	if tamper != nil {
+		tamper(b)
+	}
+	decoded, err := r.Decode(b)
+	require.NoError(t, err)
+	e, ok := decoded.(signing.Envelope)
+	require.True(t, ok)
+	return e
+}
+
+// TestVerifier_Verify tests that a signed envelope is verified by its target, which receives its payload from the
+// origin of the signer.
+func TestVerifier_Verify(t *testing.T) {
+	r := registry(t)
+	s := signer(t, r)
+	self := unittest.IdentifierFixture(t)
+	v := signing.NewVerifier(unittest.Logger(zerolog.Disabled), self)
+
+	msg := unittest.TestMessageFixture(t)
+	signed, err := s.Sign(self, *msg)
+	require.NoError(t, err)
+	e := transmit(t, r, signed, nil)
+	require.Equal(t, s.Origin(), e.Origin())
+
+	origin, verified, err := v.Verify(e)
+	require.NoError(t, err)
+	require.Equal(t, s.Origin(), origin)
+	require.Equal(t, msg.Payload, verified.Payload)
+	require.Equal(t, signing.Stats{Verified: 1}, v.Stats())
+}
+
+// TestVerifier_Forged tests that envelopes altered in transit, or signed for another target, are rejected.
+func TestVerifier_Forged(t *testing.T) {
+	r := registry(t)
+	s := signer(t, r)
+	self := unittest.IdentifierFixture(t)
+	v := signing.NewVerifier(unittest.Logger(zerolog.Disabled), self)
+
+	// flip a byte of the target, the nonce, the signature and the payload
+	for _, offset := range []int{2 + 32, 2 + 64, 2 + 80, -1} {
+		signed, err := s.Sign(self, *unittest.TestMessageFixture(t))
+		require.NoError(t, err)
+		e := transmit(
+			t, r, signed, func(b []byte) {
+				if offset < 0 {
+					offset = len(b) - 1
+				}
+				b[offset] ^= 0xff
+			},
+		)
+		_, _, err = v.Verify(e)
+		require.Error(t, err)
+		require.True(
+			t,
+			errors.Is(err, signing.ErrInvalidSignature) || errors.Is(err, signing.ErrWrongTarget),
+			"unexpected error %v", err,
+		)
+	}
+
+	signed, err := s.Sign(unittest.IdentifierFixture(t), *unittest.TestMessageFixture(t))
+	require.NoError(t, err)
+	_, _, err = v.Verify(transmit(t, r, signed, nil))
+	require.True(t, errors.Is(err, signing.ErrWrongTarget), "unexpected error %v", err)
+	require.Equal(t, signing.Stats{Rejected: 5}, v.Stats())
+}
+
+// TestVerifier_Replay tests that every nonce of an origin is accepted once, in any order within the replay window, and
+// that nonces older than the window are rejected.
+func TestVerifier_Replay(t *testing.T) {
+	r := registry(t)
+	s := signer(t, r)
+	self := unittest.IdentifierFixture(t)
+	v := signing.NewVerifier(unittest.Logger(zerolog.Disabled), self)
+
+	envelopes := make([]signing.Envelope, signing.ReplayWindow+2)
+	for i := range envelopes {
+		signed, err := s.Sign(self, *unittest.TestMessageFixture(t))
+		require.NoError(t, err)
+		envelopes[i] = transmit(t, r, signed, nil)
+	}
+
+	// the second envelope, then the last one, then the others in reverse order within the window
+	_, _, err := v.Verify(envelopes[1])
+	require.NoError(t, err)
+	last := len(envelopes) - 1
+	_, _, err = v.Verify(envelopes[last])
+	require.NoError(t, err)
+	for i := last - 1; i > last-signing.ReplayWindow; i-- {
+		if i == 1 {
+			continue
+		}
+		_, _, err = v.Verify(envelopes[i])
+		require.NoError(t, err, "envelope %d", i)
+	}
+
+	// every envelope is rejected the second time
+	for _, i := range []int{last, last - 1, 2} {
+		_, _, err = v.Verify(envelopes[i])
+		require.True(t, errors.Is(err, signing.ErrReplayed), "unexpected error %v", err)
+	}
+	// the first envelope is out of the window of the last one
+	_, _, err = v.Verify(envelopes[0])
+	require.True(t, errors.Is(err, signing.ErrReplayed), "unexpected error %v", err)
+}
+
+// TestVerifier_Expired tests that envelopes whose timestamp is too far from the clock of the verifier are rejected.
+func TestVerifier_Expired(t *testing.T) {
+	r := registry(t)
+	s := signer(t, r)
+	self := unittest.IdentifierFixture(t)
+
+	for _, offset := range []time.Duration{time.Minute, -time.Minute} {
+		v := signing.NewVerifier(
+			unittest.Logger(zerolog.Disabled),
+			self,
+			signing.WithClock(func() time.Time { return time.Now().Add(offset) }),
+		)
+		signed, err := s.Sign(self, *unittest.TestMessageFixture(t))
+		require.NoError(t, err)
+		_, _, err = v.Verify(transmit(t, r, signed, nil))
+		require.True(t, errors.Is(err, signing.ErrExpired), "unexpected error %v", err)
+	}
+}
+
+// TestVerifier_MaxOrigins tests that new origins are rejected once the verifier tracks as many origins as it can, until
+// the envelopes of tracked origins expire.
+func TestVerifier_MaxOrigins(t *testing.T) {
+	r := registry(t)
+	self := unittest.IdentifierFixture(t)
+	const skew = 50 * time.Millisecond
+	v := signing.NewVerifier(
+		unittest.Logger(zerolog.Disabled),
+		self,
+		signing.WithMaxOrigins(1),
+		signing.WithMaxClockSkew(skew),
+	)
+
+	verify := func(s *signing.Signer) error {
+		signed, err := s.Sign(self, *unittest.TestMessageFixture(t))
+		require.NoError(t, err)
+		_, _, err = v.Verify(transmit(t, r, signed, nil))
+		return err
+	}
+	first, second := signer(t, r), signer(t, r)
+	require.NoError(t, verify(first))
+	require.True(t, errors.Is(verify(second), signing.ErrTooManyOrigins))
+	require.NoError(t, verify(first))
+
+	time.Sleep(2 * skew)
+	require.NoError(t, verify(second))
+}
+
+// TestProcessor tests that signed messages sent through a signing conduit are delivered with their payload and origin,
+// and that unsigned messages and messages relayed on behalf of another origin are dropped.
+func TestProcessor(t *testing.T) {
+	r := registry(t)
+	stub := mocknet.NewNetworkStubWithCodecs(r)
+	s := signer(t, r)
+	target := unittest.IdentifierFixture(t)
+	relay := unittest.IdentifierFixture(t)
+
+	received := make(chan model.Identifier, 10)
+	v := signing.NewVerifier(unittest.Logger(zerolog.Disabled), target)
+	_, err := stub.NewMockNetwork(t, target).Register(
+		net.TestChannel,
+		v.Processor(
+			mocknet.NewMockMessageProcessor(
+				func(_ net.Channel, originID model.Identifier, msg net.Message) {
+					_, ok := msg.Payload.([]byte)
+					require.True(t, ok)
+					received <- originID
+				},
+			),
+		),
+	)
+	require.NoError(t, err)
+	noop := mocknet.NewMockMessageProcessor(func(net.Channel, model.Identifier, net.Message) {})
+	originConduit, err := stub.NewMockNetwork(t, s.Origin()).Register(net.TestChannel, noop)
+	require.NoError(t, err)
+	relayConduit, err := stub.NewMockNetwork(t, relay).Register(net.TestChannel, noop)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Conduit(originConduit).Send(target, *unittest.TestMessageFixture(t)))
+	select {
+	case origin := <-received:
+		require.Equal(t, s.Origin(), origin)
+	case <-time.After(unittest.DefaultReadyDoneTimeout):
+		require.Fail(t, "message not received on time")
+	}
+
+	// unsigned, then signed by another origin than the sender
+	require.NoError(t, relayConduit.Send(target, *unittest.TestMessageFixture(t)))
+	signed, err := s.Sign(target, *unittest.TestMessageFixture(t))
+	require.NoError(t, err)
+	require.NoError(t, relayConduit.Send(target, signed))
+	require.Eventually(
+		t, func() bool {
+			return v.Stats() == signing.Stats{Verified: 1, Rejected: 2}
+		}, unittest.DefaultReadyDoneTimeout, time.Millisecond,
+	)
+	require.Empty(t, received)
+}
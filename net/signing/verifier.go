@@ -0,0 +1,230 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net"
+)
+
+// DefaultMaxClockSkew is the default maximum difference between the timestamp of an envelope and the clock of the
+// verifying node.
+const DefaultMaxClockSkew = 30 * time.Second
+
+// DefaultMaxOrigins is the default number of origins whose replay window is tracked at once.
+const DefaultMaxOrigins = 10_000
+
+// ReplayWindow is the number of nonces below the highest nonce of an origin that are still accepted, once each, so that
+// envelopes reordered in transit are not mistaken for replays. Older nonces are rejected.
+const ReplayWindow = 64
+
+// Stats is a snapshot of the counters of a Verifier.
+type Stats struct {
+	Verified uint64 // envelopes verified
+	Rejected uint64 // envelopes rejected, for any reason
+}
+
+// window is the replay window of an origin.
+type window struct {
+	highest   uint64 // the highest nonce seen
+	seen      uint64 // bit i is set if nonce highest-i was seen
+	timestamp int64  // the latest timestamp seen, in unix nanoseconds
+}
+
+// accept marks the nonce as seen.
+// Returns false if it was already seen, or is older than the window.
+func (w *window) accept(nonce uint64) bool {
+	if nonce > w.highest {
+		shift := nonce - w.highest
+		if shift >= ReplayWindow {
+			w.seen = 0
+		} else {
+			w.seen <<= shift
+		}
+		w.seen |= 1
+		w.highest = nonce
+		return true
+	}
+	diff := w.highest - nonce
+	if diff >= ReplayWindow || w.seen&(1<<diff) != 0 {
+		return false
+	}
+	w.seen |= 1 << diff
+	return true
+}
+
+// Verifier verifies the envelopes received by a node.
+// It is safe for concurrent use.
+type Verifier struct {
+	logger     zerolog.Logger
+	self       model.Identifier
+	maxSkew    time.Duration
+	maxOrigins int
+	now        func() time.Time
+	verified   atomic.Uint64
+	rejected   atomic.Uint64
+
+	l       sync.Mutex
+	windows map[model.Identifier]*window
+}
+
+// Option is a functional option for configuring a Verifier.
+type Option func(*Verifier)
+
+// WithMaxClockSkew sets the maximum difference between the timestamp of an envelope and the clock of the verifier;
+// defaults to DefaultMaxClockSkew.
+func WithMaxClockSkew(skew time.Duration) Option {
+	return func(v *Verifier) {
+		v.maxSkew = skew
+	}
+}
+
+// WithMaxOrigins sets the number of origins whose replay window is tracked at once; defaults to DefaultMaxOrigins.
+func WithMaxOrigins(max int) Option {
+	return func(v *Verifier) {
+		v.maxOrigins = max
+	}
+}
+
+// WithClock sets the source of the current time of the verifier; defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(v *Verifier) {
+		v.now = now
+	}
+}
+
+// NewVerifier creates a Verifier.
+// Args:
+//   - logger: zerolog.Logger for logging
+//   - self: the identifier of the node, which envelopes must be signed for
+//   - opts: variadic options for configuring the verifier
+//
+// Returns initialized verifier.
+func NewVerifier(logger zerolog.Logger, self model.Identifier, opts ...Option) *Verifier {
+	v := &Verifier{
+		logger:     logger.With().Str("component", "signing").Logger(),
+		self:       self,
+		maxSkew:    DefaultMaxClockSkew,
+		maxOrigins: DefaultMaxOrigins,
+		now:        time.Now,
+		windows:    make(map[model.Identifier]*window),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Verify verifies the envelope, and marks its nonce as seen from its origin.
+// Returns the authenticated origin of the envelope and the message carrying its payload, or an error:
+//   - wrapping ErrWrongTarget if the envelope is signed for another node.
+//   - wrapping ErrInvalidSignature if the signature does not verify.
+//   - wrapping ErrExpired if the timestamp is outside of the clock skew window.
+//   - wrapping ErrReplayed if the nonce was already seen, or is older than the replay window.
+//   - wrapping ErrTooManyOrigins if the origin is new and no more origins can be tracked.
+func (v *Verifier) Verify(e Envelope) (model.Identifier, net.Message, error) {
+	origin, err := v.verify(e)
+	if err != nil {
+		v.rejected.Add(1)
+		return model.Identifier{}, net.Message{}, err
+	}
+	v.verified.Add(1)
+	return origin, net.Message{Payload: e.payload}, nil
+}
+
+// verify implements Verify, without counting.
+func (v *Verifier) verify(e Envelope) (model.Identifier, error) {
+	if e.target != v.self {
+		return model.Identifier{}, fmt.Errorf("%w: %s", ErrWrongTarget, e.target.String())
+	}
+	if len(e.key) != ed25519.PublicKeySize || !ed25519.Verify(e.key, e.signedBytes(), e.signature) {
+		return model.Identifier{}, ErrInvalidSignature
+	}
+	origin := e.Origin()
+
+	now := v.now()
+	skew := now.Sub(time.Unix(0, e.timestamp))
+	if skew > v.maxSkew || skew < -v.maxSkew {
+		return model.Identifier{}, fmt.Errorf("%w: %s from %s", ErrExpired, skew, origin.String())
+	}
+
+	v.l.Lock()
+	defer v.l.Unlock()
+	w, ok := v.windows[origin]
+	switch {
+	case !ok:
+		if len(v.windows) >= v.maxOrigins {
+			v.prune(now)
+		}
+		if len(v.windows) >= v.maxOrigins {
+			return model.Identifier{}, fmt.Errorf("%w: %d", ErrTooManyOrigins, len(v.windows))
+		}
+		// the first nonce of an origin is always accepted
+		w = &window{highest: e.nonce, seen: 1}
+		v.windows[origin] = w
+	case !w.accept(e.nonce):
+		return model.Identifier{}, fmt.Errorf("%w: nonce %d from %s", ErrReplayed, e.nonce, origin.String())
+	}
+	if e.timestamp > w.timestamp {
+		w.timestamp = e.timestamp
+	}
+	return origin, nil
+}
+
+// prune forgets the origins whose envelopes have all expired, as any replay of them is rejected by its timestamp.
+// The caller must hold the lock.
+func (v *Verifier) prune(now time.Time) {
+	for origin, w := range v.windows {
+		if now.Sub(time.Unix(0, w.timestamp)) > v.maxSkew {
+			delete(v.windows, origin)
+		}
+	}
+}
+
+// Stats returns a snapshot of the counters of the verifier.
+func (v *Verifier) Stats() Stats {
+	return Stats{
+		Verified: v.verified.Load(),
+		Rejected: v.rejected.Load(),
+	}
+}
+
+// Processor returns a processor that verifies the envelopes it receives before passing their payload on to next, with
+// their authenticated origin. Messages that are not valid envelopes, or whose origin is not the one reported by the
+// network, are logged and dropped.
+func (v *Verifier) Processor(next net.MessageProcessor) net.MessageProcessor {
+	return &verifyingProcessor{verifier: v, next: next}
+}
+
+// verifyingProcessor verifies envelopes before passing their payload on.
+type verifyingProcessor struct {
+	verifier *Verifier
+	next     net.MessageProcessor
+}
+
+// ProcessIncomingMessage verifies the envelope carried by the message and passes its payload on.
+func (p *verifyingProcessor) ProcessIncomingMessage(channel net.Channel, originID model.Identifier, msg net.Message) {
+	lg := p.verifier.logger.With().Str("channel", string(channel)).Str("origin", originID.String()).Logger()
+	e, ok := msg.Payload.(Envelope)
+	if !ok {
+		p.verifier.rejected.Add(1)
+		lg.Warn().Str("type", fmt.Sprintf("%T", msg.Payload)).Msg("Dropping message that is not a signed envelope")
+		return
+	}
+	if origin := e.Origin(); origin != originID {
+		p.verifier.rejected.Add(1)
+		lg.Warn().Err(fmt.Errorf("%w: signed by %s", ErrOriginMismatch, origin.String())).Msg("Dropping signed envelope")
+		return
+	}
+	origin, verified, err := p.verifier.Verify(e)
+	if err != nil {
+		lg.Warn().Err(err).Msg("Dropping signed envelope")
+		return
+	}
+	p.next.ProcessIncomingMessage(channel, origin, verified)
+}
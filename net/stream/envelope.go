@@ -0,0 +1,150 @@
+package stream
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/thep2p/skipgraph-go/net/codec"
+)
+
+// CodeEnvelope is the codec type code of Envelope.
+const CodeEnvelope codec.Code = 0x600
+
+// envelopeKind identifies the kind of an Envelope.
+type envelopeKind uint8
+
+const (
+	// kindOpen opens a stream, from its sender.
+	kindOpen envelopeKind = 1
+	// kindData carries a chunk of a stream, from its sender.
+	kindData envelopeKind = 2
+	// kindEnd closes a stream with its number of chunks, length and digest, from its sender.
+	kindEnd envelopeKind = 3
+	// kindCancel aborts a stream, from its sender.
+	kindCancel envelopeKind = 4
+	// kindCredit allows the sender of a stream to send more chunks, from its receiver.
+	kindCredit envelopeKind = 5
+	// kindComplete acknowledges a stream received in full and intact, from its receiver.
+	kindComplete envelopeKind = 6
+	// kindAbort aborts a stream, from its receiver, with a reason.
+	kindAbort envelopeKind = 7
+)
+
+// reason is the reason a receiver aborts a stream.
+type reason uint8
+
+const (
+	// reasonRejected is the receiver not reading the stream to its end, or having too many streams open.
+	reasonRejected reason = 1
+	// reasonIntegrity is the integrity check of the stream failing.
+	reasonIntegrity reason = 2
+	// reasonTooLarge is the stream exceeding the maximum stream size or the window of the receiver.
+	reasonTooLarge reason = 3
+	// reasonTimeout is the sender not making progress within the timeout of the receiver.
+	reasonTimeout reason = 4
+)
+
+// String returns the description of the reason.
+func (r reason) String() string {
+	switch r {
+	case reasonRejected:
+		return "rejected by receiver"
+	case reasonIntegrity:
+		return "integrity check failed at receiver"
+	case reasonTooLarge:
+		return "too large for receiver"
+	case reasonTimeout:
+		return "timed out at receiver"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(r))
+	}
+}
+
+// Envelope carries the chunks of streams and their control messages.
+// Envelopes are created by Streamer; they must be registered in the codec registry of the network with RegisterCodec.
+//
+// Wire layout: stream id (8) | kind (1) | body, where the body is, by kind: nothing for open, cancel and complete;
+// sequence number (8) | chunk for data; chunks (8) | length (8) | SHA-256 digest (32) for end; credits (4) for credit;
+// reason (1) for abort.
+type Envelope struct {
+	streamID uint64
+	kind     envelopeKind
+	seq      uint64 // the sequence number of a chunk, or the number of chunks of an ended stream
+	data     []byte // the chunk
+	length   uint64 // the length of an ended stream
+	digest   [sha256.Size]byte
+	credits  uint32
+	reason   reason
+}
+
+// RegisterCodec registers Envelope in the registry.
+// Returns an error wrapping codec.ErrCodeRegistered or codec.ErrTypeRegistered if it is already registered.
+func RegisterCodec(r *codec.Registry) error {
+	return codec.Register(r, CodeEnvelope, encodeEnvelope, decodeEnvelope)
+}
+
+// encodeEnvelope returns the encoding of the envelope.
+func encodeEnvelope(e Envelope) ([]byte, error) {
+	b := make([]byte, 0, 9+8+len(e.data))
+	b = binary.BigEndian.AppendUint64(b, e.streamID)
+	b = append(b, byte(e.kind))
+	switch e.kind {
+	case kindData:
+		b = binary.BigEndian.AppendUint64(b, e.seq)
+		b = append(b, e.data...)
+	case kindEnd:
+		b = binary.BigEndian.AppendUint64(b, e.seq)
+		b = binary.BigEndian.AppendUint64(b, e.length)
+		b = append(b, e.digest[:]...)
+	case kindCredit:
+		b = binary.BigEndian.AppendUint32(b, e.credits)
+	case kindAbort:
+		b = append(b, byte(e.reason))
+	}
+	return b, nil
+}
+
+// decodeEnvelope returns the envelope encoded in b.
+// Returns an error wrapping ErrMalformedEnvelope if b is malformed.
+func decodeEnvelope(b []byte) (Envelope, error) {
+	if len(b) < 9 {
+		return Envelope{}, fmt.Errorf("%w: envelope of %d bytes is too short", ErrMalformedEnvelope, len(b))
+	}
+	e := Envelope{streamID: binary.BigEndian.Uint64(b), kind: envelopeKind(b[8])}
+	b = b[9:]
+
+	var size int // the size of the body, or -1 if variable
+	switch e.kind {
+	case kindOpen, kindCancel, kindComplete:
+		size = 0
+	case kindData:
+		size = -1
+	case kindEnd:
+		size = 16 + sha256.Size
+	case kindCredit:
+		size = 4
+	case kindAbort:
+		size = 1
+	default:
+		return Envelope{}, fmt.Errorf("%w: unknown kind %d", ErrMalformedEnvelope, e.kind)
+	}
+	if (size >= 0 && len(b) != size) || (size < 0 && len(b) < 8) {
+		return Envelope{}, fmt.Errorf("%w: body of %d bytes for kind %d", ErrMalformedEnvelope, len(b), e.kind)
+	}
+
+	switch e.kind {
+	case kindData:
+		e.seq = binary.BigEndian.Uint64(b)
+		e.data = append([]byte(nil), b[8:]...)
+	case kindEnd:
+		e.seq = binary.BigEndian.Uint64(b)
+		e.length = binary.BigEndian.Uint64(b[8:])
+		copy(e.digest[:], b[16:])
+	case kindCredit:
+		e.credits = binary.BigEndian.Uint32(b)
+	case kindAbort:
+		e.reason = reason(b[0])
+	}
+	return e, nil
+}
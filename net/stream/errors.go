@@ -0,0 +1,23 @@
+package stream
+
+import "errors"
+
+// ErrMalformedEnvelope is returned when a received envelope cannot be decoded.
+var ErrMalformedEnvelope = errors.New("malformed stream envelope")
+
+// ErrInvalidChunkSize is returned when configuring a chunk size that is not positive or exceeds MaxChunkSize.
+var ErrInvalidChunkSize = errors.New("invalid chunk size")
+
+// ErrStreamAborted is returned when a stream is aborted by its other end, e.g., as its receiver did not read it all.
+var ErrStreamAborted = errors.New("stream aborted")
+
+// ErrStreamTimeout is returned when the other end of a stream does not make progress within the timeout.
+var ErrStreamTimeout = errors.New("stream timed out")
+
+// ErrIntegrity is returned when the chunks of a stream are missing, out of order, or do not match the length and
+// digest announced by its sender.
+var ErrIntegrity = errors.New("stream integrity check failed")
+
+// ErrStreamTooLarge is returned when a stream exceeds the maximum stream size of its receiver, or the receiver buffers
+// more chunks than its window allows.
+var ErrStreamTooLarge = errors.New("stream too large")
@@ -0,0 +1,191 @@
+package stream
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+	"time"
+)
+
+// incoming is a stream being received, read by its processor as an io.Reader.
+type incoming struct {
+	streamer *Streamer
+	key      streamKey
+	timer    *time.Timer // fails the stream when it does not make progress within the timeout
+
+	l        sync.Mutex
+	cond     *sync.Cond // signaled when a chunk arrives or the stream ends or fails
+	chunks   [][]byte   // chunks received and not read yet, the first one possibly partially read
+	next     uint64     // the sequence number of the next chunk
+	length   uint64     // the number of bytes received
+	hash     hash.Hash  // digest of the bytes received
+	consumed uint32     // chunks read and not credited to the sender yet
+	ended    bool       // the stream was received in full and intact
+	complete bool       // the stream was read to its end, and its completion acknowledged to the sender
+	err      error      // the failure of the stream, returned by Read
+}
+
+var _ io.Reader = (*incoming)(nil)
+
+// newIncoming returns a new incoming stream of the streamer.
+func newIncoming(s *Streamer, key streamKey) *incoming {
+	in := &incoming{streamer: s, key: key, hash: sha256.New()}
+	in.cond = sync.NewCond(&in.l)
+	in.timer = time.AfterFunc(
+		s.timeout, func() {
+			in.fail(fmt.Errorf("%w: stream %d from %s", ErrStreamTimeout, key.streamID, key.peer.String()), reasonTimeout)
+		},
+	)
+	return in
+}
+
+// push appends a chunk to the stream, checking that it is the next chunk and that it fits in the limits of the
+// streamer. Chunks of an ended or failed stream are ignored.
+func (in *incoming) push(seq uint64, data []byte) {
+	s := in.streamer
+	in.l.Lock()
+	if in.ended || in.err != nil {
+		in.l.Unlock()
+		return
+	}
+	var err error
+	var r reason
+	switch {
+	case seq != in.next:
+		err, r = fmt.Errorf("%w: expected chunk %d, got %d", ErrIntegrity, in.next, seq), reasonIntegrity
+	case len(data) > s.chunkSize:
+		err, r = fmt.Errorf("%w: chunk of %d bytes", ErrStreamTooLarge, len(data)), reasonTooLarge
+	case len(in.chunks) >= s.window:
+		err, r = fmt.Errorf("%w: more than %d chunks in flight", ErrStreamTooLarge, s.window), reasonTooLarge
+	case s.maxStreamSize > 0 && in.length+uint64(len(data)) > s.maxStreamSize:
+		err, r = fmt.Errorf("%w: exceeds %d bytes", ErrStreamTooLarge, s.maxStreamSize), reasonTooLarge
+	}
+	if err != nil {
+		in.l.Unlock()
+		in.fail(err, r)
+		return
+	}
+
+	in.chunks = append(in.chunks, data)
+	in.next++
+	in.length += uint64(len(data))
+	in.hash.Write(data)
+	in.timer.Reset(s.timeout)
+	in.cond.Broadcast()
+	in.l.Unlock()
+}
+
+// end ends the stream if it matches the number of chunks, length and digest announced by the sender; otherwise the
+// stream fails.
+func (in *incoming) end(chunks uint64, length uint64, digest [sha256.Size]byte) {
+	in.l.Lock()
+	if in.ended || in.err != nil {
+		in.l.Unlock()
+		return
+	}
+	if chunks != in.next || length != in.length || !bytes.Equal(digest[:], in.hash.Sum(nil)) {
+		in.l.Unlock()
+		in.fail(
+			fmt.Errorf("%w: stream %d from %s does not match its digest", ErrIntegrity, in.key.streamID, in.key.peer.String()),
+			reasonIntegrity,
+		)
+		return
+	}
+	in.ended = true
+	in.timer.Stop()
+	in.cond.Broadcast()
+	in.l.Unlock()
+}
+
+// completed acknowledges the completion of the stream to the sender, once it is read to its end.
+// The caller must hold the lock.
+// Returns true if the completion must be sent, i.e., the stream was not acknowledged yet.
+func (in *incoming) completed() bool {
+	if !in.ended || len(in.chunks) > 0 || in.complete {
+		return false
+	}
+	in.complete = true
+	return true
+}
+
+// fail fails the stream with err, which is returned by the next Read, and aborts it at the sender with the reason,
+// unless it is zero. Only the first failure of a stream is kept, and a stream acknowledged as complete cannot fail.
+func (in *incoming) fail(err error, r reason) {
+	in.l.Lock()
+	if in.complete || in.err != nil {
+		in.l.Unlock()
+		return
+	}
+	in.err = err
+	in.chunks = nil
+	in.timer.Stop()
+	in.cond.Broadcast()
+	in.l.Unlock()
+
+	in.streamer.logger.Debug().Err(err).Str("origin", in.key.peer.String()).Msg("Stream failed")
+	if r != 0 {
+		in.streamer.send(in.key.peer, Envelope{streamID: in.key.streamID, kind: kindAbort, reason: r})
+	}
+}
+
+// close aborts the stream unless it was read to its end, once its processor returns.
+func (in *incoming) close() {
+	in.l.Lock()
+	done := in.ended && len(in.chunks) == 0
+	complete := in.completed()
+	in.l.Unlock()
+	if complete {
+		in.streamer.send(in.key.peer, Envelope{streamID: in.key.streamID, kind: kindComplete})
+	}
+	if done {
+		return
+	}
+	in.fail(fmt.Errorf("%w: stream %d not read to its end", ErrStreamAborted, in.key.streamID), reasonRejected)
+}
+
+// Read reads the next bytes of the stream, waiting for them to arrive.
+// Returns io.EOF once the stream is read to its end and passed its integrity check, or the failure of the stream.
+func (in *incoming) Read(p []byte) (int, error) {
+	in.l.Lock()
+	for len(in.chunks) == 0 && !in.ended && in.err == nil {
+		in.cond.Wait()
+	}
+	if in.err != nil {
+		in.l.Unlock()
+		return 0, in.err
+	}
+	if len(in.chunks) == 0 {
+		complete := in.completed()
+		in.l.Unlock()
+		if complete {
+			in.streamer.send(in.key.peer, Envelope{streamID: in.key.streamID, kind: kindComplete})
+		}
+		return 0, io.EOF
+	}
+
+	n := copy(p, in.chunks[0])
+	in.chunks[0] = in.chunks[0][n:]
+	var credits uint32
+	if len(in.chunks[0]) == 0 {
+		in.chunks = in.chunks[1:]
+		in.consumed++
+		// credits are granted in batches, but at the latest once every chunk in flight is read
+		if threshold := uint32(max(in.streamer.window/4, 1)); in.consumed >= threshold || len(in.chunks) == 0 {
+			credits, in.consumed = in.consumed, 0
+		}
+	}
+	ended := in.ended
+	if !ended {
+		// reading is progress too, as the sender may be waiting for credits
+		in.timer.Reset(in.streamer.timeout)
+	}
+	in.l.Unlock()
+
+	if credits > 0 && !ended {
+		in.streamer.send(in.key.peer, Envelope{streamID: in.key.streamID, kind: kindCredit, credits: credits})
+	}
+	return n, nil
+}
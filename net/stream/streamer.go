@@ -0,0 +1,393 @@
+// Package stream implements the transfer of payloads too large for a single message, e.g., the key ranges handed over
+// when a node joins, on top of a net.Network channel.
+//
+// A Streamer sends the bytes of an io.Reader as a stream of chunks of at most its chunk size, so that every frame stays
+// below the maximum frame size of the network. The receiving Streamer hands each incoming stream to its Processor as an
+// io.Reader as soon as it opens, while its chunks keep arriving. Flow control is credit-based: the sender has at most a
+// window of chunks in flight, and the receiver grants more as the processor reads them, so that a slow processor
+// pushes back on the sender rather than buffering the stream. The stream ends with its number of chunks, length and
+// SHA-256 digest, which the receiver checks before reporting the end of the stream to the processor, and the completion
+// of the stream to the sender.
+package stream
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net"
+)
+
+// DefaultChunkSize is the default maximum size of a chunk in bytes.
+const DefaultChunkSize = 64 << 10
+
+// MaxChunkSize is the largest chunk size, well below the maximum frame size of networks.
+const MaxChunkSize = 1 << 20
+
+// DefaultWindow is the default number of chunks a sender may have in flight.
+const DefaultWindow = 16
+
+// DefaultTimeout is the default time allowed for the other end of a stream to make progress.
+const DefaultTimeout = 30 * time.Second
+
+// DefaultMaxStreams is the default number of incoming streams open at once.
+const DefaultMaxStreams = 64
+
+// Processor processes the streams received on a channel.
+type Processor interface {
+	// ProcessIncomingStream processes a stream from the origin, which is aborted unless it is read to its end before
+	// the method returns. Read returns io.EOF once the stream is received in full and passes its integrity check,
+	// and an error wrapping ErrIntegrity otherwise; bytes read before the end must not be trusted until then.
+	ProcessIncomingStream(origin model.Identifier, r io.Reader)
+}
+
+// ProcessorFunc is a function implementing Processor.
+type ProcessorFunc func(origin model.Identifier, r io.Reader)
+
+// ProcessIncomingStream calls f.
+func (f ProcessorFunc) ProcessIncomingStream(origin model.Identifier, r io.Reader) {
+	f(origin, r)
+}
+
+// streamKey identifies a stream by the other end of it and its identifier, which is unique to its sender.
+type streamKey struct {
+	peer     model.Identifier
+	streamID uint64
+}
+
+// Streamer sends and receives streams on a channel of a network.
+// It is safe for concurrent use.
+type Streamer struct {
+	logger        zerolog.Logger
+	conduit       net.Conduit
+	processor     Processor
+	chunkSize     int
+	window        int
+	timeout       time.Duration
+	maxStreamSize uint64
+	maxStreams    int
+	nextID        atomic.Uint64
+
+	l        sync.Mutex
+	outgoing map[streamKey]*outgoing
+	incoming map[streamKey]*incoming
+}
+
+var _ net.MessageProcessor = (*Streamer)(nil)
+
+// Option is a functional option for configuring a Streamer.
+type Option func(*Streamer)
+
+// WithChunkSize sets the maximum size of the chunks sent and received, in bytes; it must not exceed MaxChunkSize, and
+// must be the same for both ends of a stream. Defaults to DefaultChunkSize.
+func WithChunkSize(size int) Option {
+	return func(s *Streamer) {
+		s.chunkSize = size
+	}
+}
+
+// WithWindow sets the number of chunks a sender may have in flight, which must be the same for both ends of a stream;
+// defaults to DefaultWindow.
+func WithWindow(chunks int) Option {
+	return func(s *Streamer) {
+		s.window = chunks
+	}
+}
+
+// WithTimeout sets the time allowed for the other end of a stream to make progress; defaults to DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Streamer) {
+		s.timeout = timeout
+	}
+}
+
+// WithMaxStreamSize sets the maximum length of the streams received, in bytes; unlimited by default.
+func WithMaxStreamSize(size uint64) Option {
+	return func(s *Streamer) {
+		s.maxStreamSize = size
+	}
+}
+
+// WithMaxStreams sets the number of incoming streams open at once, beyond which new streams are aborted; defaults to
+// DefaultMaxStreams.
+func WithMaxStreams(count int) Option {
+	return func(s *Streamer) {
+		s.maxStreams = count
+	}
+}
+
+// NewStreamer creates a Streamer and registers it as the processor of the channel.
+// The envelopes of the streamer must be registered in the codec registry of the network (see RegisterCodec).
+// Args:
+//   - logger: zerolog.Logger for logging
+//   - network: the network to send and receive streams through
+//   - channel: the channel of the streams; the streamer is its only processor
+//   - processor: the processor of incoming streams
+//   - opts: variadic options for configuring the streamer
+//
+// Returns the streamer, or an error wrapping ErrInvalidChunkSize if the chunk size is invalid, or the error of
+// registering it on the channel, which must be treated as fatal.
+func NewStreamer(
+	logger zerolog.Logger,
+	network net.Network,
+	channel net.Channel,
+	processor Processor,
+	opts ...Option,
+) (*Streamer, error) {
+	s := &Streamer{
+		logger: logger.With().
+			Str("component", "stream").
+			Str("channel", string(channel)).
+			Logger(),
+		processor:  processor,
+		chunkSize:  DefaultChunkSize,
+		window:     DefaultWindow,
+		timeout:    DefaultTimeout,
+		maxStreams: DefaultMaxStreams,
+		outgoing:   make(map[streamKey]*outgoing),
+		incoming:   make(map[streamKey]*incoming),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.chunkSize <= 0 || s.chunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("%w: %d bytes, must be in (0, %d]", ErrInvalidChunkSize, s.chunkSize, MaxChunkSize)
+	}
+	if s.window <= 0 {
+		s.window = 1
+	}
+
+	conduit, err := network.Register(channel, s)
+	if err != nil {
+		return nil, fmt.Errorf("could not register streamer: %w", err)
+	}
+	s.conduit = conduit
+	return s, nil
+}
+
+// outgoing is the state of a stream being sent, updated by the envelopes of its receiver.
+type outgoing struct {
+	l        sync.Mutex
+	credits  int
+	complete bool
+	err      error
+	notify   chan struct{} // signaled on every update
+}
+
+// update applies f to the stream under its lock, and notifies its sender.
+func (o *outgoing) update(f func()) {
+	o.l.Lock()
+	f()
+	o.l.Unlock()
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Send streams the bytes of r to the target, and waits for the target to receive them in full and intact.
+// Returns nil once the target has checked the integrity of the stream, or an error:
+//   - wrapping ErrStreamAborted if the target aborts the stream, e.g., as its processor did not read it all.
+//   - wrapping ErrStreamTimeout if the target does not make progress within the timeout.
+//   - the error of reading r, of ctx, or of sending to the target; the stream is then canceled.
+//
+// Any returned error is benign.
+func (s *Streamer) Send(ctx context.Context, target model.Identifier, r io.Reader) error {
+	key := streamKey{peer: target, streamID: s.nextID.Add(1)}
+	o := &outgoing{credits: s.window, notify: make(chan struct{}, 1)}
+	s.l.Lock()
+	s.outgoing[key] = o
+	s.l.Unlock()
+	defer func() {
+		s.l.Lock()
+		delete(s.outgoing, key)
+		s.l.Unlock()
+	}()
+
+	err := s.stream(ctx, key, o, r)
+	if err != nil && !errors.Is(err, ErrStreamAborted) {
+		s.send(target, Envelope{streamID: key.streamID, kind: kindCancel})
+	}
+	return err
+}
+
+// stream implements Send, without canceling the stream on failure.
+func (s *Streamer) stream(ctx context.Context, key streamKey, o *outgoing, r io.Reader) error {
+	if err := s.conduit.Send(key.peer, net.Message{Payload: Envelope{streamID: key.streamID, kind: kindOpen}}); err != nil {
+		return fmt.Errorf("could not open stream %d: %w", key.streamID, err)
+	}
+
+	hash := sha256.New()
+	var seq, length uint64
+	for {
+		chunk := make([]byte, s.chunkSize)
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			if err := s.await(ctx, key, o, func() bool { return o.credits > 0 }); err != nil {
+				return err
+			}
+			o.l.Lock()
+			o.credits--
+			o.l.Unlock()
+
+			e := Envelope{streamID: key.streamID, kind: kindData, seq: seq, data: chunk[:n]}
+			if err := s.conduit.Send(key.peer, net.Message{Payload: e}); err != nil {
+				return fmt.Errorf("could not send chunk %d of stream %d: %w", seq, key.streamID, err)
+			}
+			hash.Write(chunk[:n])
+			seq++
+			length += uint64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not read stream %d: %w", key.streamID, err)
+		}
+	}
+
+	e := Envelope{streamID: key.streamID, kind: kindEnd, seq: seq, length: length}
+	hash.Sum(e.digest[:0])
+	if err := s.conduit.Send(key.peer, net.Message{Payload: e}); err != nil {
+		return fmt.Errorf("could not end stream %d: %w", key.streamID, err)
+	}
+	return s.await(ctx, key, o, func() bool { return o.complete })
+}
+
+// await waits until ready holds for the outgoing stream, which is checked under its lock.
+// Returns an error if the stream is aborted, ctx is done, or the receiver does not make progress within the timeout.
+func (s *Streamer) await(ctx context.Context, key streamKey, o *outgoing, ready func() bool) error {
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("stream %d to %s: %w", key.streamID, key.peer.String(), err)
+		}
+		o.l.Lock()
+		err, ok := o.err, ready()
+		o.l.Unlock()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-o.notify:
+		case <-ctx.Done():
+			return fmt.Errorf("stream %d to %s: %w", key.streamID, key.peer.String(), ctx.Err())
+		case <-timer.C:
+			return fmt.Errorf("%w: stream %d to %s", ErrStreamTimeout, key.streamID, key.peer.String())
+		}
+	}
+}
+
+// send sends the envelope to the peer, logging a failure.
+func (s *Streamer) send(peer model.Identifier, e Envelope) {
+	if err := s.conduit.Send(peer, net.Message{Payload: e}); err != nil {
+		s.logger.Debug().
+			Err(err).
+			Str("peer", peer.String()).
+			Uint64("stream", e.streamID).
+			Msg("Could not send stream envelope")
+	}
+}
+
+// ProcessIncomingMessage applies the envelopes received on the channel of the streamer to their stream: chunks and
+// the end of incoming streams, and the credits, completion and abortion of outgoing streams. Messages other than
+// envelopes and envelopes of unknown streams, e.g., that were aborted, are logged and dropped.
+func (s *Streamer) ProcessIncomingMessage(_ net.Channel, originID model.Identifier, msg net.Message) {
+	e, ok := msg.Payload.(Envelope)
+	if !ok {
+		s.logger.Warn().
+			Str("origin", originID.String()).
+			Str("type", fmt.Sprintf("%T", msg.Payload)).
+			Msg("Dropping message that is not a stream envelope")
+		return
+	}
+	key := streamKey{peer: originID, streamID: e.streamID}
+
+	switch e.kind {
+	case kindOpen:
+		s.open(key)
+		return
+	case kindCredit, kindComplete, kindAbort:
+		s.l.Lock()
+		o, ok := s.outgoing[key]
+		s.l.Unlock()
+		if !ok {
+			break
+		}
+		o.update(
+			func() {
+				switch e.kind {
+				case kindCredit:
+					o.credits += int(e.credits)
+				case kindComplete:
+					o.complete = true
+				default:
+					o.err = fmt.Errorf("%w: stream %d %s", ErrStreamAborted, e.streamID, e.reason)
+				}
+			},
+		)
+		return
+	default:
+		s.l.Lock()
+		in, ok := s.incoming[key]
+		s.l.Unlock()
+		if !ok {
+			break
+		}
+		switch e.kind {
+		case kindData:
+			in.push(e.seq, e.data)
+		case kindEnd:
+			in.end(e.seq, e.length, e.digest)
+		default:
+			in.fail(fmt.Errorf("%w: stream %d canceled by sender", ErrStreamAborted, e.streamID), 0)
+		}
+		return
+	}
+
+	s.logger.Debug().
+		Str("origin", originID.String()).
+		Uint64("stream", e.streamID).
+		Msg("Dropping envelope of unknown stream")
+}
+
+// open starts processing a new incoming stream, unless too many are open already.
+func (s *Streamer) open(key streamKey) {
+	s.l.Lock()
+	if _, ok := s.incoming[key]; ok {
+		s.l.Unlock()
+		s.logger.Warn().Str("origin", key.peer.String()).Uint64("stream", key.streamID).Msg("Stream opened twice")
+		return
+	}
+	if len(s.incoming) >= s.maxStreams {
+		s.l.Unlock()
+		s.logger.Warn().Str("origin", key.peer.String()).Msg("Aborting stream, too many streams open")
+		s.send(key.peer, Envelope{streamID: key.streamID, kind: kindAbort, reason: reasonRejected})
+		return
+	}
+	in := newIncoming(s, key)
+	s.incoming[key] = in
+	s.l.Unlock()
+
+	go func() {
+		defer func() {
+			in.close()
+			s.l.Lock()
+			delete(s.incoming, key)
+			s.l.Unlock()
+		}()
+		s.processor.ProcessIncomingStream(key.peer, in)
+	}()
+}
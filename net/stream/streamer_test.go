@@ -0,0 +1,316 @@
+package stream_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/codec"
+	"github.com/thep2p/skipgraph-go/net/stream"
+	"github.com/thep2p/skipgraph-go/unittest"
+	"github.com/thep2p/skipgraph-go/unittest/mocknet"
+)
+
+// lossyNetwork is a network whose conduits drop the drop-th message they send, counting from 1; 0 drops nothing.
+type lossyNetwork struct {
+	net.Network
+	drop int64
+}
+
+func (n *lossyNetwork) Register(channel net.Channel, processor net.MessageProcessor) (net.Conduit, error) {
+	c, err := n.Network.Register(channel, processor)
+	if err != nil {
+		return nil, err
+	}
+	return &lossyConduit{conduit: c, drop: n.drop}, nil
+}
+
+// lossyConduit drops the drop-th message it sends.
+type lossyConduit struct {
+	conduit net.Conduit
+	drop    int64
+	sent    atomic.Int64
+}
+
+func (c *lossyConduit) Send(target model.Identifier, msg net.Message) error {
+	if c.sent.Add(1) == c.drop {
+		return nil
+	}
+	return c.conduit.Send(target, msg)
+}
+
+// node is a streamer along with its identifier.
+type node struct {
+	id       model.Identifier
+	streamer *stream.Streamer
+}
+
+// nodes creates a sender and a receiver connected through a mock network; the messages sent by the sender go through
+// network.
+func nodes(
+	t *testing.T,
+	network func(net.Network) net.Network,
+	processor stream.Processor,
+	senderOpts []stream.Option,
+	receiverOpts []stream.Option,
+) (*node, *node) {
+	registry := codec.NewDefaultRegistry()
+	require.NoError(t, stream.RegisterCodec(registry))
+	stub := mocknet.NewNetworkStubWithCodecs(registry)
+
+	sender := &node{id: unittest.IdentifierFixture(t)}
+	ignore := stream.ProcessorFunc(func(model.Identifier, io.Reader) {})
+	s, err := stream.NewStreamer(
+		unittest.Logger(zerolog.Disabled),
+		network(stub.NewMockNetwork(t, sender.id)),
+		net.TestChannel,
+		ignore,
+		senderOpts...,
+	)
+	require.NoError(t, err)
+	sender.streamer = s
+
+	receiver := &node{id: unittest.IdentifierFixture(t)}
+	r, err := stream.NewStreamer(
+		unittest.Logger(zerolog.Disabled),
+		stub.NewMockNetwork(t, receiver.id),
+		net.TestChannel,
+		processor,
+		receiverOpts...,
+	)
+	require.NoError(t, err)
+	receiver.streamer = r
+	return sender, receiver
+}
+
+// direct returns the network as is.
+func direct(n net.Network) net.Network {
+	return n
+}
+
+// result is the outcome of reading a stream.
+type result struct {
+	origin model.Identifier
+	data   []byte
+	err    error
+}
+
+// readingProcessor returns a processor reading every stream to its end, and forwarding the result to the returned
+// channel.
+func readingProcessor() (stream.Processor, <-chan result) {
+	ch := make(chan result, 10)
+	return stream.ProcessorFunc(
+		func(origin model.Identifier, r io.Reader) {
+			data, err := io.ReadAll(r)
+			ch <- result{origin: origin, data: data, err: err}
+		},
+	), ch
+}
+
+// mustResult waits for the next result on ch and returns it.
+func mustResult(t *testing.T, ch <-chan result) result {
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(time.Second):
+		require.Fail(t, "stream not read on time")
+		return result{}
+	}
+}
+
+// TestStreamer_Send tests that streams of any length, including several times the window, are received intact.
+func TestStreamer_Send(t *testing.T) {
+	processor, results := readingProcessor()
+	opts := []stream.Option{stream.WithChunkSize(1 << 10), stream.WithWindow(4)}
+	sender, receiver := nodes(t, direct, processor, opts, opts)
+
+	for _, size := range []int{0, 1, 1 << 10, 3<<20 + 7} {
+		data := unittest.RandomBytesFixture(t, size)
+		require.NoError(t, sender.streamer.Send(context.Background(), receiver.id, bytes.NewReader(data)))
+		r := mustResult(t, results)
+		require.NoError(t, r.err)
+		require.Equal(t, sender.id, r.origin)
+		require.True(t, bytes.Equal(data, r.data), "stream of %d bytes altered", size)
+	}
+}
+
+// TestStreamer_Concurrent tests that concurrent streams to the same receiver are kept apart.
+func TestStreamer_Concurrent(t *testing.T) {
+	processor, results := readingProcessor()
+	opts := []stream.Option{stream.WithChunkSize(512), stream.WithWindow(2)}
+	sender, receiver := nodes(t, direct, processor, opts, opts)
+
+	const count = 8
+	sent := make(map[string]bool)
+	wg := sync.WaitGroup{}
+	for i := 0; i < count; i++ {
+		data := unittest.RandomBytesFixture(t, 10_000+i)
+		sent[string(data)] = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, sender.streamer.Send(context.Background(), receiver.id, bytes.NewReader(data)))
+		}()
+	}
+	unittest.CallMustReturnWithinTimeout(t, wg.Wait, time.Second, "streams not sent on time")
+	for i := 0; i < count; i++ {
+		r := mustResult(t, results)
+		require.NoError(t, r.err)
+		require.True(t, sent[string(r.data)])
+		delete(sent, string(r.data))
+	}
+}
+
+// TestStreamer_Rejected tests that a stream its processor does not read to its end is aborted at the sender.
+func TestStreamer_Rejected(t *testing.T) {
+	processor := stream.ProcessorFunc(func(model.Identifier, io.Reader) {})
+	sender, receiver := nodes(t, direct, processor, nil, nil)
+
+	err := sender.streamer.Send(context.Background(), receiver.id, bytes.NewReader(unittest.RandomBytesFixture(t, 100)))
+	require.True(t, errors.Is(err, stream.ErrStreamAborted), "unexpected error %v", err)
+}
+
+// TestStreamer_TooLarge tests that streams exceeding the maximum stream size of the receiver fail at both ends.
+func TestStreamer_TooLarge(t *testing.T) {
+	processor, results := readingProcessor()
+	sender, receiver := nodes(
+		t, direct, processor,
+		[]stream.Option{stream.WithChunkSize(100)},
+		[]stream.Option{stream.WithChunkSize(100), stream.WithMaxStreamSize(1000)},
+	)
+
+	err := sender.streamer.Send(context.Background(), receiver.id, bytes.NewReader(unittest.RandomBytesFixture(t, 1001)))
+	require.True(t, errors.Is(err, stream.ErrStreamAborted), "unexpected error %v", err)
+	r := mustResult(t, results)
+	require.True(t, errors.Is(r.err, stream.ErrStreamTooLarge), "unexpected error %v", r.err)
+}
+
+// TestStreamer_Integrity tests that a stream missing a chunk fails its integrity check at both ends.
+func TestStreamer_Integrity(t *testing.T) {
+	processor, results := readingProcessor()
+	opts := []stream.Option{stream.WithChunkSize(100)}
+	// the third message is the second chunk, after the opening of the stream and the first chunk
+	lossy := func(n net.Network) net.Network {
+		return &lossyNetwork{Network: n, drop: 3}
+	}
+	sender, receiver := nodes(t, lossy, processor, opts, opts)
+
+	err := sender.streamer.Send(context.Background(), receiver.id, bytes.NewReader(unittest.RandomBytesFixture(t, 1000)))
+	require.True(t, errors.Is(err, stream.ErrStreamAborted), "unexpected error %v", err)
+	r := mustResult(t, results)
+	require.True(t, errors.Is(r.err, stream.ErrIntegrity), "unexpected error %v", r.err)
+}
+
+// TestStreamer_Timeout tests that a stream whose receiver stalls times out at the sender, and that a stream whose
+// sender stalls times out at the receiver.
+func TestStreamer_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	stalling := stream.ProcessorFunc(
+		func(model.Identifier, io.Reader) {
+			<-release
+		},
+	)
+	sender, receiver := nodes(
+		t, direct, stalling,
+		[]stream.Option{stream.WithChunkSize(10), stream.WithWindow(2), stream.WithTimeout(50 * time.Millisecond)},
+		[]stream.Option{stream.WithChunkSize(10), stream.WithWindow(2)},
+	)
+	err := sender.streamer.Send(context.Background(), receiver.id, bytes.NewReader(unittest.RandomBytesFixture(t, 100)))
+	require.True(t, errors.Is(err, stream.ErrStreamTimeout), "unexpected error %v", err)
+
+	processor, results := readingProcessor()
+	sender, receiver = nodes(
+		t, direct, processor,
+		nil,
+		[]stream.Option{stream.WithTimeout(50 * time.Millisecond)},
+	)
+	stalled, _ := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = sender.streamer.Send(ctx, receiver.id, stalled)
+	}()
+	r := mustResult(t, results)
+	require.True(t, errors.Is(r.err, stream.ErrStreamTimeout), "unexpected error %v", r.err)
+}
+
+// TestStreamer_Canceled tests that canceling the context of a stream aborts it at the receiver.
+func TestStreamer_Canceled(t *testing.T) {
+	processor, results := readingProcessor()
+	sender, receiver := nodes(t, direct, processor, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	sent := make(chan error, 1)
+	go func() {
+		sent <- sender.streamer.Send(ctx, receiver.id, pr)
+	}()
+	// the sender waits for the rest of the first chunk when the stream is canceled
+	_, err := pw.Write(unittest.RandomBytesFixture(t, 100))
+	require.NoError(t, err)
+	cancel()
+	require.NoError(t, pw.Close())
+
+	select {
+	case err := <-sent:
+		require.True(t, errors.Is(err, context.Canceled), "unexpected error %v", err)
+	case <-time.After(time.Second):
+		require.Fail(t, "send did not return on time")
+	}
+	r := mustResult(t, results)
+	require.True(t, errors.Is(r.err, stream.ErrStreamAborted), "unexpected error %v", r.err)
+}
+
+// TestNewStreamer_InvalidChunkSize tests that chunk sizes out of (0, MaxChunkSize] are rejected.
+func TestNewStreamer_InvalidChunkSize(t *testing.T) {
+	stub := mocknet.NewNetworkStub()
+	processor, _ := readingProcessor()
+	for _, size := range []int{0, -1, stream.MaxChunkSize + 1} {
+		_, err := stream.NewStreamer(
+			unittest.Logger(zerolog.Disabled),
+			stub.NewMockNetwork(t, unittest.IdentifierFixture(t)),
+			net.TestChannel,
+			processor,
+			stream.WithChunkSize(size),
+		)
+		require.True(t, errors.Is(err, stream.ErrInvalidChunkSize))
+	}
+}
+
+// TestEnvelope_Malformed tests that malformed envelopes are rejected by the codec registry.
+func TestEnvelope_Malformed(t *testing.T) {
+	registry := codec.NewDefaultRegistry()
+	require.NoError(t, stream.RegisterCodec(registry))
+
+	envelope := func(kind byte, body ...byte) []byte {
+		b := binary.BigEndian.AppendUint16(nil, uint16(stream.CodeEnvelope))
+		b = append(b, make([]byte, 8)...)
+		b = append(b, kind)
+		return append(b, body...)
+	}
+	malformed := [][]byte{
+		envelope(1)[:6],   // too short
+		envelope(9),       // unknown kind
+		envelope(1, 0),    // open with a body
+		envelope(2, 0, 0), // data without sequence number
+		envelope(3, 0),    // truncated end
+		envelope(5, 0, 0), // truncated credit
+		envelope(7),       // abort without reason
+		envelope(6, 1, 2), // complete with a body
+	}
+	for _, b := range malformed {
+		_, err := registry.Decode(b)
+		require.True(t, errors.Is(err, stream.ErrMalformedEnvelope), "unexpected error %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+package net
+
+// ProtocolVersion is a version of the wire protocol spoken between nodes, negotiated when they connect.
+// Nodes of different releases speak the highest version they both support, down to which engines adapt their messages.
+type ProtocolVersion uint16
+
+// CurrentProtocolVersion is the highest protocol version implemented by this release.
+const CurrentProtocolVersion ProtocolVersion = 1
@@ -23,6 +23,11 @@ func (n *SkipGraphNode) Identifier() model.Identifier {
 	return n.id.GetIdentifier()
 }
 
+// Key returns the key of the node, which is its identifier unless an explicit key is set on its identity.
+func (n *SkipGraphNode) Key() model.Key {
+	return n.id.GetKey()
+}
+
 func (n *SkipGraphNode) MembershipVector() model.MembershipVector {
 	return n.id.GetMembershipVector()
 }
@@ -47,74 +52,88 @@ func (n *SkipGraphNode) SetNeighbor(dir types.Direction, level types.Level, neig
 //
 // Returns error if lookup table access fails at any level.
 func (n *SkipGraphNode) SearchByID(req model.IdSearchReq) (model.IdSearchRes, error) {
-	// Step 1: Collect candidates from levels 0 to req.Level()
-	type candidate struct {
-		id    model.Identifier
-		level types.Level
+	best, level, err := bestCandidate(
+		n.lt, req.Target(), req.Level(), req.Direction(), model.Identity.GetIdentifier, func(a, b model.Identifier) int {
+			return a.Cmp(&b)
+		},
+	)
+	if err != nil {
+		return model.IdSearchRes{}, fmt.Errorf("error while searching by id %w", err)
+	}
+
+	// the best match among the neighbors, if any
+	if best != nil {
+		return model.NewIdSearchRes(req.Target(), level, best.GetIdentifier()), nil
 	}
 
-	// Pre-allocate capacity for worst case: one candidate per level from 0 to req.Level()
-	candidates := make([]candidate, 0, req.Level()+1)
+	// Fallback: return own identifier at level 0
+	return model.NewIdSearchRes(req.Target(), 0, n.Identifier()), nil
+}
+
+// SearchByKey searches for a key in the lookup table in the given direction up to the given level.
+// It follows the same algorithm as SearchByID, but positions nodes by their keys (see model.Identity.GetKey) rather than
+// their identifiers, and returns the identity of the best match, or the node's own identity at level 0 if no match found.
+//
+// Returns error if lookup table access fails at any level.
+func (n *SkipGraphNode) SearchByKey(req model.KeySearchReq) (model.KeySearchRes, error) {
+	best, level, err := bestCandidate(n.lt, req.Target(), req.Level(), req.Direction(), model.Identity.GetKey, model.CompareKeys)
+	if err != nil {
+		return model.KeySearchRes{}, fmt.Errorf("error while searching by key %w", err)
+	}
 
-	for level := types.Level(0); level <= req.Level(); level++ {
-		identity, err := n.lt.GetEntry(req.Direction(), level)
+	if best != nil {
+		return model.NewKeySearchRes(req.Target(), level, *best), nil
+	}
+
+	// Fallback: return own identity at level 0
+	return model.NewKeySearchRes(req.Target(), 0, n.id), nil
+}
+
+// bestCandidate returns the neighbor with the best key, as extracted by keyOf and ordered by cmp, with respect to
+// target among levels 0 to maxLevel in dir, along with the level it was found at.
+// It is generic over the key type so that searching by identifier compares identifiers without boxing them in a
+// model.Key interface, hence without allocation.
+// Candidates are filtered based on direction:
+//   - Left: smallest key >= target
+//   - Right: greatest key <= target
+//
+// Returns a nil identity if no neighbor qualifies, and an error if lookup table access fails at any level.
+func bestCandidate[K any](
+	lt core.ImmutableLookupTable,
+	target K,
+	maxLevel types.Level,
+	dir types.Direction,
+	keyOf func(model.Identity) K,
+	cmp func(a, b K) int,
+) (*model.Identity, types.Level, error) {
+	var best *model.Identity
+	var bestKey K
+	var bestLevel types.Level
+
+	for level := types.Level(0); level <= maxLevel; level++ {
+		identity, err := lt.GetEntry(dir, level)
 		if err != nil {
-			return model.IdSearchRes{}, fmt.Errorf("error while searching by id in level %d: %w", level, err)
+			return nil, 0, fmt.Errorf("in level %d: %w", level, err)
 		}
-		if identity != nil {
-			candidates = append(candidates, candidate{
-				id:    identity.GetIdentifier(),
-				level: level,
-			})
+		if identity == nil {
+			continue
 		}
-	}
 
-	// Step 2: Filter candidates based on direction
-	var bestCandidate *candidate
-
-	target := req.Target()
-
-	switch req.Direction() {
-	case types.DirectionLeft:
-		// Left: find smallest ID >= target
-		for i := range candidates {
-			c := &candidates[i]
-			cmp := c.id.Compare(&target)
-			if cmp.GetComparisonResult() == model.CompareGreater || cmp.GetComparisonResult() == model.CompareEqual {
-				if bestCandidate == nil {
-					bestCandidate = c
-				} else {
-					bestCmp := c.id.Compare(&bestCandidate.id)
-					if bestCmp.GetComparisonResult() == model.CompareLess {
-						bestCandidate = c
-					}
-				}
+		key := keyOf(*identity)
+		c := cmp(key, target)
+		switch dir {
+		case types.DirectionLeft:
+			// Left: find smallest key >= target
+			if c >= 0 && (best == nil || cmp(key, bestKey) < 0) {
+				best, bestKey, bestLevel = identity, key, level
 			}
-		}
-
-	case types.DirectionRight:
-		// Right: find greatest ID <= target
-		for i := range candidates {
-			c := &candidates[i]
-			cmp := c.id.Compare(&target)
-			if cmp.GetComparisonResult() == model.CompareLess || cmp.GetComparisonResult() == model.CompareEqual {
-				if bestCandidate == nil {
-					bestCandidate = c
-				} else {
-					bestCmp := c.id.Compare(&bestCandidate.id)
-					if bestCmp.GetComparisonResult() == model.CompareGreater {
-						bestCandidate = c
-					}
-				}
+		case types.DirectionRight:
+			// Right: find greatest key <= target
+			if c <= 0 && (best == nil || cmp(key, bestKey) > 0) {
+				best, bestKey, bestLevel = identity, key, level
 			}
 		}
 	}
 
-	// Step 3: Return result or fallback
-	if bestCandidate != nil {
-		return model.NewIdSearchRes(req.Target(), bestCandidate.level, bestCandidate.id), nil
-	}
-
-	// Fallback: return own identifier at level 0
-	return model.NewIdSearchRes(req.Target(), 0, n.Identifier()), nil
+	return best, bestLevel, nil
 }
@@ -554,3 +554,28 @@ func TestSearchByIDLevelExceedsMax(t *testing.T) {
 		)
 	}
 }
+
+// BenchmarkSearchByID benchmarks candidate selection over a full lookup table at the highest level.
+func BenchmarkSearchByID(b *testing.B) {
+	lt := &lookup.Table{}
+	for level := types.Level(0); level < core.MaxLookupTableLevel; level++ {
+		require.NoError(b, lt.AddEntry(types.DirectionLeft, level, unittest.IdentityFixture(b)))
+		require.NoError(b, lt.AddEntry(types.DirectionRight, level, unittest.IdentityFixture(b)))
+	}
+	node := NewSkipGraphNode(unittest.Logger(zerolog.ErrorLevel), unittest.IdentityFixture(b), lt)
+
+	for _, dir := range []types.Direction{types.DirectionLeft, types.DirectionRight} {
+		req, err := model.NewIdSearchReq(unittest.IdentifierFixture(b), core.MaxLookupTableLevel-1, dir)
+		require.NoError(b, err)
+		b.Run(
+			string(dir), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := node.SearchByID(req); err != nil {
+						b.Fatal(err)
+					}
+				}
+			},
+		)
+	}
+}
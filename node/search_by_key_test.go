@@ -0,0 +1,97 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core"
+	"github.com/thep2p/skipgraph-go/core/lookup"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+	"github.com/thep2p/skipgraph-go/unittest"
+)
+
+// keyedLookupTable generates a full lookup table whose neighbors are positioned by random variable-length keys.
+func keyedLookupTable(t *testing.T) *lookup.Table {
+	lt := &lookup.Table{}
+	for level := types.Level(0); level < core.MaxLookupTableLevel; level++ {
+		for _, dir := range []types.Direction{types.DirectionLeft, types.DirectionRight} {
+			identity := unittest.IdentityFixture(t)
+			identity.SetKey(unittest.BytesKeyFixture(t, 16))
+			require.NoError(t, lt.AddEntry(dir, level, identity))
+		}
+	}
+	return lt
+}
+
+// TestSearchByKey verifies correct candidate selection over variable-length keys in both directions,
+// i.e., smallest key >= target in the left direction and greatest key <= target in the right direction.
+func TestSearchByKey(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		identity := unittest.IdentityFixture(t)
+		identity.SetKey(unittest.BytesKeyFixture(t, 16))
+		lt := keyedLookupTable(t)
+		node := NewSkipGraphNode(unittest.Logger(zerolog.TraceLevel), identity, lt)
+
+		target := unittest.BytesKeyFixture(t, 16)
+		level := unittest.RandomLevelFixture(t)
+		dir := unittest.RandomDirectionFixture(t)
+
+		req, err := model.NewKeySearchReq(target, level, dir)
+		require.NoError(t, err)
+		res, err := node.SearchByKey(req)
+		require.NoError(t, err)
+		require.Equal(t, model.Key(target), res.Target())
+
+		// Manually compute the expected result
+		var expected *model.Identity
+		var expectedLevel types.Level
+		for l := types.Level(0); l <= level; l++ {
+			neighbor, err := lt.GetEntry(dir, l)
+			require.NoError(t, err)
+			cmp := model.CompareKeys(neighbor.GetKey(), target)
+			if dir == types.DirectionLeft && cmp >= 0 &&
+				(expected == nil || model.CompareKeys(neighbor.GetKey(), expected.GetKey()) < 0) {
+				expected, expectedLevel = neighbor, l
+			}
+			if dir == types.DirectionRight && cmp <= 0 &&
+				(expected == nil || model.CompareKeys(neighbor.GetKey(), expected.GetKey()) > 0) {
+				expected, expectedLevel = neighbor, l
+			}
+		}
+
+		if expected == nil {
+			// Fallback: own identity at level 0
+			require.Equal(t, types.Level(0), res.TerminationLevel())
+			require.Equal(t, identity, res.Result())
+			continue
+		}
+		require.Equal(t, expectedLevel, res.TerminationLevel())
+		require.Equal(t, *expected, res.Result())
+	}
+}
+
+// TestSearchByKeyExactMatch verifies that a neighbor holding exactly the target key is returned in both directions.
+func TestSearchByKeyExactMatch(t *testing.T) {
+	identity := unittest.IdentityFixture(t)
+	identity.SetKey(model.BytesKey("tenant-1/m"))
+	lt := &lookup.Table{}
+
+	target := model.BytesKey("tenant-1/path")
+	neighbor := unittest.IdentityFixture(t)
+	neighbor.SetKey(target)
+	for _, dir := range []types.Direction{types.DirectionLeft, types.DirectionRight} {
+		require.NoError(t, lt.AddEntry(dir, 3, neighbor))
+	}
+	node := NewSkipGraphNode(unittest.Logger(zerolog.TraceLevel), identity, lt)
+
+	for _, dir := range []types.Direction{types.DirectionLeft, types.DirectionRight} {
+		req, err := model.NewKeySearchReq(target, 5, dir)
+		require.NoError(t, err)
+		res, err := node.SearchByKey(req)
+		require.NoError(t, err)
+		require.Equal(t, types.Level(3), res.TerminationLevel())
+		require.Equal(t, neighbor, res.Result())
+	}
+}
@@ -1,9 +1,11 @@
 package unittest
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"github.com/stretchr/testify/require"
 	"github.com/thep2p/skipgraph-go/core"
+	"github.com/thep2p/skipgraph-go/core/crypto"
 	"github.com/thep2p/skipgraph-go/core/lookup"
 	"github.com/thep2p/skipgraph-go/core/model"
 	"github.com/thep2p/skipgraph-go/core/types"
@@ -68,9 +70,7 @@ func IdentifierFixture(t testing.TB, opts ...IdentifierFixtureOption) model.Iden
 
 	// Validate that minID < maxID if both are set
 	if config.minID != nil && config.maxID != nil {
-		comparison := config.minID.Compare(config.maxID)
-		require.NotEqual(t, model.CompareGreater, comparison.GetComparisonResult(), "minID must be less than maxID")
-		require.NotEqual(t, model.CompareEqual, comparison.GetComparisonResult(), "minID must be less than maxID (cannot be equal)")
+		require.True(t, config.minID.Less(config.maxID), "minID must be less than maxID")
 	}
 
 	// Default to full identifier space [0, 2^256 - 1]
@@ -151,6 +151,17 @@ func MembershipVectorFixture(t testing.TB) model.MembershipVector {
 	return mv
 }
 
+// BytesKeyFixture generates a random variable-length key whose length is uniformly chosen in [1, maxSize].
+func BytesKeyFixture(t testing.TB, maxSize int) model.BytesKey {
+	require.Greater(t, maxSize, 0, "maxSize must be greater than 0")
+	sizeBig, err := rand.Int(rand.Reader, big.NewInt(int64(maxSize)))
+	require.NoError(t, err, "failed to generate random key size")
+
+	key, err := model.NewBytesKey(RandomBytesFixture(t, int(sizeBig.Int64())+1))
+	require.NoError(t, err)
+	return key
+}
+
 // AddressFixture returns an Address on localhost with a random port number.
 func AddressFixture(t testing.TB) model.Address {
 	// pick a random port
@@ -163,6 +174,15 @@ func AddressFixture(t testing.TB) model.Address {
 
 }
 
+// IdentityKeyFixture generates a random ed25519 identity key along with the identifier bound to it.
+func IdentityKeyFixture(t testing.TB) (ed25519.PrivateKey, model.Identifier) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	id, err := crypto.IdentifierOfKey(key)
+	require.NoError(t, err)
+	return key, id
+}
+
 // IdentityFixture generates a random Identity with an address on localhost.
 func IdentityFixture(t testing.TB) model.Identity {
 	id := IdentifierFixture(t)
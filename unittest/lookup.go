@@ -25,15 +25,13 @@ func SmallestIdGreaterThanOrEqualTo(
 		neighbor, err := table.GetEntry(dir, l)
 		require.NoError(t, err)
 		neighborID := neighbor.GetIdentifier()
-		cmp := neighborID.Compare(&target)
-		if cmp.GetComparisonResult() == model.CompareGreater || cmp.GetComparisonResult() == model.CompareEqual {
+		if !neighborID.Less(&target) {
 			if !foundCandidate {
 				expectedID = neighborID
 				expectedLevel = l
 				foundCandidate = true
 			} else {
-				bestCmp := neighborID.Compare(&expectedID)
-				if bestCmp.GetComparisonResult() == model.CompareLess {
+				if neighborID.Less(&expectedID) {
 					expectedID = neighborID
 					expectedLevel = l
 				}
@@ -61,15 +59,13 @@ func GreatestIdLessThanOrEqualTo(
 		neighbor, err := table.GetEntry(dir, l)
 		require.NoError(t, err)
 		neighborID := neighbor.GetIdentifier()
-		cmp := neighborID.Compare(&target)
-		if cmp.GetComparisonResult() == model.CompareLess || cmp.GetComparisonResult() == model.CompareEqual {
+		if neighborID.Cmp(&target) <= 0 {
 			if !foundCandidate {
 				expectedID = neighborID
 				expectedLevel = l
 				foundCandidate = true
 			} else {
-				bestCmp := neighborID.Compare(&expectedID)
-				if bestCmp.GetComparisonResult() == model.CompareGreater {
+				if expectedID.Less(&neighborID) {
 					expectedID = neighborID
 					expectedLevel = l
 				}
@@ -1,18 +1,25 @@
 package mocknet
 
 import (
+	"fmt"
 	"github.com/thep2p/skipgraph-go/core/model"
 	"github.com/thep2p/skipgraph-go/net"
 )
 
 type MockConduit struct {
-	stub    *NetworkStub
-	channel net.Channel
-	id      model.Identifier
+	stub         *NetworkStub
+	channel      net.Channel
+	network      *MockNetwork
+	registration *registration
 }
 
+// Send routes the message to the processor of the channel at the target.
+// Returns an error if the channel of the conduit was unregistered since, or the message cannot be routed.
 func (m MockConduit) Send(targetId model.Identifier, message net.Message) error {
-	return m.stub.routeMessageTo(m.channel, m.id, message, targetId)
+	if !m.network.registered(m.channel, m.registration) {
+		return fmt.Errorf("channel %v was unregistered", m.channel)
+	}
+	return m.stub.routeMessageTo(m.channel, m.network.id, message, targetId)
 }
 
 var _ net.Conduit = (*MockConduit)(nil)
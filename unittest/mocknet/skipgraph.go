@@ -0,0 +1,64 @@
+package mocknet
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thep2p/skipgraph-go/core/lookup"
+	"github.com/thep2p/skipgraph-go/core/model"
+	"github.com/thep2p/skipgraph-go/core/types"
+	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/unittest"
+)
+
+// ReceivedMessage is a message delivered to a processor.
+type ReceivedMessage struct {
+	Channel net.Channel
+	Origin  model.Identifier
+	Msg     net.Message
+}
+
+// NewCollectingProcessor returns a processor forwarding every message it receives to the returned channel, which
+// buffers up to capacity messages.
+func NewCollectingProcessor(capacity int) (*MockMessageProcessor, <-chan ReceivedMessage) {
+	ch := make(chan ReceivedMessage, capacity)
+	return NewMockMessageProcessor(
+		func(channel net.Channel, originID model.Identifier, msg net.Message) {
+			ch <- ReceivedMessage{Channel: channel, Origin: originID, Msg: msg}
+		},
+	), ch
+}
+
+// SkipGraphNode is a node of a skip graph assembled by a test: an identifier, a lookup table linked by the test, and a
+// processor collecting the messages delivered to the node, for the components under test to be built upon.
+type SkipGraphNode struct {
+	ID        model.Identifier
+	Table     *lookup.Table
+	Processor *MockMessageProcessor // forwards the messages it receives to Received
+	Received  <-chan ReceivedMessage
+}
+
+// NewSkipGraphNodes creates count nodes with empty lookup tables, sorted by identifier in ascending order.
+func NewSkipGraphNodes(t *testing.T, count int) []*SkipGraphNode {
+	ids := make([]model.Identifier, count)
+	for i := range ids {
+		ids[i] = unittest.IdentifierFixture(t)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Less(&ids[j]) })
+
+	nodes := make([]*SkipGraphNode, count)
+	for i, id := range ids {
+		processor, received := NewCollectingProcessor(100)
+		nodes[i] = &SkipGraphNode{ID: id, Table: &lookup.Table{}, Processor: processor, Received: received}
+	}
+	return nodes
+}
+
+// LinkPair makes right the right neighbor of left, and left the left neighbor of right, at the level.
+func LinkPair(t *testing.T, level types.Level, left *SkipGraphNode, right *SkipGraphNode) {
+	rightIdentity := model.NewIdentity(right.ID, unittest.MembershipVectorFixture(t), unittest.AddressFixture(t))
+	leftIdentity := model.NewIdentity(left.ID, unittest.MembershipVectorFixture(t), unittest.AddressFixture(t))
+	require.NoError(t, left.Table.AddEntry(types.DirectionRight, level, rightIdentity))
+	require.NoError(t, right.Table.AddEntry(types.DirectionLeft, level, leftIdentity))
+}
@@ -5,23 +5,36 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/thep2p/skipgraph-go/core/model"
 	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/codec"
 	"sync"
 	"testing"
 )
 
 // NetworkStub acts as a router to connect a set of MockNetwork
 // it needs to be locked using its l field before being accessed
+// Payloads are encoded and decoded through its codec registry as a real network would, so that processors receive
+// decoded copies rather than the values sent.
 type NetworkStub struct {
 	l        sync.Mutex
 	networks map[model.Identifier]*MockNetwork
+	codecs   *codec.Registry
 }
 
-// NewNetworkStub creates an empty NetworkStub
+// NewNetworkStub creates an empty NetworkStub with the default codec registry.
 func NewNetworkStub() *NetworkStub {
-	return &NetworkStub{networks: make(map[model.Identifier]*MockNetwork)}
+	return NewNetworkStubWithCodecs(codec.NewDefaultRegistry())
+}
+
+// NewNetworkStubWithCodecs creates an empty NetworkStub encoding payloads through the given codec registry.
+func NewNetworkStubWithCodecs(codecs *codec.Registry) *NetworkStub {
+	return &NetworkStub{
+		networks: make(map[model.Identifier]*MockNetwork),
+		codecs:   codecs,
+	}
 }
 
 // NewMockNetwork creates and returns a mock network connected to this network stub for a non-existing Identifier.
+// Inbound messages are dispatched to processors asynchronously, through a dispatcher running until the test ends.
 func (n *NetworkStub) NewMockNetwork(t *testing.T, id model.Identifier) *MockNetwork {
 	n.l.Lock()
 	defer n.l.Unlock()
@@ -29,28 +42,40 @@ func (n *NetworkStub) NewMockNetwork(t *testing.T, id model.Identifier) *MockNet
 	_, exists := n.networks[id]
 	require.False(t, exists, "attempting to create mock network for already existing identifier")
 
-	u := newMockNetwork(id, n)
+	u := newMockNetwork(t, id, n)
 	n.networks[id] = u
 
 	return u
 }
 
 // routeMessageTo imitates routing the message in the underlying network to the target identifier's mock network.
+// Returns an error if the message cannot be encoded, or cannot be dispatched to the processor of its channel.
 func (n *NetworkStub) routeMessageTo(channel net.Channel, originId model.Identifier, msg net.Message, target model.Identifier) error {
-	n.l.Lock()
-	defer n.l.Unlock()
+	b, err := n.codecs.Encode(msg.Payload)
+	if err != nil {
+		return fmt.Errorf("could not encode payload: %w", err)
+	}
+	payload, err := n.codecs.Decode(b)
+	if err != nil {
+		return fmt.Errorf("could not decode payload: %w", err)
+	}
 
+	n.l.Lock()
 	u, exists := n.networks[target]
+	n.l.Unlock()
 	if !exists {
 		return fmt.Errorf("no mock network exists for %x", target)
 	}
 
-	h, exists := u.messageProcessors[channel]
+	h, exists := u.processor(channel)
 	if !exists {
 		return fmt.Errorf("no handler exists for channel %v", channel)
 	}
 
-	h.ProcessIncomingMessage(channel, originId, msg)
+	// dispatched outside the lock, as processors may send messages themselves
+	if err := u.dispatcher.Dispatch(channel, h, originId, net.Message{Payload: payload}); err != nil {
+		return fmt.Errorf("could not dispatch message: %w", err)
+	}
 
 	return nil
 }
@@ -1,10 +1,13 @@
 package mocknet_test
 
 import (
+	"errors"
 	"github.com/thep2p/skipgraph-go/core/model"
 	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/codec"
 	"github.com/thep2p/skipgraph-go/unittest"
 	"github.com/thep2p/skipgraph-go/unittest/mocknet"
+	"sync"
 	"testing"
 	"time"
 
@@ -38,12 +41,10 @@ func TestTwoNetworks(t *testing.T) {
 	)
 
 	// sets message handler at u1
-	received := false
-	var receivedPayload interface{}
+	received := make(chan net.Message, 1)
 	f := func(channel net.Channel, originId model.Identifier, msg net.Message) {
-		received = true
-		receivedPayload = msg.Payload
 		require.Equal(t, id2, originId)
+		received <- msg
 	}
 	_, err := u1.Register(net.TestChannel, mocknet.NewMockMessageProcessor(f))
 	require.NoError(t, err)
@@ -58,13 +59,16 @@ func TestTwoNetworks(t *testing.T) {
 	)
 	require.NoError(t, err)
 	msg := unittest.TestMessageFixture(t)
-	// TODO: refactor message as an interface
 	// TODO: add test for u1 -> u2
 	require.NoError(t, con2.Send(id1, *msg))
 
-	// the handler is called
-	require.True(t, received)
-	require.Equal(t, msg.Payload, receivedPayload)
+	// the handler is called, asynchronously
+	select {
+	case r := <-received:
+		require.Equal(t, msg.Payload, r.Payload)
+	case <-time.After(100 * time.Millisecond):
+		require.Fail(t, "message not received on time")
+	}
 
 	// stops network
 	unittest.ChannelsMustCloseWithinTimeout(
@@ -72,3 +76,152 @@ func TestTwoNetworks(t *testing.T) {
 		100*time.Millisecond, "could not stop network on time", u1.Done(), u2.Done(),
 	)
 }
+
+// TestUnregisteredPayload checks that a message whose payload type is not registered in the codec registry is rejected
+// without reaching the processor.
+func TestUnregisteredPayload(t *testing.T) {
+	stub := mocknet.NewNetworkStub()
+	id1 := unittest.IdentifierFixture(t)
+	id2 := unittest.IdentifierFixture(t)
+	u1 := stub.NewMockNetwork(t, id1)
+	u2 := stub.NewMockNetwork(t, id2)
+
+	_, err := u1.Register(
+		net.TestChannel, mocknet.NewMockMessageProcessor(
+			func(channel net.Channel, originID model.Identifier, msg net.Message) {
+				require.Fail(t, "unregistered payload must not be delivered")
+			},
+		),
+	)
+	require.NoError(t, err)
+	con2, err := u2.Register(
+		net.TestChannel, mocknet.NewMockMessageProcessor(
+			func(channel net.Channel, originID model.Identifier, msg net.Message) {},
+		),
+	)
+	require.NoError(t, err)
+
+	err = con2.Send(id1, net.Message{Payload: struct{ value int }{value: 1}})
+	require.True(t, errors.Is(err, codec.ErrUnknownType))
+}
+
+// TestReplyFromProcessor checks that a processor can send messages while processing one, as messages are dispatched
+// to processors outside the lock of the stub.
+func TestReplyFromProcessor(t *testing.T) {
+	stub := mocknet.NewNetworkStub()
+	id1 := unittest.IdentifierFixture(t)
+	id2 := unittest.IdentifierFixture(t)
+	u1 := stub.NewMockNetwork(t, id1)
+	u2 := stub.NewMockNetwork(t, id2)
+
+	var con1 net.Conduit
+	con1, err := u1.Register(
+		net.TestChannel, mocknet.NewMockMessageProcessor(
+			func(channel net.Channel, originID model.Identifier, msg net.Message) {
+				// echoes the message back to its origin
+				require.NoError(t, con1.Send(originID, msg))
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	echoed := make(chan net.Message, 1)
+	con2, err := u2.Register(
+		net.TestChannel, mocknet.NewMockMessageProcessor(
+			func(channel net.Channel, originID model.Identifier, msg net.Message) {
+				require.Equal(t, id1, originID)
+				echoed <- msg
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	msg := unittest.TestMessageFixture(t)
+	require.NoError(t, con2.Send(id1, *msg))
+	select {
+	case r := <-echoed:
+		require.Equal(t, msg.Payload, r.Payload)
+	case <-time.After(100 * time.Millisecond):
+		require.Fail(t, "message not echoed on time")
+	}
+	require.Eventually(
+		t, func() bool {
+			return u1.DispatchStats().Processed == 1
+		}, 100*time.Millisecond, time.Millisecond,
+	)
+}
+
+// TestPeerEvents checks that the peer events published on a mock network are delivered to its subscribers only.
+func TestPeerEvents(t *testing.T) {
+	stub := mocknet.NewNetworkStub()
+	u1 := stub.NewMockNetwork(t, unittest.IdentifierFixture(t))
+	u2 := stub.NewMockNetwork(t, unittest.IdentifierFixture(t))
+
+	received := make(chan net.PeerEvent, 2)
+	unsubscribe := u1.Subscribe(net.PeerEventConsumerFunc(func(e net.PeerEvent) { received <- e }))
+	u2.Subscribe(net.PeerEventConsumerFunc(func(e net.PeerEvent) { require.Fail(t, "event delivered to another network") }))
+
+	event := net.PeerEvent{Type: net.PeerDisconnected, Peer: unittest.IdentifierFixture(t)}
+	u1.PublishPeerEvent(event)
+	select {
+	case e := <-received:
+		require.Equal(t, event, e)
+	case <-time.After(100 * time.Millisecond):
+		require.Fail(t, "peer event not delivered on time")
+	}
+
+	unsubscribe()
+	u1.PublishPeerEvent(event)
+	select {
+	case <-received:
+		require.Fail(t, "peer event delivered after unsubscribing")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestRegisterUnregister checks that channels can be registered concurrently, enumerated and unregistered, and that
+// the conduit of an unregistered channel can no longer send.
+func TestRegisterUnregister(t *testing.T) {
+	stub := mocknet.NewNetworkStub()
+	id1, id2 := unittest.IdentifierFixture(t), unittest.IdentifierFixture(t)
+	u1 := stub.NewMockNetwork(t, id1)
+	u2 := stub.NewMockNetwork(t, id2)
+	noop := mocknet.NewMockMessageProcessor(func(net.Channel, model.Identifier, net.Message) {})
+
+	channels := []net.Channel{"channel-a", "channel-b", "channel-c", "channel-d"}
+	errs := make(chan error, 2*len(channels))
+	wg := sync.WaitGroup{}
+	for _, channel := range append(channels, channels...) {
+		wg.Add(1)
+		go func(channel net.Channel) {
+			defer wg.Done()
+			_, err := u1.Register(channel, noop)
+			errs <- err
+		}(channel)
+	}
+	wg.Wait()
+	close(errs)
+	failed := 0
+	for err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	// every channel is registered once
+	require.Equal(t, len(channels), failed)
+	require.Equal(t, channels, u1.Channels())
+
+	c2, err := u2.Register(net.TestChannel, noop)
+	require.NoError(t, err)
+	_, err = u1.Register(net.TestChannel, noop)
+	require.NoError(t, err)
+	require.NoError(t, c2.Send(id1, *unittest.TestMessageFixture(t)))
+
+	require.NoError(t, u1.Unregister(net.TestChannel))
+	require.Error(t, u1.Unregister(net.TestChannel))
+	require.Equal(t, channels, u1.Channels())
+	require.Error(t, c2.Send(id1, *unittest.TestMessageFixture(t)))
+
+	require.NoError(t, u2.Unregister(net.TestChannel))
+	require.Error(t, c2.Send(id1, *unittest.TestMessageFixture(t)))
+}
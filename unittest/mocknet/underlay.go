@@ -2,19 +2,27 @@ package mocknet
 
 import (
 	"fmt"
+	"github.com/rs/zerolog"
 	"github.com/thep2p/skipgraph-go/core/model"
 	"github.com/thep2p/skipgraph-go/modules"
 	"github.com/thep2p/skipgraph-go/net"
+	"github.com/thep2p/skipgraph-go/net/dispatch"
+	"github.com/thep2p/skipgraph-go/net/events"
+	"github.com/thep2p/skipgraph-go/unittest"
+	"slices"
 	"sync"
+	"testing"
 )
 
 // MockNetwork keeps data necessary for processing of incoming network messages in a mock network
 type MockNetwork struct {
 	l sync.Mutex
 	// there is only one handler per message type (but not per caller)
-	messageProcessors map[net.Channel]net.MessageProcessor
+	messageProcessors map[net.Channel]*registration
 	stub              *NetworkStub
-	id                model.Identifier // Identifier of the node this mock network belongs to
+	id                model.Identifier     // Identifier of the node this mock network belongs to
+	dispatcher        *dispatch.Dispatcher // dispatches inbound messages to their processor
+	peerEvents        *events.Distributor  // delivers the peer events published by the test
 }
 
 // Start is a no-op for the mock network.
@@ -36,27 +44,112 @@ func (m *MockNetwork) Done() <-chan interface{} {
 	return ch
 }
 
+// registration is the registration of a processor for a channel; the conduit of a registration sends as long as it is
+// the current registration of its channel.
+type registration struct {
+	processor net.MessageProcessor
+}
+
+// Register registers the processor for the channel.
+// Returns an error if a processor is already registered for the channel.
 func (m *MockNetwork) Register(channel net.Channel, processor net.MessageProcessor) (net.Conduit, error) {
+	m.l.Lock()
+	defer m.l.Unlock()
 	if _, exists := m.messageProcessors[channel]; exists {
 		return nil, fmt.Errorf("message processor for channel %v already exists", channel)
 	}
-	m.l.Lock()
-	m.messageProcessors[channel] = processor
-	m.l.Unlock()
+	reg := &registration{processor: processor}
+	m.messageProcessors[channel] = reg
 	return &MockConduit{
-		channel: channel,
-		stub:    m.stub,
-		id:      m.id,
+		channel:      channel,
+		stub:         m.stub,
+		network:      m,
+		registration: reg,
 	}, nil
 }
 
-// newMockNetwork initializes an empty MockNetwork and returns a pointer to it
-func newMockNetwork(id model.Identifier, stub *NetworkStub) *MockNetwork {
+// Unregister removes the processor of the channel; the conduit returned by its registration can no longer send, and
+// messages sent to the channel fail as for a channel that was never registered.
+// Returns an error if no processor is registered for the channel.
+func (m *MockNetwork) Unregister(channel net.Channel) error {
+	m.l.Lock()
+	defer m.l.Unlock()
+	if _, exists := m.messageProcessors[channel]; !exists {
+		return fmt.Errorf("no message processor registered for channel %v", channel)
+	}
+	delete(m.messageProcessors, channel)
+	return nil
+}
+
+// Channels returns the channels that have a processor registered, in lexicographic order.
+func (m *MockNetwork) Channels() []net.Channel {
+	m.l.Lock()
+	defer m.l.Unlock()
+	channels := make([]net.Channel, 0, len(m.messageProcessors))
+	for channel := range m.messageProcessors {
+		channels = append(channels, channel)
+	}
+	slices.Sort(channels)
+	return channels
+}
+
+// processor returns the processor registered for the channel, if any.
+func (m *MockNetwork) processor(channel net.Channel) (net.MessageProcessor, bool) {
+	m.l.Lock()
+	defer m.l.Unlock()
+	reg, exists := m.messageProcessors[channel]
+	if !exists {
+		return nil, false
+	}
+	return reg.processor, true
+}
+
+// registered returns true if reg is the current registration of the channel.
+func (m *MockNetwork) registered(channel net.Channel, reg *registration) bool {
+	m.l.Lock()
+	defer m.l.Unlock()
+	return m.messageProcessors[channel] == reg
+}
+
+// Subscribe registers a consumer of the peer events of the mock network, until the returned function is called or the
+// test ends. The mock network has no connections: the events are those published with PublishPeerEvent.
+func (m *MockNetwork) Subscribe(consumer net.PeerEventConsumer) (unsubscribe func()) {
+	return m.peerEvents.Subscribe(consumer)
+}
+
+// PublishPeerEvent delivers the event to the consumers subscribed to the mock network, imitating a change of
+// connection with a peer, e.g., to test failure detection.
+func (m *MockNetwork) PublishPeerEvent(event net.PeerEvent) {
+	m.peerEvents.Publish(event)
+}
+
+// newMockNetwork initializes an empty MockNetwork and returns a pointer to it.
+// Its dispatcher is started right away and stopped when the test ends, along with the delivery of peer events.
+func newMockNetwork(t *testing.T, id model.Identifier, stub *NetworkStub) *MockNetwork {
+	ctx := unittest.NewMockThrowableContext(t)
+	dispatcher := dispatch.NewDispatcher(unittest.Logger(zerolog.WarnLevel))
+	dispatcher.Start(ctx)
+	peerEvents := events.NewDistributor(unittest.Logger(zerolog.WarnLevel))
+	t.Cleanup(
+		func() {
+			ctx.Cancel()
+			unittest.RequireAllDone(t, dispatcher)
+			peerEvents.Close()
+		},
+	)
+
 	return &MockNetwork{
 		stub:              stub,
-		messageProcessors: make(map[net.Channel]net.MessageProcessor),
+		messageProcessors: make(map[net.Channel]*registration),
 		id:                id,
+		dispatcher:        dispatcher,
+		peerEvents:        peerEvents,
 	}
 }
 
+// DispatchStats returns a snapshot of the counters of the dispatcher of the mock network.
+func (m *MockNetwork) DispatchStats() dispatch.Stats {
+	return m.dispatcher.Stats()
+}
+
 var _ net.Network = (*MockNetwork)(nil)